@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Уровни уведомлений о запуске по возрастанию важности
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// severityRank порядок уровней уведомлений для сравнения с --notify-severity
+var severityRank = map[string]int{SeverityInfo: 0, SeverityWarning: 1, SeverityCritical: 2}
+
+// notifyTimeout ограничение времени отправки одного уведомления
+const notifyTimeout = 10 * time.Second
+
+// checkAlerts возвращает нарушенные пороги оповещения: больше --alert-delete-failures
+// неудачных удалений или, с --alert-repository-failure, репозитории, очистка которых
+// не удалась целиком
+func checkAlerts(cfg *Config, s *cleanupStats) []string {
+	var alerts []string
+	if cfg.AlertDeleteFailures >= 0 && s.DeleteFailed > cfg.AlertDeleteFailures {
+		alerts = append(alerts, fmt.Sprintf("не удалось удалить %d образов, допустимо не более %d", s.DeleteFailed, cfg.AlertDeleteFailures))
+	}
+	if cfg.AlertRepositoryFailure && s.Failed > 0 {
+		alerts = append(alerts, fmt.Sprintf("не удалось составить план для %d репозиториев", s.Failed))
+	}
+	if cfg.AlertRepositoryFailure && s.DeleteFailedRepositories > 0 {
+		alerts = append(alerts, fmt.Sprintf("в %d репозиториях не удалось удалить ни одного образа", s.DeleteFailedRepositories))
+	}
+	return alerts
+}
+
+// runSeverity возвращает уровень уведомления о запуске: critical при нарушенных порогах
+// оповещения, warning при ошибках или прерывании, иначе info
+func runSeverity(s *cleanupStats) string {
+	switch {
+	case len(s.Alerts) > 0:
+		return SeverityCritical
+	case s.Failures > 0 || s.Interrupted:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+// runNotification уведомление о запуске, которое отправляется на --notify-webhook
+type runNotification struct {
+	Severity string        `json:"severity"`
+	Registry string        `json:"registry"`
+	Alerts   []string      `json:"alerts,omitempty"`
+	Summary  *cleanupStats `json:"summary"`
+}
+
+// notify отправляет итоги запуска на каждый --notify-webhook, если их уровень не ниже
+// --notify-severity. Ошибки отправки только выводятся
+func notify(cfg *Config, s *cleanupStats) {
+	severity := runSeverity(s)
+	if len(cfg.NotifyWebhooks) == 0 || severityRank[severity] < severityRank[cfg.NotifySeverity] {
+		return
+	}
+
+	body, err := json.Marshal(runNotification{Severity: severity, Registry: urlHost(cfg.RegistryURL), Alerts: s.Alerts, Summary: s})
+	if err != nil {
+		fmt.Printf("Предупреждение: не удалось подготовить уведомление: %v\n", err)
+		return
+	}
+	client := &http.Client{Timeout: notifyTimeout}
+	for _, webhook := range cfg.NotifyWebhooks {
+		if err := postNotification(client, webhook, body); err != nil {
+			fmt.Printf("Предупреждение: не удалось отправить уведомление: %v\n", err)
+		}
+	}
+}
+
+// postNotification отправляет уведомление в формате JSON. Контекст запуска может быть
+// уже отменен по --timeout, поэтому уведомление ограничено только notifyTimeout
+func postNotification(client *http.Client, webhook string, body []byte) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("некорректный адрес --notify-webhook")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// Адрес webhook часто содержит секретный токен и не выводится целиком
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("ошибка запроса к %s: %v", urlHost(webhook), err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("получен статус %d от %s", resp.StatusCode, urlHost(webhook))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"registryCleaner/pkg/acr"
+	"registryCleaner/pkg/artifactory"
+	"registryCleaner/pkg/artifactregistry"
+	"registryCleaner/pkg/dockerhub"
+	"registryCleaner/pkg/ecr"
+	"registryCleaner/pkg/gitlab"
+	"registryCleaner/pkg/harbor"
+	"registryCleaner/pkg/nexus"
+	"registryCleaner/pkg/quay"
+	"registryCleaner/pkg/registry"
+)
+
+// Допустимые значения --backend
+const (
+	BackendRegistry    = "registry"
+	BackendHarbor      = "harbor"
+	BackendGitLab      = "gitlab"
+	BackendECR         = "ecr"
+	BackendGAR         = "gar"
+	BackendACR         = "acr"
+	BackendQuay        = "quay"
+	BackendNexus       = "nexus"
+	BackendArtifactory = "artifactory"
+	BackendDockerHub   = "dockerhub"
+)
+
+// buildBackend создает backend, заданный в конфигурации. Архивация, выгрузка
+// и блокировки всегда используют Docker Registry HTTP API V2 клиента client.
+// HTTP-клиенты backend, кроме ecr и gar, использующих SDK облаков, получают
+// транспорт с параметрами соединений из конфигурации
+func buildBackend(ctx context.Context, cfg *Config, client *registry.Client) (registry.Backend, error) {
+	switch cfg.Backend {
+	case BackendHarbor:
+		backend := harbor.NewClient(cfg.RegistryURL, cfg.Username, cfg.Password)
+		backend.Client.Transport = newTransport(cfg)
+		return backend, nil
+	case BackendGitLab:
+		backend := gitlab.NewClient(cfg.RegistryURL, cfg.GitLabProject, cfg.GitLabGroup, cfg.GitLabToken, os.Getenv("CI_JOB_TOKEN"))
+		backend.Client.Transport = newTransport(cfg)
+		return backend, nil
+	case BackendECR:
+		return ecr.NewClient(ctx, cfg.RegistryURL)
+	case BackendGAR:
+		return artifactregistry.NewClient(ctx, cfg.GARProject, cfg.GARLocation)
+	case BackendACR:
+		return newACRClient(ctx, cfg)
+	case BackendQuay:
+		backend := quay.NewClient(cfg.RegistryURL, cfg.QuayNamespaces, cfg.QuayToken, cfg.QuayExpire)
+		backend.Client.Transport = newTransport(cfg)
+		return backend, nil
+	case BackendNexus:
+		backend := nexus.NewClient(cfg.RegistryURL, cfg.Username, cfg.Password)
+		backend.Client.Transport = newTransport(cfg)
+		return backend, nil
+	case BackendArtifactory:
+		backend := artifactory.NewClient(cfg.RegistryURL, cfg.Username, cfg.Password, cfg.ArtifactoryToken)
+		backend.Client.Transport = newTransport(cfg)
+		return backend, nil
+	case BackendDockerHub:
+		backend := dockerhub.NewClient(cfg.HubURL, cfg.Username, cfg.Password, cfg.HubNamespaces)
+		backend.Client.Transport = newTransport(cfg)
+		return backend, nil
+	default:
+		return client, nil
+	}
+}
+
+// newACRClient создает клиент ACR. Учетные данные берутся из --username и --password,
+// затем из переменных сервисного принципала AZURE_CLIENT_ID и AZURE_CLIENT_SECRET,
+// а если они не заданы - из входа в Azure CLI
+func newACRClient(ctx context.Context, cfg *Config) (registry.Backend, error) {
+	username, password := cfg.Username, cfg.Password
+	if username == "" || password == "" {
+		username, password = os.Getenv("AZURE_CLIENT_ID"), os.Getenv("AZURE_CLIENT_SECRET")
+	}
+	if username == "" || password == "" {
+		token, err := acr.AzureCLIToken(ctx, cfg.RegistryURL)
+		if err != nil {
+			return nil, err
+		}
+		username, password = acr.TokenUsername, token
+	}
+	backend := acr.NewClient(cfg.RegistryURL, username, password)
+	backend.Client.Transport = newTransport(cfg)
+	return backend, nil
+}
@@ -0,0 +1,860 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"registryCleaner/pkg/cleanup"
+	"registryCleaner/pkg/dockerhub"
+	"registryCleaner/pkg/gc"
+	"registryCleaner/pkg/registry"
+	"registryCleaner/pkg/sentry"
+)
+
+// Config параметры запуска очистки
+type Config struct {
+	RegistryURL string
+	Username    string
+	Password    string
+	// Backend API, через которое выполняется очистка: registry, harbor, gitlab, ecr, gar, acr, quay, nexus, artifactory или dockerhub
+	Backend       string
+	GitLabProject string
+	GitLabGroup   string
+	GitLabToken   string
+	GARProject    string
+	GARLocation   string
+	// Пространства имен Quay, токен API и срок действия, устанавливаемый тегам вместо удаления
+	QuayNamespaces stringList
+	QuayToken      string
+	QuayExpire     time.Duration
+	// Запустить задачи сжатия blob store Nexus после очистки
+	NexusCompact bool
+	// Токен доступа Artifactory, используется вместо имени пользователя и пароля
+	ArtifactoryToken string
+	// Адрес API Docker Hub и пространства имен, репозитории которых очищаются
+	HubURL        string
+	HubNamespaces stringList
+	// Репозитории, которые очищаются; пустой список - все репозитории
+	Repositories stringList
+	// Файл со списком репозиториев вместо _catalog, "-" - stdin
+	ReposFile string
+	// Размер страницы каталога и часть каталога, которую обрабатывает запуск: диапазон
+	// имен <после>..<до> и префикс
+	CatalogPageSize int
+	CatalogRange    string
+	CatalogPrefix   string
+	// Файл неизменяемых тегов <репозиторий>:<тег>, которые никогда не удаляются
+	ProtectedFile string
+	// Файл команд: пространства имен, политики, неизменяемые теги и уведомления каждой команды
+	TeamsFile string
+	// Адрес и токен внешнего списка удержания: образы из него не удаляются
+	HoldURL   string
+	HoldToken string
+	// Доверенные ключи и удостоверения cosign: подписанные ими образы не удаляются, кроме
+	// тегов <репозиторий>:<тег> из DeleteSigned
+	TrustedKeys       stringList
+	TrustedIdentities stringList
+	CosignCommand     string
+	DeleteSigned      stringList
+	// Файл, в который подкоманда plan сохраняет план, а очистка с JiraURL - план,
+	// ожидающий согласования
+	PlanOut string
+	// Количество слоев в каждом списке отчета analyze-layers
+	LayersTop int
+	// Не проверять перед удалением доступность registry и поддержку удаления
+	SkipPreflight bool
+	// Доверять заголовку Docker-Content-Digest без сверки с содержимым манифеста
+	SkipDigestCheck bool
+	// Удалять тег, если registry отклоняет удаление по digest
+	DeleteTagFallback bool
+	// Как определять время создания образа с несколькими платформами: newest, oldest,
+	// annotation или платформа os/architecture[/variant]
+	IndexCreated string
+	// Очищать только образы платформы os/architecture[/variant]
+	Platform string
+	// Удалять подписи cosign, оставшиеся от удаленных образов
+	SweepSignatures bool
+	// Проверять после удаления, что registry больше не отдает манифесты, и через сколько
+	VerifyDeletes     bool
+	VerifyDeleteDelay time.Duration
+	// Подкоманда preflight выводит результат в формате JSON
+	JSONOutput bool
+	// Args аргументы подкоманды после флагов, например файл плана для apply
+	Args []string
+	// Пространства имен, репозитории которых не очищаются
+	ExcludeNamespaces stringList
+	// Порядок обработки репозиториев: catalog или largest
+	Order       string
+	KeepLast    int
+	Concurrency int
+	// Общее ограничение времени работы, 0 - без ограничения
+	Timeout time.Duration
+	// Порядок образов для политики хранения: created или build-number
+	Sort string
+	// Порядок образов с одинаковым временем создания: tag, tag-reverse или digest
+	TieBreak string
+	// Действие, если время создания образа получить не удалось
+	CreatedFallback string
+	// Формат времени в именах тегов и выражение, выделяющее его из тега
+	TagTimeLayout  string
+	TagTimePattern string
+	// Часовой пояс вывода времени, отсчета суток возраста и времени в тегах
+	Timezone string
+	// Файл постоянного кэша метаданных образов
+	CacheFile string
+	// Файл кэша конфигураций образов по digest
+	ConfigCacheFile string
+	// Файл кэша ETag списков тегов и манифестов для условных запросов
+	ETagCacheFile string
+	// Параметры повторного использования HTTP-соединений
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	KeepAlive           time.Duration
+	// Использовать HTTP/1.1 вместо HTTP/2
+	HTTP1Only bool
+	// User-Agent запросов и дополнительные заголовки "Имя: значение"
+	UserAgent string
+	Headers   stringList
+	// Выводить каждый HTTP-запрос, а с DebugHTTPHeaders и его заголовки
+	DebugHTTP        bool
+	DebugHTTPHeaders bool
+	// Адрес сервера net/http/pprof, профиль (cpu или mem), записываемый при выходе, и его файл
+	PprofAddr   string
+	Profile     string
+	ProfileFile string
+	// Файл состояния между запусками и режим инкрементальной очистки
+	StateFile   string
+	Incremental string
+	// Начать заново, не продолжая прерванный запуск
+	Restart bool
+	// Очищать только репозитории, записанные в --state-file, и брать под управление новые
+	Managed bool
+	Adopt   bool
+
+	// Архивный registry, в который копируются образы перед удалением
+	ArchiveURL      string
+	ArchiveUsername string
+	ArchivePassword string
+	ArchivePrefix   string
+
+	// Карантин вместо удаления: repository или tag, префикс репозиториев карантина и
+	// возраст, после которого подкоманда purge-quarantine удаляет образы из карантина
+	Quarantine          string
+	QuarantinePrefix    string
+	QuarantineOlderThan string
+
+	// Файл отметок двухфазного удаления и срок, после которого отмеченные образы удаляются
+	SoftDeleteFile string
+	GracePeriod    string
+
+	// Каталог для выгрузки удаляемых образов в формате OCI image layout
+	ExportDir string
+
+	// Каталог архива SBOM удаляемых образов, создание недостающих SBOM программой syft
+	SBOMDir      string
+	SBOMGenerate bool
+	SyftCommand  string
+
+	// Пороги, при превышении которых очистка прерывается без --force
+	MaxDeletePercent float64
+	MaxDeleteCount   int
+	Force            bool
+	// Jira: при превышении порогов план сохраняется в PlanOut и открывается задача на его
+	// согласование вместо удаления
+	JiraURL       string
+	JiraProject   string
+	JiraUser      string
+	JiraToken     string
+	JiraIssueType string
+
+	// Ограничение частоты удалений, 0 - без ограничения
+	MaxDeletesPerMinute int
+
+	// Файл, в который записываются итоги запуска в формате JSON
+	SummaryFile string
+
+	// Каталог textfile collector node_exporter, в который записываются метрики запуска
+	TextfileDir string
+
+	// Файл, в который записываются итоги репозиториев в формате JUnit XML
+	JUnitOut string
+
+	// Пороги оповещения: неудачных удалений больше AlertDeleteFailures (-1 - не
+	// проверять) и репозитории, очистка которых не удалась целиком
+	AlertDeleteFailures    int
+	AlertRepositoryFailure bool
+	// Адреса, на которые отправляются итоги запуска, и минимальный уровень уведомления
+	NotifyWebhooks stringList
+	NotifySeverity string
+	// Системы дежурств: инцидент открывается, если очистка не выполнена или нарушены
+	// пороги оповещения, и закрывается следующим успешным запуском
+	PagerDutyRoutingKey string
+	PagerDutyURL        string
+	OpsgenieAPIKey      string
+	OpsgenieURL         string
+	// DSN проекта Sentry для паник и ошибок запуска и окружение событий
+	SentryDSN         string
+	SentryEnvironment string
+	// Журнал, в который дублируется вывод: syslog или journald, адрес syslog и тег
+	LogSink    string
+	SyslogAddr string
+	SyslogTag  string
+
+	// Прервать очистку при первой ошибке вместо сбора ошибок в итоговую сводку
+	FailFast bool
+
+	// Запрашивать подтверждение перед очисткой каждого репозитория
+	Interactive bool
+
+	// Выполнять план каждого репозитория сразу после составления, не храня планы
+	// всего registry в памяти
+	Stream bool
+
+	// Регулярное выражение тегов, группы захвата которого задают группу, и количество
+	// сохраняемых образов в каждой группе
+	KeepPattern  string
+	KeepPerGroup int
+	// Классы тегов по префиксу вида <префикс>=<количество сохраняемых образов>
+	KeepPrefixes stringList
+
+	// Условия <ключ>[=<значение>] на аннотации манифеста и метки: такие образы не удаляются
+	KeepAnnotations stringList
+	// Метка конфигурации образа со сроком хранения, как quay.expires-after
+	ExpiresLabel string
+
+	// Сканер образов, программы Trivy и Grype, сервер Trivy, адрес и токен HTTP-сканера,
+	// ограничение времени сканирования одного образа и сканирование всех образов для
+	// остальных политик
+	VulnScanner  string
+	TrivyCommand string
+	TrivyServer  string
+	GrypeCommand string
+	ScannerURL   string
+	ScannerToken string
+	ScanTimeout  time.Duration
+	ScanAll      bool
+	// Правило удаления уязвимых образов <уровень>[:<возраст>] и уровень, без уязвимостей
+	// которого сохраняется новейший образ
+	DeleteVulnerable string
+	KeepClean        string
+
+	// Git-репозиторий, ветки и выражение тегов с SHA коммита: образы коммитов,
+	// которых нет в ветках, удаляются
+	GitRepo       string
+	GitBranches   stringList
+	GitTagPattern string
+
+	// Удалять только образы, которые не скачивались указанное количество дней
+	UnpulledDays int
+
+	// Фильтры в формате acr purge: <репозиторий>:<тег> и минимальный возраст удаляемых образов
+	PurgeFilters stringList
+	PurgeAgo     string
+
+	// CEL-выражение, которое должно быть истинно для удаляемых образов
+	PolicyCEL string
+
+	// Rego-политика OPA и запрос, возвращающий теги для удаления
+	PolicyRego      string
+	PolicyRegoQuery string
+
+	// Внешняя программа, принимающая решение по каждому кандидату на удаление
+	// PolicyExec программа внешней политики и ее аргументы без разбора shell
+	PolicyExec        stringList
+	PolicyExecTimeout time.Duration
+	// WASI-модуль, принимающий решение по каждому кандидату на удаление
+	PolicyWasm string
+
+	// Контейнер registry, сервер SSH или под Kubernetes, где после очистки запускается
+	// garbage collection, и шаблон команды, либо хранилище, в котором blob удаляются напрямую
+	GCContainer      string
+	DockerHost       string
+	GCSSH            string
+	GCSSHKey         string
+	GCSSHKnownHosts  string
+	GCK8sSelector    string
+	GCK8sNamespace   string
+	GCK8sContainer   string
+	Kubeconfig       string
+	GCCommand        string
+	GCFSRoot         string
+	GCS3Bucket       string
+	GCAzureAccount   string
+	GCAzureKey       string
+	GCAzureContainer string
+	GCGCSBucket      string
+	// Корень и адрес объектного хранилища registry
+	GCStorageRoot     string
+	GCStorageEndpoint string
+	GCDryRun          bool
+	// Программы и их аргументы без разбора shell, переводящие registry в режим только
+	// для чтения на время garbage collection и обратно
+	GCReadOnlyEnable  stringList
+	GCReadOnlyDisable stringList
+
+	// Блокировки от одновременного запуска нескольких экземпляров
+	LockFile string
+	LockTag  string
+	LockURL  string
+	LockTTL  time.Duration
+
+	// Адрес HTTP API, адрес gRPC API и токены доступа к ним в режиме serve:
+	// APIToken дает право запускать очистку, APIViewerToken - только просматривать планы и запуски
+	Listen         string
+	GRPCListen     string
+	APIToken       string
+	APIViewerToken string
+
+	// Выбор лидера среди реплик serve через Lease Kubernetes
+	LeaderElect          bool
+	LeaderElectLease     string
+	LeaderElectNamespace string
+}
+
+// stringList значение флага, который можно указать несколько раз
+type stringList []string
+
+// String возвращает значения через запятую
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set добавляет очередное значение флага
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// envOrDefault возвращает значение переменной окружения или значение по умолчанию
+func envOrDefault(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// parseConfig разбирает флаги командной строки, значения по умолчанию берутся из переменных окружения
+func parseConfig(args []string) *Config {
+	cfg := &Config{KeepLast: 2}
+	fs := newFlagSet(cfg)
+	fs.Parse(args)
+	cfg.Args = fs.Args()
+	// Переменная окружения задает только путь к программе: аргументы без разбора shell
+	// передаются повторными флагами
+	if len(cfg.PolicyExec) == 0 && os.Getenv("POLICY_EXEC") != "" {
+		cfg.PolicyExec = stringList{os.Getenv("POLICY_EXEC")}
+	}
+	if len(cfg.GCReadOnlyEnable) == 0 && os.Getenv("GC_READONLY_ENABLE") != "" {
+		cfg.GCReadOnlyEnable = stringList{os.Getenv("GC_READONLY_ENABLE")}
+	}
+	if len(cfg.GCReadOnlyDisable) == 0 && os.Getenv("GC_READONLY_DISABLE") != "" {
+		cfg.GCReadOnlyDisable = stringList{os.Getenv("GC_READONLY_DISABLE")}
+	}
+	return cfg
+}
+
+// newFlagSet описывает флаги командной строки, заполняющие cfg
+func newFlagSet(cfg *Config) *flag.FlagSet {
+	fs := flag.NewFlagSet("registry-cleaner", flag.ExitOnError)
+	fs.StringVar(&cfg.RegistryURL, "registry-url", envOrDefault("REGISTRY_URL", "http://localhost:5000"), "URL Docker Registry")
+	fs.StringVar(&cfg.Username, "username", os.Getenv("REGISTRY_USERNAME"), "имя пользователя Registry")
+	fs.StringVar(&cfg.Password, "password", os.Getenv("REGISTRY_PASSWORD"), "пароль Registry")
+	fs.StringVar(&cfg.Backend, "backend", envOrDefault("REGISTRY_BACKEND", BackendRegistry), "API для очистки: registry - Docker Registry HTTP API V2, harbor - Harbor v2 API, gitlab - API Container Registry GitLab, ecr - API Amazon ECR, gar - API Google Artifact Registry, acr - REST API Azure Container Registry, quay - API Quay, nexus - REST API Nexus Repository 3, artifactory - AQL и REST API JFrog Artifactory, dockerhub - API Docker Hub")
+	fs.StringVar(&cfg.GitLabProject, "gitlab-project", os.Getenv("GITLAB_PROJECT"), "ID или путь проекта GitLab, репозитории которого очищаются")
+	fs.StringVar(&cfg.GitLabGroup, "gitlab-group", os.Getenv("GITLAB_GROUP"), "ID или путь группы GitLab, репозитории всех проектов которой очищаются")
+	fs.StringVar(&cfg.GitLabToken, "gitlab-token", os.Getenv("GITLAB_TOKEN"), "токен доступа GitLab с правами api; без него используется CI_JOB_TOKEN")
+	fs.StringVar(&cfg.GARProject, "gar-project", os.Getenv("GOOGLE_CLOUD_PROJECT"), "проект Google Cloud, Docker-репозитории Artifact Registry которого очищаются")
+	fs.StringVar(&cfg.GARLocation, "gar-location", os.Getenv("GAR_LOCATION"), "регион репозиториев Artifact Registry, например europe-west1 (по умолчанию все регионы)")
+	fs.Var(&cfg.QuayNamespaces, "quay-namespace", "пространство имен (организация или пользователь) Quay, репозитории которого очищаются; можно указать несколько раз")
+	fs.StringVar(&cfg.QuayToken, "quay-token", os.Getenv("QUAY_TOKEN"), "OAuth-токен приложения Quay с правами на чтение и запись репозиториев")
+	fs.DurationVar(&cfg.QuayExpire, "quay-expire", 0, "вместо удаления устанавливать тегам Quay срок действия, например 168h (0 - удалять сразу)")
+	fs.BoolVar(&cfg.NexusCompact, "nexus-compact", false, "после очистки запустить задачи Nexus \"Admin - Compact blob store\"")
+	fs.StringVar(&cfg.ArtifactoryToken, "artifactory-token", os.Getenv("ARTIFACTORY_TOKEN"), "токен доступа Artifactory; без него используются --username и --password")
+	fs.StringVar(&cfg.HubURL, "hub-url", envOrDefault("DOCKER_HUB_URL", dockerhub.DefaultBaseURL), "адрес API Docker Hub")
+	fs.Var(&cfg.HubNamespaces, "hub-namespace", "пользователь или организация Docker Hub, репозитории которых очищаются (по умолчанию --username); можно указать несколько раз")
+	fs.Var(&cfg.Repositories, "repository", "очищать только указанный репозиторий; можно указать несколько раз")
+	fs.StringVar(&cfg.ReposFile, "repos-file", os.Getenv("REPOS_FILE"), "файл со списком репозиториев, по одному в строке, вместо запроса списка у registry (_catalog); - читать из stdin")
+	fs.IntVar(&cfg.CatalogPageSize, "catalog-page-size", 0, "количество репозиториев на странице каталога _catalog (параметр n); 0 - размер страницы по умолчанию registry (только --backend registry)")
+	fs.StringVar(&cfg.CatalogRange, "catalog-range", os.Getenv("CATALOG_RANGE"), "очищать только репозитории с именами после <после> и до <до> включительно в виде <после>..<до>, например ..m, m..t, t..: диапазоны с общими границами делят каталог между запусками без пересечений (только --backend registry)")
+	fs.StringVar(&cfg.CatalogPrefix, "catalog-prefix", os.Getenv("CATALOG_PREFIX"), "очищать только репозитории, имена которых начинаются с префикса, например team-a/ (только --backend registry)")
+	fs.Var(&cfg.TrustedKeys, "trusted-key", "открытый ключ cosign (файл или URI KMS): образы, подписи которых проверяются этим ключом, не удаляются; можно указать несколько раз")
+	fs.Var(&cfg.TrustedIdentities, "trusted-identity", "удостоверение подписи cosign без ключа <издатель OIDC>=<выражение идентичности>: подписанные так образы не удаляются; можно указать несколько раз")
+	fs.StringVar(&cfg.CosignCommand, "cosign-command", envOrDefault("COSIGN_COMMAND", "cosign"), "программа cosign для проверки подписей --trusted-key и --trusted-identity")
+	fs.Var(&cfg.DeleteSigned, "delete-signed", "разрешить удаление подписанных доверенным ключом образов с тегами <репозиторий>:<тег>, допускаются шаблоны * и ?; можно указать несколько раз")
+	fs.StringVar(&cfg.TeamsFile, "teams-file", os.Getenv("TEAMS_FILE"), "файл команд в формате JSON: пространства имен каждой команды, ее политика хранения, неизменяемые теги и адреса уведомлений; правила команды действуют только на ее репозитории")
+	fs.StringVar(&cfg.ProtectedFile, "protected-file", os.Getenv("PROTECTED_FILE"), "файл неизменяемых тегов: по одной записи <репозиторий>:<тег> в строке, допускаются шаблоны * и ?; такие образы никогда не удаляются")
+	fs.StringVar(&cfg.HoldURL, "hold-url", os.Getenv("HOLD_URL"), "адрес списка удержания (legal hold), запрашиваемого перед каждым запуском: JSON-массив записей <репозиторий>:<тег> или <репозиторий>@<digest> с шаблонами * и ?; такие образы не удаляются, а если список получить не удалось, запуск прерывается")
+	fs.StringVar(&cfg.HoldToken, "hold-token", os.Getenv("HOLD_TOKEN"), "токен сервиса --hold-url (Authorization: Bearer)")
+	fs.StringVar(&cfg.PlanOut, "out", "", "файл, в который подкоманда plan сохраняет план для apply, а очистка с --jira-url - план, ожидающий согласования")
+	fs.BoolVar(&cfg.SkipPreflight, "skip-preflight", false, "не проверять перед удалением доступность /v2/ и поддержку удаления в registry")
+	fs.BoolVar(&cfg.SkipDigestCheck, "skip-digest-check", false, "не сверять Docker-Content-Digest с sha256 манифеста и запрашивать digest тегов запросом HEAD; по умолчанию теги с несовпадающим digest не удаляются")
+	fs.BoolVar(&cfg.DeleteTagFallback, "delete-tag-fallback", false, "если registry отклоняет удаление по digest статусом 405 или 400, удалять ссылку тега (Nexus, некоторые прокси; только --backend registry)")
+	fs.StringVar(&cfg.IndexCreated, "index-created", envOrDefault("INDEX_CREATED", registry.IndexCreatedNewest), "время создания образа с несколькими платформами (manifest list, OCI index): newest - самой новой платформы, oldest - самой старой, annotation - из аннотации org.opencontainers.image.created index, os/architecture[/variant] - указанной платформы; метаданные кэшируются по digest, после смены способа очистите кэш")
+	fs.StringVar(&cfg.Platform, "platform", os.Getenv("IMAGE_PLATFORM"), "очищать только образы, содержащие платформу os/architecture[/variant], например linux/amd64; остальные образы и артефакты без платформы не учитываются политикой и не удаляются (только --backend registry)")
+	fs.BoolVar(&cfg.SweepSignatures, "sweep-signatures", false, "не применять политику хранения к подписям, аттестациям и SBOM cosign (теги sha256-<digest>.sig, .att, .sbom) и удалять их, только если образа, к которому они относятся, нет в репозитории или он удаляется")
+	fs.BoolVar(&cfg.VerifyDeletes, "verify-deletes", false, "после удаления образов репозитория проверить запросом HEAD, что registry больше не отдает их манифесты (только --backend registry)")
+	fs.DurationVar(&cfg.VerifyDeleteDelay, "verify-delete-delay", 0, "с --verify-deletes проверять удаление не раньше, чем через указанное время после последнего удаления, например 30s: кэши и реплики могут отдавать манифест некоторое время")
+	fs.BoolVar(&cfg.JSONOutput, "json", false, "подкоманда preflight выводит результат в формате JSON")
+	fs.IntVar(&cfg.LayersTop, "top", 20, "количество слоев в каждом списке отчета analyze-layers (0 - все)")
+	fs.Var(&cfg.ExcludeNamespaces, "exclude-namespace", "не обращаться к репозиториям пространства имен, например infra/ или base-images/, включая вложенные; можно указать несколько раз")
+	fs.StringVar(&cfg.Order, "order", envOrDefault("REPOSITORY_ORDER", cleanup.OrderCatalog), "порядок обработки репозиториев: catalog - как их возвращает registry, largest - сначала самые большие (по количеству тегов, а при удалении - по освобождаемому месту)")
+	fs.DurationVar(&cfg.Timeout, "timeout", 0, "максимальная длительность всего запуска, например 2h (0 - без ограничения)")
+	fs.IntVar(&cfg.Concurrency, "concurrency", 4, "количество тегов, метаданные которых запрашиваются одновременно")
+	fs.IntVar(&cfg.MaxIdleConnsPerHost, "max-idle-conns-per-host", 0, "сколько простаивающих соединений с registry сохранять для повторного использования (0 - по --concurrency, но не меньше 2)")
+	fs.DurationVar(&cfg.IdleConnTimeout, "idle-conn-timeout", 90*time.Second, "через сколько закрывать простаивающее соединение с registry")
+	fs.StringVar(&cfg.PprofAddr, "pprof-addr", os.Getenv("PPROF_ADDR"), "адрес сервера net/http/pprof для профилирования во время работы, например localhost:6060")
+	fs.StringVar(&cfg.Profile, "profile", "", "записать при выходе профиль: cpu - процессора за весь запуск, mem - памяти")
+	fs.StringVar(&cfg.ProfileFile, "profile-file", "", "файл профиля --profile (по умолчанию cpu.pprof или mem.pprof)")
+	fs.BoolVar(&cfg.HTTP1Only, "http1", false, "не использовать HTTP/2: по умолчанию он согласуется с registry по TLS автоматически, флаг нужен для прокси и балансировщиков с ошибками в поддержке HTTP/2")
+	fs.StringVar(&cfg.UserAgent, "user-agent", envOrDefault("REGISTRY_USER_AGENT", registry.DefaultUserAgent()), "заголовок User-Agent запросов к registry")
+	fs.Var(&cfg.Headers, "header", "дополнительный заголовок запросов к registry в виде \"Имя: значение\", например \"X-Forwarded-User: ci\" для прокси перед registry; можно указать несколько раз")
+	fs.BoolVar(&cfg.DebugHTTP, "debug-http", false, "выводить в stderr каждый запрос к registry: метод, URL, статус и время ответа")
+	fs.BoolVar(&cfg.DebugHTTPHeaders, "debug-http-headers", false, "как --debug-http, но выводить и заголовки запросов и ответов; учетные данные скрываются")
+	fs.DurationVar(&cfg.KeepAlive, "keep-alive", 30*time.Second, "период TCP keep-alive соединений с registry (отрицательное значение отключает keep-alive)")
+	fs.StringVar(&cfg.Sort, "sort", envOrDefault("IMAGE_SORT", cleanup.SortCreated), "порядок образов для политики хранения: created - по времени создания, build-number - по последнему числу в теге (build-1234), теги без номера считаются новейшими")
+	fs.StringVar(&cfg.TieBreak, "tie-break", envOrDefault("IMAGE_TIE_BREAK", cleanup.TieBreakTag), "порядок образов с одинаковым временем создания: tag - по тегу в естественном порядке (build-10 новее build-9), tag-reverse - в обратном, digest - по digest манифеста")
+	fs.StringVar(&cfg.CreatedFallback, "created-fallback", envOrDefault("CREATED_FALLBACK", cleanup.CreatedFallbackSkip), "действие, если время создания образа получить не удалось: skip - не учитывать образ в политике и не удалять, oldest - считать образ самым старым, last-modified - взять время из заголовка Last-Modified манифеста (только --backend registry), fail - не очищать репозиторий")
+	fs.StringVar(&cfg.TagTimeLayout, "tag-time-layout", os.Getenv("TAG_TIME_LAYOUT"), "формат времени в именах тегов в нотации Go, например 20060102-1504 или v2006.01.02: время из тега используется вместо времени создания образа, метаданные таких образов не запрашиваются")
+	fs.StringVar(&cfg.TagTimePattern, "tag-time-pattern", os.Getenv("TAG_TIME_PATTERN"), "регулярное выражение, выделяющее время из тега первой группой захвата (по умолчанию строится из --tag-time-layout)")
+	fs.StringVar(&cfg.Timezone, "timezone", os.Getenv("TIMEZONE"), "часовой пояс IANA, например Europe/Moscow или UTC: в нем выводится время, по его календарю отсчитываются сутки --unpulled-days и --purge-ago, в нем понимается время в тегах без пояса (по умолчанию время выводится в локальном поясе, время в тегах - в UTC)")
+	fs.StringVar(&cfg.CacheFile, "cache-file", os.Getenv("CACHE_FILE"), "файл кэша времени создания и размера образов по digest")
+	fs.StringVar(&cfg.ConfigCacheFile, "config-cache-file", os.Getenv("CONFIG_CACHE_FILE"), "файл кэша конфигураций образов по digest: конфигурации, общие для многих репозиториев, не скачиваются и в следующих запусках (в пределах запуска они скачиваются один раз и без него)")
+	fs.StringVar(&cfg.ETagCacheFile, "etag-cache-file", os.Getenv("ETAG_CACHE_FILE"), "файл кэша ETag списков тегов и манифестов: повторные запросы отправляются с If-None-Match, и неизменившиеся ресурсы registry не передает заново (только --backend registry)")
+	fs.StringVar(&cfg.StateFile, "state-file", os.Getenv("STATE_FILE"), "файл состояния очистки между запусками")
+	fs.StringVar(&cfg.Incremental, "incremental", "", "пропускать репозитории, не изменившиеся с прошлой очистки: tags - по списку тегов, digests - по тегам и digest")
+	fs.BoolVar(&cfg.Managed, "managed", false, "очищать только репозитории, взятые под управление: записанные в --state-file; новые репозитории пропускаются")
+	fs.BoolVar(&cfg.Adopt, "adopt", false, "с --managed взять под управление и очистить новые репозитории, которых нет в --state-file")
+	fs.BoolVar(&cfg.Restart, "restart", false, "не продолжать прерванный запуск из --state-file, а начать очистку заново")
+
+	fs.StringVar(&cfg.ArchiveURL, "archive-url", os.Getenv("ARCHIVE_REGISTRY_URL"), "URL архивного Registry, куда копируются образы перед удалением")
+	fs.StringVar(&cfg.ArchiveUsername, "archive-username", os.Getenv("ARCHIVE_REGISTRY_USERNAME"), "имя пользователя архивного Registry")
+	fs.StringVar(&cfg.ArchivePassword, "archive-password", os.Getenv("ARCHIVE_REGISTRY_PASSWORD"), "пароль архивного Registry")
+	fs.StringVar(&cfg.ArchivePrefix, "archive-prefix", os.Getenv("ARCHIVE_REGISTRY_PREFIX"), "префикс имени репозитория в архивном Registry")
+	fs.StringVar(&cfg.Quarantine, "quarantine", os.Getenv("QUARANTINE"), "помещать образы в карантин перед удалением: repository - копировать в репозиторий <--quarantine-prefix>/<репозиторий>, tag - оставлять в том же репозитории и удалять только исходный тег; копия получает тег zz-trash-<время>-<тег> (только --backend registry)")
+	fs.StringVar(&cfg.QuarantinePrefix, "quarantine-prefix", envOrDefault("QUARANTINE_PREFIX", "quarantine"), "префикс репозиториев карантина для --quarantine repository")
+	fs.StringVar(&cfg.SoftDeleteFile, "soft-delete-file", os.Getenv("SOFT_DELETE_FILE"), "файл отметок двухфазного удаления: кандидаты на удаление сначала отмечаются и удаляются одним из следующих запусков, если с отметки прошло не меньше --grace-period и они все еще подлежат удалению")
+	fs.StringVar(&cfg.GracePeriod, "grace-period", envOrDefault("GRACE_PERIOD", "7d"), "срок ожидания удаления отмеченных образов для --soft-delete-file, например 7d или 36h")
+	fs.StringVar(&cfg.QuarantineOlderThan, "older-than", os.Getenv("QUARANTINE_OLDER_THAN"), "подкоманда purge-quarantine удаляет образы, помещенные в карантин раньше указанного времени назад, например 7d или 36h")
+
+	fs.StringVar(&cfg.ExportDir, "export-dir", os.Getenv("EXPORT_DIR"), "каталог, куда удаляемые образы выгружаются в виде OCI tar-архивов")
+	fs.StringVar(&cfg.SBOMDir, "sbom-dir", os.Getenv("SBOM_DIR"), "каталог архива SBOM: перед удалением образа в него сохраняется SBOM, прикрепленный cosign, а если SBOM сохранить не удалось, образ не удаляется")
+	fs.BoolVar(&cfg.SBOMGenerate, "sbom-generate", false, "создавать SBOM программой syft для образов без прикрепленного SBOM при --sbom-dir")
+	fs.StringVar(&cfg.SyftCommand, "syft-command", envOrDefault("SYFT_COMMAND", "syft"), "программа syft для --sbom-generate")
+
+	fs.Float64Var(&cfg.MaxDeletePercent, "max-delete-percent", 0, "прервать очистку, если будет удалено больше указанного процента образов (0 - без ограничения)")
+	fs.IntVar(&cfg.MaxDeleteCount, "max-delete-count", 0, "прервать очистку, если будет удалено больше указанного количества образов (0 - без ограничения)")
+	fs.StringVar(&cfg.JiraURL, "jira-url", os.Getenv("JIRA_URL"), "адрес Jira: если план превышает --max-delete-percent или --max-delete-count, он сохраняется в --out, а в Jira открывается задача на его согласование; образы удаляются только подкомандой apply")
+	fs.StringVar(&cfg.JiraProject, "jira-project", os.Getenv("JIRA_PROJECT"), "ключ проекта Jira для задач согласования")
+	fs.StringVar(&cfg.JiraUser, "jira-user", os.Getenv("JIRA_USER"), "email пользователя Jira Cloud; без него --jira-token передается как персональный токен доступа Jira Data Center")
+	fs.StringVar(&cfg.JiraToken, "jira-token", os.Getenv("JIRA_TOKEN"), "API-токен Jira Cloud или персональный токен доступа Jira Data Center")
+	fs.StringVar(&cfg.JiraIssueType, "jira-issue-type", envOrDefault("JIRA_ISSUE_TYPE", "Task"), "тип задач согласования Jira")
+	fs.IntVar(&cfg.MaxDeletesPerMinute, "max-deletes-per-minute", 0, "не больше указанного количества запросов удаления в минуту; остальные удаления ждут очереди (0 - без ограничения)")
+	fs.StringVar(&cfg.SummaryFile, "summary-file", os.Getenv("SUMMARY_FILE"), "файл, в который записываются итоги запуска в формате JSON: репозитории, теги, удаленные манифесты, ошибки, освобожденное место и длительность")
+	fs.StringVar(&cfg.JUnitOut, "junit-out", os.Getenv("JUNIT_OUT"), "файл, в который записываются итоги репозиториев в формате JUnit XML: репозиторий - тест, ошибки его очистки - провал теста")
+	fs.StringVar(&cfg.TextfileDir, "textfile-dir", os.Getenv("TEXTFILE_DIR"), "каталог textfile collector node_exporter (--collector.textfile.directory), в который записываются метрики запуска в формате Prometheus")
+	fs.IntVar(&cfg.AlertDeleteFailures, "alert-delete-failures", -1, "оповещать с уровнем critical, если не удалось удалить больше указанного количества образов (-1 - не проверять, 0 - при любой ошибке удаления)")
+	fs.BoolVar(&cfg.AlertRepositoryFailure, "alert-repository-failure", false, "оповещать с уровнем critical, если для репозитория не удалось составить план или не удалось удалить ни одного его образа")
+	fs.Var(&cfg.NotifyWebhooks, "notify-webhook", "адрес, на который итоги запуска отправляются запросом POST в формате JSON с уровнем info, warning или critical; можно указать несколько раз")
+	fs.StringVar(&cfg.PagerDutyRoutingKey, "pagerduty-routing-key", os.Getenv("PAGERDUTY_ROUTING_KEY"), "ключ интеграции PagerDuty Events API v2: инцидент открывается, если очистка не выполнена (registry недоступен, ошибка аутентификации) или нарушены пороги --alert-*, и закрывается следующим успешным запуском")
+	fs.StringVar(&cfg.PagerDutyURL, "pagerduty-url", envOrDefault("PAGERDUTY_URL", "https://events.pagerduty.com/v2/enqueue"), "адрес PagerDuty Events API v2, для региона EU - https://events.eu.pagerduty.com/v2/enqueue")
+	fs.StringVar(&cfg.OpsgenieAPIKey, "opsgenie-api-key", os.Getenv("OPSGENIE_API_KEY"), "ключ интеграции API Opsgenie: алерт создается и закрывается так же, как инцидент --pagerduty-routing-key")
+	fs.StringVar(&cfg.OpsgenieURL, "opsgenie-url", envOrDefault("OPSGENIE_URL", "https://api.opsgenie.com"), "адрес API Opsgenie, для региона EU - https://api.eu.opsgenie.com")
+	fs.StringVar(&cfg.NotifySeverity, "notify-severity", envOrDefault("NOTIFY_SEVERITY", SeverityWarning), "минимальный уровень отправляемых уведомлений: info - каждый запуск, warning - запуски с ошибками или прерванные, critical - только при превышении порогов --alert-*")
+	fs.StringVar(&cfg.SentryDSN, "sentry-dsn", os.Getenv("SENTRY_DSN"), "DSN проекта Sentry, в который отправляются паники и ошибки запуска с registry и репозиториями")
+	fs.StringVar(&cfg.SentryEnvironment, "sentry-environment", os.Getenv("SENTRY_ENVIRONMENT"), "окружение событий Sentry, например production")
+	fs.StringVar(&cfg.LogSink, "log-sink", os.Getenv("LOG_SINK"), "дублировать вывод в журнал: syslog или journald (если journald недоступен - в локальный syslog); строки с ошибками передаются с приоритетом err, предупреждения - warning")
+	fs.StringVar(&cfg.SyslogAddr, "syslog-addr", os.Getenv("SYSLOG_ADDR"), "адрес удаленного syslog для --log-sink syslog, например udp://logs.example.com:514 (по умолчанию локальный syslog)")
+	fs.StringVar(&cfg.SyslogTag, "syslog-tag", envOrDefault("SYSLOG_TAG", "registry-cleaner"), "тег (SYSLOG_IDENTIFIER) сообщений в журнале")
+	fs.BoolVar(&cfg.FailFast, "fail-fast", false, "прервать очистку при первой ошибке; по умолчанию ошибки репозиториев и тегов не прерывают очистку, а выводятся в итоговой сводке")
+	fs.BoolVar(&cfg.Force, "force", false, "выполнить удаление, даже если превышены пороги --max-delete-percent и --max-delete-count")
+
+	fs.BoolVar(&cfg.Interactive, "interactive", false, "показывать план каждого репозитория и запрашивать подтверждение перед удалением")
+	fs.BoolVar(&cfg.Stream, "stream", false, "очищать каждый репозиторий сразу после составления его плана, не храня планы всего registry в памяти; пороги --max-delete-percent проверяются для каждого репозитория, а --max-delete-count - по сумме уже удаленных образов")
+
+	fs.StringVar(&cfg.KeepPattern, "keep-pattern", os.Getenv("KEEP_PATTERN"), "регулярное выражение тегов, группы захвата которого задают группу, например ^(?P<branch>.+)-[0-9a-f]{7}$; в каждой группе сохраняются --keep-per-group новейших образов, остальные теги обрабатываются как обычно")
+	fs.IntVar(&cfg.KeepPerGroup, "keep-per-group", 3, "количество новейших образов, сохраняемых в каждой группе --keep-pattern")
+	fs.Var(&cfg.KeepPrefixes, "keep-prefix", "класс тегов <префикс>=<количество>, например release-=10: в классе сохраняется указанное количество новейших образов, теги вне классов обрабатываются как обычно; можно указать несколько раз")
+	fs.StringVar(&cfg.ExpiresLabel, "expires-label", os.Getenv("EXPIRES_LABEL"), "метка конфигурации образа со сроком хранения от создания, например quay.expires-after: значение вида 12h, 5d или 2w удаляет образ по истечении срока независимо от политики, never - защищает образ от удаления")
+	fs.StringVar(&cfg.VulnScanner, "vuln-scanner", os.Getenv("VULN_SCANNER"), "сканер образов для --delete-vulnerable, --keep-clean и --scan-all: trivy или grype - сканировать образы программой, harbor - итоги сканирования Harbor, http - внешний сервис --scanner-url")
+	fs.StringVar(&cfg.TrivyCommand, "trivy-command", envOrDefault("TRIVY_COMMAND", "trivy"), "программа trivy для --vuln-scanner trivy")
+	fs.StringVar(&cfg.TrivyServer, "trivy-server", os.Getenv("TRIVY_SERVER"), "адрес сервера Trivy: образы сканируются в режиме клиента, без локальной базы уязвимостей")
+	fs.StringVar(&cfg.GrypeCommand, "grype-command", envOrDefault("GRYPE_COMMAND", "grype"), "программа grype для --vuln-scanner grype")
+	fs.StringVar(&cfg.ScannerURL, "scanner-url", os.Getenv("SCANNER_URL"), "адрес сервиса --vuln-scanner http: образ передается запросом POST в формате JSON, сервис отвечает уязвимостями и вердиктами")
+	fs.StringVar(&cfg.ScannerToken, "scanner-token", os.Getenv("SCANNER_TOKEN"), "токен сервиса --vuln-scanner http (Authorization: Bearer)")
+	fs.BoolVar(&cfg.ScanAll, "scan-all", false, "сканировать все образы до применения политики хранения: уязвимости и вердикты сканера доступны --policy-cel (vulnerabilities, scan), Rego и внешним политикам (поле scan)")
+	fs.DurationVar(&cfg.ScanTimeout, "scan-timeout", 5*time.Minute, "ограничение времени сканирования, проверки подписи или создания SBOM одного образа")
+	fs.StringVar(&cfg.DeleteVulnerable, "delete-vulnerable", os.Getenv("DELETE_VULNERABLE"), "удалять образы с уязвимостями указанного уровня и выше, созданные раньше указанного времени назад, независимо от политики хранения, например critical:30d")
+	fs.StringVar(&cfg.KeepClean, "keep-clean", os.Getenv("KEEP_CLEAN"), "сохранять новейший образ репозитория без уязвимостей указанного уровня и выше, например high, даже если политика хранения его удаляет")
+	fs.Var(&cfg.KeepAnnotations, "keep-annotation", "не удалять образы с аннотацией манифеста или меткой <ключ>[=<значение>], например retention=permanent; можно указать несколько раз")
+	fs.StringVar(&cfg.GitRepo, "git-repo", os.Getenv("GIT_REPO"), "git-репозиторий, коммиты которого указаны в тегах: образы коммитов, достижимых из веток --git-branch, сохраняются, остальные удаляются")
+	fs.Var(&cfg.GitBranches, "git-branch", "ветка --git-repo, коммиты которой сохраняются (по умолчанию все ветки); можно указать несколько раз")
+	fs.StringVar(&cfg.GitTagPattern, "git-tag-pattern", envOrDefault("GIT_TAG_PATTERN", cleanup.DefaultGitTagPattern), "регулярное выражение тегов с SHA коммита; SHA - первая группа захвата")
+	fs.IntVar(&cfg.UnpulledDays, "unpulled-days", 0, "удалять только образы, которые не скачивались указанное количество дней (требует статистики скачиваний, --backend harbor, ecr, nexus, artifactory или dockerhub)")
+	fs.Var(&cfg.PurgeFilters, "purge-filter", "удалять только теги, подходящие под фильтр <регулярное выражение репозитория>:<регулярное выражение тега>, как в acr purge; можно указать несколько раз")
+	fs.StringVar(&cfg.PurgeAgo, "purge-ago", os.Getenv("PURGE_AGO"), "удалять только образы старше указанной длительности, например 30d или 2d3h6m")
+	fs.StringVar(&cfg.PolicyCEL, "policy-cel", os.Getenv("POLICY_CEL"), "CEL-выражение над tag, repository, digest, created, age, size и labels; образ удаляется, только если оно истинно. Значения labels - строки: метку проверяйте сравнением labels.keep == \"true\", а не !labels.keep")
+	fs.StringVar(&cfg.PolicyRego, "policy-rego", os.Getenv("POLICY_REGO"), "Rego-политика OPA: .rego файл, каталог или bundle .tar.gz")
+	fs.StringVar(&cfg.PolicyRegoQuery, "policy-rego-query", cleanup.DefaultRegoQuery, "запрос к Rego-политике, возвращающий множество тегов для удаления")
+	fs.Var(&cfg.PolicyExec, "policy-exec", "программа, которая получает каждый кандидат на удаление в формате JSON в stdin и отвечает keep или delete (или POLICY_EXEC); повторные флаги задают ее аргументы по одному, например --policy-exec /usr/local/bin/check --policy-exec --team --policy-exec backend")
+	fs.DurationVar(&cfg.PolicyExecTimeout, "policy-exec-timeout", 30*time.Second, "ограничение времени одного запуска --policy-exec и --policy-wasm")
+	fs.StringVar(&cfg.PolicyWasm, "policy-wasm", os.Getenv("POLICY_WASM"), "WASI-модуль, который выполняется в изолированной среде с тем же протоколом, что и --policy-exec")
+
+	fs.StringVar(&cfg.GCContainer, "gc-container", os.Getenv("GC_CONTAINER"), "контейнер registry, в котором после удаления запускается garbage collection через Docker API")
+	fs.StringVar(&cfg.DockerHost, "docker-host", envOrDefault("DOCKER_HOST", gc.DefaultDockerHost), "адрес Docker Engine API: unix:///var/run/docker.sock или tcp://host:2375")
+	fs.StringVar(&cfg.GCSSH, "gc-ssh", os.Getenv("GC_SSH"), "сервер registry в виде [user@]host[:port], на котором после удаления garbage collection запускается по SSH")
+	fs.StringVar(&cfg.GCSSHKey, "gc-ssh-key", os.Getenv("GC_SSH_KEY"), "закрытый ключ SSH (по умолчанию ssh-agent и ключи из ~/.ssh)")
+	fs.StringVar(&cfg.GCSSHKnownHosts, "gc-ssh-known-hosts", os.Getenv("GC_SSH_KNOWN_HOSTS"), "файл known_hosts для проверки ключа сервера (по умолчанию ~/.ssh/known_hosts)")
+	fs.StringVar(&cfg.GCK8sSelector, "gc-k8s-selector", os.Getenv("GC_K8S_SELECTOR"), "селектор меток пода registry в Kubernetes, например app=registry, в котором после удаления запускается garbage collection")
+	fs.StringVar(&cfg.GCK8sNamespace, "gc-k8s-namespace", os.Getenv("GC_K8S_NAMESPACE"), "пространство имен пода registry (по умолчанию из текущего контекста kubeconfig)")
+	fs.StringVar(&cfg.GCK8sContainer, "gc-k8s-container", os.Getenv("GC_K8S_CONTAINER"), "контейнер пода registry (по умолчанию первый)")
+	fs.StringVar(&cfg.Kubeconfig, "kubeconfig", "", "файл kubeconfig (по умолчанию KUBECONFIG, ~/.kube/config или сервисный аккаунт пода)")
+	fs.StringVar(&cfg.GCCommand, "gc-command", envOrDefault("GC_COMMAND", gc.DefaultCommand), "шаблон команды garbage collection (text/template, поля .RegistryURL, .Deleted, .Repositories)")
+	fs.StringVar(&cfg.GCFSRoot, "gc-fs-root", os.Getenv("GC_FS_ROOT"), "корень хранилища registry с драйвером filesystem, например /var/lib/registry: после удаления blob без ссылок удаляются напрямую")
+	fs.StringVar(&cfg.GCS3Bucket, "gc-s3-bucket", os.Getenv("GC_S3_BUCKET"), "бакет хранилища registry с драйвером s3: после удаления blob без ссылок удаляются напрямую")
+	fs.StringVar(&cfg.GCAzureContainer, "gc-azure-container", os.Getenv("GC_AZURE_CONTAINER"), "контейнер Azure Blob Storage хранилища registry с драйвером azure: после удаления blob без ссылок удаляются напрямую")
+	fs.StringVar(&cfg.GCAzureAccount, "gc-azure-account", os.Getenv("AZURE_STORAGE_ACCOUNT"), "учетная запись хранения Azure")
+	fs.StringVar(&cfg.GCAzureKey, "gc-azure-key", os.Getenv("AZURE_STORAGE_KEY"), "ключ учетной записи хранения Azure")
+	fs.StringVar(&cfg.GCGCSBucket, "gc-gcs-bucket", os.Getenv("GC_GCS_BUCKET"), "бакет Google Cloud Storage хранилища registry с драйвером gcs: после удаления blob без ссылок удаляются напрямую")
+	fs.StringVar(&cfg.GCStorageRoot, "gc-storage-root", os.Getenv("GC_STORAGE_ROOT"), "rootdirectory драйвера s3, azure или gcs в конфигурации registry")
+	fs.StringVar(&cfg.GCStorageEndpoint, "gc-storage-endpoint", os.Getenv("GC_STORAGE_ENDPOINT"), "адрес объектного хранилища вместо облачного, например MinIO, Azurite или fake-gcs-server")
+	fs.BoolVar(&cfg.GCDryRun, "gc-dry-run", false, "только вывести blob и загрузки, которые удалит сборка в хранилище (--gc-fs-root, --gc-s3-bucket, --gc-azure-container или --gc-gcs-bucket)")
+	fs.Var(&cfg.GCReadOnlyEnable, "gc-readonly-enable", "программа, переводящая registry в режим только для чтения перед garbage collection (или GC_READONLY_ENABLE); повторные флаги задают ее аргументы по одному, например --gc-readonly-enable ./registry-readonly.sh --gc-readonly-enable on")
+	fs.Var(&cfg.GCReadOnlyDisable, "gc-readonly-disable", "программа, возвращающая registry в обычный режим после garbage collection (или GC_READONLY_DISABLE); аргументы задаются повторными флагами, как у --gc-readonly-enable")
+
+	fs.StringVar(&cfg.LockFile, "lock-file", os.Getenv("LOCK_FILE"), "локальный файл блокировки от одновременного запуска")
+	fs.StringVar(&cfg.LockTag, "lock-tag", os.Getenv("LOCK_TAG"), "маркер блокировки в самом registry в виде repository:tag")
+	fs.StringVar(&cfg.LockURL, "lock-url", os.Getenv("LOCK_URL"), "URL внешнего сервиса блокировок (POST - захват, PUT - продление, DELETE - освобождение)")
+	fs.DurationVar(&cfg.LockTTL, "lock-ttl", 6*time.Hour, "срок блокировки; пока идет очистка, он продлевается каждую треть срока, а брошенная блокировка после него считается устаревшей")
+
+	fs.StringVar(&cfg.Listen, "listen", envOrDefault("SERVE_LISTEN", ":8080"), "адрес HTTP API в режиме serve")
+	fs.StringVar(&cfg.GRPCListen, "grpc-listen", os.Getenv("GRPC_LISTEN"), "адрес gRPC API в режиме serve, по умолчанию gRPC API не запускается")
+	fs.StringVar(&cfg.APIToken, "api-token", os.Getenv("API_TOKEN"), "токен доступа к HTTP и gRPC API в режиме serve (Authorization: Bearer <токен>) с правом запускать очистку (роль operator)")
+	fs.BoolVar(&cfg.LeaderElect, "leader-elect", false, "в режиме serve выбирать лидера среди реплик через Lease Kubernetes: запускает очистку только лидер")
+	fs.StringVar(&cfg.LeaderElectLease, "leader-elect-lease", envOrDefault("LEADER_ELECT_LEASE", "registry-cleaner"), "имя Lease для выбора лидера")
+	fs.StringVar(&cfg.LeaderElectNamespace, "leader-elect-namespace", os.Getenv("POD_NAMESPACE"), "пространство имен Lease (по умолчанию пространство имен пода или текущего контекста kubeconfig)")
+	fs.StringVar(&cfg.APIViewerToken, "api-viewer-token", os.Getenv("API_VIEWER_TOKEN"), "токен доступа к API в режиме serve только для просмотра планов и запусков (роль viewer)")
+
+	return fs
+}
+
+// Validate проверяет согласованность параметров
+func (cfg *Config) Validate() error {
+	switch cfg.Backend {
+	case BackendRegistry, BackendHarbor:
+	case BackendQuay:
+		if len(cfg.QuayNamespaces) == 0 {
+			return fmt.Errorf("для --backend quay укажите --quay-namespace")
+		}
+		// Время создания в Quay зависит от истории тега, а не только от digest
+		if cfg.CacheFile != "" {
+			return fmt.Errorf("--cache-file не поддерживается с --backend quay")
+		}
+	case BackendGitLab, BackendECR, BackendGAR, BackendACR, BackendNexus, BackendArtifactory, BackendDockerHub:
+		if cfg.Backend == BackendGitLab && cfg.GitLabProject == "" && cfg.GitLabGroup == "" {
+			return fmt.Errorf("для --backend gitlab укажите --gitlab-project или --gitlab-group")
+		}
+		if cfg.Backend == BackendGAR && cfg.GARProject == "" {
+			return fmt.Errorf("для --backend gar укажите --gar-project")
+		}
+		if cfg.Backend == BackendDockerHub && (cfg.Username == "" || cfg.Password == "") {
+			return fmt.Errorf("для --backend dockerhub укажите --username и --password (пароль или токен доступа)")
+		}
+		// Registry API этих backend требует отдельной аутентификации
+		if cfg.ArchiveURL != "" || cfg.ExportDir != "" || cfg.SBOMDir != "" || cfg.LockTag != "" {
+			return fmt.Errorf("--archive-url, --export-dir, --sbom-dir и --lock-tag не поддерживаются с --backend %s", cfg.Backend)
+		}
+	default:
+		return fmt.Errorf("неизвестный backend %q, допустимо: registry, harbor, gitlab, ecr, gar, acr, quay, nexus, artifactory, dockerhub", cfg.Backend)
+	}
+	switch cfg.Order {
+	case cleanup.OrderCatalog, cleanup.OrderLargest:
+	default:
+		return fmt.Errorf("неизвестный порядок --order %q, допустимо: catalog, largest", cfg.Order)
+	}
+	switch cfg.Sort {
+	case cleanup.SortCreated, cleanup.SortBuildNumber:
+	default:
+		return fmt.Errorf("неизвестный порядок --sort %q, допустимо: created, build-number", cfg.Sort)
+	}
+	switch cfg.TieBreak {
+	case cleanup.TieBreakTag, cleanup.TieBreakTagReverse, cleanup.TieBreakDigest:
+	default:
+		return fmt.Errorf("неизвестный порядок --tie-break %q, допустимо: tag, tag-reverse, digest", cfg.TieBreak)
+	}
+	switch cfg.CreatedFallback {
+	case cleanup.CreatedFallbackSkip, cleanup.CreatedFallbackOldest, cleanup.CreatedFallbackFail:
+	case cleanup.CreatedFallbackLastModified:
+		if cfg.Backend != BackendRegistry {
+			return fmt.Errorf("--created-fallback last-modified доступен только с --backend registry")
+		}
+	default:
+		return fmt.Errorf("неизвестное действие --created-fallback %q, допустимо: skip, oldest, last-modified, fail", cfg.CreatedFallback)
+	}
+	switch cfg.Incremental {
+	case "", cleanup.IncrementalTags, cleanup.IncrementalDigests:
+	default:
+		return fmt.Errorf("неизвестный режим --incremental %q, допустимо: tags, digests", cfg.Incremental)
+	}
+	// Неизменившийся репозиторий пропускается, а правила, зависящие от времени, могут
+	// удалить его образы и без новых тегов
+	if cfg.Incremental != "" && (cfg.UnpulledDays > 0 || cfg.PurgeAgo != "" || cfg.ExpiresLabel != "" || cfg.DeleteVulnerable != "" || cfg.GitRepo != "") {
+		return fmt.Errorf("--incremental несовместим с --unpulled-days, --purge-ago, --expires-label, --delete-vulnerable и --git-repo: решения по ним меняются без изменения тегов")
+	}
+	switch cfg.Backend {
+	case BackendHarbor, BackendECR, BackendNexus, BackendArtifactory, BackendDockerHub:
+	default:
+		if cfg.UnpulledDays > 0 {
+			return fmt.Errorf("--unpulled-days требует backend со статистикой скачиваний: harbor, ecr, nexus, artifactory или dockerhub")
+		}
+	}
+	if len(cfg.KeepAnnotations) > 0 && cfg.TagTimeLayout != "" {
+		return fmt.Errorf("--keep-annotation несовместим с --tag-time-layout: аннотации образов со временем в теге не запрашиваются")
+	}
+	switch cfg.VulnScanner {
+	case "":
+		if cfg.DeleteVulnerable != "" || cfg.KeepClean != "" || cfg.ScanAll {
+			return fmt.Errorf("--delete-vulnerable, --keep-clean и --scan-all требуют --vuln-scanner")
+		}
+	case ScannerTrivy, ScannerGrype:
+		if cfg.Backend != BackendRegistry && cfg.Backend != BackendHarbor {
+			return fmt.Errorf("--vuln-scanner %s доступен только с --backend registry или harbor", cfg.VulnScanner)
+		}
+	case ScannerHarbor:
+		if cfg.Backend != BackendHarbor {
+			return fmt.Errorf("--vuln-scanner harbor доступен только с --backend harbor")
+		}
+	case ScannerHTTP:
+		if cfg.ScannerURL == "" {
+			return fmt.Errorf("--vuln-scanner http требует --scanner-url")
+		}
+	default:
+		return fmt.Errorf("неизвестный сканер --vuln-scanner %q, допустимо: trivy, grype, harbor, http", cfg.VulnScanner)
+	}
+	if cfg.VulnScanner != "" && cfg.DeleteVulnerable == "" && cfg.KeepClean == "" && !cfg.ScanAll {
+		return fmt.Errorf("--vuln-scanner требует --delete-vulnerable, --keep-clean или --scan-all")
+	}
+	if cfg.DeleteVulnerable != "" {
+		if _, _, err := cleanup.ParseVulnerableRule(cfg.DeleteVulnerable); err != nil {
+			return fmt.Errorf("--delete-vulnerable: %v", err)
+		}
+	}
+	if cfg.KeepClean != "" {
+		if _, err := registry.ParseSeverity(cfg.KeepClean); err != nil {
+			return fmt.Errorf("--keep-clean: %v", err)
+		}
+	}
+	for _, identity := range cfg.TrustedIdentities {
+		if _, err := cleanup.ParseTrustedIdentity(identity); err != nil {
+			return fmt.Errorf("--trusted-identity: %v", err)
+		}
+	}
+	if len(cfg.TrustedKeys) > 0 || len(cfg.TrustedIdentities) > 0 {
+		if cfg.Backend != BackendRegistry && cfg.Backend != BackendHarbor {
+			return fmt.Errorf("--trusted-key и --trusted-identity доступны только с --backend registry или harbor")
+		}
+	} else if len(cfg.DeleteSigned) > 0 {
+		return fmt.Errorf("--delete-signed требует --trusted-key или --trusted-identity")
+	}
+	if cfg.TeamsFile != "" {
+		teams, err := loadTeamsFile(cfg.TeamsFile)
+		if err != nil {
+			return err
+		}
+		if _, err := newTenants(teams); err != nil {
+			return fmt.Errorf("--teams-file: %v", err)
+		}
+		for _, team := range teams {
+			if cfg.Incremental != "" && (team.UnpulledDays > 0 || team.PurgeAgo != "") {
+				return fmt.Errorf("--incremental несовместим с unpulledDays и purgeAgo команды %s: решения по времени меняются без изменения тегов", team.Name)
+			}
+		}
+	}
+	if _, err := cleanup.ParseProtectedTags(cfg.DeleteSigned); err != nil {
+		return fmt.Errorf("--delete-signed: %v", err)
+	}
+	if cfg.ExpiresLabel != "" && cfg.TagTimeLayout != "" {
+		return fmt.Errorf("--expires-label несовместим с --tag-time-layout: метки образов со временем в теге не запрашиваются")
+	}
+	if len(cfg.GitBranches) > 0 && cfg.GitRepo == "" {
+		return fmt.Errorf("--git-branch требует --git-repo")
+	}
+	if cfg.JiraURL != "" {
+		if cfg.JiraProject == "" || cfg.PlanOut == "" {
+			return fmt.Errorf("--jira-url требует --jira-project и --out: в --out сохраняется план, ожидающий согласования")
+		}
+		if cfg.MaxDeletePercent <= 0 && cfg.MaxDeleteCount <= 0 {
+			return fmt.Errorf("--jira-url требует --max-delete-percent или --max-delete-count")
+		}
+		if cfg.Stream {
+			return fmt.Errorf("--jira-url несовместим с --stream: для согласования нужен план всего registry")
+		}
+	}
+	if cfg.Stream && cfg.Order == cleanup.OrderLargest {
+		return fmt.Errorf("--stream несовместим с --order largest: для сортировки нужны планы всех репозиториев")
+	}
+	if cfg.ReposFile == "-" && cfg.Interactive {
+		return fmt.Errorf("--repos-file - несовместим с --interactive: stdin нужен для подтверждений")
+	}
+	if cfg.TagTimePattern != "" && cfg.TagTimeLayout == "" {
+		return fmt.Errorf("--tag-time-pattern требует --tag-time-layout")
+	}
+	if cfg.Timezone != "" {
+		if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+			return fmt.Errorf("некорректный часовой пояс --timezone %q: %v", cfg.Timezone, err)
+		}
+	}
+	if cfg.NexusCompact && cfg.Backend != BackendNexus {
+		return fmt.Errorf("--nexus-compact доступен только с --backend nexus")
+	}
+	gcTargets := 0
+	for _, target := range []string{cfg.GCContainer, cfg.GCSSH, cfg.GCK8sSelector, cfg.GCFSRoot, cfg.GCS3Bucket, cfg.GCAzureContainer, cfg.GCGCSBucket} {
+		if target != "" {
+			gcTargets++
+		}
+	}
+	if gcTargets > 1 {
+		return fmt.Errorf("укажите только один способ garbage collection: --gc-container, --gc-ssh, --gc-k8s-selector, --gc-fs-root, --gc-s3-bucket, --gc-azure-container или --gc-gcs-bucket")
+	}
+	if gcTargets > 0 && cfg.Backend != BackendRegistry {
+		return fmt.Errorf("garbage collection после очистки доступен только с --backend registry")
+	}
+	switch cfg.Quarantine {
+	case "", cleanup.QuarantineRepository, cleanup.QuarantineTag:
+	default:
+		return fmt.Errorf("неизвестный карантин --quarantine %q, допустимо: repository, tag", cfg.Quarantine)
+	}
+	if cfg.Quarantine != "" && cfg.Backend != BackendRegistry {
+		return fmt.Errorf("--quarantine доступен только с --backend registry")
+	}
+	if cfg.Quarantine == cleanup.QuarantineRepository && strings.Trim(cfg.QuarantinePrefix, "/") == "" {
+		return fmt.Errorf("--quarantine repository требует --quarantine-prefix")
+	}
+	if cfg.QuarantineOlderThan != "" {
+		if _, err := cleanup.ParseAgo(cfg.QuarantineOlderThan); err != nil {
+			return fmt.Errorf("--older-than: %v", err)
+		}
+	}
+	if cfg.SoftDeleteFile != "" {
+		if _, err := cleanup.ParseAgo(cfg.GracePeriod); err != nil {
+			return fmt.Errorf("--grace-period: %v", err)
+		}
+	}
+	if cfg.SBOMGenerate && cfg.SBOMDir == "" {
+		return fmt.Errorf("--sbom-generate требует --sbom-dir")
+	}
+	if cfg.DeleteTagFallback && cfg.Backend != BackendRegistry {
+		return fmt.Errorf("--delete-tag-fallback доступен только с --backend registry")
+	}
+	if !registry.ValidIndexCreated(cfg.IndexCreated) {
+		return fmt.Errorf("некорректный --index-created %q: ожидается newest, oldest, annotation или os/architecture[/variant]", cfg.IndexCreated)
+	}
+	if cfg.Platform != "" && !registry.ValidPlatform(cfg.Platform) {
+		return fmt.Errorf("некорректная --platform %q: ожидается os/architecture[/variant], например linux/amd64", cfg.Platform)
+	}
+	if cfg.Platform != "" && cfg.Backend != BackendRegistry {
+		return fmt.Errorf("--platform доступна только с --backend registry")
+	}
+	if cfg.Profile != "" && cfg.Profile != ProfileCPU && cfg.Profile != ProfileMem {
+		return fmt.Errorf("некорректный --profile %q: ожидается cpu или mem", cfg.Profile)
+	}
+	if cfg.ProfileFile != "" && cfg.Profile == "" {
+		return fmt.Errorf("--profile-file требует --profile")
+	}
+	if cfg.MaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("--max-idle-conns-per-host не может быть отрицательным")
+	}
+	if cfg.IdleConnTimeout < 0 {
+		return fmt.Errorf("--idle-conn-timeout не может быть отрицательным")
+	}
+	if cfg.CatalogPageSize < 0 {
+		return fmt.Errorf("--catalog-page-size не может быть отрицательным")
+	}
+	if _, err := registry.ParseCatalogRange(cfg.CatalogRange); err != nil {
+		return fmt.Errorf("некорректный --catalog-range %q: %v", cfg.CatalogRange, err)
+	}
+	if (cfg.CatalogPageSize > 0 || cfg.CatalogRange != "" || cfg.CatalogPrefix != "") && cfg.Backend != BackendRegistry {
+		return fmt.Errorf("--catalog-page-size, --catalog-range и --catalog-prefix доступны только с --backend registry")
+	}
+	if (cfg.CatalogRange != "" || cfg.CatalogPrefix != "") && cfg.ReposFile != "" {
+		return fmt.Errorf("--catalog-range и --catalog-prefix несовместимы с --repos-file: каталог не запрашивается")
+	}
+	for _, header := range cfg.Headers {
+		if _, _, err := registry.ParseHeader(header); err != nil {
+			return fmt.Errorf("некорректный --header %q: %v", header, err)
+		}
+	}
+	switch cfg.LogSink {
+	case "", LogSinkSyslog, LogSinkJournald:
+	default:
+		return fmt.Errorf("неизвестный журнал --log-sink %q, допустимо: syslog, journald", cfg.LogSink)
+	}
+	if cfg.SyslogAddr != "" && cfg.LogSink != LogSinkSyslog {
+		return fmt.Errorf("--syslog-addr требует --log-sink syslog")
+	}
+	if cfg.SentryDSN != "" {
+		if _, err := sentry.NewClient(cfg.SentryDSN); err != nil {
+			return fmt.Errorf("некорректный --sentry-dsn: %v", err)
+		}
+	}
+	if cfg.AlertDeleteFailures < -1 {
+		return fmt.Errorf("--alert-delete-failures не может быть меньше -1")
+	}
+	if _, ok := severityRank[cfg.NotifySeverity]; !ok {
+		return fmt.Errorf("неизвестный уровень --notify-severity %q, допустимо: info, warning, critical", cfg.NotifySeverity)
+	}
+	if cfg.ETagCacheFile != "" && cfg.Backend != BackendRegistry {
+		return fmt.Errorf("--etag-cache-file доступен только с --backend registry")
+	}
+	if cfg.VerifyDeletes && cfg.Backend != BackendRegistry {
+		return fmt.Errorf("--verify-deletes доступен только с --backend registry")
+	}
+	if cfg.VerifyDeleteDelay > 0 && !cfg.VerifyDeletes {
+		return fmt.Errorf("--verify-delete-delay требует --verify-deletes")
+	}
+	if cfg.GCDryRun && !cfg.storageGC() {
+		return fmt.Errorf("--gc-dry-run доступен только со сборкой в хранилище: --gc-fs-root, --gc-s3-bucket, --gc-azure-container или --gc-gcs-bucket")
+	}
+	if (len(cfg.GCReadOnlyEnable) == 0) != (len(cfg.GCReadOnlyDisable) == 0) {
+		return fmt.Errorf("--gc-readonly-enable и --gc-readonly-disable указываются вместе")
+	}
+	if len(cfg.GCReadOnlyEnable) > 0 && gcTargets == 0 {
+		return fmt.Errorf("--gc-readonly-enable требует способа garbage collection, например --gc-container")
+	}
+	if cfg.GCAzureContainer != "" && (cfg.GCAzureAccount == "" || cfg.GCAzureKey == "") {
+		return fmt.Errorf("для --gc-azure-container требуются --gc-azure-account и --gc-azure-key")
+	}
+	if cfg.Incremental != "" && cfg.StateFile == "" {
+		return fmt.Errorf("для --incremental необходимо указать --state-file")
+	}
+	if cfg.Managed && cfg.StateFile == "" {
+		return fmt.Errorf("для --managed необходимо указать --state-file")
+	}
+	if cfg.Adopt && !cfg.Managed {
+		return fmt.Errorf("--adopt используется только с --managed")
+	}
+	if cfg.LockTTL <= 0 {
+		return fmt.Errorf("--lock-ttl должен быть больше нуля")
+	}
+	return nil
+}
+
+// location возвращает часовой пояс --timezone или nil, если он не задан. Пояс проверен
+// в Validate
+func (cfg *Config) location() *time.Location {
+	if cfg.Timezone == "" {
+		return nil
+	}
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil
+	}
+	return loc
+}
+
+// storageGC сообщает, что garbage collection выполняется прямо в хранилище registry
+func (cfg *Config) storageGC() bool {
+	return cfg.GCFSRoot != "" || cfg.GCS3Bucket != "" || cfg.GCAzureContainer != "" || cfg.GCGCSBucket != ""
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"registryCleaner/pkg/cleanup"
+)
+
+// Confirmation ответ пользователя на запрос подтверждения
+type Confirmation int
+
+const (
+	ConfirmYes Confirmation = iota
+	ConfirmNo
+	ConfirmQuit
+)
+
+// Confirmer запрашивает у пользователя подтверждение удаления для каждого репозитория
+type Confirmer struct {
+	in  *bufio.Reader
+	out io.Writer
+	loc *time.Location
+	// all устанавливается после ответа "all", дальнейшие планы подтверждаются без вопросов
+	all bool
+}
+
+// NewConfirmer создает Confirmer, читающий ответы из in и пишущий вопросы в out.
+// Время создания образов выводится в часовом поясе loc, nil - в локальном
+func NewConfirmer(in io.Reader, out io.Writer, loc *time.Location) *Confirmer {
+	return &Confirmer{in: bufio.NewReader(in), out: out, loc: loc}
+}
+
+// Confirm показывает план удаления репозитория и спрашивает y/n/all/quit
+func (c *Confirmer) Confirm(plan *cleanup.RepositoryPlan) Confirmation {
+	if c.all {
+		return ConfirmYes
+	}
+
+	fmt.Fprintf(c.out, "\nРепозиторий %s: сохраняется %d, удаляется %d образов:\n",
+		plan.Repository, len(plan.Keep), len(plan.Delete))
+	for _, img := range plan.Delete {
+		fmt.Fprintf(c.out, "  - %s:%s (создан: %s)\n", img.Repository, img.Tag, cleanup.FormatTime(img.Created, c.loc))
+	}
+
+	for {
+		fmt.Fprintf(c.out, "Удалить? [y]es/[n]o/[a]ll/[q]uit: ")
+		answer, err := c.in.ReadString('\n')
+		if err != nil && answer == "" {
+			// Ввод закрыт, дальнейшее удаление без подтверждения недопустимо
+			fmt.Fprintln(c.out)
+			return ConfirmQuit
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes", "д", "да":
+			return ConfirmYes
+		case "n", "no", "н", "нет":
+			return ConfirmNo
+		case "a", "all", "все":
+			c.all = true
+			return ConfirmYes
+		case "q", "quit", "в", "выход":
+			return ConfirmQuit
+		}
+	}
+}
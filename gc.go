@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"registryCleaner/pkg/cleanup"
+	"registryCleaner/pkg/gc"
+)
+
+// gcSetup runner и шаблон команды garbage collection либо сборщик blob в хранилище,
+// а также команды перевода registry в режим только для чтения
+type gcSetup struct {
+	runner    gc.Runner
+	command   *gc.Command
+	collector *gc.StorageCollector
+	readOnly  *gc.ReadOnlyHook
+}
+
+// buildGC создает runner garbage collection, заданный в конфигурации,
+// или возвращает nil, если запуск garbage collection не настроен
+func buildGC(ctx context.Context, cfg *Config) (*gcSetup, error) {
+	if cfg.storageGC() {
+		storage, err := buildStorage(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &gcSetup{
+			collector: &gc.StorageCollector{
+				Storage:      storage,
+				GracePeriod:  gc.DefaultGracePeriod,
+				UploadMaxAge: gc.DefaultUploadMaxAge,
+				DryRun:       cfg.GCDryRun,
+			},
+			readOnly: buildReadOnlyHook(cfg),
+		}, nil
+	}
+	if cfg.GCContainer == "" && cfg.GCSSH == "" && cfg.GCK8sSelector == "" {
+		return nil, nil
+	}
+
+	command, err := gc.ParseCommand(cfg.GCCommand)
+	if err != nil {
+		return nil, err
+	}
+
+	var runner gc.Runner
+	switch {
+	case cfg.GCSSH != "":
+		runner, err = gc.NewSSHRunner(cfg.GCSSH, cfg.GCSSHKey, cfg.GCSSHKnownHosts)
+	case cfg.GCK8sSelector != "":
+		runner, err = gc.NewKubernetesRunner(cfg.Kubeconfig, cfg.GCK8sNamespace, cfg.GCK8sSelector, cfg.GCK8sContainer)
+	default:
+		runner, err = gc.NewDockerRunner(cfg.DockerHost, cfg.GCContainer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &gcSetup{runner: runner, command: command, readOnly: buildReadOnlyHook(cfg)}, nil
+}
+
+// buildReadOnlyHook возвращает команды перевода registry в режим только для чтения
+// или nil, если они не заданы
+func buildReadOnlyHook(cfg *Config) *gc.ReadOnlyHook {
+	if len(cfg.GCReadOnlyEnable) == 0 {
+		return nil
+	}
+	return &gc.ReadOnlyHook{
+		Enable:  append([]string(nil), cfg.GCReadOnlyEnable...),
+		Disable: append([]string(nil), cfg.GCReadOnlyDisable...),
+	}
+}
+
+// buildStorage создает хранилище registry для garbage collection без участия registry
+func buildStorage(ctx context.Context, cfg *Config) (gc.Storage, error) {
+	switch {
+	case cfg.GCS3Bucket != "":
+		return gc.NewS3Storage(ctx, cfg.GCS3Bucket, cfg.GCStorageRoot, cfg.GCStorageEndpoint)
+	case cfg.GCAzureContainer != "":
+		return gc.NewAzureStorage(cfg.GCAzureAccount, cfg.GCAzureKey, cfg.GCAzureContainer, cfg.GCStorageRoot, cfg.GCStorageEndpoint)
+	case cfg.GCGCSBucket != "":
+		return gc.NewGCSStorage(ctx, cfg.GCGCSBucket, cfg.GCStorageRoot, cfg.GCStorageEndpoint)
+	default:
+		return &gc.FilesystemStorage{Root: cfg.GCFSRoot}, nil
+	}
+}
+
+// newGCInfo собирает сведения об удалении для garbage collection по выполненным планам
+func newGCInfo(cfg *Config, executed []*cleanup.RepositoryPlan) gc.Info {
+	info := gc.Info{RegistryURL: cfg.RegistryURL}
+	for _, plan := range executed {
+		addGCInfo(&info, plan)
+	}
+	return info
+}
+
+// addGCInfo учитывает в сведениях для garbage collection выполненный план
+func addGCInfo(info *gc.Info, plan *cleanup.RepositoryPlan) {
+	if len(plan.Deleted) > 0 {
+		info.Deleted += len(plan.Deleted)
+		info.Repositories = append(info.Repositories, plan.Repository)
+	}
+}
+
+// runGC запускает garbage collection, если в ходе очистки были удалены образы,
+// выводит его результат и возвращает код выхода процесса
+func runGC(ctx context.Context, cfg *Config, setup *gcSetup, info gc.Info) int {
+	if info.Deleted == 0 {
+		fmt.Println("\nОбразы не удалялись, garbage collection не требуется")
+		return 0
+	}
+
+	// Пробный запуск ничего не удаляет, и переключать registry не нужно
+	if setup.readOnly == nil || (setup.collector != nil && setup.collector.DryRun) {
+		return executeGC(ctx, setup, info)
+	}
+
+	fmt.Println("\n🔒 Перевод registry в режим только для чтения")
+	output, err := setup.readOnly.EnableReadOnly(ctx)
+	printOutput(output)
+	if err != nil {
+		fmt.Printf("❌ Не удалось перевести registry в режим только для чтения: %v\n", err)
+		fmt.Println("Garbage collection не запускался")
+		// Команда могла успеть частично переключить registry
+		disableReadOnly(ctx, setup.readOnly)
+		return 1
+	}
+
+	code := executeGC(ctx, setup, info)
+	if !disableReadOnly(ctx, setup.readOnly) {
+		return 1
+	}
+	return code
+}
+
+// disableReadOnly возвращает registry в обычный режим даже после отмены контекста
+// и сообщает, удалось ли это
+func disableReadOnly(ctx context.Context, hook *gc.ReadOnlyHook) bool {
+	fmt.Println("\n🔓 Возврат registry в обычный режим")
+	output, err := hook.DisableReadOnly(context.WithoutCancel(ctx))
+	printOutput(output)
+	if err != nil {
+		fmt.Printf("❌ Не удалось вернуть registry в обычный режим, загрузка образов недоступна: %v\n", err)
+		return false
+	}
+	return true
+}
+
+// executeGC выполняет garbage collection и возвращает код выхода процесса
+func executeGC(ctx context.Context, setup *gcSetup, info gc.Info) int {
+	if setup.collector != nil {
+		return runStorageGC(ctx, setup.collector)
+	}
+
+	command, err := setup.command.Render(info)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("\n🧹 Запуск garbage collection: %s\n", command)
+	output, err := setup.runner.Run(ctx, command)
+	if output != "" {
+		fmt.Println("Вывод garbage collection:")
+		printOutput(output)
+	}
+	if err != nil {
+		fmt.Printf("❌ Ошибка garbage collection: %v\n", err)
+		return 1
+	}
+	fmt.Println("✅ Garbage collection завершен")
+	return 0
+}
+
+// runStorageGC удаляет blob без ссылок и брошенные загрузки прямо в хранилище registry
+// и возвращает код выхода процесса
+func runStorageGC(ctx context.Context, collector *gc.StorageCollector) int {
+	if collector.DryRun {
+		fmt.Printf("\n🔍 Поиск blob без ссылок в %s (--gc-dry-run, ничего не удаляется)\n", collector.Storage)
+	} else {
+		fmt.Printf("\n🧹 Поиск blob без ссылок в %s\n", collector.Storage)
+	}
+	result, err := collector.Collect(ctx)
+
+	verb, total := "Удалено", "Освобождено"
+	if collector.DryRun {
+		verb, total = "Будет удалено", "Будет освобождено"
+	}
+	fmt.Printf("  Манифестов в репозиториях: %d\n", result.Manifests)
+	if result.Recent > 0 {
+		fmt.Printf("  Пропущено свежих blob (моложе %s): %d\n", collector.GracePeriod, result.Recent)
+	}
+	fmt.Printf("  %s blob: %d, %s\n", verb, result.Removed, formatBytes(result.Bytes))
+	fmt.Printf("  %s брошенных загрузок: %d, %s\n", verb, result.Uploads, formatBytes(result.UploadBytes))
+	fmt.Printf("  %s всего: %s\n", total, formatBytes(result.Bytes+result.UploadBytes))
+	if err != nil {
+		fmt.Printf("❌ Ошибка garbage collection: %v\n", err)
+		return 1
+	}
+	fmt.Println("✅ Garbage collection завершен")
+	return 0
+}
+
+// printOutput выводит вывод внешней команды с отступом
+func printOutput(output string) {
+	if output == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		fmt.Printf("  %s\n", line)
+	}
+}
+
+// formatBytes форматирует размер в двоичных единицах
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
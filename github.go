@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// jobSummaryFailureLimit максимальное количество ошибок в сводке задания GitHub Actions
+const jobSummaryFailureLimit = 50
+
+// githubActions сообщает, запущена ли программа в GitHub Actions
+func githubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// writeAnnotation выводит после строки с ошибкой или предупреждением команду
+// ::error:: или ::warning::, по которой GitHub Actions отмечает строку в журнале и
+// показывает ее на странице запуска
+func writeAnnotation(w io.Writer, line string) {
+	var command string
+	switch linePriority(line) {
+	case priorityErr:
+		command = "error"
+	case priorityWarning:
+		command = "warning"
+	default:
+		return
+	}
+	fmt.Fprintf(w, "::%s::%s\n", command, escapeWorkflowData(strings.TrimSpace(line)))
+}
+
+// escapeWorkflowData экранирует текст команды GitHub Actions
+func escapeWorkflowData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// writeJobSummary дописывает итоги запуска в формате Markdown в сводку задания
+// GitHub Actions: файл из переменной окружения GITHUB_STEP_SUMMARY
+func writeJobSummary(cfg *Config, s *cleanupStats, failures []error) {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Очистка %s\n\n", urlHost(cfg.RegistryURL))
+	if s.Interrupted {
+		b.WriteString("> [!WARNING]\n> Очистка прервана, итоги неполные\n\n")
+	}
+	b.WriteString("| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Репозиториев | %d (обработано %d, пропущено %d, с ошибками %d) |\n", s.Repositories, s.Processed, s.Skipped, s.Failed)
+	fmt.Fprintf(&b, "| Тегов проверено | %d |\n", s.TagsExamined)
+	fmt.Fprintf(&b, "| Манифестов удалено | %d |\n", s.Deleted)
+	if s.DeleteFailed > 0 {
+		fmt.Fprintf(&b, "| Не удалось удалить | %d |\n", s.DeleteFailed)
+	}
+	if len(s.Held) > 0 {
+		fmt.Fprintf(&b, "| На удержании | %d |\n", len(s.Held))
+	}
+	fmt.Fprintf(&b, "| Освобождено (оценка) | %s |\n", formatBytes(s.BytesReclaimed))
+	fmt.Fprintf(&b, "| Ошибок | %d |\n", s.Failures)
+	fmt.Fprintf(&b, "| Длительность | %s |\n", (time.Duration(s.DurationSeconds * float64(time.Second))).Round(time.Millisecond))
+
+	if len(s.Alerts) > 0 {
+		b.WriteString("\n### 🚨 Превышены пороги оповещения\n\n")
+		for _, alert := range s.Alerts {
+			fmt.Fprintf(&b, "- %s\n", escapeMarkdown(alert))
+		}
+	}
+	if len(s.RepositoryStats) > 0 {
+		b.WriteString("\n### Освобождено по репозиториям\n\n| Репозиторий | Удалено | Освобождено |\n|---|---:|---:|\n")
+		for _, repo := range s.RepositoryStats {
+			fmt.Fprintf(&b, "| `%s` | %d | %s |\n", repo.Repository, repo.Deleted, formatBytes(repo.BytesReclaimed))
+		}
+	}
+	if len(s.Held) > 0 {
+		b.WriteString("\n### 🔒 Не удалены из-за удержания\n\n")
+		for _, img := range s.Held {
+			fmt.Fprintf(&b, "- `%s:%s`", img.Repository, img.Tag)
+			if img.Reason != "" {
+				fmt.Fprintf(&b, " (%s)", escapeMarkdown(img.Reason))
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(failures) > 0 {
+		b.WriteString("\n### ❌ Ошибки\n\n")
+		for i, err := range failures {
+			if i == jobSummaryFailureLimit {
+				fmt.Fprintf(&b, "- и еще %d\n", len(failures)-jobSummaryFailureLimit)
+				break
+			}
+			fmt.Fprintf(&b, "- %s\n", escapeMarkdown(err.Error()))
+		}
+	}
+	b.WriteString("\n")
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err == nil {
+		_, err = f.WriteString(b.String())
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Printf("Предупреждение: не удалось записать сводку задания GitHub Actions: %v\n", err)
+	}
+}
+
+// escapeMarkdown экранирует символы, которые сломали бы строку таблицы или списка Markdown
+func escapeMarkdown(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"registryCleaner/pkg/cleanerpb"
+)
+
+// grpcServer gRPC API очистки поверх того же Server, что и HTTP API,
+// поэтому запуски и их история у обоих API общие
+type grpcServer struct {
+	cleanerpb.UnimplementedCleanerServer
+	server *Server
+}
+
+// grpcOperatorMethods вызовы, требующие роли operator; остальным достаточно viewer
+var grpcOperatorMethods = map[string]bool{
+	cleanerpb.Cleaner_StartRun_FullMethodName: true,
+}
+
+// NewGRPCServer создает gRPC сервер с проверкой токена API
+func (s *Server) NewGRPCServer() *grpc.Server {
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := s.authorizeGRPC(ctx, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := s.authorizeGRPC(stream.Context(), info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	)
+	cleanerpb.RegisterCleanerServer(server, &grpcServer{server: s})
+	return server
+}
+
+// authorizeGRPC пропускает только вызовы с метаданными authorization: Bearer <токен>
+// роли, достаточной для метода method
+func (s *Server) authorizeGRPC(ctx context.Context, method string) error {
+	required := roleViewer
+	if grpcOperatorMethods[method] {
+		required = roleOperator
+	}
+	switch got := s.roleOf(grpcAuthorization(ctx)); {
+	case got == roleNone:
+		return status.Error(codes.Unauthenticated, "требуется токен API")
+	case got < required:
+		return status.Errorf(codes.PermissionDenied, "недостаточно прав: требуется роль %s", required)
+	}
+	return nil
+}
+
+// grpcAuthorization возвращает значение метаданных authorization вызова
+func grpcAuthorization(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// StartRun запускает очистку, если она еще не выполняется
+func (g *grpcServer) StartRun(_ context.Context, req *cleanerpb.StartRunRequest) (*cleanerpb.Run, error) {
+	run, err := g.server.start(req.GetRepositories())
+	if errors.Is(err, errRunInProgress) {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+	if errors.Is(err, errUnknownRepository) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, errNotLeader) {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return pbRun(run.summary(false)), nil
+}
+
+// ListRuns возвращает историю запусков, начиная с последнего
+func (g *grpcServer) ListRuns(context.Context, *cleanerpb.ListRunsRequest) (*cleanerpb.ListRunsResponse, error) {
+	g.server.mu.Lock()
+	runs := append([]*serverRun{}, g.server.runs...)
+	g.server.mu.Unlock()
+
+	resp := &cleanerpb.ListRunsResponse{}
+	for i := len(runs) - 1; i >= 0; i-- {
+		resp.Runs = append(resp.Runs, pbRun(runs[i].summary(false)))
+	}
+	return resp, nil
+}
+
+// GetRun возвращает запуск вместе с его выводом
+func (g *grpcServer) GetRun(_ context.Context, req *cleanerpb.GetRunRequest) (*cleanerpb.Run, error) {
+	run := g.server.findRun(int(req.GetId()))
+	if run == nil {
+		return nil, status.Error(codes.NotFound, "запуск не найден")
+	}
+	return pbRun(run.summary(true)), nil
+}
+
+// WatchRun передает строки вывода и обработку репозиториев по мере выполнения запуска,
+// а последним сообщением - итог запуска
+func (g *grpcServer) WatchRun(req *cleanerpb.WatchRunRequest, stream grpc.ServerStreamingServer[cleanerpb.RunEvent]) error {
+	run := g.server.findRun(int(req.GetId()))
+	if run == nil {
+		return status.Error(codes.NotFound, "запуск не найден")
+	}
+
+	sentLines, sentEvents := 0, 0
+	for {
+		lines, events, changed, done := run.since(sentLines, sentEvents)
+		for _, line := range lines {
+			if err := stream.Send(&cleanerpb.RunEvent{Event: &cleanerpb.RunEvent_Output{Output: line}}); err != nil {
+				return err
+			}
+		}
+		for _, event := range events {
+			if err := stream.Send(&cleanerpb.RunEvent{Event: &cleanerpb.RunEvent_Progress{Progress: pbProgress(event)}}); err != nil {
+				return err
+			}
+		}
+		sentLines += len(lines)
+		sentEvents += len(events)
+		if done {
+			return stream.Send(&cleanerpb.RunEvent{Event: &cleanerpb.RunEvent_Done{Done: pbRun(run.summary(false))}})
+		}
+
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// GetPlan возвращает план очистки без удаления образов
+func (g *grpcServer) GetPlan(ctx context.Context, _ *cleanerpb.GetPlanRequest) (*cleanerpb.GetPlanResponse, error) {
+	plans, _, err := g.server.plan(ctx, g.server.roleOf(grpcAuthorization(ctx)) == roleOperator)
+	if errors.Is(err, errPlanNotReady) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	resp := &cleanerpb.GetPlanResponse{}
+	for _, plan := range plans {
+		resp.Repositories = append(resp.Repositories, &cleanerpb.RepositoryPlan{
+			Repository: plan.Repository,
+			Error:      plan.Error,
+			Keep:       pbImages(plan.Keep),
+			Delete:     pbImages(plan.Delete),
+		})
+	}
+	return resp, nil
+}
+
+// pbRun преобразует сведения о запуске в сообщение gRPC
+func pbRun(s runSummary) *cleanerpb.Run {
+	run := &cleanerpb.Run{
+		Id:       int64(s.ID),
+		Started:  timestamppb.New(s.Started),
+		ExitCode: int32(s.ExitCode),
+		Output:   s.Output,
+	}
+	if s.Finished != nil {
+		run.Finished = timestamppb.New(*s.Finished)
+	}
+	switch s.Status {
+	case runRunning:
+		run.Status = cleanerpb.RunStatus_RUN_STATUS_RUNNING
+	case runSucceeded:
+		run.Status = cleanerpb.RunStatus_RUN_STATUS_SUCCEEDED
+	case runFailed:
+		run.Status = cleanerpb.RunStatus_RUN_STATUS_FAILED
+	}
+	return run
+}
+
+// pbProgress преобразует событие обработки репозитория в сообщение gRPC
+func pbProgress(event progressEvent) *cleanerpb.RepositoryProgress {
+	progress := &cleanerpb.RepositoryProgress{
+		Repository: event.Repository,
+		Index:      int32(event.Index),
+		Total:      int32(event.Total),
+		Keep:       int32(event.Keep),
+		Delete:     int32(event.Delete),
+		Deleted:    int32(event.Deleted),
+		Error:      event.Error,
+	}
+	switch event.Stage {
+	case stagePlanned:
+		progress.Stage = cleanerpb.ProgressStage_PROGRESS_STAGE_PLANNED
+	case stageExecuted:
+		progress.Stage = cleanerpb.ProgressStage_PROGRESS_STAGE_EXECUTED
+	}
+	return progress
+}
+
+// pbImages преобразует образы плана в сообщения gRPC
+func pbImages(images []apiImage) []*cleanerpb.Image {
+	result := make([]*cleanerpb.Image, 0, len(images))
+	for _, img := range images {
+		item := &cleanerpb.Image{Tag: img.Tag, Digest: img.Digest, Created: timestamppb.New(img.Created), Size: img.Size}
+		if img.LastPulled != nil {
+			item.LastPulled = timestamppb.New(*img.LastPulled)
+		}
+		result = append(result, item)
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"registryCleaner/pkg/cleanup"
+	"registryCleaner/pkg/jira"
+)
+
+// jiraLabel метка задач согласования планов, по ней находится уже открытая задача
+const jiraLabel = "registry-cleaner"
+
+// jiraTimeout ограничение времени обращения к Jira
+const jiraTimeout = time.Minute
+
+// requestApproval сохраняет план, превысивший пороги, в --out и открывает задачу Jira на
+// его согласование. Если открытая задача по этому registry уже есть, новый план
+// добавляется в нее комментарием. Образы не удаляются: после согласования план
+// выполняется подкомандой apply
+func requestApproval(cfg *Config, plans []*cleanup.RepositoryPlan, violations []string) int {
+	file := cleanup.NewPlanFile(cfg.RegistryURL, cfg.Backend, plans)
+	if err := file.Save(cfg.PlanOut); err != nil {
+		log.Printf("Ошибка сохранения плана: %v", err)
+		return 1
+	}
+
+	var report strings.Builder
+	report.WriteString("План очистки превышает допустимые пороги:\n")
+	for _, v := range violations {
+		fmt.Fprintf(&report, "* %s\n", v)
+	}
+	fmt.Fprintf(&report, "\nПлан сохранен в %s. После согласования выполните его:\n{noformat}registry-cleaner apply %s{noformat}\n",
+		cfg.PlanOut, cfg.PlanOut)
+	report.WriteString("\n{noformat}")
+	printPlanFile(&report, file, cfg.location())
+	report.WriteString("{noformat}\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), jiraTimeout)
+	defer cancel()
+	client := jira.NewClient(cfg.JiraURL, cfg.JiraUser, cfg.JiraToken)
+	host := urlHost(cfg.RegistryURL)
+	jql := fmt.Sprintf("project = %q AND labels = %q AND summary ~ %q AND statusCategory != Done", cfg.JiraProject, jiraLabel, host)
+	key, err := client.FindIssue(ctx, jql)
+	if err == nil && key != "" {
+		err = client.AddComment(ctx, key, "Составлен новый план, он заменил предыдущий.\n\n"+report.String())
+	} else if err == nil {
+		key, err = client.CreateIssue(ctx, jira.Issue{
+			Project:     cfg.JiraProject,
+			Type:        cfg.JiraIssueType,
+			Summary:     fmt.Sprintf("Согласование очистки %s: к удалению образов %d", host, file.DeleteCount()),
+			Description: report.String(),
+			Labels:      []string{jiraLabel},
+		})
+	}
+	if err != nil {
+		log.Printf("%v", err)
+		fmt.Printf("План сохранен в %s, но задача на согласование не открыта\n", cfg.PlanOut)
+		return 1
+	}
+
+	fmt.Printf("\n⏸  Удаление приостановлено до согласования плана: %s\n", client.IssueURL(key))
+	fmt.Printf("План сохранен в %s. После согласования выполните: registry-cleaner apply %s\n", cfg.PlanOut, cfg.PlanOut)
+	return 0
+}
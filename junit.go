@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// junitTestSuites корневой элемент отчета JUnit XML
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite запуск очистки одного registry
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase очистка одного репозитория
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// junitMessage сообщение о провале или пропуске теста
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit записывает итоги репозиториев в формате JUnit XML, чтобы CI показывал
+// частичные сбои очистки в своем интерфейсе тестов. Каждый репозиторий - тест, ошибки
+// его очистки - провал теста. Ошибки, не относящиеся к репозиторию, собираются в тест
+// "registry". Репозитории, в которых из-за прерывания или отказа в интерактивном режиме
+// ничего не удалялось, и репозитории без изменений с прошлой очистки отмечаются пропущенными
+func (s *cleanupStats) writeJUnit(path, host string, failures []error) error {
+	byRepository := make(map[string][]string)
+	var failed []string
+	for _, err := range failures {
+		repo := failureRepository(err)
+		if _, ok := byRepository[repo]; !ok {
+			failed = append(failed, repo)
+		}
+		byRepository[repo] = append(byRepository[repo], err.Error())
+	}
+
+	suite := junitTestSuite{
+		Name:      host,
+		Time:      fmt.Sprintf("%.3f", s.DurationSeconds),
+		Timestamp: s.Started.UTC().Format(time.RFC3339),
+	}
+	addCase := func(c junitTestCase, errs []string) {
+		if len(errs) > 0 {
+			c.Failure = &junitMessage{
+				Message: fmt.Sprintf("ошибок: %d", len(errs)),
+				Type:    "cleanup",
+				Text:    strings.Join(errs, "\n"),
+			}
+			c.Skipped = nil
+			suite.Failures++
+		} else if c.Skipped != nil {
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, c)
+		suite.Tests++
+	}
+
+	planned := make(map[string]bool)
+	for _, result := range s.results {
+		planned[result.Repository] = true
+		c := junitTestCase{Name: result.Repository, ClassName: host}
+		switch {
+		case result.Unchanged:
+			c.Skipped = &junitMessage{Message: "список тегов не изменился с прошлой очистки"}
+		case result.Delete > 0 && !result.Executed:
+			c.Skipped = &junitMessage{Message: "образы не удалялись"}
+		default:
+			c.SystemOut = fmt.Sprintf("Тегов: %d, сохранено: %d, к удалению: %d, удалено: %d",
+				result.Tags, result.Keep, result.Delete, result.Deleted)
+		}
+		addCase(c, byRepository[result.Repository])
+	}
+	// Репозитории, план которых составить не удалось, и общие ошибки
+	for _, repo := range failed {
+		if planned[repo] {
+			continue
+		}
+		name := repo
+		if repo == "-" {
+			name = "registry"
+		}
+		addCase(junitTestCase{Name: name, ClassName: host}, byRepository[repo])
+	}
+
+	report := junitTestSuites{
+		Name:     "registry-cleaner",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("ошибка записи отчета JUnit %s: %v", path, err)
+	}
+	return nil
+}
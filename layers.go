@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"registryCleaner/pkg/cleanup"
+	"registryCleaner/pkg/registry"
+)
+
+// runAnalyzeLayers выполняет подкоманду analyze-layers: собирает слои всех образов
+// registry и выводит самые распространенные общие слои и самые большие уникальные слои.
+// Отчет помогает решить, какие образы перевести на общий базовый образ
+func runAnalyzeLayers(args []string) int {
+	cfg := parseConfig(args)
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: %v\n", err)
+		return 2
+	}
+	defer startLogSink(cfg)()
+	defer startProfiling(cfg)()
+	defer reportPanic(cfg)
+
+	ctx := context.Background()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	client := newRegistryClient(cfg)
+	backend, err := buildBackend(ctx, cfg, client)
+	if err != nil {
+		log.Printf("Ошибка настройки backend: %v", err)
+		return 1
+	}
+	// Время из имени тега и политика не нужны: анализу нужны метаданные всех образов
+	planner := &cleanup.Planner{Backend: backend, Concurrency: cfg.Concurrency, Log: io.Discard}
+	if cfg.CacheFile != "" {
+		if planner.Cache, err = cleanup.LoadMetadataCache(cfg.CacheFile); err != nil {
+			log.Printf("Ошибка загрузки кэша: %v", err)
+			return 1
+		}
+	}
+
+	repositories, err := listRepositories(ctx, cfg, backend)
+	if err != nil {
+		log.Printf("Ошибка при получении списка репозиториев: %v", err)
+		return 1
+	}
+	repositories = cleanupRepositories(cfg, repositories)
+
+	var images []registry.ImageInfo
+	var failures []error
+	for _, repo := range repositories {
+		fmt.Printf("Анализ репозитория: %s\n", repo)
+		repoImages, tagErrs, err := planner.Images(ctx, repo)
+		if err != nil {
+			fmt.Printf("Ошибка при получении образов %s: %v\n", repo, err)
+			failures = append(failures, fmt.Errorf("%s: %w", repo, err))
+			continue
+		}
+		failures = append(failures, tagErrs...)
+		images = append(images, repoImages...)
+	}
+
+	if planner.Cache != nil {
+		if err := planner.Cache.Save(); err != nil {
+			fmt.Printf("Предупреждение: не удалось сохранить кэш: %v\n", err)
+		}
+	}
+	saveClientCaches(client)
+
+	printLayerReport(cleanup.AnalyzeLayers(images), cfg.LayersTop)
+	if len(failures) > 0 {
+		printFailures(failures)
+		return 1
+	}
+	return 0
+}
+
+// printLayerReport выводит отчет о слоях
+func printLayerReport(report *cleanup.LayerReport, top int) {
+	fmt.Printf("\n🧱 Слои образов registry\n")
+	fmt.Printf("  Образов: %d, различных слоев: %d\n", report.Images, len(report.Layers))
+	fmt.Printf("  Занято слоями: %s, без дедупликации было бы %s\n", formatBytes(report.StoredSize), formatBytes(report.ReferencedSize))
+	fmt.Printf("  Уникальные слои (принадлежат одному образу): %s\n", formatBytes(report.UniqueSize()))
+	if report.ImagesWithoutLayers > 0 {
+		fmt.Printf("  ⚠️  Образов, слои которых backend не сообщает: %d, они не учтены\n", report.ImagesWithoutLayers)
+	}
+
+	shared := report.Shared(top)
+	fmt.Printf("\nСамые распространенные общие слои:\n")
+	if len(shared) == 0 {
+		fmt.Println("  нет")
+	}
+	for _, layer := range shared {
+		fmt.Printf("  %s  %s  образов %d, репозиториев %d, сэкономлено %s\n", cleanup.ShortDigest(layer.Digest), formatBytes(layer.Size),
+			layer.Images, len(layer.Repositories), formatBytes(layer.Saved()))
+		fmt.Printf("      %s\n", strings.Join(layer.Repositories, ", "))
+	}
+
+	unique := report.Unique(top)
+	fmt.Printf("\nСамые большие уникальные слои:\n")
+	if len(unique) == 0 {
+		fmt.Println("  нет")
+	}
+	for _, layer := range unique {
+		fmt.Printf("  %s  %s  %s\n", cleanup.ShortDigest(layer.Digest), formatBytes(layer.Size), layer.Example)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Сроки выбора лидера, как у контроллеров Kubernetes
+const (
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+	// stopGrace время на завершение очистки по SIGTERM после потери лидерства. Лидерство
+	// теряется, когда Lease не удалось продлить за renewDeadline, а другой экземпляр может
+	// захватить его по истечении leaseDuration: очистка должна завершиться раньше
+	stopGrace = leaseDuration - renewDeadline - retryPeriod
+)
+
+// newLeaderElector создает выбор лидера среди реплик serve через Lease Kubernetes.
+// Учетные данные берутся из kubeconfig или сервисного аккаунта пода, имя экземпляра -
+// из POD_NAME или имени хоста. Потеряв лидерство, экземпляр завершает выполняющуюся
+// очистку до того, как Lease сможет захватить другой экземпляр, чтобы ее не выполняли
+// одновременно два экземпляра
+func newLeaderElector(cfg *Config, server *Server) (*leaderelection.LeaderElector, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = cfg.Kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{})
+
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки конфигурации Kubernetes: %v", err)
+	}
+	namespace := cfg.LeaderElectNamespace
+	if namespace == "" {
+		if namespace, _, err = clientConfig.Namespace(); err != nil {
+			return nil, fmt.Errorf("ошибка определения пространства имен Kubernetes: %v", err)
+		}
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания клиента Kubernetes: %v", err)
+	}
+
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		if identity, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("не удалось определить имя экземпляра: %v", err)
+		}
+	}
+
+	return leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Name: cfg.LeaderElectLease, Namespace: namespace},
+			Client:     client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+		},
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Name:            cfg.LeaderElectLease,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {
+				log.Printf("Экземпляр %s стал лидером, запуск очистки разрешен", identity)
+			},
+			OnStoppedLeading: func() {
+				log.Printf("Экземпляр %s больше не лидер, выполняющаяся очистка прерывается", identity)
+				server.terminate(stopGrace)
+			},
+			OnNewLeader: func(leader string) {
+				if leader != identity {
+					log.Printf("Лидер: %s, экземпляр %s в резерве", leader, identity)
+				}
+			},
+		},
+	})
+}
+
+// runLeaderElection участвует в выборе лидера, пока не отменен ctx; после потери
+// лидерства экземпляр снова ждет своей очереди. При отмене ctx Lease освобождается
+func runLeaderElection(ctx context.Context, elector *leaderelection.LeaderElector) {
+	for ctx.Err() == nil {
+		elector.Run(ctx)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"log/syslog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Допустимые значения --log-sink
+const (
+	LogSinkSyslog   = "syslog"
+	LogSinkJournald = "journald"
+)
+
+// journaldSocket сокет нативного протокола journald
+const journaldSocket = "/run/systemd/journal/socket"
+
+// Приоритеты syslog, с которыми передаются строки вывода
+const (
+	priorityErr     = 3
+	priorityWarning = 4
+	priorityInfo    = 6
+)
+
+// logSink получатель строк вывода с приоритетом
+type logSink interface {
+	write(priority int, line string) error
+	Close() error
+}
+
+// startLogSink дублирует вывод программы в syslog или journald, заданные --log-sink.
+// Вывод по-прежнему попадает в stdout и stderr, а в журнал передается построчно с
+// приоритетом по содержимому строки. В GitHub Actions ошибки и предупреждения вывода
+// дополнительно отмечаются аннотациями. Возвращаемая функция дожидается передачи всего
+// вывода, ее нужно вызвать перед выходом
+func startLogSink(cfg *Config) func() {
+	annotate := githubActions()
+	if cfg.LogSink == "" && !annotate {
+		return func() {}
+	}
+	var sink logSink
+	if cfg.LogSink != "" {
+		var err error
+		if sink, err = openLogSink(cfg); err != nil {
+			log.Printf("Предупреждение: не удалось подключиться к %s: %v", cfg.LogSink, err)
+			if !annotate {
+				return func() {}
+			}
+		}
+	}
+
+	stdout, stderr := os.Stdout, os.Stderr
+	var wg sync.WaitGroup
+	tee := func(original *os.File) *os.File {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return original
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			forwardLines(r, original, sink, annotate)
+		}()
+		return w
+	}
+	os.Stdout, os.Stderr = tee(stdout), tee(stderr)
+	log.SetOutput(os.Stderr)
+
+	return func() {
+		log.SetOutput(stderr)
+		if os.Stdout != stdout {
+			os.Stdout.Close()
+		}
+		if os.Stderr != stderr {
+			os.Stderr.Close()
+		}
+		wg.Wait()
+		os.Stdout, os.Stderr = stdout, stderr
+		if sink != nil {
+			sink.Close()
+		}
+	}
+}
+
+// forwardLines сразу передает прочитанное в original, чтобы запросы подтверждения без
+// перевода строки были видны, а в журнал - каждую законченную строку. С annotate после
+// каждой законченной строки в original выводится ее аннотация GitHub Actions
+func forwardLines(r io.ReadCloser, original io.Writer, sink logSink, annotate bool) {
+	defer r.Close()
+	var pending []byte
+	// written сколько байт незаконченной строки уже выведено
+	written := 0
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(chunk)
+		pending = append(pending, chunk[:n]...)
+		for {
+			i := bytes.IndexByte(pending, '\n')
+			if i < 0 {
+				break
+			}
+			original.Write(pending[written : i+1])
+			written = 0
+			line := string(pending[:i])
+			if annotate {
+				writeAnnotation(original, line)
+			}
+			writeLogLine(sink, line)
+			pending = pending[i+1:]
+		}
+		original.Write(pending[written:])
+		written = len(pending)
+		if err != nil {
+			writeLogLine(sink, string(pending))
+			return
+		}
+	}
+}
+
+// writeLogLine передает в журнал непустую строку; ошибки журнала не мешают очистке
+func writeLogLine(sink logSink, line string) {
+	if sink == nil || strings.TrimSpace(line) == "" {
+		return
+	}
+	sink.write(linePriority(line), line)
+}
+
+// linePriority определяет приоритет строки вывода по ее отметкам: ошибки и остановки
+// передаются как err, предупреждения как warning, остальное как info
+func linePriority(line string) int {
+	for _, marker := range []string{"Ошибка", "❌", "⛔", "🚨"} {
+		if strings.Contains(line, marker) {
+			return priorityErr
+		}
+	}
+	for _, marker := range []string{"Предупреждение", "⚠️"} {
+		if strings.Contains(line, marker) {
+			return priorityWarning
+		}
+	}
+	return priorityInfo
+}
+
+// openLogSink подключается к журналу из --log-sink. Если journald недоступен, вывод
+// передается в локальный syslog
+func openLogSink(cfg *Config) (logSink, error) {
+	if cfg.LogSink == LogSinkJournald {
+		sink, err := openJournald(cfg.SyslogTag)
+		if err == nil {
+			return sink, nil
+		}
+		log.Printf("Предупреждение: journald недоступен (%v), вывод передается в syslog", err)
+		return openSyslog("", cfg.SyslogTag)
+	}
+	return openSyslog(cfg.SyslogAddr, cfg.SyslogTag)
+}
+
+// syslogSink передает строки в syslog
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+// openSyslog подключается к syslog по адресу вида udp://host:514 или tcp://host:514,
+// пустой адрес - локальный syslog
+func openSyslog(addr, tag string) (*syslogSink, error) {
+	network, raddr := "", ""
+	if addr != "" {
+		u, err := url.Parse(addr)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("некорректный адрес syslog %q", addr)
+		}
+		network, raddr = u.Scheme, u.Host
+	}
+	w, err := syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{w: w}, nil
+}
+
+func (s *syslogSink) write(priority int, line string) error {
+	switch priority {
+	case priorityErr:
+		return s.w.Err(line)
+	case priorityWarning:
+		return s.w.Warning(line)
+	default:
+		return s.w.Info(line)
+	}
+}
+
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
+
+// journaldSink передает строки в journald по нативному протоколу
+type journaldSink struct {
+	conn *net.UnixConn
+	tag  string
+}
+
+// openJournald подключается к сокету journald
+func openJournald(tag string) (*journaldSink, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journaldSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &journaldSink{conn: conn, tag: tag}, nil
+}
+
+func (s *journaldSink) write(priority int, line string) error {
+	// Строка вывода не содержит переводов строки, поэтому поля передаются в простом
+	// текстовом формате
+	message := fmt.Sprintf("PRIORITY=%d\nSYSLOG_IDENTIFIER=%s\nMESSAGE=%s\n", priority, s.tag, line)
+	_, err := s.conn.Write([]byte(message))
+	return err
+}
+
+func (s *journaldSink) Close() error {
+	return s.conn.Close()
+}
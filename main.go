@@ -1,401 +1,871 @@
 package main
 
 import (
-	"encoding/json"
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
-	"sort"
+	"slices"
 	"strings"
 	"time"
-)
-
-// RegistryClient структура для работы с Docker Registry
-type RegistryClient struct {
-	BaseURL  string
-	Username string
-	Password string
-	Client   *http.Client
-}
 
-// RepositoriesResponse структура ответа со списком репозиториев
-type RepositoriesResponse struct {
-	Repositories []string `json:"repositories"`
-}
+	"golang.org/x/time/rate"
 
-// TagsResponse структура ответа со списком тегов
-type TagsResponse struct {
-	Name string   `json:"name"`
-	Tags []string `json:"tags"`
-}
-
-// ManifestResponse структура ответа с манифестом
-type ManifestResponse struct {
-	SchemaVersion int `json:"schemaVersion"`
-	History       []struct {
-		V1Compatibility string `json:"v1Compatibility"`
-	} `json:"history"`
-}
+	"registryCleaner/pkg/cleanup"
+	"registryCleaner/pkg/gc"
+	"registryCleaner/pkg/nexus"
+	"registryCleaner/pkg/registry"
+)
 
-// ManifestV2Response структура ответа с манифестом v2
-type ManifestV2Response struct {
-	SchemaVersion int    `json:"schemaVersion"`
-	MediaType     string `json:"mediaType"`
-	Config        struct {
-		Digest string `json:"digest"`
-	} `json:"config"`
-}
+func main() {
+	// Подкоманда selftest проверяет очистку на встроенном registry
+	if len(os.Args) > 1 && os.Args[1] == "selftest" {
+		os.Exit(runSelftest(os.Args[2:]))
+	}
+	// Подкоманда plan составляет план без удаления, show выводит сохраненный план,
+	// apply выполняет его, diff сравнивает планы
+	if len(os.Args) > 1 && os.Args[1] == "plan" {
+		os.Exit(runPlanCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "show" {
+		os.Exit(runShowCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "apply" {
+		os.Exit(runApplyCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		os.Exit(runDiffCommand(os.Args[2:]))
+	}
+	// Подкоманда preflight проверяет доступность registry и поддержку удаления
+	if len(os.Args) > 1 && os.Args[1] == "preflight" {
+		os.Exit(runPreflightCommand(os.Args[2:]))
+	}
+	// Подкоманда verify проверяет целостность манифестов и blob
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		os.Exit(runVerifyCommand(os.Args[2:]))
+	}
+	// Подкоманда analyze-layers выводит отчет о слоях, общих для образов registry
+	if len(os.Args) > 1 && os.Args[1] == "analyze-layers" {
+		os.Exit(runAnalyzeLayers(os.Args[2:]))
+	}
+	// Подкоманда purge-quarantine удаляет образы, срок карантина которых истек
+	if len(os.Args) > 1 && os.Args[1] == "purge-quarantine" {
+		os.Exit(runPurgeQuarantine(os.Args[2:]))
+	}
+	// Подкоманда state выводит репозитории под управлением из файла состояния
+	if len(os.Args) > 1 && os.Args[1] == "state" {
+		os.Exit(runStateCommand(os.Args[2:]))
+	}
+	// Подкоманда serve запускает HTTP API для управления очисткой
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		os.Exit(runServe(os.Args[2:]))
+	}
 
-// ConfigResponse структура ответа с конфигурацией образа
-type ConfigResponse struct {
-	Created time.Time `json:"created"`
-	Config  struct {
-		Labels map[string]string `json:"Labels"`
-	} `json:"config"`
+	// Получаем параметры из флагов или переменных окружения
+	cfg := parseConfig(os.Args[1:])
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: %v\n", err)
+		os.Exit(2)
+	}
+	os.Exit(run(cfg))
 }
 
-// V1Compatibility структура для парсинга v1 совместимости
-type V1Compatibility struct {
-	Created time.Time `json:"created"`
-}
+// run выполняет очистку и возвращает код выхода процесса
+func run(cfg *Config) int {
+	defer startLogSink(cfg)()
+	defer startProfiling(cfg)()
+	defer reportPanic(cfg)
+	started := time.Now()
+	ctx := context.Background()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
 
-// ImageInfo информация об образе
-type ImageInfo struct {
-	Repository string
-	Tag        string
-	Digest     string
-	Created    time.Time
-}
+	fmt.Printf("🐳 Docker Registry Cleaner\n")
+	fmt.Printf("Подключение к Docker Registry: %s\n", cfg.RegistryURL)
 
-// NewRegistryClient создает новый клиент для работы с Registry
-func NewRegistryClient(baseURL, username, password string) *RegistryClient {
-	return &RegistryClient{
-		BaseURL:  strings.TrimSuffix(baseURL, "/"),
-		Username: username,
-		Password: password,
-		Client:   &http.Client{Timeout: 30 * time.Second},
+	policy, closePolicy, err := buildPolicy(ctx, cfg)
+	if err != nil {
+		return failRun(cfg, "Ошибка загрузки политики: %v", err)
 	}
-}
+	defer closePolicy()
 
-// makeRequest выполняет HTTP запрос с аутентификацией
-func (rc *RegistryClient) makeRequest(method, url string) (*http.Response, error) {
-	req, err := http.NewRequest(method, url, nil)
+	gcSetup, err := buildGC(ctx, cfg)
 	if err != nil {
-		return nil, err
+		return failRun(cfg, "Ошибка настройки garbage collection: %v", err)
 	}
 
-	if rc.Username != "" && rc.Password != "" {
-		req.SetBasicAuth(rc.Username, rc.Password)
+	client := newRegistryClient(cfg)
+	backend, err := buildBackend(ctx, cfg, client)
+	if err != nil {
+		return failRun(cfg, "Ошибка настройки backend: %v", err)
 	}
-
-	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json")
-
-	return rc.Client.Do(req)
-}
-
-// GetRepositories получает список всех репозиториев
-func (rc *RegistryClient) GetRepositories() ([]string, error) {
-	url := fmt.Sprintf("%s/v2/_catalog", rc.BaseURL)
-	resp, err := rc.makeRequest("GET", url)
+	planner, closePlanner, err := newPlanner(ctx, cfg, backend, policy, os.Stdout)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при получении списка репозиториев: %v", err)
+		return failRun(cfg, "Ошибка настройки очистки: %v", err)
 	}
-	defer resp.Body.Close()
+	defer closePlanner()
+	tenants := planner.Tenants
+	shutdown := NewShutdown()
+	executor := newExecutor(cfg, client, backend, shutdown)
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("получен статус %d при запросе репозиториев", resp.StatusCode)
+	var state *cleanup.State
+	if cfg.StateFile != "" {
+		var err error
+		state, err = cleanup.LoadState(cfg.StateFile)
+		if err != nil {
+			return failRun(cfg, "Ошибка загрузки состояния: %v", err)
+		}
+		planner.State = state
+		planner.Incremental = cfg.Incremental
+		executor.State = state
 	}
 
-	var repoResp RepositoriesResponse
-	if err := json.NewDecoder(resp.Body).Decode(&repoResp); err != nil {
-		return nil, fmt.Errorf("ошибка декодирования ответа: %v", err)
+	// Захватываем блокировки, чтобы не выполнять очистку одновременно с другим экземпляром
+	ctx, release, ok := acquireLocks(ctx, cfg, client)
+	if !ok {
+		return 1
 	}
+	defer release()
 
-	return repoResp.Repositories, nil
-}
-
-// GetTags получает список тегов для репозитория
-func (rc *RegistryClient) GetTags(repository string) ([]string, error) {
-	url := fmt.Sprintf("%s/v2/%s/tags/list", rc.BaseURL, repository)
-	resp, err := rc.makeRequest("GET", url)
+	// Получаем список всех репозиториев
+	repositories, err := listRepositories(ctx, cfg, backend)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при получении тегов для %s: %v", repository, err)
+		return failRun(cfg, "Ошибка при получении списка репозиториев: %v", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("получен статус %d при запросе тегов для %s", resp.StatusCode, repository)
+	repositories = cleanupRepositories(cfg, repositories)
+	if cfg.Managed {
+		repositories = managedRepositories(cfg, state, tenants, repositories)
 	}
 
-	var tagsResp TagsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tagsResp); err != nil {
-		return nil, fmt.Errorf("ошибка декодирования тегов: %v", err)
+	// Продолжаем прерванный запуск, пропуская уже обработанные репозитории
+	if state != nil && !cfg.Restart {
+		if checkpoint := state.InterruptedCheckpoint(cfg.RegistryURL); checkpoint != nil {
+			fmt.Printf("Продолжаем прерванный запуск от %s: обработано %d репозиториев, удалено %d образов\n",
+				cleanup.FormatTime(checkpoint.Started, cfg.location()), len(checkpoint.Completed), checkpoint.TotalDeleted())
+			var remaining []string
+			for _, repo := range repositories {
+				if !checkpoint.IsCompleted(repo) {
+					remaining = append(remaining, repo)
+				}
+			}
+			repositories = remaining
+		}
 	}
 
-	return tagsResp.Tags, nil
-}
-
-// GetManifestDigest получает digest манифеста
-func (rc *RegistryClient) GetManifestDigest(repository, tag string) (string, error) {
-	url := fmt.Sprintf("%s/v2/%s/manifests/%s", rc.BaseURL, repository, tag)
-	resp, err := rc.makeRequest("HEAD", url)
-	if err != nil {
-		return "", fmt.Errorf("ошибка при получении манифеста для %s:%s: %v", repository, tag, err)
+	if len(repositories) == 0 {
+		fmt.Println("Репозитории не найдены")
+		return 0
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("получен статус %d при запросе манифеста для %s:%s", resp.StatusCode, repository, tag)
+	fmt.Printf("Найдено %d репозиториев\n", len(repositories))
+	// Репозитории без прав доступа пропускаются с ошибкой в итоговой сводке
+	targeted := len(repositories)
+	repositories, accessFailures, ok := checkPreflight(ctx, cfg, client, repositories)
+	if !ok {
+		printFailures(accessFailures)
+		return failRun(cfg, "Registry недоступен для очистки: проверка перед удалением не пройдена")
+	}
+	if cfg.FailFast && len(accessFailures) > 0 {
+		printFailures(accessFailures)
+		return 1
+	}
+	if cfg.Order == cleanup.OrderLargest {
+		fmt.Println("Оценка размера репозиториев по количеству тегов")
+		repositories = cleanup.SortBySize(ctx, backend, repositories, cfg.Concurrency)
+	}
+
+	if cfg.Stream {
+		run := &streamRun{
+			cfg: cfg, client: client, backend: backend, planner: planner, executor: executor,
+			state: state, shutdown: shutdown, gcSetup: gcSetup, started: started, targeted: targeted,
+		}
+		return runStream(ctx, run, repositories, accessFailures)
+	}
+
+	// Составляем план очистки для каждого репозитория. Ошибки не прерывают очистку
+	// без --fail-fast, а собираются для итоговой сводки
+	progress := openProgress()
+	var plans []*cleanup.RepositoryPlan
+	failures := accessFailures
+	planFailed := len(accessFailures)
+	for i, repo := range repositories {
+		if shutdown.Requested() || ctx.Err() != nil {
+			break
+		}
+		event := progressEvent{Repository: repo, Stage: stagePlanned, Index: i + 1, Total: len(repositories)}
+		plan, err := planner.Plan(ctx, repo)
+		if err != nil {
+			fmt.Printf("Ошибка при очистке репозитория %s: %v\n", repo, err)
+			failures = append(failures, fmt.Errorf("%s: %w", repo, err))
+			planFailed++
+			event.Error = err.Error()
+			progress.report(event)
+			if cfg.FailFast {
+				break
+			}
+			continue
+		}
+		failures = append(failures, plan.Errors...)
+		event.Keep, event.Delete = len(plan.Keep), len(plan.Delete)
+		progress.report(event)
+		plans = append(plans, plan)
+		if cfg.FailFast && len(plan.Errors) > 0 {
+			break
+		}
 	}
 
-	digest := resp.Header.Get("Docker-Content-Digest")
-	if digest == "" {
-		return "", fmt.Errorf("digest не найден для %s:%s", repository, tag)
+	if planner.Cache != nil {
+		if err := planner.Cache.Save(); err != nil {
+			fmt.Printf("Предупреждение: не удалось сохранить кэш: %v\n", err)
+		}
 	}
+	if planner.SoftDelete != nil {
+		if err := planner.SoftDelete.Save(); err != nil {
+			fmt.Printf("Предупреждение: не удалось сохранить отметки удаления: %v\n", err)
+		}
+	}
+	saveClientCaches(client)
 
-	return digest, nil
-}
-
-// GetImageCreated получает время создания образа из манифеста
-func (rc *RegistryClient) GetImageCreated(repository, tag string) (time.Time, error) {
-	url := fmt.Sprintf("%s/v2/%s/manifests/%s", rc.BaseURL, repository, tag)
+	if cfg.FailFast && len(failures) > 0 {
+		fmt.Println("\n⛔ Очистка остановлена после ошибки (--fail-fast), образы не удалялись")
+		reportSummary(cfg, newCleanupStats(started, targeted, planFailed, plans, nil, nil, failures), failures, true)
+		return 1
+	}
 
-	// Сначала пробуем получить манифест v1
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return time.Time{}, err
+	// Сначала очищаем репозитории, где освободится больше всего места
+	if cfg.Order == cleanup.OrderLargest {
+		cleanup.SortPlansBySize(plans)
 	}
 
-	if rc.Username != "" && rc.Password != "" {
-		req.SetBasicAuth(rc.Username, rc.Password)
+	// Проверяем пороги до удаления чего-либо
+	guard := &cleanup.DeleteGuard{MaxPercent: cfg.MaxDeletePercent, MaxCount: cfg.MaxDeleteCount}
+	if violations := guard.Check(plans); len(violations) > 0 {
+		fmt.Printf("\n🚨 План очистки превышает допустимые пороги:\n")
+		for _, v := range violations {
+			fmt.Printf("  - %s\n", v)
+		}
+		if !cfg.Force && cfg.JiraURL != "" {
+			return requestApproval(cfg, plans, violations)
+		}
+		if !cfg.Force {
+			fmt.Printf("Удаление отменено. Проверьте политику очистки или запустите с --force\n")
+			return 1
+		}
+		fmt.Printf("Указан --force, продолжаем удаление\n\n")
 	}
 
-	// Пробуем получить v1 манифест
-	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v1+json")
-	resp, err := rc.Client.Do(req)
-	if err != nil {
-		return time.Time{}, fmt.Errorf("ошибка при получении манифеста для %s:%s: %v", repository, tag, err)
+	var confirmer *Confirmer
+	if cfg.Interactive {
+		confirmer = NewConfirmer(os.Stdin, os.Stdout, cfg.location())
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
-		var manifest ManifestResponse
-		if err := json.NewDecoder(resp.Body).Decode(&manifest); err == nil && len(manifest.History) > 0 {
-			var v1Compat V1Compatibility
-			if err := json.Unmarshal([]byte(manifest.History[0].V1Compatibility), &v1Compat); err == nil {
-				return v1Compat.Created, nil
+	// Запоминаем прогресс, чтобы прерванный запуск можно было продолжить
+	if state != nil {
+		checkpoint := state.InterruptedCheckpoint(cfg.RegistryURL)
+		if checkpoint == nil || cfg.Restart {
+			if err := state.StartCheckpoint(cfg.RegistryURL); err != nil {
+				fmt.Printf("Предупреждение: не удалось сохранить прогресс: %v\n", err)
 			}
 		}
 	}
 
-	// Если v1 не сработал, пробуем v2
-	req, err = http.NewRequest("GET", url, nil)
-	if err != nil {
-		return time.Time{}, err
+	// Очищаем каждый репозиторий
+	var executed []*cleanup.RepositoryPlan
+	deleteFailed := make(map[string]int)
+execute:
+	for i, plan := range plans {
+		if shutdown.Requested() || ctx.Err() != nil {
+			break
+		}
+		if confirmer != nil && len(plan.Delete) > 0 {
+			switch confirmer.Confirm(plan) {
+			case ConfirmNo:
+				fmt.Printf("Репозиторий %s пропущен\n", plan.Repository)
+				continue
+			case ConfirmQuit:
+				fmt.Println("Удаление прервано пользователем")
+				break execute
+			}
+		}
+		executed = append(executed, plan)
+		err := executor.Execute(ctx, plan)
+		event := progressEvent{
+			Repository: plan.Repository, Stage: stageExecuted, Index: i + 1, Total: len(plans),
+			Keep: len(plan.Keep), Delete: len(plan.Delete), Deleted: len(plan.Deleted),
+		}
+		if err != nil {
+			event.Error = err.Error()
+			failed := deleteFailures(err)
+			deleteFailed[plan.Repository] = len(failed)
+			failures = append(failures, failed...)
+		}
+		progress.report(event)
+		if errors.Is(err, cleanup.ErrInterrupted) {
+			// Репозиторий обработан не полностью и будет продолжен при следующем запуске
+			break
+		}
+		if err == nil && state != nil {
+			state.RecordRepository(plan, repositoryPolicy(cfg, tenants, plan.Repository))
+		}
+		if state != nil {
+			if err := state.CheckpointRepository(plan.Repository); err != nil {
+				fmt.Printf("Предупреждение: не удалось сохранить прогресс: %v\n", err)
+			}
+		}
+		if err != nil && cfg.FailFast {
+			break
+		}
 	}
 
-	if rc.Username != "" && rc.Password != "" {
-		req.SetBasicAuth(rc.Username, rc.Password)
+	stoppedOnError := cfg.FailFast && len(failures) > 0
+	if shutdown.Requested() || ctx.Err() != nil || stoppedOnError {
+		switch {
+		case stoppedOnError:
+			fmt.Println("\n⛔ Очистка остановлена после ошибки (--fail-fast)")
+		case ctx.Err() != nil:
+			fmt.Printf("\n⛔ Превышено время работы (--timeout %s)\n", cfg.Timeout)
+		}
+		printInterruptedSummary(len(repositories), plans, executed)
+		reportSummary(cfg, newCleanupStats(started, targeted, planFailed, plans, executed, deleteFailed, failures), failures, true)
+		if state != nil {
+			if err := state.Save(); err != nil {
+				fmt.Printf("Предупреждение: не удалось сохранить состояние: %v\n", err)
+			} else {
+				fmt.Printf("Прогресс сохранен в %s, следующий запуск продолжит очистку\n", cfg.StateFile)
+			}
+		}
+		if shutdown.Requested() && !stoppedOnError {
+			return shutdown.ExitCode()
+		}
+		return 1
 	}
 
-	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json")
-	resp, err = rc.Client.Do(req)
-	if err != nil {
-		return time.Time{}, fmt.Errorf("ошибка при получении v2 манифеста для %s:%s: %v", repository, tag, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusOK {
-		var manifestV2 ManifestV2Response
-		if err := json.NewDecoder(resp.Body).Decode(&manifestV2); err == nil && manifestV2.Config.Digest != "" {
-			// Получаем конфигурацию образа
-			configURL := fmt.Sprintf("%s/v2/%s/blobs/%s", rc.BaseURL, repository, manifestV2.Config.Digest)
-			configResp, err := rc.makeRequest("GET", configURL)
-			if err == nil {
-				defer configResp.Body.Close()
-				if configResp.StatusCode == http.StatusOK {
-					var config ConfigResponse
-					if err := json.NewDecoder(configResp.Body).Decode(&config); err == nil {
-						return config.Created, nil
-					}
-				}
-			}
+	if state != nil {
+		state.FinishCheckpoint()
+		if err := state.Save(); err != nil {
+			fmt.Printf("Предупреждение: не удалось сохранить состояние: %v\n", err)
 		}
 	}
 
-	// Если ничего не получилось, возвращаем текущее время как fallback
-	fmt.Printf("  Предупреждение: не удалось получить время создания для %s:%s, используем текущее время\n", repository, tag)
-	return time.Now(), nil
+	if len(failures) > 0 {
+		fmt.Println("\n⚠️  Очистка завершена с ошибками")
+	} else {
+		fmt.Println("\n✅ Очистка завершена!")
+	}
+	code := finishCleanup(ctx, cfg, backend, gcSetup, newGCInfo(cfg, executed))
+	reportSummary(cfg, newCleanupStats(started, targeted, planFailed, plans, executed, deleteFailed, failures), failures, false)
+	if len(failures) > 0 {
+		return 1
+	}
+	return code
 }
 
-// DeleteManifest удаляет манифест по digest
-func (rc *RegistryClient) DeleteManifest(repository, digest string) error {
-	url := fmt.Sprintf("%s/v2/%s/manifests/%s", rc.BaseURL, repository, digest)
+// reportSummary выводит итоги и ошибки запуска, сохраняет итоги в --summary-file и
+// --textfile-dir и отправляет уведомления
+func reportSummary(cfg *Config, summary *cleanupStats, failures []error, interrupted bool) {
+	summary.Interrupted = interrupted
+	summary.Alerts = checkAlerts(cfg, summary)
+	summary.print()
+	if cfg.SummaryFile != "" {
+		if err := summary.save(cfg.SummaryFile); err != nil {
+			fmt.Printf("Предупреждение: не удалось сохранить итоги: %v\n", err)
+		}
+	}
+	if cfg.TextfileDir != "" {
+		if err := summary.writeTextfile(cfg.TextfileDir, cfg.RegistryURL); err != nil {
+			fmt.Printf("Предупреждение: не удалось записать метрики: %v\n", err)
+		}
+	}
+	notify(cfg, summary)
+	notifyTeams(cfg, summary, failures)
+	pageSummary(cfg, summary)
+	writeJobSummary(cfg, summary, failures)
+	if cfg.JUnitOut != "" {
+		if err := summary.writeJUnit(cfg.JUnitOut, urlHost(cfg.RegistryURL), failures); err != nil {
+			fmt.Printf("Предупреждение: не удалось записать отчет JUnit: %v\n", err)
+		}
+	}
+	printFailures(failures)
+	reportErrors(cfg, failures)
+}
 
-	req, err := http.NewRequest("DELETE", url, nil)
-	if err != nil {
-		return fmt.Errorf("ошибка создания DELETE запроса: %v", err)
+// finishCleanup подсказывает, как освободить место после удаления, или освобождает его
+// сам для настроенного backend и garbage collection. Возвращает код выхода процесса
+func finishCleanup(ctx context.Context, cfg *Config, backend registry.Backend, gcSetup *gcSetup, info gc.Info) int {
+	switch cfg.Backend {
+	case BackendHarbor:
+		fmt.Println("\n⚠️  Важно: Место освобождается после garbage collection в Harbor (Administration -> Clean Up)")
+		return 0
+	case BackendGitLab:
+		fmt.Println("\n⚠️  Важно: GitLab удаляет теги асинхронно; на собственной установке место освобождается после gitlab-ctl registry-garbage-collect")
+		return 0
+	case BackendECR:
+		fmt.Println("\n⚠️  Важно: ECR освобождает место автоматически, запускать garbage collection не нужно")
+		return 0
+	case BackendGAR:
+		fmt.Println("\n⚠️  Важно: Artifact Registry освобождает место автоматически, запускать garbage collection не нужно")
+		return 0
+	case BackendACR:
+		fmt.Println("\n⚠️  Важно: ACR освобождает место автоматически, запускать garbage collection не нужно")
+		return 0
+	case BackendQuay:
+		if cfg.QuayExpire > 0 {
+			fmt.Printf("\n⚠️  Важно: Quay удалит теги по истечении срока действия (%s); до этого их можно продлить в интерфейсе Quay\n", cfg.QuayExpire)
+		} else {
+			fmt.Println("\n⚠️  Важно: Quay освобождает место автоматически после окончания срока хранения удаленных тегов (time machine)")
+		}
+		return 0
+	case BackendDockerHub:
+		fmt.Println("\n⚠️  Важно: Docker Hub освобождает место автоматически, запускать garbage collection не нужно")
+		return 0
+	case BackendArtifactory:
+		fmt.Println("\n⚠️  Важно: Artifactory перемещает удаленные каталоги в Trash Can, а место освобождается после очистки корзины и garbage collection")
+		return 0
+	case BackendNexus:
+		if !cfg.NexusCompact {
+			fmt.Println("\n⚠️  Важно: Место освобождается после задачи Nexus \"Admin - Compact blob store\" (или запустите с --nexus-compact)")
+			return 0
+		}
+		started, err := backend.(*nexus.Client).Compact(ctx)
+		for _, name := range started {
+			fmt.Printf("Запущена задача Nexus %q\n", name)
+		}
+		if err != nil {
+			log.Printf("Ошибка запуска сжатия blob store: %v", err)
+			return 1
+		}
+		return 0
+	}
+	if gcSetup != nil {
+		return runGC(ctx, cfg, gcSetup, info)
 	}
+	fmt.Println("\n⚠️  Важно: После удаления манифестов запустите garbage collection в Registry:")
+	fmt.Println("docker exec <registry-container> registry garbage-collect /etc/docker/registry/config.yml")
+	fmt.Println("Или в поде -> registry garbage-collect /etc/docker/registry/config.yml")
+	return 0
+}
 
-	if rc.Username != "" && rc.Password != "" {
-		req.SetBasicAuth(rc.Username, rc.Password)
+// newRegistryClient создает клиент Docker Registry API V2 с параметрами подключения из конфигурации
+func newRegistryClient(cfg *Config) *registry.Client {
+	client := registry.NewClient(cfg.RegistryURL, cfg.Username, cfg.Password)
+	client.TrustDigestHeader = cfg.SkipDigestCheck
+	client.TagDeleteFallback = cfg.DeleteTagFallback
+	client.IndexCreated = cfg.IndexCreated
+	client.Client.Transport = newTransport(cfg)
+	client.CatalogPageSize = cfg.CatalogPageSize
+	// Диапазон проверен в Validate
+	client.Catalog, _ = registry.ParseCatalogRange(cfg.CatalogRange)
+	client.Catalog.Prefix = cfg.CatalogPrefix
+	if cfg.ConfigCacheFile != "" {
+		// Кэш только ускоряет запуск, поэтому поврежденный файл не мешает очистке
+		configs, err := registry.LoadConfigCache(cfg.ConfigCacheFile)
+		if err != nil {
+			log.Printf("Предупреждение: %v, кэш конфигураций заполняется заново", err)
+			configs = registry.NewConfigCache()
+			configs.Path = cfg.ConfigCacheFile
+		}
+		client.Configs = configs
 	}
+	if cfg.ETagCacheFile != "" {
+		etags, err := registry.LoadETagCache(cfg.ETagCacheFile)
+		if err != nil {
+			log.Printf("Предупреждение: %v, кэш ETag заполняется заново", err)
+			etags = registry.NewETagCache()
+			etags.Path = cfg.ETagCacheFile
+		}
+		client.UseETagCache(etags)
+	}
+	return client
+}
+
+// newTransport создает HTTP-транспорт с параметрами соединений, заголовками и трассировкой из
+// конфигурации. Без --max-idle-conns-per-host сохраняется столько соединений, сколько
+// запросов выполняется одновременно
+func newTransport(cfg *Config) http.RoundTripper {
+	idle := cfg.MaxIdleConnsPerHost
+	if idle == 0 {
+		idle = max(cfg.Concurrency, 2)
+	}
+	transport := registry.NewTransport(registry.TransportOptions{
+		MaxIdleConnsPerHost: idle,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+		KeepAlive:           cfg.KeepAlive,
+		HTTP1Only:           cfg.HTTP1Only,
+	})
 
-	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json")
+	var next http.RoundTripper = transport
+	if cfg.DebugHTTP || cfg.DebugHTTPHeaders {
+		next = &registry.DebugTransport{Next: transport, Log: os.Stderr, DumpHeaders: cfg.DebugHTTPHeaders}
+	}
 
-	resp, err := rc.Client.Do(req)
-	if err != nil {
-		return fmt.Errorf("ошибка при удалении манифеста %s: %v", digest, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusAccepted || resp.StatusCode == http.StatusOK {
-		return nil
-	}
-
-	// Читаем тело ответа для получения детальной информации об ошибке
-	body, _ := io.ReadAll(resp.Body)
-
-	switch resp.StatusCode {
-	case http.StatusMethodNotAllowed: // 405
-		fmt.Printf("\n🚨 ОШИБКА КОНФИГУРАЦИИ REGISTRY:\n")
-		fmt.Printf("Docker Registry не настроен для поддержки удаления образов.\n\n")
-		fmt.Printf("📋 Для исправления:\n")
-		fmt.Printf("1. Остановите Registry\n")
-		fmt.Printf("2. Добавьте в config.yml:\n")
-		fmt.Printf("   storage:\n")
-		fmt.Printf("     delete:\n")
-		fmt.Printf("       enabled: true\n")
-		fmt.Printf("3. Перезапустите Registry\n\n")
-		fmt.Printf("📄 Подробные инструкции: см. файл REGISTRY_SETUP.md\n\n")
-		return fmt.Errorf("удаление не поддерживается Registry (статус 405)")
-	case http.StatusNotFound: // 404
-		return fmt.Errorf("манифест не найден (статус 404): %s", string(body))
-	case http.StatusUnauthorized: // 401
-		return fmt.Errorf("ошибка авторизации (статус 401): %s", string(body))
-	case http.StatusForbidden: // 403
-		return fmt.Errorf("доступ запрещен (статус 403): %s", string(body))
-	default:
-		return fmt.Errorf("получен статус %d при удалении манифеста: %s", resp.StatusCode, string(body))
+	// Заголовки проверены в Validate
+	header := make(http.Header)
+	for _, value := range cfg.Headers {
+		name, content, _ := registry.ParseHeader(value)
+		header.Add(name, content)
 	}
+	return &registry.HeaderTransport{Next: next, UserAgent: cfg.UserAgent, Header: header}
 }
 
-// CleanupRepository очищает репозиторий, оставляя только 2 самых новых образа
-func (rc *RegistryClient) CleanupRepository(repository string, keepLast int) error {
-	fmt.Printf("Обработка репозитория: %s\n", repository)
+// saveClientCaches сохраняет кэши конфигураций образов и ETag, если они хранятся в файлах
+func saveClientCaches(client *registry.Client) {
+	if client.Configs != nil {
+		if err := client.Configs.Save(); err != nil {
+			fmt.Printf("Предупреждение: не удалось сохранить кэш конфигураций: %v\n", err)
+		}
+	}
+	if client.ETags != nil {
+		requests, notModified := client.ETags.Stats()
+		if requests > 0 {
+			fmt.Printf("Условные запросы: %d из %d ресурсов не изменились с прошлого запуска\n", notModified, requests)
+		}
+		if err := client.ETags.Save(); err != nil {
+			fmt.Printf("Предупреждение: не удалось сохранить кэш ETag: %v\n", err)
+		}
+	}
+}
 
-	tags, err := rc.GetTags(repository)
+// newPlanner создает Planner по конфигурации: с неизменяемыми тегами, списком удержания,
+// проверкой подписей, кэшем метаданных, отметками удаления и политиками команд. Ход
+// работы и сведения о загруженных данных выводятся в out. Возвращаемая функция
+// освобождает политики команд
+func newPlanner(ctx context.Context, cfg *Config, backend registry.Backend, policy cleanup.Policy, out io.Writer) (*cleanup.Planner, func(), error) {
+	tagTime, err := buildTagTime(cfg)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	planner := &cleanup.Planner{
+		Backend:         backend,
+		Policy:          policy,
+		Concurrency:     cfg.Concurrency,
+		TagTime:         tagTime,
+		Sort:            cfg.Sort,
+		TieBreak:        cfg.TieBreak,
+		Platform:        cfg.Platform,
+		Location:        cfg.location(),
+		SweepSignatures: cfg.SweepSignatures,
+		CreatedFallback: cfg.CreatedFallback,
+		Log:             out,
+	}
+
+	if cfg.ProtectedFile != "" {
+		protected, err := cleanup.LoadProtectedTags(cfg.ProtectedFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		planner.Protected = protected
+		fmt.Fprintf(out, "Неизменяемые теги %s: %d записей\n", cfg.ProtectedFile, protected.Len())
 	}
 
-	if len(tags) <= keepLast {
-		fmt.Printf("  В репозитории %s только %d тегов, пропускаем\n", repository, len(tags))
-		return nil
+	if planner.Holds, err = fetchLegalHolds(cfg); err != nil {
+		return nil, nil, err
+	}
+	if planner.Holds != nil {
+		fmt.Fprintf(out, "Список удержания %s: %d записей\n", cfg.HoldURL, planner.Holds.Len())
 	}
 
-	var images []ImageInfo
+	if planner.Signatures, planner.DeleteSigned, err = buildSignatureVerifier(cfg); err != nil {
+		return nil, nil, err
+	}
 
-	// Получаем информацию о каждом образе
-	for _, tag := range tags {
-		digest, err := rc.GetManifestDigest(repository, tag)
+	if cfg.CacheFile != "" {
+		cache, err := cleanup.LoadMetadataCache(cfg.CacheFile)
 		if err != nil {
-			fmt.Printf("  Предупреждение: не удалось получить digest для %s:%s: %v\n", repository, tag, err)
-			continue
+			return nil, nil, err
 		}
+		planner.Cache = cache
+		fmt.Fprintf(out, "Кэш метаданных %s: %d записей\n", cfg.CacheFile, cache.Len())
+	}
 
-		created, err := rc.GetImageCreated(repository, tag)
+	if cfg.SoftDeleteFile != "" {
+		grace, _ := cleanup.ParseAgo(cfg.GracePeriod)
+		softDelete, err := cleanup.LoadSoftDelete(cfg.SoftDeleteFile, grace)
 		if err != nil {
-			fmt.Printf("  Предупреждение: не удалось получить время создания для %s:%s: %v\n", repository, tag, err)
-			created = time.Now() // Используем текущее время в качестве запасного варианта
+			return nil, nil, err
 		}
+		planner.SoftDelete = softDelete
+		fmt.Fprintf(out, "Отметки удаления %s: %d тегов, срок ожидания %s\n", cfg.SoftDeleteFile, softDelete.Len(), cfg.GracePeriod)
+	}
 
-		images = append(images, ImageInfo{
-			Repository: repository,
-			Tag:        tag,
-			Digest:     digest,
-			Created:    created,
-		})
+	tenants, closeTenants, err := buildTenants(ctx, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка загрузки команд: %v", err)
+	}
+	planner.Tenants = tenants
+	if tenants != nil {
+		fmt.Fprintf(out, "Команды %s: %d\n", cfg.TeamsFile, tenants.Len())
+	}
+	return planner, closeTenants, nil
+}
 
-		fmt.Printf("  Образ %s:%s создан %s\n", repository, tag, created.Format("2006-01-02 15:04:05"))
+// newExecutor создает Executor с ограничением частоты удалений, архивацией и выгрузкой,
+// заданными в конфигурации
+func newExecutor(cfg *Config, client *registry.Client, backend registry.Backend, shutdown *Shutdown) *cleanup.Executor {
+	executor := &cleanup.Executor{
+		Backend:       backend,
+		Stopped:       shutdown.Requested,
+		FailFast:      cfg.FailFast,
+		TagFallback:   cfg.DeleteTagFallback,
+		VerifyDeletes: cfg.VerifyDeletes,
+		VerifyDelay:   cfg.VerifyDeleteDelay,
+		Location:      cfg.location(),
+	}
+	if cfg.MaxDeletesPerMinute > 0 {
+		// Без запаса: запросы удаления распределяются по минуте равномерно
+		executor.Limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(cfg.MaxDeletesPerMinute)), 1)
 	}
 
-	// Сортируем по времени создания (новые образы первыми)
-	sort.Slice(images, func(i, j int) bool {
-		return images[i].Created.After(images[j].Created)
-	})
+	if cfg.ArchiveURL != "" {
+		archive := registry.NewClient(cfg.ArchiveURL, cfg.ArchiveUsername, cfg.ArchivePassword)
+		executor.Archiver = registry.NewArchiver(client, archive, cfg.ArchivePrefix)
+		fmt.Printf("Образы будут скопированы в архивный Registry %s перед удалением\n", cfg.ArchiveURL)
+	}
 
-	fmt.Printf("  Образы отсортированы по времени создания (новые первыми):\n")
-	for i, img := range images {
-		status := "сохранить"
-		if i >= keepLast {
-			status = "удалить"
-		}
-		fmt.Printf("    %d. %s:%s (%s) - %s\n", i+1, img.Repository, img.Tag,
-			img.Created.Format("2006-01-02 15:04:05"), status)
+	if cfg.Quarantine != "" {
+		executor.Quarantine = cleanup.NewQuarantine(client, cfg.Quarantine, strings.Trim(cfg.QuarantinePrefix, "/"))
+		fmt.Printf("Образы будут помещены в карантин перед удалением (%s)\n", cfg.Quarantine)
 	}
 
-	// Удаляем все кроме последних keepLast образов
-	if len(images) > keepLast {
-		toDelete := images[keepLast:]
-		fmt.Printf("  Найдено %d образов, сохраняем %d новейших, удаляем %d старых\n",
-			len(images), keepLast, len(toDelete))
+	if cfg.ExportDir != "" {
+		executor.Exporter = &registry.Exporter{Client: client, Dir: cfg.ExportDir}
+		fmt.Printf("Образы будут выгружены в %s перед удалением\n", cfg.ExportDir)
+	}
 
-		for _, img := range toDelete {
-			fmt.Printf("  Удаляем %s:%s (создан: %s, digest: %s)\n",
-				img.Repository, img.Tag, img.Created.Format("2006-01-02 15:04:05"), img.Digest[:12])
-			if err := rc.DeleteManifest(img.Repository, img.Digest); err != nil {
-				fmt.Printf("  Ошибка при удалении %s:%s: %v\n", img.Repository, img.Tag, err)
-			} else {
-				fmt.Printf("  Успешно удален %s:%s\n", img.Repository, img.Tag)
+	if cfg.SBOMDir != "" {
+		executor.SBOM = buildSBOMArchiver(cfg, client)
+		fmt.Printf("SBOM образов будут сохранены в %s перед удалением\n", cfg.SBOMDir)
+	}
+	return executor
+}
+
+// acquireLocks захватывает блокировки и продлевает их каждую треть --lock-ttl, пока идет
+// очистка, чтобы долгий запуск не потерял блокировку по сроку. Возвращаемый контекст
+// отменяется, если блокировку перехватил другой экземпляр: текущие запросы прерываются и
+// новые удаления не начинаются. Возвращаемая функция останавливает продление и
+// освобождает блокировки
+func acquireLocks(ctx context.Context, cfg *Config, client *registry.Client) (context.Context, func(), bool) {
+	locks, err := buildLocks(cfg, client)
+	if err != nil {
+		log.Printf("Ошибка настройки блокировки: %v", err)
+		return ctx, nil, false
+	}
+	for i, lock := range locks {
+		if err := lock.Acquire(ctx); err != nil {
+			log.Printf("Ошибка захвата блокировки: %v", err)
+			releaseLocks(locks[:i])
+			return ctx, nil, false
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(cfg.LockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			for _, lock := range locks {
+				renewer, ok := lock.(cleanup.Renewer)
+				if !ok {
+					continue
+				}
+				err := renewer.Renew(ctx, cfg.LockTTL)
+				if errors.Is(err, cleanup.ErrLockLost) {
+					log.Printf("Ошибка: %v, очистка прерывается", err)
+					cancel()
+					return
+				}
+				if err != nil {
+					fmt.Printf("Предупреждение: не удалось продлить блокировку: %v\n", err)
+				}
 			}
 		}
+	}()
+
+	return ctx, func() {
+		close(done)
+		<-stopped
+		cancel()
+		releaseLocks(locks)
+	}, true
+}
+
+// listRepositories возвращает репозитории из --repos-file, а без него - из backend
+func listRepositories(ctx context.Context, cfg *Config, backend registry.Backend) ([]string, error) {
+	if cfg.ReposFile == "" {
+		return backend.ListRepositories(ctx)
+	}
+	if cfg.ReposFile == "-" {
+		return readRepositories(os.Stdin)
 	}
 
-	return nil
+	f, err := os.Open(cfg.ReposFile)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия списка репозиториев: %v", err)
+	}
+	defer f.Close()
+	return readRepositories(f)
 }
 
-func main() {
-	// Получаем параметры из переменных окружения или используем значения по умолчанию
-	registryURL := os.Getenv("REGISTRY_URL")
-	if registryURL == "" {
-		registryURL = "http://localhost:5000" // Значение по умолчанию
+// readRepositories читает имена репозиториев по одному в строке, пропуская пустые строки,
+// комментарии # и повторы
+func readRepositories(r io.Reader) ([]string, error) {
+	var repositories []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		repo := strings.TrimSpace(scanner.Text())
+		if repo == "" || strings.HasPrefix(repo, "#") || seen[repo] {
+			continue
+		}
+		seen[repo] = true
+		repositories = append(repositories, repo)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения списка репозиториев: %v", err)
 	}
+	return repositories, nil
+}
 
-	username := os.Getenv("REGISTRY_USERNAME")
-	password := os.Getenv("REGISTRY_PASSWORD")
+// cleanupRepositories исключает из списка репозитории, которые не очищаются:
+// репозиторий маркера блокировки, репозитории исключенных пространств имен и не
+// перечисленные в --repository. Выполняется до получения тегов, поэтому к исключенным
+// репозиториям программа не обращается вовсе
+func cleanupRepositories(cfg *Config, repositories []string) []string {
+	var lockRepository string
+	if cfg.LockTag != "" {
+		lockRepository = cfg.LockTag[:strings.LastIndex(cfg.LockTag, ":")]
+	}
 
-	keepLast := 2 // Количество образов для сохранения
+	var result []string
+	for _, repo := range repositories {
+		if repo == lockRepository {
+			continue
+		}
+		if excludedNamespace(cfg.ExcludeNamespaces, repo) {
+			continue
+		}
+		if len(cfg.Repositories) > 0 && !slices.Contains(cfg.Repositories, repo) {
+			continue
+		}
+		result = append(result, repo)
+	}
+	return result
+}
 
-	fmt.Printf("🐳 Docker Registry Cleaner\n")
-	fmt.Printf("Подключение к Docker Registry: %s\n", registryURL)
+// excludedNamespace сообщает, находится ли репозиторий в одном из пространств имен
+// namespaces, например infra/ или base-images
+func excludedNamespace(namespaces []string, repository string) bool {
+	for _, ns := range namespaces {
+		ns = strings.TrimSuffix(ns, "/")
+		if repository == ns || strings.HasPrefix(repository, ns+"/") {
+			return true
+		}
+	}
+	return false
+}
 
-	client := NewRegistryClient(registryURL, username, password)
+// deleteFailures возвращает ошибки удаления отдельных образов из ошибки Executor.Execute,
+// кроме признака прерывания
+func deleteFailures(err error) []error {
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
 
-	// Получаем список всех репозиториев
-	repositories, err := client.GetRepositories()
-	if err != nil {
-		log.Fatalf("Ошибка при получении списка репозиториев: %v", err)
+	var failures []error
+	for _, e := range errs {
+		if !errors.Is(e, cleanup.ErrInterrupted) {
+			failures = append(failures, e)
+		}
 	}
+	return failures
+}
 
-	if len(repositories) == 0 {
-		fmt.Println("Репозитории не найдены")
+// printFailures выводит итоговую сводку ошибок запуска
+func printFailures(failures []error) {
+	if len(failures) == 0 {
 		return
 	}
+	fmt.Printf("\n❌ Ошибок за запуск: %d\n", len(failures))
+	for _, err := range failures {
+		fmt.Printf("  - %v\n", err)
+	}
+}
 
-	fmt.Printf("Найдено %d репозиториев\n", len(repositories))
+// failureRepository возвращает репозиторий, к которому относится ошибка, или "-".
+// Ошибки репозиториев и тегов начинаются с имени репозитория, в котором нет ":"
+func failureRepository(err error) string {
+	repo, _, ok := strings.Cut(err.Error(), ":")
+	if !ok || strings.ContainsAny(repo, " ") {
+		return "-"
+	}
+	return repo
+}
 
-	// Очищаем каждый репозиторий
-	for _, repo := range repositories {
-		if err := client.CleanupRepository(repo, keepLast); err != nil {
-			fmt.Printf("Ошибка при очистке репозитория %s: %v\n", repo, err)
+// printInterruptedSummary выводит итоги запуска, прерванного сигналом
+func printInterruptedSummary(total int, planned, executed []*cleanup.RepositoryPlan) {
+	deleted := 0
+	for _, plan := range executed {
+		deleted += len(plan.Deleted)
+	}
+
+	fmt.Printf("\n⛔ Очистка прервана\n")
+	fmt.Printf("  Репозиториев проанализировано: %d из %d\n", len(planned), total)
+	fmt.Printf("  Репозиториев обработано: %d\n", len(executed))
+	fmt.Printf("  Образов удалено: %d\n", deleted)
+	for _, plan := range executed {
+		for _, img := range plan.Deleted {
+			fmt.Printf("    - %s:%s (%s)\n", img.Repository, img.Tag, img.Digest)
 		}
 	}
+}
 
-	fmt.Println("\n✅ Очистка завершена!")
-	fmt.Println("\n⚠️  Важно: После удаления манифестов запустите garbage collection в Registry:")
-	fmt.Println("docker exec <registry-container> registry garbage-collect /etc/docker/registry/config.yml")
-	fmt.Println("Или в поде -> registry garbage-collect /etc/docker/registry/config.yml")
+// buildLocks создает блокировки, заданные в конфигурации
+func buildLocks(cfg *Config, client *registry.Client) ([]cleanup.Lock, error) {
+	info := cleanup.NewLockInfo(cfg.RegistryURL, cfg.LockTTL)
+	var locks []cleanup.Lock
+
+	if cfg.LockFile != "" {
+		locks = append(locks, &cleanup.FileLock{Path: cfg.LockFile, Info: info})
+	}
+	if cfg.LockTag != "" {
+		lock, err := cleanup.ParseRegistryLock(client, cfg.LockTag, info)
+		if err != nil {
+			return nil, err
+		}
+		locks = append(locks, lock)
+	}
+	if cfg.LockURL != "" {
+		locks = append(locks, &cleanup.URLLock{URL: cfg.LockURL, Info: info, Client: client.Client})
+	}
+
+	return locks, nil
+}
+
+// releaseLocks освобождает блокировки в обратном порядке. Используется отдельный
+// контекст, чтобы блокировки освобождались и после отмены основного
+func releaseLocks(locks []cleanup.Lock) {
+	ctx := context.Background()
+	for i := len(locks) - 1; i >= 0; i-- {
+		if err := locks[i].Release(ctx); err != nil {
+			fmt.Printf("Предупреждение: %v\n", err)
+		}
+	}
 }
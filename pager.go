@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// opsgenieMessageLimit максимальная длина сообщения алерта Opsgenie
+const opsgenieMessageLimit = 130
+
+// incident инцидент запуска: очистка не выполнена целиком или превышен бюджет ошибок
+type incident struct {
+	Summary string
+	// Details подробности: нарушенные пороги, итоги запуска или ошибка
+	Details map[string]interface{}
+}
+
+// pager система дежурств, в которой открываются и закрываются инциденты очистки. Инциденты
+// одного registry объединяются по ключу, поэтому повторяющийся сбой не открывает новых
+// инцидентов, а успешный запуск закрывает открытый
+type pager interface {
+	Trigger(ctx context.Context, key string, inc incident) error
+	Resolve(ctx context.Context, key string) error
+}
+
+// newPagers создает клиенты систем дежурств, заданных в конфигурации
+func newPagers(cfg *Config) map[string]pager {
+	pagers := make(map[string]pager)
+	client := &http.Client{Timeout: notifyTimeout}
+	if cfg.PagerDutyRoutingKey != "" {
+		pagers["PagerDuty"] = &pagerDuty{RoutingKey: cfg.PagerDutyRoutingKey, URL: cfg.PagerDutyURL, Client: client}
+	}
+	if cfg.OpsgenieAPIKey != "" {
+		pagers["Opsgenie"] = &opsgenie{APIKey: cfg.OpsgenieAPIKey, URL: strings.TrimRight(cfg.OpsgenieURL, "/"), Client: client}
+	}
+	return pagers
+}
+
+// incidentKey ключ инцидентов registry
+func incidentKey(cfg *Config) string {
+	return "registry-cleaner-" + urlHost(cfg.RegistryURL)
+}
+
+// failRun выводит ошибку, из-за которой очистка не выполнялась, открывает инцидент и
+// возвращает код выхода 1
+func failRun(cfg *Config, format string, args ...interface{}) int {
+	message := fmt.Sprintf(format, args...)
+	log.Print(message)
+	page(cfg, &incident{
+		Summary: fmt.Sprintf("Очистка %s не выполнена: %s", urlHost(cfg.RegistryURL), message),
+		Details: map[string]interface{}{"registry": cfg.RegistryURL, "backend": cfg.Backend, "error": message},
+	})
+	return 1
+}
+
+// pageSummary открывает инцидент, если запуск нарушил пороги оповещения --alert-*, и
+// закрывает открытый инцидент после полного запуска без нарушений
+func pageSummary(cfg *Config, s *cleanupStats) {
+	alerts := s.Alerts
+	// Без порогов оповещения запуск, в котором не удалось составить ни одного плана,
+	// тоже считается невыполненным: например, истекли учетные данные
+	if len(alerts) == 0 && s.Failed > 0 && s.Processed == 0 && s.Skipped == 0 {
+		alerts = []string{fmt.Sprintf("не удалось составить план ни для одного из %d репозиториев", s.Failed)}
+	}
+	if len(alerts) == 0 {
+		if !s.Interrupted {
+			page(cfg, nil)
+		}
+		return
+	}
+	page(cfg, &incident{
+		Summary: fmt.Sprintf("Очистка %s превысила бюджет ошибок: %s", urlHost(cfg.RegistryURL), strings.Join(alerts, "; ")),
+		Details: map[string]interface{}{"registry": cfg.RegistryURL, "backend": cfg.Backend, "alerts": alerts, "summary": s},
+	})
+}
+
+// page открывает инцидент inc во всех системах дежурств, nil - закрывает открытый.
+// Ошибки отправки только выводятся
+func page(cfg *Config, inc *incident) {
+	key := incidentKey(cfg)
+	for name, p := range newPagers(cfg) {
+		var err error
+		if inc != nil {
+			err = p.Trigger(context.Background(), key, *inc)
+		} else {
+			err = p.Resolve(context.Background(), key)
+		}
+		if err != nil {
+			fmt.Printf("Предупреждение: не удалось отправить событие в %s: %v\n", name, err)
+		}
+	}
+}
+
+// pagerDuty отправляет события в PagerDuty Events API v2 по ключу интеграции сервиса
+type pagerDuty struct {
+	RoutingKey string
+	// URL адрес Events API: https://events.pagerduty.com/v2/enqueue или
+	// https://events.eu.pagerduty.com/v2/enqueue
+	URL    string
+	Client *http.Client
+}
+
+// Trigger открывает инцидент PagerDuty
+func (p *pagerDuty) Trigger(ctx context.Context, key string, inc incident) error {
+	return p.send(ctx, map[string]interface{}{
+		"routing_key":  p.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    key,
+		"payload": map[string]interface{}{
+			"summary":        truncate(inc.Summary, 1024),
+			"source":         key,
+			"severity":       "critical",
+			"component":      "registry-cleaner",
+			"custom_details": inc.Details,
+		},
+	})
+}
+
+// Resolve закрывает инцидент PagerDuty; событие без открытого инцидента игнорируется
+func (p *pagerDuty) Resolve(ctx context.Context, key string) error {
+	return p.send(ctx, map[string]interface{}{"routing_key": p.RoutingKey, "event_action": "resolve", "dedup_key": key})
+}
+
+// send отправляет событие
+func (p *pagerDuty) send(ctx context.Context, event interface{}) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return postIncident(ctx, p.Client, p.URL, nil, body)
+}
+
+// opsgenie создает и закрывает алерты Opsgenie Alert API по ключу интеграции API
+type opsgenie struct {
+	APIKey string
+	// URL адрес API: https://api.opsgenie.com или https://api.eu.opsgenie.com
+	URL    string
+	Client *http.Client
+}
+
+// Trigger создает алерт Opsgenie; алерт с тем же alias не дублируется
+func (o *opsgenie) Trigger(ctx context.Context, key string, inc incident) error {
+	description, err := json.MarshalIndent(inc.Details, "", "  ")
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"message":     truncate(inc.Summary, opsgenieMessageLimit),
+		"alias":       key,
+		"description": truncate(inc.Summary+"\n\n"+string(description), 15000),
+		"source":      "registry-cleaner",
+		"priority":    "P2",
+		"tags":        []string{"registry-cleaner"},
+	})
+	if err != nil {
+		return err
+	}
+	return postIncident(ctx, o.Client, o.URL+"/v2/alerts", o.headers(), body)
+}
+
+// Resolve закрывает алерт Opsgenie по alias
+func (o *opsgenie) Resolve(ctx context.Context, key string) error {
+	endpoint := o.URL + "/v2/alerts/" + url.PathEscape(key) + "/close?identifierType=alias"
+	return postIncident(ctx, o.Client, endpoint, o.headers(), []byte(`{"source":"registry-cleaner"}`))
+}
+
+// headers заголовки аутентификации Opsgenie
+func (o *opsgenie) headers() http.Header {
+	return http.Header{"Authorization": {"GenieKey " + o.APIKey}}
+}
+
+// postIncident отправляет событие запросом POST в формате JSON. Opsgenie обрабатывает
+// запросы асинхронно, поэтому успехом считается любой статус 2xx
+func postIncident(ctx context.Context, client *http.Client, endpoint string, header http.Header, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("получен статус %d от %s", resp.StatusCode, urlHost(endpoint))
+	}
+	return nil
+}
+
+// truncate обрезает строку до limit символов
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
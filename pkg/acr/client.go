@@ -0,0 +1,307 @@
+// Package acr реализует registry.Backend поверх REST API Azure Container Registry. Метаданные
+// образов и признак блокировки удаления берутся из расширенного API /acr/v1, через него же
+// удаляются манифесты. Запросы выполняются с токенами доступа, полученными в /oauth2/token
+package acr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// pageSize количество элементов на странице ответов ACR
+const pageSize = 100
+
+// TokenUsername имя пользователя, с которым используется токен az acr login --expose-token
+const TokenUsername = "00000000-0000-0000-0000-000000000000"
+
+// Client клиент REST API Azure Container Registry. Username и Password - учетные данные
+// сервисного принципала, пользователя-администратора или токена репозитория
+type Client struct {
+	BaseURL  string
+	Username string
+	Password string
+	Client   *http.Client
+
+	mu        sync.Mutex
+	tokens    map[string]string
+	manifests map[string][]manifest
+}
+
+// manifest манифест репозитория в ответе /acr/v1/{name}/_manifests
+type manifest struct {
+	Digest      string    `json:"digest"`
+	ImageSize   int64     `json:"imageSize"`
+	CreatedTime time.Time `json:"createdTime"`
+	Tags        []string  `json:"tags"`
+	Attributes  struct {
+		DeleteEnabled *bool `json:"deleteEnabled"`
+	} `json:"changeableAttributes"`
+}
+
+var (
+	_ registry.Backend          = (*Client)(nil)
+	_ registry.ImmutableChecker = (*Client)(nil)
+)
+
+// NewClient создает клиент ACR
+func NewClient(baseURL, username, password string) *Client {
+	return &Client{
+		BaseURL:   strings.TrimSuffix(baseURL, "/"),
+		Username:  username,
+		Password:  password,
+		Client:    &http.Client{Timeout: 30 * time.Second},
+		tokens:    make(map[string]string),
+		manifests: make(map[string][]manifest),
+	}
+}
+
+// AzureCLIToken получает токен доступа к registry через az acr login --expose-token
+// от имени пользователя, вошедшего в Azure CLI. Токен используется как пароль
+// с именем пользователя TokenUsername
+func AzureCLIToken(ctx context.Context, baseURL string) (string, error) {
+	cmd := exec.CommandContext(ctx, "az", "acr", "login", "--name", host(baseURL), "--expose-token", "--output", "json")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("ошибка выполнения az acr login: %v", err)
+	}
+
+	var result struct {
+		AccessToken string `json:"accessToken"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return "", fmt.Errorf("ошибка декодирования ответа az acr login: %v", err)
+	}
+	if result.AccessToken == "" {
+		return "", fmt.Errorf("az acr login не вернул токен доступа")
+	}
+	return result.AccessToken, nil
+}
+
+// host возвращает имя хоста registry из URL
+func host(baseURL string) string {
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+// token возвращает токен доступа с областью scope, запрашивая его при необходимости
+func (c *Client) token(ctx context.Context, scope string) (string, error) {
+	c.mu.Lock()
+	token, ok := c.tokens[scope]
+	c.mu.Unlock()
+	if ok {
+		return token, nil
+	}
+
+	query := url.Values{"service": {host(c.BaseURL)}, "scope": {scope}}
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/oauth2/token?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения токена доступа: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("получен статус %d при получении токена доступа %s", resp.StatusCode, scope)
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("ошибка декодирования токена доступа: %v", err)
+	}
+
+	c.mu.Lock()
+	c.tokens[scope] = result.AccessToken
+	c.mu.Unlock()
+	return result.AccessToken, nil
+}
+
+// do выполняет запрос с токеном области scope. Если токен истек, он запрашивается
+// заново и запрос повторяется
+func (c *Client) do(ctx context.Context, method, path, scope string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		token, err := c.token(ctx, scope)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := c.Client.Do(req)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, err
+		}
+
+		resp.Body.Close()
+		c.mu.Lock()
+		delete(c.tokens, scope)
+		c.mu.Unlock()
+	}
+}
+
+// repositoryScope возвращает область токена для чтения и удаления в репозитории
+func repositoryScope(name string) string {
+	return "repository:" + name + ":metadata_read,delete"
+}
+
+// nextLink извлекает адрес следующей страницы из заголовка Link
+var nextLink = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// getPages получает все страницы списка field по адресу path
+func getPages[T any](ctx context.Context, c *Client, path, scope, field string) ([]T, error) {
+	var items []T
+	for next := path + "?n=" + fmt.Sprint(pageSize); next != ""; {
+		resp, err := c.do(ctx, "GET", next, scope)
+		if err != nil {
+			return nil, err
+		}
+
+		var page map[string]json.RawMessage
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("получен статус %d при запросе %s", resp.StatusCode, path)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка декодирования ответа %s: %v", path, err)
+		}
+
+		var batch []T
+		if raw, ok := page[field]; ok {
+			if err := json.Unmarshal(raw, &batch); err != nil {
+				return nil, fmt.Errorf("ошибка декодирования %s: %v", field, err)
+			}
+		}
+		items = append(items, batch...)
+
+		next = ""
+		if m := nextLink.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+			next = m[1]
+		}
+	}
+	return items, nil
+}
+
+// ListRepositories получает список репозиториев registry
+func (c *Client) ListRepositories(ctx context.Context) ([]string, error) {
+	names, err := getPages[string](ctx, c, "/acr/v1/_catalog", "registry:catalog:*", "repositories")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении списка репозиториев: %v", err)
+	}
+	return names, nil
+}
+
+// ListTags получает манифесты репозитория и возвращает их теги. Манифесты запоминаются
+// до следующего вызова ListTags для этого репозитория
+func (c *Client) ListTags(ctx context.Context, name string) ([]string, error) {
+	manifests, err := getPages[manifest](ctx, c, "/acr/v1/"+name+"/_manifests", repositoryScope(name), "manifests")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении манифестов %s: %v", name, err)
+	}
+
+	c.mu.Lock()
+	c.manifests[name] = manifests
+	c.mu.Unlock()
+
+	var tags []string
+	for _, m := range manifests {
+		tags = append(tags, m.Tags...)
+	}
+	return tags, nil
+}
+
+// findManifest возвращает манифест, помеченный тегом
+func (c *Client) findManifest(ctx context.Context, name, tag string) (manifest, error) {
+	c.mu.Lock()
+	manifests, ok := c.manifests[name]
+	c.mu.Unlock()
+
+	if !ok {
+		if _, err := c.ListTags(ctx, name); err != nil {
+			return manifest{}, err
+		}
+		c.mu.Lock()
+		manifests = c.manifests[name]
+		c.mu.Unlock()
+	}
+
+	for _, m := range manifests {
+		for _, t := range m.Tags {
+			if t == tag {
+				return m, nil
+			}
+		}
+	}
+	return manifest{}, fmt.Errorf("тег %s:%s: %w", name, tag, registry.ErrNotFound)
+}
+
+// ResolveDigest возвращает digest манифеста, помеченного тегом
+func (c *Client) ResolveDigest(ctx context.Context, name, tag string) (string, error) {
+	m, err := c.findManifest(ctx, name, tag)
+	if err != nil {
+		return "", err
+	}
+	return m.Digest, nil
+}
+
+// GetImageMeta возвращает время загрузки манифеста в ACR в качестве времени создания и его размер
+func (c *Client) GetImageMeta(ctx context.Context, name, tag string) (registry.ImageMeta, error) {
+	m, err := c.findManifest(ctx, name, tag)
+	if err != nil {
+		return registry.ImageMeta{}, err
+	}
+	return registry.ImageMeta{Created: m.CreatedTime, Size: m.ImageSize}, nil
+}
+
+// Immutable сообщает, заблокировано ли удаление манифеста (deleteEnabled=false)
+func (c *Client) Immutable(ctx context.Context, name, tag string) (bool, error) {
+	m, err := c.findManifest(ctx, name, tag)
+	if err != nil {
+		return false, err
+	}
+	return m.Attributes.DeleteEnabled != nil && !*m.Attributes.DeleteEnabled, nil
+}
+
+// Delete удаляет манифест вместе со всеми его тегами
+func (c *Client) Delete(ctx context.Context, name, digest string) error {
+	resp, err := c.do(ctx, "DELETE", "/acr/v1/"+name+"/_manifests/"+digest, repositoryScope(name))
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения DELETE запроса: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted:
+		return nil
+	case http.StatusMethodNotAllowed:
+		return fmt.Errorf("удаление %s@%s заблокировано (deleteEnabled=false)", name, digest)
+	case http.StatusNotFound:
+		return fmt.Errorf("манифест %s@%s: %w", name, digest, registry.ErrNotFound)
+	default:
+		return fmt.Errorf("получен статус %d при удалении %s@%s", resp.StatusCode, name, digest)
+	}
+}
@@ -0,0 +1,321 @@
+// Package artifactory реализует registry.Backend поверх API JFrog Artifactory. Образы
+// локальных Docker-репозиториев находятся запросами AQL вместе со статистикой скачиваний,
+// а удаляются как каталоги тегов, так как Docker API Artifactory часто доступен только для чтения
+package artifactory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// Имена файлов манифестов в каталоге тега
+const (
+	manifestFile     = "manifest.json"
+	manifestListFile = "list.manifest.json"
+)
+
+// Client клиент API Artifactory. Репозитории очистки именуются <репозиторий Artifactory>/<образ>
+type Client struct {
+	BaseURL  string
+	Username string
+	Password string
+	// Token токен доступа, передается как Bearer вместо Username и Password
+	Token  string
+	Client *http.Client
+
+	mu   sync.Mutex
+	tags map[string]map[string]tagFolder
+}
+
+// tagFolder каталог тега: манифест, суммарный размер файлов и статистика скачиваний
+type tagFolder struct {
+	Path           string
+	Digest         string
+	Created        time.Time
+	Size           int64
+	LastDownloaded time.Time
+}
+
+// item элемент результата AQL
+type item struct {
+	Repo    string    `json:"repo"`
+	Path    string    `json:"path"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+	SHA256  string    `json:"sha256"`
+	Stats   []struct {
+		Downloaded time.Time `json:"downloaded"`
+	} `json:"stats"`
+}
+
+var (
+	_ registry.Backend          = (*Client)(nil)
+	_ registry.PullTimeProvider = (*Client)(nil)
+)
+
+// NewClient создает клиент Artifactory. baseURL указывает на приложение Artifactory,
+// например https://example.jfrog.io/artifactory
+func NewClient(baseURL, username, password, token string) *Client {
+	return &Client{
+		BaseURL:  strings.TrimSuffix(baseURL, "/"),
+		Username: username,
+		Password: password,
+		Token:    token,
+		Client:   &http.Client{Timeout: 60 * time.Second},
+		tags:     make(map[string]map[string]tagFolder),
+	}
+}
+
+// do выполняет запрос к API Artifactory с аутентификацией
+func (c *Client) do(ctx context.Context, method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	} else if c.Username != "" && c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	return c.Client.Do(req)
+}
+
+// aql выполняет запрос AQL и возвращает найденные элементы
+func (c *Client) aql(ctx context.Context, query string) ([]item, error) {
+	resp, err := c.do(ctx, "POST", "/api/search/aql", "text/plain", strings.NewReader(query))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения AQL: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("получен статус %d при выполнении AQL: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Results []item `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования результата AQL: %v", err)
+	}
+	return result.Results, nil
+}
+
+// quote возвращает строку в виде литерала JSON для запроса AQL
+func quote(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// splitName разделяет имя репозитория очистки на репозиторий Artifactory и образ
+func splitName(name string) (repo, image string, err error) {
+	repo, image, ok := strings.Cut(name, "/")
+	if !ok {
+		return "", "", fmt.Errorf("репозиторий %s должен иметь вид <репозиторий Artifactory>/<образ>", name)
+	}
+	return repo, image, nil
+}
+
+// isManifest сообщает, является ли файл манифестом тега
+func isManifest(name string) bool {
+	return name == manifestFile || name == manifestListFile
+}
+
+// ListRepositories получает образы всех локальных Docker-репозиториев
+func (c *Client) ListRepositories(ctx context.Context) ([]string, error) {
+	resp, err := c.do(ctx, "GET", "/api/repositories?type=local&packageType=docker", "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении списка репозиториев: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("получен статус %d при запросе репозиториев", resp.StatusCode)
+	}
+
+	var repositories []struct {
+		Key string `json:"key"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&repositories); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования ответа: %v", err)
+	}
+
+	var names []string
+	for _, r := range repositories {
+		items, err := c.aql(ctx, fmt.Sprintf(
+			`items.find({"repo":%s,"$or":[{"name":%q},{"name":%q}]}).include("path")`,
+			quote(r.Key), manifestFile, manifestListFile))
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при получении образов %s: %v", r.Key, err)
+		}
+
+		seen := make(map[string]bool)
+		for _, it := range items {
+			i := strings.LastIndex(it.Path, "/")
+			// Служебные каталоги вроде _uploads не являются образами
+			if i <= 0 || strings.HasPrefix(it.Path, "_") {
+				continue
+			}
+			name := r.Key + "/" + it.Path[:i]
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// ListTags находит каталоги тегов образа одним запросом AQL по всем его файлам.
+// Сведения о тегах запоминаются до следующего вызова ListTags для этого репозитория
+func (c *Client) ListTags(ctx context.Context, name string) ([]string, error) {
+	repo, image, err := splitName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := c.aql(ctx, fmt.Sprintf(
+		`items.find({"repo":%s,"path":{"$match":%s}}).include("path","name","created","size","sha256","stat.downloaded")`,
+		quote(repo), quote(image+"/*")))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении тегов для %s: %v", name, err)
+	}
+
+	folders := make(map[string]tagFolder)
+	sizes := make(map[string]int64)
+	for _, it := range items {
+		tag := strings.TrimPrefix(it.Path, image+"/")
+		// Вложенные каталоги принадлежат другим образам
+		if tag == it.Path || strings.Contains(tag, "/") {
+			continue
+		}
+		sizes[tag] += it.Size
+
+		if !isManifest(it.Name) {
+			continue
+		}
+		folder := tagFolder{Path: it.Path, Digest: "sha256:" + it.SHA256, Created: it.Created}
+		if len(it.Stats) > 0 {
+			folder.LastDownloaded = it.Stats[0].Downloaded
+		}
+		folders[tag] = folder
+	}
+
+	var tags []string
+	for tag, folder := range folders {
+		folder.Size = sizes[tag]
+		folders[tag] = folder
+		tags = append(tags, tag)
+	}
+
+	c.mu.Lock()
+	c.tags[name] = folders
+	c.mu.Unlock()
+
+	return tags, nil
+}
+
+// folder возвращает каталог тега
+func (c *Client) folder(ctx context.Context, name, tag string) (tagFolder, error) {
+	c.mu.Lock()
+	folders, ok := c.tags[name]
+	c.mu.Unlock()
+
+	if !ok {
+		if _, err := c.ListTags(ctx, name); err != nil {
+			return tagFolder{}, err
+		}
+		c.mu.Lock()
+		folders = c.tags[name]
+		c.mu.Unlock()
+	}
+
+	folder, ok := folders[tag]
+	if !ok {
+		return tagFolder{}, fmt.Errorf("тег %s:%s: %w", name, tag, registry.ErrNotFound)
+	}
+	return folder, nil
+}
+
+// ResolveDigest возвращает digest манифеста тега
+func (c *Client) ResolveDigest(ctx context.Context, name, tag string) (string, error) {
+	folder, err := c.folder(ctx, name, tag)
+	if err != nil {
+		return "", err
+	}
+	return folder.Digest, nil
+}
+
+// GetImageMeta возвращает время загрузки манифеста в Artifactory в качестве времени создания
+// и суммарный размер файлов каталога тега
+func (c *Client) GetImageMeta(ctx context.Context, name, tag string) (registry.ImageMeta, error) {
+	folder, err := c.folder(ctx, name, tag)
+	if err != nil {
+		return registry.ImageMeta{}, err
+	}
+	return registry.ImageMeta{Created: folder.Created, Size: folder.Size}, nil
+}
+
+// LastPulled возвращает время последнего скачивания манифеста тега
+func (c *Client) LastPulled(ctx context.Context, name, tag string) (time.Time, error) {
+	folder, err := c.folder(ctx, name, tag)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return folder.LastDownloaded, nil
+}
+
+// Delete удаляет каталоги всех тегов образа, манифест которых имеет digest
+func (c *Client) Delete(ctx context.Context, name, digest string) error {
+	repo, _, err := splitName(name)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	var paths []string
+	for _, folder := range c.tags[name] {
+		if folder.Digest == digest {
+			paths = append(paths, folder.Path)
+		}
+	}
+	c.mu.Unlock()
+
+	if len(paths) == 0 {
+		return fmt.Errorf("не найдено тегов %s с digest %s", name, digest)
+	}
+
+	for _, path := range paths {
+		resp, err := c.do(ctx, "DELETE", "/"+url.PathEscape(repo)+"/"+(&url.URL{Path: path}).EscapedPath(), "", nil)
+		if err != nil {
+			return fmt.Errorf("ошибка выполнения DELETE запроса: %v", err)
+		}
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		case http.StatusForbidden:
+			return fmt.Errorf("нет прав на удаление %s/%s (требуется право Delete/Overwrite)", repo, path)
+		default:
+			return fmt.Errorf("получен статус %d при удалении %s/%s", resp.StatusCode, repo, path)
+		}
+	}
+	return nil
+}
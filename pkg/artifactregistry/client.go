@@ -0,0 +1,304 @@
+// Package artifactregistry реализует registry.Backend поверх API Google Artifact Registry
+// с аутентификацией Application Default Credentials. Репозитории Docker всех регионов
+// проекта перечисляются через API, а образы удаляются как версии пакетов
+package artifactregistry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"registryCleaner/pkg/registry"
+)
+
+// DefaultBaseURL адрес API Artifact Registry
+const DefaultBaseURL = "https://artifactregistry.googleapis.com/v1"
+
+// scope область доступа токенов ADC
+const scope = "https://www.googleapis.com/auth/cloud-platform"
+
+// Client клиент API Artifact Registry. Репозитории очистки именуются
+// <регион>/<репозиторий>/<образ>, например europe-west1/docker/payment-service
+type Client struct {
+	BaseURL string
+	Project string
+	// Location регион репозиториев, пустая строка - все регионы проекта
+	Location string
+	// Client HTTP клиент, добавляющий токен доступа к запросам
+	Client *http.Client
+
+	mu       sync.Mutex
+	versions map[string][]version
+}
+
+// version версия пакета Docker, то есть манифест образа с его тегами
+type version struct {
+	Name        string    `json:"name"`
+	CreateTime  time.Time `json:"createTime"`
+	RelatedTags []struct {
+		Name string `json:"name"`
+	} `json:"relatedTags"`
+	Metadata struct {
+		BuildTime      time.Time `json:"buildTime"`
+		ImageSizeBytes string    `json:"imageSizeBytes"`
+	} `json:"metadata"`
+}
+
+var _ registry.Backend = (*Client)(nil)
+
+// NewClient создает клиент с учетными данными Application Default Credentials:
+// GOOGLE_APPLICATION_CREDENTIALS, gcloud auth application-default login,
+// Workload Identity или сервисный аккаунт виртуальной машины
+func NewClient(ctx context.Context, project, location string) (*Client, error) {
+	tokens, err := google.DefaultTokenSource(ctx, scope)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения учетных данных Google: %v", err)
+	}
+
+	client := oauth2.NewClient(ctx, tokens)
+	client.Timeout = 30 * time.Second
+
+	return &Client{
+		BaseURL:  DefaultBaseURL,
+		Project:  project,
+		Location: location,
+		Client:   client,
+		versions: make(map[string][]version),
+	}, nil
+}
+
+// do выполняет запрос к API по имени ресурса
+func (c *Client) do(ctx context.Context, method, resource string, query url.Values) (*http.Response, error) {
+	target := c.BaseURL + "/" + resource
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+// getPages получает все страницы списка field ресурса resource
+func getPages[T any](ctx context.Context, c *Client, resource, field string, query url.Values) ([]T, error) {
+	var items []T
+	for {
+		resp, err := c.do(ctx, "GET", resource, query)
+		if err != nil {
+			return nil, err
+		}
+
+		var page map[string]json.RawMessage
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("получен статус %d при запросе %s", resp.StatusCode, resource)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка декодирования ответа %s: %v", resource, err)
+		}
+
+		var batch []T
+		if raw, ok := page[field]; ok {
+			if err := json.Unmarshal(raw, &batch); err != nil {
+				return nil, fmt.Errorf("ошибка декодирования %s: %v", field, err)
+			}
+		}
+		items = append(items, batch...)
+
+		var next string
+		if raw, ok := page["nextPageToken"]; ok {
+			json.Unmarshal(raw, &next)
+		}
+		if next == "" {
+			return items, nil
+		}
+		query = cloneWith(query, "pageToken", next)
+	}
+}
+
+// cloneWith возвращает копию параметров запроса с установленным значением
+func cloneWith(query url.Values, key, value string) url.Values {
+	clone := url.Values{}
+	for k, v := range query {
+		clone[k] = v
+	}
+	clone.Set(key, value)
+	return clone
+}
+
+// packageResource возвращает имя ресурса пакета по имени репозитория очистки.
+// Символы "/" в имени образа Artifact Registry кодирует как %2F
+func (c *Client) packageResource(name string) (string, error) {
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) != 3 {
+		return "", fmt.Errorf("репозиторий %s должен иметь вид <регион>/<репозиторий>/<образ>", name)
+	}
+	return fmt.Sprintf("projects/%s/locations/%s/repositories/%s/packages/%s",
+		c.Project, parts[0], parts[1], url.PathEscape(parts[2])), nil
+}
+
+// ListRepositories получает образы всех Docker-репозиториев проекта
+func (c *Client) ListRepositories(ctx context.Context) ([]string, error) {
+	locations := []string{c.Location}
+	if c.Location == "" {
+		found, err := getPages[struct {
+			LocationID string `json:"locationId"`
+		}](ctx, c, "projects/"+c.Project+"/locations", "locations", nil)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при получении списка регионов: %v", err)
+		}
+		locations = nil
+		for _, l := range found {
+			locations = append(locations, l.LocationID)
+		}
+	}
+
+	var names []string
+	for _, location := range locations {
+		repositories, err := getPages[struct {
+			Name   string `json:"name"`
+			Format string `json:"format"`
+		}](ctx, c, fmt.Sprintf("projects/%s/locations/%s/repositories", c.Project, location), "repositories", nil)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при получении репозиториев региона %s: %v", location, err)
+		}
+
+		for _, repo := range repositories {
+			if repo.Format != "DOCKER" {
+				continue
+			}
+			packages, err := getPages[struct {
+				Name string `json:"name"`
+			}](ctx, c, repo.Name+"/packages", "packages", nil)
+			if err != nil {
+				return nil, fmt.Errorf("ошибка при получении образов %s: %v", repo.Name, err)
+			}
+
+			repoID := repo.Name[strings.LastIndex(repo.Name, "/")+1:]
+			for _, pkg := range packages {
+				image, err := url.PathUnescape(pkg.Name[strings.LastIndex(pkg.Name, "/")+1:])
+				if err != nil {
+					return nil, fmt.Errorf("некорректное имя пакета %s: %v", pkg.Name, err)
+				}
+				names = append(names, location+"/"+repoID+"/"+image)
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// ListTags получает версии образа и возвращает их теги. Версии запоминаются
+// до следующего вызова ListTags для этого репозитория
+func (c *Client) ListTags(ctx context.Context, name string) ([]string, error) {
+	resource, err := c.packageResource(name)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := getPages[version](ctx, c, resource+"/versions", "versions", url.Values{"view": {"FULL"}})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении версий %s: %v", name, err)
+	}
+
+	c.mu.Lock()
+	if c.versions == nil {
+		c.versions = make(map[string][]version)
+	}
+	c.versions[name] = versions
+	c.mu.Unlock()
+
+	var tags []string
+	for _, v := range versions {
+		for _, tag := range v.RelatedTags {
+			tags = append(tags, tag.Name[strings.LastIndex(tag.Name, "/")+1:])
+		}
+	}
+	return tags, nil
+}
+
+// findVersion возвращает версию, помеченную тегом
+func (c *Client) findVersion(ctx context.Context, name, tag string) (version, error) {
+	c.mu.Lock()
+	versions, ok := c.versions[name]
+	c.mu.Unlock()
+
+	if !ok {
+		if _, err := c.ListTags(ctx, name); err != nil {
+			return version{}, err
+		}
+		c.mu.Lock()
+		versions = c.versions[name]
+		c.mu.Unlock()
+	}
+
+	for _, v := range versions {
+		for _, t := range v.RelatedTags {
+			if strings.HasSuffix(t.Name, "/tags/"+tag) {
+				return v, nil
+			}
+		}
+	}
+	return version{}, fmt.Errorf("тег %s:%s: %w", name, tag, registry.ErrNotFound)
+}
+
+// ResolveDigest возвращает digest версии, помеченной тегом
+func (c *Client) ResolveDigest(ctx context.Context, name, tag string) (string, error) {
+	v, err := c.findVersion(ctx, name, tag)
+	if err != nil {
+		return "", err
+	}
+	return v.Name[strings.LastIndex(v.Name, "/")+1:], nil
+}
+
+// GetImageMeta возвращает время сборки образа (или время загрузки, если оно неизвестно) и его размер
+func (c *Client) GetImageMeta(ctx context.Context, name, tag string) (registry.ImageMeta, error) {
+	v, err := c.findVersion(ctx, name, tag)
+	if err != nil {
+		return registry.ImageMeta{}, err
+	}
+
+	meta := registry.ImageMeta{Created: v.Metadata.BuildTime}
+	if meta.Created.IsZero() {
+		meta.Created = v.CreateTime
+	}
+	if v.Metadata.ImageSizeBytes != "" {
+		meta.Size, _ = strconv.ParseInt(v.Metadata.ImageSizeBytes, 10, 64)
+	}
+	return meta, nil
+}
+
+// Delete удаляет версию образа вместе с ее тегами
+func (c *Client) Delete(ctx context.Context, name, digest string) error {
+	resource, err := c.packageResource(name)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(ctx, "DELETE", resource+"/versions/"+digest, url.Values{"force": {"true"}})
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения DELETE запроса: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusForbidden:
+		return fmt.Errorf("нет прав на удаление %s@%s (требуется roles/artifactregistry.repoAdmin)", name, digest)
+	default:
+		return fmt.Errorf("получен статус %d при удалении %s@%s", resp.StatusCode, name, digest)
+	}
+}
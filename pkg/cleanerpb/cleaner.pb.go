@@ -0,0 +1,1021 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: cleaner.proto
+
+package cleanerpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// RunStatus состояние запуска
+type RunStatus int32
+
+const (
+	RunStatus_RUN_STATUS_UNSPECIFIED RunStatus = 0
+	RunStatus_RUN_STATUS_RUNNING     RunStatus = 1
+	RunStatus_RUN_STATUS_SUCCEEDED   RunStatus = 2
+	RunStatus_RUN_STATUS_FAILED      RunStatus = 3
+)
+
+// Enum value maps for RunStatus.
+var (
+	RunStatus_name = map[int32]string{
+		0: "RUN_STATUS_UNSPECIFIED",
+		1: "RUN_STATUS_RUNNING",
+		2: "RUN_STATUS_SUCCEEDED",
+		3: "RUN_STATUS_FAILED",
+	}
+	RunStatus_value = map[string]int32{
+		"RUN_STATUS_UNSPECIFIED": 0,
+		"RUN_STATUS_RUNNING":     1,
+		"RUN_STATUS_SUCCEEDED":   2,
+		"RUN_STATUS_FAILED":      3,
+	}
+)
+
+func (x RunStatus) Enum() *RunStatus {
+	p := new(RunStatus)
+	*p = x
+	return p
+}
+
+func (x RunStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RunStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_cleaner_proto_enumTypes[0].Descriptor()
+}
+
+func (RunStatus) Type() protoreflect.EnumType {
+	return &file_cleaner_proto_enumTypes[0]
+}
+
+func (x RunStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RunStatus.Descriptor instead.
+func (RunStatus) EnumDescriptor() ([]byte, []int) {
+	return file_cleaner_proto_rawDescGZIP(), []int{0}
+}
+
+// ProgressStage этап обработки репозитория
+type ProgressStage int32
+
+const (
+	ProgressStage_PROGRESS_STAGE_UNSPECIFIED ProgressStage = 0
+	// План очистки репозитория составлен
+	ProgressStage_PROGRESS_STAGE_PLANNED ProgressStage = 1
+	// Образы репозитория удалены по плану
+	ProgressStage_PROGRESS_STAGE_EXECUTED ProgressStage = 2
+)
+
+// Enum value maps for ProgressStage.
+var (
+	ProgressStage_name = map[int32]string{
+		0: "PROGRESS_STAGE_UNSPECIFIED",
+		1: "PROGRESS_STAGE_PLANNED",
+		2: "PROGRESS_STAGE_EXECUTED",
+	}
+	ProgressStage_value = map[string]int32{
+		"PROGRESS_STAGE_UNSPECIFIED": 0,
+		"PROGRESS_STAGE_PLANNED":     1,
+		"PROGRESS_STAGE_EXECUTED":    2,
+	}
+)
+
+func (x ProgressStage) Enum() *ProgressStage {
+	p := new(ProgressStage)
+	*p = x
+	return p
+}
+
+func (x ProgressStage) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ProgressStage) Descriptor() protoreflect.EnumDescriptor {
+	return file_cleaner_proto_enumTypes[1].Descriptor()
+}
+
+func (ProgressStage) Type() protoreflect.EnumType {
+	return &file_cleaner_proto_enumTypes[1]
+}
+
+func (x ProgressStage) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ProgressStage.Descriptor instead.
+func (ProgressStage) EnumDescriptor() ([]byte, []int) {
+	return file_cleaner_proto_rawDescGZIP(), []int{1}
+}
+
+// Run запуск очистки
+type Run struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Id      int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Started *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=started,proto3" json:"started,omitempty"`
+	// finished не задан, пока запуск выполняется
+	Finished *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=finished,proto3" json:"finished,omitempty"`
+	Status   RunStatus              `protobuf:"varint,4,opt,name=status,proto3,enum=registrycleaner.v1.RunStatus" json:"status,omitempty"`
+	ExitCode int32                  `protobuf:"varint,5,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	// output заполняется только в GetRun
+	Output        []string `protobuf:"bytes,6,rep,name=output,proto3" json:"output,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Run) Reset() {
+	*x = Run{}
+	mi := &file_cleaner_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Run) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Run) ProtoMessage() {}
+
+func (x *Run) ProtoReflect() protoreflect.Message {
+	mi := &file_cleaner_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Run.ProtoReflect.Descriptor instead.
+func (*Run) Descriptor() ([]byte, []int) {
+	return file_cleaner_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Run) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Run) GetStarted() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Started
+	}
+	return nil
+}
+
+func (x *Run) GetFinished() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Finished
+	}
+	return nil
+}
+
+func (x *Run) GetStatus() RunStatus {
+	if x != nil {
+		return x.Status
+	}
+	return RunStatus_RUN_STATUS_UNSPECIFIED
+}
+
+func (x *Run) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *Run) GetOutput() []string {
+	if x != nil {
+		return x.Output
+	}
+	return nil
+}
+
+type StartRunRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// repositories репозитории, очистка которых утверждена; пустой список - все
+	Repositories  []string `protobuf:"bytes,1,rep,name=repositories,proto3" json:"repositories,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartRunRequest) Reset() {
+	*x = StartRunRequest{}
+	mi := &file_cleaner_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartRunRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartRunRequest) ProtoMessage() {}
+
+func (x *StartRunRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cleaner_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartRunRequest.ProtoReflect.Descriptor instead.
+func (*StartRunRequest) Descriptor() ([]byte, []int) {
+	return file_cleaner_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StartRunRequest) GetRepositories() []string {
+	if x != nil {
+		return x.Repositories
+	}
+	return nil
+}
+
+type ListRunsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRunsRequest) Reset() {
+	*x = ListRunsRequest{}
+	mi := &file_cleaner_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRunsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRunsRequest) ProtoMessage() {}
+
+func (x *ListRunsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cleaner_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRunsRequest.ProtoReflect.Descriptor instead.
+func (*ListRunsRequest) Descriptor() ([]byte, []int) {
+	return file_cleaner_proto_rawDescGZIP(), []int{2}
+}
+
+type ListRunsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Runs          []*Run                 `protobuf:"bytes,1,rep,name=runs,proto3" json:"runs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRunsResponse) Reset() {
+	*x = ListRunsResponse{}
+	mi := &file_cleaner_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRunsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRunsResponse) ProtoMessage() {}
+
+func (x *ListRunsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cleaner_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRunsResponse.ProtoReflect.Descriptor instead.
+func (*ListRunsResponse) Descriptor() ([]byte, []int) {
+	return file_cleaner_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListRunsResponse) GetRuns() []*Run {
+	if x != nil {
+		return x.Runs
+	}
+	return nil
+}
+
+type GetRunRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRunRequest) Reset() {
+	*x = GetRunRequest{}
+	mi := &file_cleaner_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRunRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRunRequest) ProtoMessage() {}
+
+func (x *GetRunRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cleaner_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRunRequest.ProtoReflect.Descriptor instead.
+func (*GetRunRequest) Descriptor() ([]byte, []int) {
+	return file_cleaner_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetRunRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type WatchRunRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRunRequest) Reset() {
+	*x = WatchRunRequest{}
+	mi := &file_cleaner_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRunRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRunRequest) ProtoMessage() {}
+
+func (x *WatchRunRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cleaner_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRunRequest.ProtoReflect.Descriptor instead.
+func (*WatchRunRequest) Descriptor() ([]byte, []int) {
+	return file_cleaner_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *WatchRunRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+// RepositoryProgress обработка одного репозитория
+type RepositoryProgress struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Repository string                 `protobuf:"bytes,1,opt,name=repository,proto3" json:"repository,omitempty"`
+	Stage      ProgressStage          `protobuf:"varint,2,opt,name=stage,proto3,enum=registrycleaner.v1.ProgressStage" json:"stage,omitempty"`
+	// index номер репозитория, начиная с 1, из total на этом этапе
+	Index int32 `protobuf:"varint,3,opt,name=index,proto3" json:"index,omitempty"`
+	Total int32 `protobuf:"varint,4,opt,name=total,proto3" json:"total,omitempty"`
+	// keep и delete количество сохраняемых и удаляемых образов по плану
+	Keep   int32 `protobuf:"varint,5,opt,name=keep,proto3" json:"keep,omitempty"`
+	Delete int32 `protobuf:"varint,6,opt,name=delete,proto3" json:"delete,omitempty"`
+	// deleted количество удаленных образов на этапе EXECUTED
+	Deleted int32 `protobuf:"varint,7,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	// error ошибка обработки репозитория
+	Error         string `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RepositoryProgress) Reset() {
+	*x = RepositoryProgress{}
+	mi := &file_cleaner_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RepositoryProgress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RepositoryProgress) ProtoMessage() {}
+
+func (x *RepositoryProgress) ProtoReflect() protoreflect.Message {
+	mi := &file_cleaner_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RepositoryProgress.ProtoReflect.Descriptor instead.
+func (*RepositoryProgress) Descriptor() ([]byte, []int) {
+	return file_cleaner_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RepositoryProgress) GetRepository() string {
+	if x != nil {
+		return x.Repository
+	}
+	return ""
+}
+
+func (x *RepositoryProgress) GetStage() ProgressStage {
+	if x != nil {
+		return x.Stage
+	}
+	return ProgressStage_PROGRESS_STAGE_UNSPECIFIED
+}
+
+func (x *RepositoryProgress) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *RepositoryProgress) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *RepositoryProgress) GetKeep() int32 {
+	if x != nil {
+		return x.Keep
+	}
+	return 0
+}
+
+func (x *RepositoryProgress) GetDelete() int32 {
+	if x != nil {
+		return x.Delete
+	}
+	return 0
+}
+
+func (x *RepositoryProgress) GetDeleted() int32 {
+	if x != nil {
+		return x.Deleted
+	}
+	return 0
+}
+
+func (x *RepositoryProgress) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// RunEvent событие хода запуска
+type RunEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*RunEvent_Output
+	//	*RunEvent_Progress
+	//	*RunEvent_Done
+	Event         isRunEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RunEvent) Reset() {
+	*x = RunEvent{}
+	mi := &file_cleaner_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunEvent) ProtoMessage() {}
+
+func (x *RunEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_cleaner_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunEvent.ProtoReflect.Descriptor instead.
+func (*RunEvent) Descriptor() ([]byte, []int) {
+	return file_cleaner_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RunEvent) GetEvent() isRunEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *RunEvent) GetOutput() string {
+	if x != nil {
+		if x, ok := x.Event.(*RunEvent_Output); ok {
+			return x.Output
+		}
+	}
+	return ""
+}
+
+func (x *RunEvent) GetProgress() *RepositoryProgress {
+	if x != nil {
+		if x, ok := x.Event.(*RunEvent_Progress); ok {
+			return x.Progress
+		}
+	}
+	return nil
+}
+
+func (x *RunEvent) GetDone() *Run {
+	if x != nil {
+		if x, ok := x.Event.(*RunEvent_Done); ok {
+			return x.Done
+		}
+	}
+	return nil
+}
+
+type isRunEvent_Event interface {
+	isRunEvent_Event()
+}
+
+type RunEvent_Output struct {
+	// output строка вывода очистки
+	Output string `protobuf:"bytes,1,opt,name=output,proto3,oneof"`
+}
+
+type RunEvent_Progress struct {
+	Progress *RepositoryProgress `protobuf:"bytes,2,opt,name=progress,proto3,oneof"`
+}
+
+type RunEvent_Done struct {
+	// done итог запуска, последнее событие потока
+	Done *Run `protobuf:"bytes,3,opt,name=done,proto3,oneof"`
+}
+
+func (*RunEvent_Output) isRunEvent_Event() {}
+
+func (*RunEvent_Progress) isRunEvent_Event() {}
+
+func (*RunEvent_Done) isRunEvent_Event() {}
+
+type GetPlanRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPlanRequest) Reset() {
+	*x = GetPlanRequest{}
+	mi := &file_cleaner_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPlanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPlanRequest) ProtoMessage() {}
+
+func (x *GetPlanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cleaner_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPlanRequest.ProtoReflect.Descriptor instead.
+func (*GetPlanRequest) Descriptor() ([]byte, []int) {
+	return file_cleaner_proto_rawDescGZIP(), []int{8}
+}
+
+// Image образ в плане очистки
+type Image struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tag           string                 `protobuf:"bytes,1,opt,name=tag,proto3" json:"tag,omitempty"`
+	Digest        string                 `protobuf:"bytes,2,opt,name=digest,proto3" json:"digest,omitempty"`
+	Created       *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=created,proto3" json:"created,omitempty"`
+	Size          int64                  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	LastPulled    *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=last_pulled,json=lastPulled,proto3" json:"last_pulled,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Image) Reset() {
+	*x = Image{}
+	mi := &file_cleaner_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Image) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Image) ProtoMessage() {}
+
+func (x *Image) ProtoReflect() protoreflect.Message {
+	mi := &file_cleaner_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Image.ProtoReflect.Descriptor instead.
+func (*Image) Descriptor() ([]byte, []int) {
+	return file_cleaner_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Image) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *Image) GetDigest() string {
+	if x != nil {
+		return x.Digest
+	}
+	return ""
+}
+
+func (x *Image) GetCreated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
+
+func (x *Image) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Image) GetLastPulled() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastPulled
+	}
+	return nil
+}
+
+// RepositoryPlan план очистки репозитория
+type RepositoryPlan struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Repository    string                 `protobuf:"bytes,1,opt,name=repository,proto3" json:"repository,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Keep          []*Image               `protobuf:"bytes,3,rep,name=keep,proto3" json:"keep,omitempty"`
+	Delete        []*Image               `protobuf:"bytes,4,rep,name=delete,proto3" json:"delete,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RepositoryPlan) Reset() {
+	*x = RepositoryPlan{}
+	mi := &file_cleaner_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RepositoryPlan) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RepositoryPlan) ProtoMessage() {}
+
+func (x *RepositoryPlan) ProtoReflect() protoreflect.Message {
+	mi := &file_cleaner_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RepositoryPlan.ProtoReflect.Descriptor instead.
+func (*RepositoryPlan) Descriptor() ([]byte, []int) {
+	return file_cleaner_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *RepositoryPlan) GetRepository() string {
+	if x != nil {
+		return x.Repository
+	}
+	return ""
+}
+
+func (x *RepositoryPlan) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *RepositoryPlan) GetKeep() []*Image {
+	if x != nil {
+		return x.Keep
+	}
+	return nil
+}
+
+func (x *RepositoryPlan) GetDelete() []*Image {
+	if x != nil {
+		return x.Delete
+	}
+	return nil
+}
+
+type GetPlanResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Repositories  []*RepositoryPlan      `protobuf:"bytes,1,rep,name=repositories,proto3" json:"repositories,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPlanResponse) Reset() {
+	*x = GetPlanResponse{}
+	mi := &file_cleaner_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPlanResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPlanResponse) ProtoMessage() {}
+
+func (x *GetPlanResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cleaner_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPlanResponse.ProtoReflect.Descriptor instead.
+func (*GetPlanResponse) Descriptor() ([]byte, []int) {
+	return file_cleaner_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *GetPlanResponse) GetRepositories() []*RepositoryPlan {
+	if x != nil {
+		return x.Repositories
+	}
+	return nil
+}
+
+var File_cleaner_proto protoreflect.FileDescriptor
+
+const file_cleaner_proto_rawDesc = "" +
+	"\n" +
+	"\rcleaner.proto\x12\x12registrycleaner.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xef\x01\n" +
+	"\x03Run\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x124\n" +
+	"\astarted\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\astarted\x126\n" +
+	"\bfinished\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\bfinished\x125\n" +
+	"\x06status\x18\x04 \x01(\x0e2\x1d.registrycleaner.v1.RunStatusR\x06status\x12\x1b\n" +
+	"\texit_code\x18\x05 \x01(\x05R\bexitCode\x12\x16\n" +
+	"\x06output\x18\x06 \x03(\tR\x06output\"5\n" +
+	"\x0fStartRunRequest\x12\"\n" +
+	"\frepositories\x18\x01 \x03(\tR\frepositories\"\x11\n" +
+	"\x0fListRunsRequest\"?\n" +
+	"\x10ListRunsResponse\x12+\n" +
+	"\x04runs\x18\x01 \x03(\v2\x17.registrycleaner.v1.RunR\x04runs\"\x1f\n" +
+	"\rGetRunRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"!\n" +
+	"\x0fWatchRunRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"\xf5\x01\n" +
+	"\x12RepositoryProgress\x12\x1e\n" +
+	"\n" +
+	"repository\x18\x01 \x01(\tR\n" +
+	"repository\x127\n" +
+	"\x05stage\x18\x02 \x01(\x0e2!.registrycleaner.v1.ProgressStageR\x05stage\x12\x14\n" +
+	"\x05index\x18\x03 \x01(\x05R\x05index\x12\x14\n" +
+	"\x05total\x18\x04 \x01(\x05R\x05total\x12\x12\n" +
+	"\x04keep\x18\x05 \x01(\x05R\x04keep\x12\x16\n" +
+	"\x06delete\x18\x06 \x01(\x05R\x06delete\x12\x18\n" +
+	"\adeleted\x18\a \x01(\x05R\adeleted\x12\x14\n" +
+	"\x05error\x18\b \x01(\tR\x05error\"\xa2\x01\n" +
+	"\bRunEvent\x12\x18\n" +
+	"\x06output\x18\x01 \x01(\tH\x00R\x06output\x12D\n" +
+	"\bprogress\x18\x02 \x01(\v2&.registrycleaner.v1.RepositoryProgressH\x00R\bprogress\x12-\n" +
+	"\x04done\x18\x03 \x01(\v2\x17.registrycleaner.v1.RunH\x00R\x04doneB\a\n" +
+	"\x05event\"\x10\n" +
+	"\x0eGetPlanRequest\"\xb8\x01\n" +
+	"\x05Image\x12\x10\n" +
+	"\x03tag\x18\x01 \x01(\tR\x03tag\x12\x16\n" +
+	"\x06digest\x18\x02 \x01(\tR\x06digest\x124\n" +
+	"\acreated\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\acreated\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x03R\x04size\x12;\n" +
+	"\vlast_pulled\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"lastPulled\"\xa8\x01\n" +
+	"\x0eRepositoryPlan\x12\x1e\n" +
+	"\n" +
+	"repository\x18\x01 \x01(\tR\n" +
+	"repository\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x12-\n" +
+	"\x04keep\x18\x03 \x03(\v2\x19.registrycleaner.v1.ImageR\x04keep\x121\n" +
+	"\x06delete\x18\x04 \x03(\v2\x19.registrycleaner.v1.ImageR\x06delete\"Y\n" +
+	"\x0fGetPlanResponse\x12F\n" +
+	"\frepositories\x18\x01 \x03(\v2\".registrycleaner.v1.RepositoryPlanR\frepositories*p\n" +
+	"\tRunStatus\x12\x1a\n" +
+	"\x16RUN_STATUS_UNSPECIFIED\x10\x00\x12\x16\n" +
+	"\x12RUN_STATUS_RUNNING\x10\x01\x12\x18\n" +
+	"\x14RUN_STATUS_SUCCEEDED\x10\x02\x12\x15\n" +
+	"\x11RUN_STATUS_FAILED\x10\x03*h\n" +
+	"\rProgressStage\x12\x1e\n" +
+	"\x1aPROGRESS_STAGE_UNSPECIFIED\x10\x00\x12\x1a\n" +
+	"\x16PROGRESS_STAGE_PLANNED\x10\x01\x12\x1b\n" +
+	"\x17PROGRESS_STAGE_EXECUTED\x10\x022\x95\x03\n" +
+	"\aCleaner\x12H\n" +
+	"\bStartRun\x12#.registrycleaner.v1.StartRunRequest\x1a\x17.registrycleaner.v1.Run\x12U\n" +
+	"\bListRuns\x12#.registrycleaner.v1.ListRunsRequest\x1a$.registrycleaner.v1.ListRunsResponse\x12D\n" +
+	"\x06GetRun\x12!.registrycleaner.v1.GetRunRequest\x1a\x17.registrycleaner.v1.Run\x12O\n" +
+	"\bWatchRun\x12#.registrycleaner.v1.WatchRunRequest\x1a\x1c.registrycleaner.v1.RunEvent0\x01\x12R\n" +
+	"\aGetPlan\x12\".registrycleaner.v1.GetPlanRequest\x1a#.registrycleaner.v1.GetPlanResponseB\x1fZ\x1dregistryCleaner/pkg/cleanerpbb\x06proto3"
+
+var (
+	file_cleaner_proto_rawDescOnce sync.Once
+	file_cleaner_proto_rawDescData []byte
+)
+
+func file_cleaner_proto_rawDescGZIP() []byte {
+	file_cleaner_proto_rawDescOnce.Do(func() {
+		file_cleaner_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_cleaner_proto_rawDesc), len(file_cleaner_proto_rawDesc)))
+	})
+	return file_cleaner_proto_rawDescData
+}
+
+var file_cleaner_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_cleaner_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_cleaner_proto_goTypes = []any{
+	(RunStatus)(0),                // 0: registrycleaner.v1.RunStatus
+	(ProgressStage)(0),            // 1: registrycleaner.v1.ProgressStage
+	(*Run)(nil),                   // 2: registrycleaner.v1.Run
+	(*StartRunRequest)(nil),       // 3: registrycleaner.v1.StartRunRequest
+	(*ListRunsRequest)(nil),       // 4: registrycleaner.v1.ListRunsRequest
+	(*ListRunsResponse)(nil),      // 5: registrycleaner.v1.ListRunsResponse
+	(*GetRunRequest)(nil),         // 6: registrycleaner.v1.GetRunRequest
+	(*WatchRunRequest)(nil),       // 7: registrycleaner.v1.WatchRunRequest
+	(*RepositoryProgress)(nil),    // 8: registrycleaner.v1.RepositoryProgress
+	(*RunEvent)(nil),              // 9: registrycleaner.v1.RunEvent
+	(*GetPlanRequest)(nil),        // 10: registrycleaner.v1.GetPlanRequest
+	(*Image)(nil),                 // 11: registrycleaner.v1.Image
+	(*RepositoryPlan)(nil),        // 12: registrycleaner.v1.RepositoryPlan
+	(*GetPlanResponse)(nil),       // 13: registrycleaner.v1.GetPlanResponse
+	(*timestamppb.Timestamp)(nil), // 14: google.protobuf.Timestamp
+}
+var file_cleaner_proto_depIdxs = []int32{
+	14, // 0: registrycleaner.v1.Run.started:type_name -> google.protobuf.Timestamp
+	14, // 1: registrycleaner.v1.Run.finished:type_name -> google.protobuf.Timestamp
+	0,  // 2: registrycleaner.v1.Run.status:type_name -> registrycleaner.v1.RunStatus
+	2,  // 3: registrycleaner.v1.ListRunsResponse.runs:type_name -> registrycleaner.v1.Run
+	1,  // 4: registrycleaner.v1.RepositoryProgress.stage:type_name -> registrycleaner.v1.ProgressStage
+	8,  // 5: registrycleaner.v1.RunEvent.progress:type_name -> registrycleaner.v1.RepositoryProgress
+	2,  // 6: registrycleaner.v1.RunEvent.done:type_name -> registrycleaner.v1.Run
+	14, // 7: registrycleaner.v1.Image.created:type_name -> google.protobuf.Timestamp
+	14, // 8: registrycleaner.v1.Image.last_pulled:type_name -> google.protobuf.Timestamp
+	11, // 9: registrycleaner.v1.RepositoryPlan.keep:type_name -> registrycleaner.v1.Image
+	11, // 10: registrycleaner.v1.RepositoryPlan.delete:type_name -> registrycleaner.v1.Image
+	12, // 11: registrycleaner.v1.GetPlanResponse.repositories:type_name -> registrycleaner.v1.RepositoryPlan
+	3,  // 12: registrycleaner.v1.Cleaner.StartRun:input_type -> registrycleaner.v1.StartRunRequest
+	4,  // 13: registrycleaner.v1.Cleaner.ListRuns:input_type -> registrycleaner.v1.ListRunsRequest
+	6,  // 14: registrycleaner.v1.Cleaner.GetRun:input_type -> registrycleaner.v1.GetRunRequest
+	7,  // 15: registrycleaner.v1.Cleaner.WatchRun:input_type -> registrycleaner.v1.WatchRunRequest
+	10, // 16: registrycleaner.v1.Cleaner.GetPlan:input_type -> registrycleaner.v1.GetPlanRequest
+	2,  // 17: registrycleaner.v1.Cleaner.StartRun:output_type -> registrycleaner.v1.Run
+	5,  // 18: registrycleaner.v1.Cleaner.ListRuns:output_type -> registrycleaner.v1.ListRunsResponse
+	2,  // 19: registrycleaner.v1.Cleaner.GetRun:output_type -> registrycleaner.v1.Run
+	9,  // 20: registrycleaner.v1.Cleaner.WatchRun:output_type -> registrycleaner.v1.RunEvent
+	13, // 21: registrycleaner.v1.Cleaner.GetPlan:output_type -> registrycleaner.v1.GetPlanResponse
+	17, // [17:22] is the sub-list for method output_type
+	12, // [12:17] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
+}
+
+func init() { file_cleaner_proto_init() }
+func file_cleaner_proto_init() {
+	if File_cleaner_proto != nil {
+		return
+	}
+	file_cleaner_proto_msgTypes[7].OneofWrappers = []any{
+		(*RunEvent_Output)(nil),
+		(*RunEvent_Progress)(nil),
+		(*RunEvent_Done)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_cleaner_proto_rawDesc), len(file_cleaner_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_cleaner_proto_goTypes,
+		DependencyIndexes: file_cleaner_proto_depIdxs,
+		EnumInfos:         file_cleaner_proto_enumTypes,
+		MessageInfos:      file_cleaner_proto_msgTypes,
+	}.Build()
+	File_cleaner_proto = out.File
+	file_cleaner_proto_goTypes = nil
+	file_cleaner_proto_depIdxs = nil
+}
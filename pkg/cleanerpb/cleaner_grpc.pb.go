@@ -0,0 +1,299 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: cleaner.proto
+
+package cleanerpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Cleaner_StartRun_FullMethodName = "/registrycleaner.v1.Cleaner/StartRun"
+	Cleaner_ListRuns_FullMethodName = "/registrycleaner.v1.Cleaner/ListRuns"
+	Cleaner_GetRun_FullMethodName   = "/registrycleaner.v1.Cleaner/GetRun"
+	Cleaner_WatchRun_FullMethodName = "/registrycleaner.v1.Cleaner/WatchRun"
+	Cleaner_GetPlan_FullMethodName  = "/registrycleaner.v1.Cleaner/GetPlan"
+)
+
+// CleanerClient is the client API for Cleaner service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Cleaner gRPC API очистки, повторяющее HTTP API подкоманды serve.
+// Все вызовы требуют метаданных authorization: Bearer <токен>
+type CleanerClient interface {
+	// StartRun запускает очистку; ALREADY_EXISTS, если она уже выполняется,
+	// INVALID_ARGUMENT, если репозиторий не очищается с параметрами сервера,
+	// UNAVAILABLE, если экземпляр в резерве при выборе лидера
+	StartRun(ctx context.Context, in *StartRunRequest, opts ...grpc.CallOption) (*Run, error)
+	// ListRuns возвращает историю запусков, начиная с последнего
+	ListRuns(ctx context.Context, in *ListRunsRequest, opts ...grpc.CallOption) (*ListRunsResponse, error)
+	// GetRun возвращает запуск вместе с его выводом
+	GetRun(ctx context.Context, in *GetRunRequest, opts ...grpc.CallOption) (*Run, error)
+	// WatchRun передает ход запуска: строки вывода, обработку каждого репозитория
+	// и итог запуска последним сообщением
+	WatchRun(ctx context.Context, in *WatchRunRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RunEvent], error)
+	// GetPlan составляет план очистки без удаления образов
+	GetPlan(ctx context.Context, in *GetPlanRequest, opts ...grpc.CallOption) (*GetPlanResponse, error)
+}
+
+type cleanerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCleanerClient(cc grpc.ClientConnInterface) CleanerClient {
+	return &cleanerClient{cc}
+}
+
+func (c *cleanerClient) StartRun(ctx context.Context, in *StartRunRequest, opts ...grpc.CallOption) (*Run, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Run)
+	err := c.cc.Invoke(ctx, Cleaner_StartRun_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cleanerClient) ListRuns(ctx context.Context, in *ListRunsRequest, opts ...grpc.CallOption) (*ListRunsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRunsResponse)
+	err := c.cc.Invoke(ctx, Cleaner_ListRuns_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cleanerClient) GetRun(ctx context.Context, in *GetRunRequest, opts ...grpc.CallOption) (*Run, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Run)
+	err := c.cc.Invoke(ctx, Cleaner_GetRun_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cleanerClient) WatchRun(ctx context.Context, in *WatchRunRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RunEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Cleaner_ServiceDesc.Streams[0], Cleaner_WatchRun_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRunRequest, RunEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Cleaner_WatchRunClient = grpc.ServerStreamingClient[RunEvent]
+
+func (c *cleanerClient) GetPlan(ctx context.Context, in *GetPlanRequest, opts ...grpc.CallOption) (*GetPlanResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetPlanResponse)
+	err := c.cc.Invoke(ctx, Cleaner_GetPlan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CleanerServer is the server API for Cleaner service.
+// All implementations must embed UnimplementedCleanerServer
+// for forward compatibility.
+//
+// Cleaner gRPC API очистки, повторяющее HTTP API подкоманды serve.
+// Все вызовы требуют метаданных authorization: Bearer <токен>
+type CleanerServer interface {
+	// StartRun запускает очистку; ALREADY_EXISTS, если она уже выполняется,
+	// INVALID_ARGUMENT, если репозиторий не очищается с параметрами сервера,
+	// UNAVAILABLE, если экземпляр в резерве при выборе лидера
+	StartRun(context.Context, *StartRunRequest) (*Run, error)
+	// ListRuns возвращает историю запусков, начиная с последнего
+	ListRuns(context.Context, *ListRunsRequest) (*ListRunsResponse, error)
+	// GetRun возвращает запуск вместе с его выводом
+	GetRun(context.Context, *GetRunRequest) (*Run, error)
+	// WatchRun передает ход запуска: строки вывода, обработку каждого репозитория
+	// и итог запуска последним сообщением
+	WatchRun(*WatchRunRequest, grpc.ServerStreamingServer[RunEvent]) error
+	// GetPlan составляет план очистки без удаления образов
+	GetPlan(context.Context, *GetPlanRequest) (*GetPlanResponse, error)
+	mustEmbedUnimplementedCleanerServer()
+}
+
+// UnimplementedCleanerServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCleanerServer struct{}
+
+func (UnimplementedCleanerServer) StartRun(context.Context, *StartRunRequest) (*Run, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartRun not implemented")
+}
+func (UnimplementedCleanerServer) ListRuns(context.Context, *ListRunsRequest) (*ListRunsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRuns not implemented")
+}
+func (UnimplementedCleanerServer) GetRun(context.Context, *GetRunRequest) (*Run, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRun not implemented")
+}
+func (UnimplementedCleanerServer) WatchRun(*WatchRunRequest, grpc.ServerStreamingServer[RunEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchRun not implemented")
+}
+func (UnimplementedCleanerServer) GetPlan(context.Context, *GetPlanRequest) (*GetPlanResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPlan not implemented")
+}
+func (UnimplementedCleanerServer) mustEmbedUnimplementedCleanerServer() {}
+func (UnimplementedCleanerServer) testEmbeddedByValue()                 {}
+
+// UnsafeCleanerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CleanerServer will
+// result in compilation errors.
+type UnsafeCleanerServer interface {
+	mustEmbedUnimplementedCleanerServer()
+}
+
+func RegisterCleanerServer(s grpc.ServiceRegistrar, srv CleanerServer) {
+	// If the following call pancis, it indicates UnimplementedCleanerServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Cleaner_ServiceDesc, srv)
+}
+
+func _Cleaner_StartRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CleanerServer).StartRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Cleaner_StartRun_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CleanerServer).StartRun(ctx, req.(*StartRunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cleaner_ListRuns_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRunsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CleanerServer).ListRuns(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Cleaner_ListRuns_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CleanerServer).ListRuns(ctx, req.(*ListRunsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cleaner_GetRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CleanerServer).GetRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Cleaner_GetRun_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CleanerServer).GetRun(ctx, req.(*GetRunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cleaner_WatchRun_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRunRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CleanerServer).WatchRun(m, &grpc.GenericServerStream[WatchRunRequest, RunEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Cleaner_WatchRunServer = grpc.ServerStreamingServer[RunEvent]
+
+func _Cleaner_GetPlan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPlanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CleanerServer).GetPlan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Cleaner_GetPlan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CleanerServer).GetPlan(ctx, req.(*GetPlanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Cleaner_ServiceDesc is the grpc.ServiceDesc for Cleaner service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Cleaner_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "registrycleaner.v1.Cleaner",
+	HandlerType: (*CleanerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartRun",
+			Handler:    _Cleaner_StartRun_Handler,
+		},
+		{
+			MethodName: "ListRuns",
+			Handler:    _Cleaner_ListRuns_Handler,
+		},
+		{
+			MethodName: "GetRun",
+			Handler:    _Cleaner_GetRun_Handler,
+		},
+		{
+			MethodName: "GetPlan",
+			Handler:    _Cleaner_GetPlan_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchRun",
+			Handler:       _Cleaner_WatchRun_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "cleaner.proto",
+}
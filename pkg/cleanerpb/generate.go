@@ -0,0 +1,4 @@
+// Package cleanerpb содержит gRPC API очистки, сгенерированное из cleaner.proto
+package cleanerpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative cleaner.proto
@@ -0,0 +1,69 @@
+package cleanup
+
+import (
+	"fmt"
+	"strings"
+
+	"registryCleaner/pkg/registry"
+)
+
+// AnnotationRule условие на аннотацию манифеста или метку образа: ключ и значение,
+// пустое значение подходит под любое
+type AnnotationRule struct {
+	Key   string
+	Value string
+}
+
+// ParseAnnotationRule разбирает условие вида "retention=permanent" или "retention"
+func ParseAnnotationRule(s string) (AnnotationRule, error) {
+	key, value, _ := strings.Cut(s, "=")
+	if key == "" {
+		return AnnotationRule{}, fmt.Errorf("условие %q должно иметь вид <ключ>[=<значение>]", s)
+	}
+	return AnnotationRule{Key: key, Value: value}, nil
+}
+
+// Match сообщает, подходит ли образ под условие по аннотациям манифеста или меткам конфигурации
+func (r AnnotationRule) Match(img registry.ImageInfo) bool {
+	for _, values := range []map[string]string{img.Annotations, img.Labels} {
+		if value, ok := values[r.Key]; ok && (r.Value == "" || value == r.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeepAnnotatedPolicy сохраняет кандидатов базовой политики на удаление, подходящих хотя бы
+// под одно условие, например образы с аннотацией retention=permanent
+type KeepAnnotatedPolicy struct {
+	Base  Policy
+	Rules []AnnotationRule
+}
+
+// Select переносит в сохраняемые кандидатов с подходящими аннотациями или метками
+func (p KeepAnnotatedPolicy) Select(images []registry.ImageInfo) (keep, remove []registry.ImageInfo) {
+	keep, candidates := p.Base.Select(images)
+	for _, img := range candidates {
+		if p.match(img) {
+			keep = append(keep, img)
+		} else {
+			remove = append(remove, img)
+		}
+	}
+	return keep, remove
+}
+
+// match сообщает, подходит ли образ хотя бы под одно условие
+func (p KeepAnnotatedPolicy) match(img registry.ImageInfo) bool {
+	for _, rule := range p.Rules {
+		if rule.Match(img) {
+			return true
+		}
+	}
+	return false
+}
+
+// Skip пропускает репозиторий, если его пропускает базовая политика
+func (p KeepAnnotatedPolicy) Skip(tags []string) bool {
+	return baseSkip(p.Base, tags)
+}
@@ -0,0 +1,173 @@
+package cleanup
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// testEpoch время создания самого старого тестового образа
+var testEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testDigest возвращает digest тестового образа по имени
+func testDigest(name string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(name)))
+}
+
+// fakeImage образ в fakeBackend
+type fakeImage struct {
+	digest  string
+	created time.Time
+}
+
+// fakeBackend backend в памяти для тестов Planner и Executor
+type fakeBackend struct {
+	mu sync.Mutex
+	// repositories теги репозиториев
+	repositories map[string]map[string]fakeImage
+	// resolveErrs ошибки получения digest по тегу
+	resolveErrs map[string]error
+	// metaErrs ошибки получения метаданных по тегу
+	metaErrs map[string]error
+	// deleteErrs ошибки удаления по digest
+	deleteErrs map[string]error
+	// deleted digest удаленных манифестов в порядке удаления
+	deleted []string
+}
+
+// newFakeBackend создает backend с репозиторием, теги которого созданы по порядку с
+// интервалом в час: первый тег самый старый
+func newFakeBackend(repository string, tags ...string) *fakeBackend {
+	b := &fakeBackend{
+		repositories: make(map[string]map[string]fakeImage),
+		resolveErrs:  make(map[string]error),
+		metaErrs:     make(map[string]error),
+		deleteErrs:   make(map[string]error),
+	}
+	images := make(map[string]fakeImage)
+	for i, tag := range tags {
+		images[tag] = fakeImage{digest: testDigest(repository + ":" + tag), created: testEpoch.Add(time.Duration(i) * time.Hour)}
+	}
+	b.repositories[repository] = images
+	return b
+}
+
+func (b *fakeBackend) ListRepositories(ctx context.Context) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var repositories []string
+	for repo := range b.repositories {
+		repositories = append(repositories, repo)
+	}
+	sort.Strings(repositories)
+	return repositories, nil
+}
+
+func (b *fakeBackend) ListTags(ctx context.Context, repository string) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	images, ok := b.repositories[repository]
+	if !ok {
+		return nil, fmt.Errorf("репозиторий %s: %w", repository, registry.ErrNotFound)
+	}
+	var tags []string
+	for tag := range images {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+func (b *fakeBackend) ResolveDigest(ctx context.Context, repository, tag string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.resolveErrs[tag]; err != nil {
+		return "", err
+	}
+	img, ok := b.repositories[repository][tag]
+	if !ok {
+		return "", fmt.Errorf("%s:%s: %w", repository, tag, registry.ErrNotFound)
+	}
+	return img.digest, nil
+}
+
+func (b *fakeBackend) GetImageMeta(ctx context.Context, repository, tag string) (registry.ImageMeta, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.metaErrs[tag]; err != nil {
+		return registry.ImageMeta{}, err
+	}
+	img, ok := b.repositories[repository][tag]
+	if !ok {
+		return registry.ImageMeta{}, fmt.Errorf("%s:%s: %w", repository, tag, registry.ErrNotFound)
+	}
+	return registry.ImageMeta{Created: img.created, Size: 1024}, nil
+}
+
+// Delete удаляет манифест и все теги, которые на него указывают
+func (b *fakeBackend) Delete(ctx context.Context, repository, digest string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.deleteErrs[digest]; err != nil {
+		return err
+	}
+	found := false
+	for tag, img := range b.repositories[repository] {
+		if img.digest == digest {
+			delete(b.repositories[repository], tag)
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("%s@%s: %w", repository, digest, registry.ErrNotFound)
+	}
+	b.deleted = append(b.deleted, digest)
+	return nil
+}
+
+// tags возвращает оставшиеся теги репозитория
+func (b *fakeBackend) tags(repository string) []string {
+	tags, _ := b.ListTags(context.Background(), repository)
+	return tags
+}
+
+// tagDeletingBackend fakeBackend, который умеет удалять теги (registry.TagDeleter)
+type tagDeletingBackend struct {
+	*fakeBackend
+	deletedTags []string
+}
+
+func (b *tagDeletingBackend) DeleteTag(ctx context.Context, repository, tag string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.repositories[repository][tag]; !ok {
+		return fmt.Errorf("%s:%s: %w", repository, tag, registry.ErrNotFound)
+	}
+	delete(b.repositories[repository], tag)
+	b.deletedTags = append(b.deletedTags, tag)
+	return nil
+}
+
+// immutableBackend fakeBackend с неизменяемыми тегами (registry.ImmutableChecker)
+type immutableBackend struct {
+	*fakeBackend
+	immutable map[string]bool
+}
+
+func (b *immutableBackend) Immutable(ctx context.Context, repository, tag string) (bool, error) {
+	return b.immutable[tag], nil
+}
+
+// imageTags возвращает теги образов
+func imageTags(images []registry.ImageInfo) []string {
+	var tags []string
+	for _, img := range images {
+		tags = append(tags, img.Tag)
+	}
+	return tags
+}
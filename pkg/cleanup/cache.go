@@ -0,0 +1,127 @@
+package cleanup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"registryCleaner/pkg/registry"
+)
+
+// metadataCacheVersion версия формата кэша. Кэш другой версии не загружается: в его
+// записях может не быть метаданных, добавленных позже, например аннотаций манифеста, слоев
+// или платформ
+const metadataCacheVersion = 4
+
+// metadataCacheFile содержимое файла кэша
+type metadataCacheFile struct {
+	Version int                           `json:"version"`
+	Entries map[string]registry.ImageMeta `json:"entries"`
+}
+
+// MetadataCache постоянный кэш метаданных образов по digest манифеста.
+// Время создания, размер и метки для digest никогда не меняются, поэтому повторные
+// запуски могут не скачивать манифесты и конфигурации уже известных образов
+type MetadataCache struct {
+	Path string
+
+	mu      sync.Mutex
+	entries map[string]registry.ImageMeta
+	dirty   bool
+}
+
+// LoadMetadataCache загружает кэш из файла, отсутствующий файл означает пустой кэш
+func LoadMetadataCache(path string) (*MetadataCache, error) {
+	cache := &MetadataCache{Path: path, entries: make(map[string]registry.ImageMeta)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения кэша %s: %v", path, err)
+	}
+
+	var file metadataCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("ошибка разбора кэша %s: %v", path, err)
+	}
+	// Кэш прежней версии заполняется заново
+	if file.Version == metadataCacheVersion && file.Entries != nil {
+		cache.entries = file.Entries
+	}
+
+	return cache, nil
+}
+
+// Get возвращает метаданные для digest, если они есть в кэше
+func (c *MetadataCache) Get(digest string) (registry.ImageMeta, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta, ok := c.entries[digest]
+	return meta, ok
+}
+
+// Put сохраняет метаданные для digest
+func (c *MetadataCache) Put(digest string, meta registry.ImageMeta) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[digest] = meta
+	c.dirty = true
+}
+
+// Len возвращает количество записей в кэше
+func (c *MetadataCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Save атомарно записывает кэш в файл, если он изменился
+func (c *MetadataCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	if err := writeJSONFile(c.Path, metadataCacheFile{Version: metadataCacheVersion, Entries: c.entries}); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// writeJSONFile атомарно записывает значение в файл в формате JSON
+func writeJSONFile(path string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, data)
+}
+
+// writeFile атомарно записывает содержимое в файл
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("ошибка записи %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка записи %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка записи %s: %v", path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("ошибка записи %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package cleanup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+
+	"registryCleaner/pkg/registry"
+)
+
+// CELPolicy вычисляет CEL-выражение для каждого образа, отобранного базовой политикой
+// для удаления, и удаляет образ, только если выражение истинно. В выражении доступны:
+// repository, tag, digest (string), created (timestamp), age (duration),
+// size (int, байты), labels и annotations (map(string, string)), а с ScanPolicy -
+// vulnerabilities (map(string, int), уровни важности в верхнем регистре) и scan
+// (map(string, string), вердикты сканера). Значения меток - строки, поэтому метка-флаг
+// проверяется сравнением: labels.keep == "true", а не !labels.keep. Если выражение не удалось вычислить, например
+// при обращении к отсутствующей метке, образ сохраняется
+type CELPolicy struct {
+	Base Policy
+	// Log получает предупреждения, по умолчанию os.Stdout
+	Log io.Writer
+
+	program cel.Program
+}
+
+// NewCELPolicy компилирует выражение expression
+func NewCELPolicy(base Policy, expression string) (*CELPolicy, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("repository", cel.StringType),
+		cel.Variable("tag", cel.StringType),
+		cel.Variable("digest", cel.StringType),
+		cel.Variable("created", cel.TimestampType),
+		cel.Variable("age", cel.DurationType),
+		cel.Variable("size", cel.IntType),
+		cel.Variable("labels", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("annotations", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("vulnerabilities", cel.MapType(cel.StringType, cel.IntType)),
+		cel.Variable("scan", cel.MapType(cel.StringType, cel.StringType)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания окружения CEL: %v", err)
+	}
+
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		if strings.Contains(expression, "labels") || strings.Contains(expression, "annotations") {
+			return nil, fmt.Errorf("ошибка компиляции выражения CEL: %v (значения labels и annotations - строки, сравнивайте их явно, например labels.keep == \"true\")", issues.Err())
+		}
+		return nil, fmt.Errorf("ошибка компиляции выражения CEL: %v", issues.Err())
+	}
+	if !ast.OutputType().IsExactType(cel.BoolType) {
+		return nil, fmt.Errorf("выражение CEL должно возвращать bool, а возвращает %s", ast.OutputType())
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подготовки выражения CEL: %v", err)
+	}
+
+	return &CELPolicy{Base: base, program: program}, nil
+}
+
+// printf выводит предупреждение
+func (p *CELPolicy) printf(format string, args ...interface{}) {
+	out := p.Log
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, format, args...)
+}
+
+// Select отбирает образы базовой политикой и вычисляет выражение для каждого кандидата на удаление
+func (p *CELPolicy) Select(images []registry.ImageInfo) (keep, remove []registry.ImageInfo) {
+	return confirmCandidates(p.Base, images, p.decide, p.printf)
+}
+
+// Skip пропускает репозиторий, если его пропускает базовая политика
+func (p *CELPolicy) Skip(tags []string) bool {
+	return baseSkip(p.Base, tags)
+}
+
+// decide вычисляет выражение для одного образа
+func (p *CELPolicy) decide(img registry.ImageInfo) (string, error) {
+	labels := img.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	annotations := img.Annotations
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	vulnerabilities := map[string]int{}
+	scan := map[string]string{}
+	if img.Scan != nil {
+		for severity, n := range img.Scan.Vulnerabilities {
+			vulnerabilities[severity] = n
+		}
+		for name, value := range img.Scan.Attributes {
+			scan[name] = value
+		}
+	}
+
+	out, _, err := p.program.Eval(map[string]interface{}{
+		"repository":  img.Repository,
+		"tag":         img.Tag,
+		"digest":      img.Digest,
+		"created":     img.Created,
+		"age":         time.Since(img.Created),
+		"size":        img.Size,
+		"labels":      labels,
+		"annotations": annotations,
+
+		"vulnerabilities": vulnerabilities,
+		"scan":            scan,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	remove, ok := out.Value().(bool)
+	if !ok {
+		return "", fmt.Errorf("выражение вернуло %v вместо bool", out.Value())
+	}
+	if remove {
+		return DecisionDelete, nil
+	}
+	return DecisionKeep, nil
+}
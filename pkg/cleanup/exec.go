@@ -0,0 +1,176 @@
+package cleanup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// Решения внешней программы
+const (
+	DecisionKeep   = "keep"
+	DecisionDelete = "delete"
+)
+
+// ExecCandidate образ-кандидат на удаление, передаваемый внешней программе в stdin
+type ExecCandidate struct {
+	Repository string            `json:"repository"`
+	Tag        string            `json:"tag"`
+	Digest     string            `json:"digest"`
+	Created    time.Time         `json:"created"`
+	Size       int64             `json:"size"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	// Annotations аннотации манифеста OCI
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Scan итоги сканирования образа с --scan-all
+	Scan *registry.ScanResult `json:"scan,omitempty"`
+}
+
+// execResponse ответ внешней программы в формате JSON
+type execResponse struct {
+	Decision string `json:"decision"`
+}
+
+// ExecPolicy передает каждый образ, отобранный базовой политикой для удаления,
+// внешней программе и удаляет его, только если программа ответила delete.
+// Программа получает ExecCandidate в формате JSON в stdin и выводит в stdout
+// keep или delete, либо JSON вида {"decision": "keep"}. Если программа завершилась
+// с ошибкой или ответ не распознан, образ сохраняется
+type ExecPolicy struct {
+	Base Policy
+	// Command программа и ее аргументы
+	Command []string
+	// Timeout ограничение времени одного запуска программы, 0 - без ограничения
+	Timeout time.Duration
+	// Log получает предупреждения, по умолчанию os.Stdout
+	Log io.Writer
+}
+
+// printf выводит предупреждение
+func (p *ExecPolicy) printf(format string, args ...interface{}) {
+	out := p.Log
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, format, args...)
+}
+
+// Select отбирает образы базовой политикой и запрашивает решение по каждому кандидату на удаление
+func (p *ExecPolicy) Select(images []registry.ImageInfo) (keep, remove []registry.ImageInfo) {
+	return confirmCandidates(p.Base, images, p.decide, p.printf)
+}
+
+// Skip пропускает репозиторий, если его пропускает базовая политика: внешняя программа
+// может только отменить удаление, но не добавить образы к удаляемым
+func (p *ExecPolicy) Skip(tags []string) bool {
+	return baseSkip(p.Base, tags)
+}
+
+// confirmCandidates отбирает образы базовой политикой и оставляет среди удаляемых только те,
+// по которым decide вернул delete. При ошибке decide образ сохраняется
+func confirmCandidates(base Policy, images []registry.ImageInfo, decide func(registry.ImageInfo) (string, error),
+	printf func(format string, args ...interface{})) (keep, remove []registry.ImageInfo) {
+	keep, candidates := base.Select(images)
+	for _, img := range candidates {
+		decision, err := decide(img)
+		if err != nil {
+			printf("  Предупреждение: политика не приняла решение по %s:%s, образ сохраняется: %v\n", img.Repository, img.Tag, err)
+			decision = DecisionKeep
+		}
+		if decision == DecisionDelete {
+			remove = append(remove, img)
+		} else {
+			keep = append(keep, img)
+		}
+	}
+	return keep, remove
+}
+
+// baseSkip проверяет, пропускает ли репозиторий базовая политика
+func baseSkip(base Policy, tags []string) bool {
+	prefilter, ok := base.(Prefilter)
+	return ok && prefilter.Skip(tags)
+}
+
+// newExecCandidate преобразует образ во входные данные внешней политики
+func newExecCandidate(img registry.ImageInfo) ExecCandidate {
+	return ExecCandidate{
+		Repository:  img.Repository,
+		Tag:         img.Tag,
+		Digest:      img.Digest,
+		Created:     img.Created,
+		Size:        img.Size,
+		Labels:      img.Labels,
+		Annotations: img.Annotations,
+		Scan:        img.Scan,
+	}
+}
+
+// candidateJSON кодирует образ для передачи внешней политике
+func candidateJSON(img registry.ImageInfo) ([]byte, error) {
+	input, err := json.Marshal(newExecCandidate(img))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка кодирования образа: %v", err)
+	}
+	return input, nil
+}
+
+// decide запускает внешнюю программу для одного образа
+func (p *ExecPolicy) decide(img registry.ImageInfo) (string, error) {
+	if len(p.Command) == 0 {
+		return "", fmt.Errorf("не задана программа")
+	}
+
+	input, err := candidateJSON(img)
+	if err != nil {
+		return "", err
+	}
+
+	ctx := context.Background()
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Command[0], p.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%v: %s", err, msg)
+		}
+		return "", err
+	}
+
+	return parseDecision(stdout.Bytes())
+}
+
+// parseDecision разбирает ответ внешней программы
+func parseDecision(output []byte) (string, error) {
+	answer := strings.TrimSpace(string(output))
+	if strings.HasPrefix(answer, "{") {
+		var resp execResponse
+		if err := json.Unmarshal([]byte(answer), &resp); err != nil {
+			return "", fmt.Errorf("ошибка декодирования ответа: %v", err)
+		}
+		answer = resp.Decision
+	}
+
+	switch decision := strings.ToLower(strings.TrimSpace(answer)); decision {
+	case DecisionKeep, DecisionDelete:
+		return decision, nil
+	default:
+		return "", fmt.Errorf("неизвестный ответ %q, ожидается keep или delete", answer)
+	}
+}
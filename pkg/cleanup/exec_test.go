@@ -0,0 +1,202 @@
+package cleanup
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"registryCleaner/pkg/registry"
+)
+
+// selectAllPolicy политика без Prefilter, которая отдает в кандидаты на удаление все образы
+type selectAllPolicy struct{}
+
+func (selectAllPolicy) Select(images []registry.ImageInfo) (keep, remove []registry.ImageInfo) {
+	return nil, images
+}
+
+// testImages возвращает образы с тегами tags, новые первыми
+func testImages(tags ...string) []registry.ImageInfo {
+	var images []registry.ImageInfo
+	for _, tag := range tags {
+		images = append(images, registry.ImageInfo{Repository: "app", Tag: tag, Digest: testDigest("app:" + tag)})
+	}
+	return images
+}
+
+func TestParseDecision(t *testing.T) {
+	tests := []struct {
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{output: "keep", want: DecisionKeep},
+		{output: " DELETE\n", want: DecisionDelete},
+		{output: `{"decision": "delete"}`, want: DecisionDelete},
+		{output: `{"decision": "maybe"}`, wantErr: true},
+		{output: `{"decision":`, wantErr: true},
+		{output: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDecision([]byte(tt.output))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseDecision(%q) = %q, %v; ожидалось %q, ошибка: %v", tt.output, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestExecPolicySelect(t *testing.T) {
+	tests := []struct {
+		name string
+		// script команда sh, получающая кандидата в stdin
+		script string
+		keep   []string
+		remove []string
+	}{
+		{
+			name:   "все кандидаты удаляются",
+			script: "echo delete",
+			keep:   []string{"v4"},
+			remove: []string{"v3", "v2", "v1"},
+		},
+		{
+			name:   "решение по данным образа",
+			script: `grep -q '"tag":"v2"' && echo keep || echo '{"decision": "delete"}'`,
+			keep:   []string{"v4", "v2"},
+			remove: []string{"v3", "v1"},
+		},
+		{
+			name:   "при ошибке программы образ сохраняется",
+			script: "echo delete; exit 1",
+			keep:   []string{"v4", "v3", "v2", "v1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &ExecPolicy{Base: KeepLastPolicy{N: 1}, Command: []string{"sh", "-c", tt.script}, Log: io.Discard}
+			keep, remove := policy.Select(testImages("v4", "v3", "v2", "v1"))
+			if got := imageTags(keep); !reflect.DeepEqual(got, tt.keep) {
+				t.Errorf("сохраняются %v, ожидалось %v", got, tt.keep)
+			}
+			if got := imageTags(remove); !reflect.DeepEqual(got, tt.remove) {
+				t.Errorf("удаляются %v, ожидалось %v", got, tt.remove)
+			}
+		})
+	}
+}
+
+func TestConfirmCandidates(t *testing.T) {
+	errDecide := errors.New("политика недоступна")
+
+	tests := []struct {
+		name   string
+		base   Policy
+		decide func(img registry.ImageInfo) (string, error)
+		keep   []string
+		remove []string
+	}{
+		{
+			name:   "все кандидаты удаляются",
+			base:   KeepLastPolicy{N: 1},
+			decide: func(registry.ImageInfo) (string, error) { return DecisionDelete, nil },
+			keep:   []string{"v4"},
+			remove: []string{"v3", "v2", "v1"},
+		},
+		{
+			name:   "политика сохраняет кандидатов",
+			base:   KeepLastPolicy{N: 1},
+			decide: func(registry.ImageInfo) (string, error) { return DecisionKeep, nil },
+			keep:   []string{"v4", "v3", "v2", "v1"},
+		},
+		{
+			name: "решение по каждому образу",
+			base: KeepLastPolicy{N: 1},
+			decide: func(img registry.ImageInfo) (string, error) {
+				if img.Tag == "v2" {
+					return DecisionKeep, nil
+				}
+				return DecisionDelete, nil
+			},
+			keep:   []string{"v4", "v2"},
+			remove: []string{"v3", "v1"},
+		},
+		{
+			name: "при ошибке образ сохраняется",
+			base: KeepLastPolicy{N: 1},
+			decide: func(img registry.ImageInfo) (string, error) {
+				if img.Tag == "v3" {
+					return "", errDecide
+				}
+				return DecisionDelete, nil
+			},
+			keep:   []string{"v4", "v3"},
+			remove: []string{"v2", "v1"},
+		},
+		{
+			name:   "неизвестное решение сохраняет образ",
+			base:   KeepLastPolicy{N: 2},
+			decide: func(registry.ImageInfo) (string, error) { return "maybe", nil },
+			keep:   []string{"v4", "v3", "v2", "v1"},
+		},
+		{
+			name: "образы, сохраненные базовой политикой, не передаются решению",
+			base: KeepLastPolicy{N: 4},
+			decide: func(img registry.ImageInfo) (string, error) {
+				t.Errorf("решение запрошено для сохраняемого образа %s", img.Tag)
+				return DecisionDelete, nil
+			},
+			keep: []string{"v4", "v3", "v2", "v1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			printf := func(format string, args ...interface{}) {}
+			keep, remove := confirmCandidates(tt.base, testImages("v4", "v3", "v2", "v1"), tt.decide, printf)
+			if got := imageTags(keep); !reflect.DeepEqual(got, tt.keep) {
+				t.Errorf("сохраняются %v, ожидалось %v", got, tt.keep)
+			}
+			if got := imageTags(remove); !reflect.DeepEqual(got, tt.remove) {
+				t.Errorf("удаляются %v, ожидалось %v", got, tt.remove)
+			}
+		})
+	}
+}
+
+func TestConfirmCandidatesKeepsBaseSlice(t *testing.T) {
+	images := testImages("v4", "v3", "v2")
+	keep, _ := confirmCandidates(KeepLastPolicy{N: 1}, images, func(registry.ImageInfo) (string, error) {
+		return DecisionKeep, nil
+	}, func(string, ...interface{}) {})
+	if len(keep) != 3 {
+		t.Fatalf("сохраняется %d образов, ожидалось 3", len(keep))
+	}
+	if got := imageTags(images); !reflect.DeepEqual(got, []string{"v4", "v3", "v2"}) {
+		t.Errorf("исходные образы изменены: %v", got)
+	}
+}
+
+func TestBaseSkip(t *testing.T) {
+	tests := []struct {
+		name string
+		base Policy
+		tags []string
+		skip bool
+	}{
+		{name: "тегов меньше keep-last", base: KeepLastPolicy{N: 3}, tags: []string{"v1", "v2"}, skip: true},
+		{name: "тегов столько же, сколько keep-last", base: KeepLastPolicy{N: 2}, tags: []string{"v1", "v2"}, skip: true},
+		{name: "тегов больше keep-last", base: KeepLastPolicy{N: 1}, tags: []string{"v1", "v2"}},
+		{name: "политика без Prefilter", base: selectAllPolicy{}, tags: []string{"v1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := baseSkip(tt.base, tt.tags); got != tt.skip {
+				t.Errorf("baseSkip = %v, ожидалось %v", got, tt.skip)
+			}
+		})
+	}
+}
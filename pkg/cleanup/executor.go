@@ -0,0 +1,377 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"registryCleaner/pkg/registry"
+)
+
+// ErrInterrupted возвращается, если выполнение плана остановлено Stopped или отменой контекста
+var ErrInterrupted = errors.New("очистка прервана")
+
+// ErrStillServed оборачивается в ошибки образов, которые registry удалил по ответу на
+// DELETE, но продолжает отдавать при проверке удаления
+var ErrStillServed = errors.New("registry сообщил об удалении, но манифест все еще доступен")
+
+// Executor выполняет планы очистки
+type Executor struct {
+	Backend registry.Backend
+	// Archiver, если задан, копирует образы в архивный registry перед удалением
+	Archiver *registry.Archiver
+	// Exporter, если задан, выгружает образы в OCI tar-архивы перед удалением
+	Exporter *registry.Exporter
+	// SBOM, если задан, сохраняет SBOM образов в архив перед удалением
+	SBOM *SBOMArchiver
+	// Quarantine, если задан, копирует образы в карантин перед удалением
+	Quarantine *Quarantine
+	// State, если задан, получает прогресс удаления для продолжения прерванного запуска
+	State *State
+	// Stopped, если задан, проверяется перед каждым удалением; true прекращает новые удаления
+	Stopped func() bool
+	// Limiter, если задан, ограничивает частоту запросов удаления: лишние удаления
+	// не отбрасываются, а ждут своей очереди
+	Limiter *rate.Limiter
+	// FailFast прекращает удаление после первой ошибки; по умолчанию ошибки
+	// отдельных образов собираются, а удаление продолжается
+	FailFast bool
+	// TagFallback удаляет тег, если registry отклоняет удаление по digest статусом 405
+	// или 400 и backend реализует registry.TagDeleter
+	TagFallback bool
+	// VerifyDeletes после удаления образов репозитория проверяет, что манифесты больше
+	// не отдаются, если backend реализует registry.ManifestChecker
+	VerifyDeletes bool
+	// VerifyDelay время после последнего удаления, через которое выполняется проверка:
+	// кэши и реплики registry могут отдавать манифест некоторое время после удаления
+	VerifyDelay time.Duration
+	// Location часовой пояс вывода времени создания, по умолчанию локальный
+	Location *time.Location
+	// Log получает ход работы, по умолчанию os.Stdout
+	Log io.Writer
+
+	setupHelpOnce sync.Once
+	lastDelete    time.Time
+	// deletedByTag образы, удаленные по тегу, в виде репозиторий:тег
+	deletedByTag map[string]bool
+}
+
+// printf выводит сообщение о ходе работы
+func (e *Executor) printf(format string, args ...interface{}) {
+	out := e.Log
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, format, args...)
+}
+
+// Execute удаляет образы, отмеченные в плане для удаления. Если backend реализует
+// registry.BulkDeleter, образы удаляются одним запросом после экспорта и архивации.
+// Возвращает объединенную ошибку по всем образам, которые не удалось удалить
+func (e *Executor) Execute(ctx context.Context, plan *RepositoryPlan) error {
+	if len(plan.Delete) == 0 {
+		return nil
+	}
+
+	e.printf("Очистка репозитория: %s\n", plan.Repository)
+	e.printf("  Найдено %d образов, сохраняем %d новейших, удаляем %d старых\n",
+		plan.Total(), len(plan.Keep), len(plan.Delete))
+
+	bulk, isBulk := e.Backend.(registry.BulkDeleter)
+	start := len(plan.Deleted)
+
+	sboms := e.archiveSBOMs(ctx, plan.Delete)
+	var errs []error
+	var ready []registry.ImageInfo
+	// Манифесты, удаленные по digest: их остальные теги удалены тем же запросом
+	removed := make(map[string]bool)
+	for i, img := range plan.Delete {
+		if (e.Stopped != nil && e.Stopped()) || ctx.Err() != nil {
+			e.printf("  Остановка: оставшиеся %d образов %s не удаляются\n", len(plan.Delete)-i, plan.Repository)
+			return errors.Join(append(errs, ErrInterrupted)...)
+		}
+		if removed[img.Digest] {
+			e.printf("  Тег %s:%s удален вместе с манифестом %s\n", img.Repository, img.Tag, img.Digest)
+			e.deleted(plan, img)
+			continue
+		}
+		e.printf("  Удаляем %s:%s (создан: %s, digest: %s)\n",
+			img.Repository, img.Tag, FormatTime(img.Created, e.Location), ShortDigest(img.Digest))
+		if err := e.prepare(ctx, img, sboms); err != nil {
+			errs = append(errs, fmt.Errorf("%s:%s: %w", img.Repository, img.Tag, err))
+			if e.FailFast {
+				// Подготовленные для удаления одним запросом образы тоже не удаляются
+				return e.failFast(plan, len(plan.Delete)-i-1+len(ready), errs)
+			}
+			continue
+		}
+		if isBulk {
+			ready = append(ready, img)
+			continue
+		}
+		if !e.wait(ctx) {
+			e.printf("  Остановка: оставшиеся %d образов %s не удаляются\n", len(plan.Delete)-i, plan.Repository)
+			return errors.Join(append(errs, ErrInterrupted)...)
+		}
+		if err := e.delete(ctx, img); err != nil {
+			errs = append(errs, e.deleteFailed(img, err))
+			if e.FailFast {
+				return e.failFast(plan, len(plan.Delete)-i-1, errs)
+			}
+			continue
+		}
+		if !e.deletedByTag[img.Repository+":"+img.Tag] {
+			removed[img.Digest] = true
+		}
+		e.deleted(plan, img)
+	}
+
+	if len(ready) > 0 {
+		if !e.wait(ctx) {
+			e.printf("  Остановка: оставшиеся %d образов %s не удаляются\n", len(ready), plan.Repository)
+			return errors.Join(append(errs, ErrInterrupted)...)
+		}
+		e.printf("  Удаляем %d образов %s одним запросом\n", len(ready), plan.Repository)
+		if err := bulk.DeleteImages(ctx, plan.Repository, ready); err != nil {
+			for _, img := range ready {
+				errs = append(errs, e.deleteFailed(img, err))
+			}
+		} else {
+			for _, img := range ready {
+				e.deleted(plan, img)
+			}
+		}
+	}
+
+	errs = append(errs, e.verifyDeleted(ctx, plan, start)...)
+	return errors.Join(errs...)
+}
+
+// verifyDeleted проверяет, что образы, удаленные начиная с индекса start в plan.Deleted,
+// больше не отдаются registry. Образы, которые registry продолжает отдавать, исключаются
+// из удаленных и возвращаются как ошибки
+func (e *Executor) verifyDeleted(ctx context.Context, plan *RepositoryPlan, start int) []error {
+	checker, ok := e.Backend.(registry.ManifestChecker)
+	if !e.VerifyDeletes || !ok || len(plan.Deleted) == start {
+		return nil
+	}
+
+	if delay := time.Until(e.lastDelete.Add(e.VerifyDelay)); delay > 0 {
+		e.printf("  Проверка удаления через %s\n", delay.Round(time.Second))
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		}
+	}
+
+	var errs []error
+	deleted := plan.Deleted[:start:start]
+	// Теги одного манифеста удаляются одним запросом, поэтому манифест проверяется один раз
+	served := make(map[string]bool)
+	checked := make(map[string]bool)
+	for _, img := range plan.Deleted[start:] {
+		// Образ, удаленный по тегу, проверяется по тегу: манифест может остаться у других тегов
+		reference := img.Digest
+		if e.deletedByTag[img.Repository+":"+img.Tag] {
+			reference = img.Tag
+		}
+		if !checked[reference] {
+			checked[reference] = true
+			exists, err := checker.ManifestExists(ctx, img.Repository, reference)
+			if err != nil {
+				e.printf("  Предупреждение: не удалось проверить удаление %s:%s: %v\n", img.Repository, img.Tag, err)
+			}
+			served[reference] = err == nil && exists
+		}
+		if served[reference] {
+			e.printf("  ⚠️  %s:%s (%s): %v\n", img.Repository, img.Tag, img.Digest, ErrStillServed)
+			errs = append(errs, fmt.Errorf("%s:%s: %w", img.Repository, img.Tag, ErrStillServed))
+			continue
+		}
+		deleted = append(deleted, img)
+	}
+	if len(errs) == 0 {
+		e.printf("  Удаление %d образов %s подтверждено\n", len(plan.Deleted)-start, plan.Repository)
+	}
+	plan.Deleted = deleted
+	return errs
+}
+
+// delete удаляет манифест образа по digest, а если registry отклоняет удаление по digest
+// и включен TagFallback - удаляет тег. В карантине в том же репозитории всегда удаляется
+// тег: удаление по digest удалило бы и тег карантина
+func (e *Executor) delete(ctx context.Context, img registry.ImageInfo) error {
+	if e.Quarantine != nil && e.Quarantine.Mode == QuarantineTag {
+		if err := e.Quarantine.Client.DeleteTag(ctx, img.Repository, img.Tag); err != nil {
+			return err
+		}
+		e.markDeletedByTag(img)
+		return nil
+	}
+
+	err := e.Backend.Delete(ctx, img.Repository, img.Digest)
+	tagDeleter, ok := e.Backend.(registry.TagDeleter)
+	if err == nil || !e.TagFallback || !ok ||
+		!(errors.Is(err, registry.ErrDeleteUnsupported) || errors.Is(err, registry.ErrDeleteRejected)) {
+		return err
+	}
+
+	e.printf("  Удаление %s по digest отклонено (%v), удаляем тег %s\n", img.Repository, err, img.Tag)
+	if err := tagDeleter.DeleteTag(ctx, img.Repository, img.Tag); err != nil {
+		return err
+	}
+	e.markDeletedByTag(img)
+	return nil
+}
+
+// markDeletedByTag запоминает, что образ удален по тегу, а не по digest
+func (e *Executor) markDeletedByTag(img registry.ImageInfo) {
+	if e.deletedByTag == nil {
+		e.deletedByTag = make(map[string]bool)
+	}
+	e.deletedByTag[img.Repository+":"+img.Tag] = true
+}
+
+// failFast сообщает, что после ошибки оставшиеся remaining образов не удаляются,
+// и возвращает накопленные ошибки
+func (e *Executor) failFast(plan *RepositoryPlan, remaining int, errs []error) error {
+	if remaining > 0 {
+		e.printf("  Остановка после ошибки (--fail-fast): оставшиеся %d образов %s не удаляются\n", remaining, plan.Repository)
+	}
+	return errors.Join(errs...)
+}
+
+// wait ждет очереди на запрос удаления по Limiter. Возвращает false, если за время
+// ожидания очистку остановили через Stopped или отменой контекста
+func (e *Executor) wait(ctx context.Context) bool {
+	if e.Limiter == nil {
+		return true
+	}
+	reservation := e.Limiter.Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		return true
+	}
+	if delay >= time.Second {
+		e.printf("  Ожидание очереди удаления: %s\n", delay.Round(time.Second))
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	// Stopped проверяется регулярно, чтобы долгое ожидание не задерживало остановку
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case <-ctx.Done():
+			reservation.Cancel()
+			return false
+		case <-ticker.C:
+			if e.Stopped != nil && e.Stopped() {
+				reservation.Cancel()
+				return false
+			}
+		}
+	}
+}
+
+// prepare выгружает и архивирует образ перед удалением, если это настроено. Образ, SBOM
+// которого не удалось сохранить (ошибка в sboms), не удаляется
+func (e *Executor) prepare(ctx context.Context, img registry.ImageInfo, sboms map[string]error) error {
+	if err := sboms[img.Tag]; err != nil {
+		return err
+	}
+	if e.Exporter != nil {
+		path, err := e.Exporter.ExportImage(ctx, img)
+		if err != nil {
+			e.printf("  Ошибка экспорта %s:%s, удаление пропущено: %v\n", img.Repository, img.Tag, err)
+			return err
+		}
+		e.printf("  Образ %s:%s выгружен в %s\n", img.Repository, img.Tag, path)
+	}
+	if e.Archiver != nil {
+		if err := e.Archiver.ArchiveImage(ctx, img); err != nil {
+			e.printf("  Ошибка архивации %s:%s, удаление пропущено: %v\n", img.Repository, img.Tag, err)
+			return err
+		}
+		e.printf("  Образ %s:%s скопирован в архивный Registry\n", img.Repository, img.Tag)
+	}
+	if e.Quarantine != nil {
+		tag, err := e.Quarantine.Copy(ctx, img)
+		if err != nil {
+			e.printf("  Ошибка помещения %s:%s в карантин, удаление пропущено: %v\n", img.Repository, img.Tag, err)
+			return err
+		}
+		e.printf("  Образ %s:%s помещен в карантин: %s:%s\n", img.Repository, img.Tag, e.Quarantine.Repository(img.Repository), tag)
+	}
+	return nil
+}
+
+// archiveSBOMs сохраняет SBOM удаляемых образов, если задан SBOM, и возвращает ошибки по
+// тегам. SBOM сохраняются до первого удаления: прикрепленный SBOM может удаляться тем же
+// планом раньше своего образа. У подписей и вложений cosign собственного SBOM нет
+func (e *Executor) archiveSBOMs(ctx context.Context, images []registry.ImageInfo) map[string]error {
+	if e.SBOM == nil {
+		return nil
+	}
+	errs := make(map[string]error)
+	for _, img := range images {
+		if _, artifact := SignatureSubject(img.Tag); artifact {
+			continue
+		}
+		path, err := e.SBOM.Archive(ctx, img)
+		if err != nil {
+			e.printf("  Ошибка сохранения SBOM %s:%s, удаление пропущено: %v\n", img.Repository, img.Tag, err)
+			errs[img.Tag] = err
+			continue
+		}
+		e.printf("  SBOM образа %s:%s сохранен в %s\n", img.Repository, img.Tag, path)
+	}
+	return errs
+}
+
+// deleteFailed выводит ошибку удаления образа и возвращает ее с указанием образа
+func (e *Executor) deleteFailed(img registry.ImageInfo, err error) error {
+	if errors.Is(err, registry.ErrDeleteUnsupported) {
+		e.setupHelpOnce.Do(e.printSetupHelp)
+	}
+	e.printf("  Ошибка при удалении %s:%s: %v\n", img.Repository, img.Tag, err)
+	return fmt.Errorf("%s:%s: %w", img.Repository, img.Tag, err)
+}
+
+// deleted отмечает образ удаленным и сохраняет прогресс
+func (e *Executor) deleted(plan *RepositoryPlan, img registry.ImageInfo) {
+	e.printf("  Успешно удален %s:%s\n", img.Repository, img.Tag)
+	plan.Deleted = append(plan.Deleted, img)
+	e.lastDelete = time.Now()
+	if e.State != nil {
+		if err := e.State.CheckpointDeletion(img.Repository); err != nil {
+			e.printf("  Предупреждение: не удалось сохранить прогресс: %v\n", err)
+		}
+	}
+}
+
+// printSetupHelp объясняет, как включить удаление в Registry
+func (e *Executor) printSetupHelp() {
+	e.printf("\n🚨 ОШИБКА КОНФИГУРАЦИИ REGISTRY:\n")
+	e.printf("Docker Registry не настроен для поддержки удаления образов.\n\n")
+	e.printf("📋 Для исправления:\n")
+	e.printf("1. Остановите Registry\n")
+	e.printf("2. Добавьте в config.yml:\n")
+	e.printf("   storage:\n")
+	e.printf("     delete:\n")
+	e.printf("       enabled: true\n")
+	e.printf("3. Перезапустите Registry\n\n")
+	e.printf("📄 Подробные инструкции: см. файл REGISTRY_SETUP.md\n\n")
+}
@@ -0,0 +1,181 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"registryCleaner/pkg/registry"
+)
+
+// planFor составляет план репозитория fakeBackend: удаляются теги remove
+func planFor(b *fakeBackend, repository string, remove ...string) *RepositoryPlan {
+	plan := &RepositoryPlan{Repository: repository}
+	for _, tag := range remove {
+		img := b.repositories[repository][tag]
+		plan.Delete = append(plan.Delete, registry.ImageInfo{Repository: repository, Tag: tag, Digest: img.digest, Created: img.created})
+	}
+	return plan
+}
+
+func TestExecutorExecute(t *testing.T) {
+	errDelete := errors.New("ошибка удаления")
+
+	tests := []struct {
+		name     string
+		remove   []string
+		setup    func(b *fakeBackend)
+		failFast bool
+		stopped  bool
+		// deleted теги, которые должны попасть в plan.Deleted
+		deleted []string
+		// left теги, оставшиеся в репозитории
+		left    []string
+		wantErr error
+	}{
+		{
+			name:    "удаляются все образы плана",
+			remove:  []string{"v1", "v2"},
+			deleted: []string{"v1", "v2"},
+			left:    []string{"v3"},
+		},
+		{
+			name:    "пустой план",
+			left:    []string{"v1", "v2", "v3"},
+			deleted: nil,
+		},
+		{
+			name:    "ошибка одного образа не останавливает удаление",
+			remove:  []string{"v1", "v2"},
+			setup:   func(b *fakeBackend) { b.deleteErrs[testDigest("app:v1")] = errDelete },
+			deleted: []string{"v2"},
+			left:    []string{"v1", "v3"},
+			wantErr: errDelete,
+		},
+		{
+			name:     "fail-fast останавливает удаление после ошибки",
+			remove:   []string{"v1", "v2"},
+			setup:    func(b *fakeBackend) { b.deleteErrs[testDigest("app:v1")] = errDelete },
+			failFast: true,
+			left:     []string{"v1", "v2", "v3"},
+			wantErr:  errDelete,
+		},
+		{
+			name:    "остановка до удаления",
+			remove:  []string{"v1", "v2"},
+			stopped: true,
+			left:    []string{"v1", "v2", "v3"},
+			wantErr: ErrInterrupted,
+		},
+		{
+			name:    "образ уже удален",
+			remove:  []string{"v1"},
+			setup:   func(b *fakeBackend) { delete(b.repositories["app"], "v1") },
+			left:    []string{"v2", "v3"},
+			wantErr: registry.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend("app", "v1", "v2", "v3")
+			plan := planFor(backend, "app", tt.remove...)
+			if tt.setup != nil {
+				tt.setup(backend)
+			}
+			executor := &Executor{
+				Backend:  backend,
+				FailFast: tt.failFast,
+				Stopped:  func() bool { return tt.stopped },
+				Log:      io.Discard,
+			}
+
+			err := executor.Execute(context.Background(), plan)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute вернул %v, ожидалась ошибка %v", err, tt.wantErr)
+			}
+			if got := imageTags(plan.Deleted); !reflect.DeepEqual(got, tt.deleted) {
+				t.Errorf("удалены %v, ожидалось %v", got, tt.deleted)
+			}
+			if got := backend.tags("app"); !reflect.DeepEqual(got, tt.left) {
+				t.Errorf("в репозитории остались %v, ожидалось %v", got, tt.left)
+			}
+		})
+	}
+}
+
+func TestExecutorTagFallback(t *testing.T) {
+	tests := []struct {
+		name        string
+		deleteErr   error
+		tagFallback bool
+		deletedTags []string
+		wantErr     bool
+	}{
+		{name: "405 с удалением тега", deleteErr: registry.ErrDeleteUnsupported, tagFallback: true, deletedTags: []string{"v1"}},
+		{name: "400 с удалением тега", deleteErr: registry.ErrDeleteRejected, tagFallback: true, deletedTags: []string{"v1"}},
+		{name: "405 без удаления тега", deleteErr: registry.ErrDeleteUnsupported, wantErr: true},
+		{name: "другая ошибка не удаляет тег", deleteErr: fmt.Errorf("статус 500"), tagFallback: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &tagDeletingBackend{fakeBackend: newFakeBackend("app", "v1", "v2")}
+			plan := planFor(backend.fakeBackend, "app", "v1")
+			backend.deleteErrs[testDigest("app:v1")] = tt.deleteErr
+			executor := &Executor{Backend: backend, TagFallback: tt.tagFallback, Log: io.Discard}
+
+			err := executor.Execute(context.Background(), plan)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute вернул %v, ошибка ожидалась: %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(backend.deletedTags, tt.deletedTags) {
+				t.Errorf("удалены теги %v, ожидалось %v", backend.deletedTags, tt.deletedTags)
+			}
+			if wantDeleted := len(tt.deletedTags); len(plan.Deleted) != wantDeleted {
+				t.Errorf("удалено образов %d, ожидалось %d", len(plan.Deleted), wantDeleted)
+			}
+		})
+	}
+}
+
+func TestExecutorSharedDigest(t *testing.T) {
+	backend := newFakeBackend("app", "v1", "v2", "v3")
+	// build-1 указывает на тот же манифест, что и v1, и удаляется тем же запросом
+	backend.repositories["app"]["build-1"] = backend.repositories["app"]["v1"]
+	plan := planFor(backend, "app", "v1", "build-1")
+
+	if err := (&Executor{Backend: backend, Log: io.Discard}).Execute(context.Background(), plan); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := imageTags(plan.Deleted); !reflect.DeepEqual(got, []string{"v1", "build-1"}) {
+		t.Errorf("удалены %v, ожидалось [v1 build-1]", got)
+	}
+	if len(backend.deleted) != 1 {
+		t.Errorf("запросов удаления %d, ожидался 1: %v", len(backend.deleted), backend.deleted)
+	}
+	if got := backend.tags("app"); !reflect.DeepEqual(got, []string{"v2", "v3"}) {
+		t.Errorf("в репозитории остались %v, ожидалось [v2 v3]", got)
+	}
+}
+
+func TestExecutorCancelledContext(t *testing.T) {
+	backend := newFakeBackend("app", "v1", "v2", "v3")
+	plan := planFor(backend, "app", "v1", "v2")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := (&Executor{Backend: backend, Log: io.Discard}).Execute(ctx, plan)
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("Execute вернул %v, ожидалась ошибка %v", err, ErrInterrupted)
+	}
+	if len(backend.deleted) != 0 {
+		t.Errorf("после отмены удалены %v", backend.deleted)
+	}
+}
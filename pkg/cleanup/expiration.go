@@ -0,0 +1,97 @@
+package cleanup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// ExpiresNever значение метки срока хранения, защищающее образ от удаления
+const ExpiresNever = "never"
+
+// expiresAfter срок хранения в формате quay.expires-after: число и единица s, m, h, d или w
+var expiresAfter = regexp.MustCompile(`^(\d+)([smhdw])$`)
+
+// expiresUnits длительность единиц срока хранения
+var expiresUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// ParseExpiresAfter разбирает срок хранения вида "12h", "5d" или "2w"
+func ParseExpiresAfter(s string) (time.Duration, error) {
+	m := expiresAfter.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("срок хранения %q должен иметь вид <число><s|m|h|d|w> или %s", s, ExpiresNever)
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, fmt.Errorf("некорректный срок хранения %q: %v", s, err)
+	}
+	return time.Duration(n) * expiresUnits[m[2]], nil
+}
+
+// ExpirationPolicy учитывает срок хранения из метки Label конфигурации образа, как
+// quay.expires-after: образ, срок которого истек с момента создания, удаляется независимо
+// от базовой политики, а образ с меткой never сохраняется. Образы без метки или с
+// некорректным значением остаются решению базовой политики
+type ExpirationPolicy struct {
+	Base  Policy
+	Label string
+	// Log получает предупреждения о некорректных метках, по умолчанию os.Stdout
+	Log io.Writer
+}
+
+// printf выводит сообщение о ходе работы
+func (p ExpirationPolicy) printf(format string, args ...interface{}) {
+	out := p.Log
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, format, args...)
+}
+
+// Select переносит в удаляемые образы с истекшим сроком хранения, а в сохраняемые - образы
+// с меткой never
+func (p ExpirationPolicy) Select(images []registry.ImageInfo) (keep, remove []registry.ImageInfo) {
+	baseKeep, baseRemove := p.Base.Select(images)
+	now := time.Now()
+	for _, img := range baseKeep {
+		if p.expired(img, now) {
+			remove = append(remove, img)
+		} else {
+			keep = append(keep, img)
+		}
+	}
+	for _, img := range baseRemove {
+		if strings.EqualFold(img.Labels[p.Label], ExpiresNever) {
+			keep = append(keep, img)
+		} else {
+			remove = append(remove, img)
+		}
+	}
+	return keep, remove
+}
+
+// expired сообщает, истек ли к now срок хранения образа
+func (p ExpirationPolicy) expired(img registry.ImageInfo, now time.Time) bool {
+	value, ok := img.Labels[p.Label]
+	if !ok || strings.EqualFold(value, ExpiresNever) {
+		return false
+	}
+	after, err := ParseExpiresAfter(value)
+	if err != nil {
+		p.printf("  Предупреждение: метка %s образа %s:%s не учитывается: %v\n", p.Label, img.Repository, img.Tag, err)
+		return false
+	}
+	return !now.Before(img.Created.Add(after))
+}
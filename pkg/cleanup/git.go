@@ -0,0 +1,138 @@
+package cleanup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+
+	"registryCleaner/pkg/registry"
+)
+
+// DefaultGitTagPattern выражение тегов, содержащих SHA коммита
+const DefaultGitTagPattern = `^([0-9a-f]{7,40})$`
+
+// GitCommits коммиты, достижимые из веток git-репозитория
+type GitCommits struct {
+	// shas полные SHA коммитов в порядке возрастания
+	shas []string
+}
+
+// LoadGitCommits клонирует репозиторий url без содержимого файлов и собирает коммиты,
+// достижимые из веток branches; пустой список означает все ветки. Используется git
+// из PATH с его настройками доступа: ключами SSH и помощниками учетных данных
+func LoadGitCommits(ctx context.Context, url string, branches []string) (*GitCommits, error) {
+	dir, err := os.MkdirTemp("", "registry-cleaner-git-")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания каталога для клона: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := runGit(ctx, "", "clone", "--bare", "--quiet", "--no-tags", "--filter=tree:0", url, dir); err != nil {
+		return nil, err
+	}
+
+	args := []string{"rev-list"}
+	if len(branches) == 0 {
+		args = append(args, "--branches")
+	}
+	for _, branch := range branches {
+		args = append(args, "refs/heads/"+branch)
+	}
+	out, err := runGit(ctx, dir, append(args, "--")...)
+	if err != nil {
+		return nil, err
+	}
+
+	shas := strings.Fields(string(out))
+	sort.Strings(shas)
+	return &GitCommits{shas: shas}, nil
+}
+
+// runGit выполняет команду git в каталоге dir и возвращает stdout
+func runGit(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// Len возвращает количество коммитов
+func (c *GitCommits) Len() int {
+	return len(c.shas)
+}
+
+// Contains сообщает, есть ли коммит с SHA, начинающимся с prefix
+func (c *GitCommits) Contains(prefix string) bool {
+	i := sort.SearchStrings(c.shas, prefix)
+	return i < len(c.shas) && strings.HasPrefix(c.shas[i], prefix)
+}
+
+// GitPolicy сохраняет образы, тег которых содержит SHA коммита, достижимого из веток
+// git-репозитория, и удаляет образы коммитов, исчезнувших после rebase или вместе
+// с удаленной веткой. SHA выделяется первой группой захвата Pattern; теги, не подходящие
+// под Pattern, обрабатывает базовая политика
+type GitPolicy struct {
+	Base    Policy
+	Commits *GitCommits
+	Pattern *regexp.Regexp
+}
+
+// Select отбирает образы коммитов по их наличию в ветках, остальные передает базовой политике
+func (p GitPolicy) Select(images []registry.ImageInfo) (keep, remove []registry.ImageInfo) {
+	var rest []registry.ImageInfo
+	for _, img := range images {
+		exists, ok := p.commitExists(img.Tag)
+		switch {
+		case !ok:
+			rest = append(rest, img)
+		case exists:
+			keep = append(keep, img)
+		default:
+			remove = append(remove, img)
+		}
+	}
+
+	baseKeep, baseRemove := p.Base.Select(rest)
+	return append(keep, baseKeep...), append(remove, baseRemove...)
+}
+
+// Skip пропускает репозиторий, если коммиты всех тегов с SHA есть в ветках, а остальные
+// теги пропускает базовая политика
+func (p GitPolicy) Skip(tags []string) bool {
+	var rest []string
+	for _, tag := range tags {
+		exists, ok := p.commitExists(tag)
+		if !ok {
+			rest = append(rest, tag)
+			continue
+		}
+		if !exists {
+			return false
+		}
+	}
+	return baseSkip(p.Base, rest)
+}
+
+// commitExists сообщает, есть ли в ветках коммит тега; ok = false, если тег не содержит SHA
+func (p GitPolicy) commitExists(tag string) (exists, ok bool) {
+	m := p.Pattern.FindStringSubmatch(tag)
+	if m == nil {
+		return false, false
+	}
+	sha := m[0]
+	if len(m) > 1 {
+		sha = m[1]
+	}
+	return p.Commits.Contains(strings.ToLower(sha)), true
+}
@@ -0,0 +1,140 @@
+package cleanup
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"registryCleaner/pkg/registry"
+)
+
+// groupFunc определяет группу тега и количество сохраняемых в ней образов;
+// ok = false означает, что тег обрабатывает базовая политика
+type groupFunc func(tag string) (group string, keep int, ok bool)
+
+// selectGrouped сохраняет в каждой группе заданное количество новейших образов, а образы
+// вне групп передает базовой политике. Порядок от новых к старым сохраняется
+func selectGrouped(base Policy, images []registry.ImageInfo, groupOf groupFunc) (keep, remove []registry.ImageInfo) {
+	var rest []registry.ImageInfo
+	counts := make(map[string]int)
+	for _, img := range images {
+		group, limit, ok := groupOf(img.Tag)
+		if !ok {
+			rest = append(rest, img)
+			continue
+		}
+		if counts[group] < limit {
+			keep = append(keep, img)
+		} else {
+			remove = append(remove, img)
+		}
+		counts[group]++
+	}
+
+	baseKeep, baseRemove := base.Select(rest)
+	return append(keep, baseKeep...), append(remove, baseRemove...)
+}
+
+// skipGrouped сообщает, что ни в одной группе нет лишних тегов, а остальные теги
+// пропускает базовая политика
+func skipGrouped(base Policy, tags []string, groupOf groupFunc) bool {
+	var rest []string
+	counts := make(map[string]int)
+	for _, tag := range tags {
+		group, limit, ok := groupOf(tag)
+		if !ok {
+			rest = append(rest, tag)
+			continue
+		}
+		counts[group]++
+		if counts[group] > limit {
+			return false
+		}
+	}
+	return baseSkip(base, rest)
+}
+
+// GroupKeepPolicy сохраняет PerGroup самых новых образов в каждой группе тегов вместо
+// общего числа новейших образов репозитория. Группа определяется группами захвата
+// Pattern: для "^(?P<branch>.+)-[0-9a-f]{7}$" теги feature-x-abc1234 и feature-x-def5678
+// попадают в группу feature-x. Выражение без групп захвата объединяет все подходящие теги
+// в одну группу. Теги, не подходящие под Pattern, обрабатывает базовая политика
+type GroupKeepPolicy struct {
+	Base     Policy
+	Pattern  *regexp.Regexp
+	PerGroup int
+}
+
+// Select делит образы на подходящие под Pattern и остальные; первые отбираются по группам,
+// вторые передаются базовой политике
+func (p GroupKeepPolicy) Select(images []registry.ImageInfo) (keep, remove []registry.ImageInfo) {
+	return selectGrouped(p.Base, images, p.group)
+}
+
+// Skip пропускает репозиторий, если ни в одной группе нет лишних тегов, а остальные
+// теги пропускает базовая политика
+func (p GroupKeepPolicy) Skip(tags []string) bool {
+	return skipGrouped(p.Base, tags, p.group)
+}
+
+// group возвращает ключ группы тега - значения групп захвата через "/"
+func (p GroupKeepPolicy) group(tag string) (string, int, bool) {
+	m := p.Pattern.FindStringSubmatch(tag)
+	if m == nil {
+		return "", 0, false
+	}
+	return strings.Join(m[1:], "/"), p.PerGroup, true
+}
+
+// PrefixClass класс тегов с общим префиксом и количеством сохраняемых образов
+type PrefixClass struct {
+	Prefix string
+	Keep   int
+}
+
+// ParsePrefixClass разбирает класс вида "release-=10"
+func ParsePrefixClass(s string) (PrefixClass, error) {
+	i := strings.LastIndex(s, "=")
+	if i <= 0 {
+		return PrefixClass{}, fmt.Errorf("класс тегов %q должен иметь вид <префикс>=<количество>", s)
+	}
+	keep, err := strconv.Atoi(s[i+1:])
+	if err != nil || keep < 0 {
+		return PrefixClass{}, fmt.Errorf("некорректное количество в классе тегов %q", s)
+	}
+	return PrefixClass{Prefix: s[:i], Keep: keep}, nil
+}
+
+// PrefixKeepPolicy сохраняет в каждом классе тегов свое количество новейших образов,
+// например 10 release-, 3 nightly- и 1 pr-. Тег относится к классу с самым длинным
+// подходящим префиксом; теги вне классов обрабатывает базовая политика
+type PrefixKeepPolicy struct {
+	Base    Policy
+	Classes []PrefixClass
+}
+
+// Select отбирает образы по классам, остальные передает базовой политике
+func (p PrefixKeepPolicy) Select(images []registry.ImageInfo) (keep, remove []registry.ImageInfo) {
+	return selectGrouped(p.Base, images, p.class)
+}
+
+// Skip пропускает репозиторий, если ни в одном классе нет лишних тегов, а остальные
+// теги пропускает базовая политика
+func (p PrefixKeepPolicy) Skip(tags []string) bool {
+	return skipGrouped(p.Base, tags, p.class)
+}
+
+// class возвращает префикс класса тега и количество сохраняемых в нем образов
+func (p PrefixKeepPolicy) class(tag string) (string, int, bool) {
+	var match *PrefixClass
+	for i, c := range p.Classes {
+		if strings.HasPrefix(tag, c.Prefix) && (match == nil || len(c.Prefix) > len(match.Prefix)) {
+			match = &p.Classes[i]
+		}
+	}
+	if match == nil {
+		return "", 0, false
+	}
+	return match.Prefix, match.Keep, true
+}
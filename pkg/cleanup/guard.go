@@ -0,0 +1,69 @@
+package cleanup
+
+import "fmt"
+
+// DeleteGuard ограничивает объем удаления, защищая registry от ошибочной политики
+type DeleteGuard struct {
+	// MaxPercent максимальная доля удаляемых образов в процентах, 0 - без ограничения
+	MaxPercent float64
+	// MaxCount максимальное количество удаляемых образов, 0 - без ограничения
+	MaxCount int
+}
+
+// Check проверяет планы очистки и возвращает список нарушенных порогов.
+// Пороги применяются как к каждому репозиторию, так и ко всему registry в целом. Образы
+// репозиториев, пропущенных без запроса метаданных, входят в общее количество образов
+// registry по числу тегов
+func (g *DeleteGuard) Check(plans []*RepositoryPlan) []string {
+	var violations []string
+	total, deleted := 0, 0
+
+	for _, plan := range plans {
+		if plan.Total() > 0 {
+			total += plan.Total()
+		} else {
+			total += len(plan.Tags)
+		}
+		deleted += len(plan.Delete)
+		violations = append(violations, g.check(plan.Repository, len(plan.Delete), plan.Total())...)
+	}
+
+	violations = append(violations, g.check("весь registry", deleted, total)...)
+	return violations
+}
+
+// check проверяет пороги для одной области удаления
+func (g *DeleteGuard) check(scope string, deleted, total int) []string {
+	var violations []string
+	if deleted == 0 {
+		return nil
+	}
+
+	if g.MaxCount > 0 && deleted > g.MaxCount {
+		violations = append(violations, fmt.Sprintf("%s: будет удалено %d образов, допустимо не более %d",
+			scope, deleted, g.MaxCount))
+	}
+
+	if g.MaxPercent > 0 {
+		percent := float64(deleted) * 100 / float64(total)
+		if percent > g.MaxPercent {
+			violations = append(violations, fmt.Sprintf("%s: будет удалено %.1f%% образов (%d из %d), допустимо не более %.1f%%",
+				scope, percent, deleted, total, g.MaxPercent))
+		}
+	}
+
+	return violations
+}
+
+// CheckStream проверяет план одного репозитория при потоковой очистке, когда планы
+// следующих репозиториев еще не составлены: доля удаляемых образов проверяется только
+// для репозитория, а количество - и для репозитория, и вместе с deletedBefore образами,
+// уже удаленными в этом запуске
+func (g *DeleteGuard) CheckStream(plan *RepositoryPlan, deletedBefore int) []string {
+	violations := g.check(plan.Repository, len(plan.Delete), plan.Total())
+	if deletedBefore > 0 && g.MaxCount > 0 && deletedBefore+len(plan.Delete) > g.MaxCount {
+		violations = append(violations, fmt.Sprintf("весь registry: будет удалено %d образов, допустимо не более %d",
+			deletedBefore+len(plan.Delete), g.MaxCount))
+	}
+	return violations
+}
@@ -0,0 +1,123 @@
+package cleanup
+
+import (
+	"testing"
+
+	"registryCleaner/pkg/registry"
+)
+
+// guardPlan возвращает план с keep сохраняемыми и remove удаляемыми образами
+func guardPlan(repository string, keep, remove int) *RepositoryPlan {
+	plan := &RepositoryPlan{Repository: repository}
+	for i := 0; i < keep; i++ {
+		plan.Keep = append(plan.Keep, registry.ImageInfo{Repository: repository})
+		plan.Tags = append(plan.Tags, "keep")
+	}
+	for i := 0; i < remove; i++ {
+		plan.Delete = append(plan.Delete, registry.ImageInfo{Repository: repository})
+		plan.Tags = append(plan.Tags, "remove")
+	}
+	return plan
+}
+
+// skippedPlan возвращает план репозитория, пропущенного без запроса метаданных
+func skippedPlan(repository string, tags int) *RepositoryPlan {
+	plan := &RepositoryPlan{Repository: repository}
+	for i := 0; i < tags; i++ {
+		plan.Tags = append(plan.Tags, "tag")
+	}
+	return plan
+}
+
+func TestDeleteGuardCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		guard      DeleteGuard
+		plans      []*RepositoryPlan
+		violations int
+	}{
+		{
+			name:  "без порогов",
+			guard: DeleteGuard{},
+			plans: []*RepositoryPlan{guardPlan("app", 0, 10)},
+		},
+		{
+			name:  "в пределах порогов",
+			guard: DeleteGuard{MaxPercent: 50, MaxCount: 10},
+			plans: []*RepositoryPlan{guardPlan("app", 5, 5), guardPlan("api", 8, 2)},
+		},
+		{
+			name:       "превышена доля в репозитории",
+			guard:      DeleteGuard{MaxPercent: 50},
+			plans:      []*RepositoryPlan{guardPlan("app", 2, 8), guardPlan("api", 30, 0)},
+			violations: 1,
+		},
+		{
+			name:       "превышена доля в репозитории и в registry",
+			guard:      DeleteGuard{MaxPercent: 50},
+			plans:      []*RepositoryPlan{guardPlan("app", 2, 8)},
+			violations: 2,
+		},
+		{
+			name:       "превышено количество в registry",
+			guard:      DeleteGuard{MaxCount: 5},
+			plans:      []*RepositoryPlan{guardPlan("app", 5, 4), guardPlan("api", 5, 4)},
+			violations: 1,
+		},
+		{
+			name:       "превышено количество в репозитории и в registry",
+			guard:      DeleteGuard{MaxCount: 5},
+			plans:      []*RepositoryPlan{guardPlan("app", 0, 6)},
+			violations: 2,
+		},
+		{
+			name:  "пропущенные репозитории учитываются по тегам",
+			guard: DeleteGuard{MaxPercent: 50},
+			plans: []*RepositoryPlan{guardPlan("app", 1, 1), skippedPlan("api", 2), skippedPlan("web", 2)},
+		},
+		{
+			name:       "пропущенный репозиторий снижает долю в registry",
+			guard:      DeleteGuard{MaxPercent: 40},
+			plans:      []*RepositoryPlan{guardPlan("app", 1, 1), skippedPlan("api", 2)},
+			violations: 1,
+		},
+		{
+			name:  "ничего не удаляется",
+			guard: DeleteGuard{MaxPercent: 1, MaxCount: 1},
+			plans: []*RepositoryPlan{guardPlan("app", 3, 0), skippedPlan("api", 2)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := tt.guard.Check(tt.plans)
+			if len(violations) != tt.violations {
+				t.Errorf("нарушений %d, ожидалось %d: %v", len(violations), tt.violations, violations)
+			}
+		})
+	}
+}
+
+func TestDeleteGuardCheckStream(t *testing.T) {
+	tests := []struct {
+		name          string
+		guard         DeleteGuard
+		plan          *RepositoryPlan
+		deletedBefore int
+		violations    int
+	}{
+		{name: "в пределах порогов", guard: DeleteGuard{MaxPercent: 50, MaxCount: 10}, plan: guardPlan("app", 5, 5), deletedBefore: 4},
+		{name: "превышена доля в репозитории", guard: DeleteGuard{MaxPercent: 50}, plan: guardPlan("app", 1, 4), violations: 1},
+		{name: "превышено количество вместе с удаленными", guard: DeleteGuard{MaxCount: 10}, plan: guardPlan("app", 5, 5), deletedBefore: 6, violations: 1},
+		{name: "первый репозиторий не проверяется дважды", guard: DeleteGuard{MaxCount: 3}, plan: guardPlan("app", 0, 4), violations: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := tt.guard.CheckStream(tt.plan, tt.deletedBefore)
+			if len(violations) != tt.violations {
+				t.Errorf("нарушений %d, ожидалось %d: %v", len(violations), tt.violations, violations)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package cleanup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"registryCleaner/pkg/registry"
+)
+
+// Hold запись списка удержания: ссылка <репозиторий>:<тег> или <репозиторий>@<digest>,
+// где репозиторий и тег могут содержать шаблоны *, ? и [...], и причина удержания
+type Hold struct {
+	Reference string `json:"reference"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// UnmarshalJSON принимает запись как объект или как строку со ссылкой
+func (h *Hold) UnmarshalJSON(data []byte) error {
+	var reference string
+	if err := json.Unmarshal(data, &reference); err == nil {
+		*h = Hold{Reference: reference}
+		return nil
+	}
+	type hold Hold
+	return json.Unmarshal(data, (*hold)(h))
+}
+
+// HeldImage образ, сохраненный из-за удержания, и причина удержания
+type HeldImage struct {
+	registry.ImageInfo
+	Reason string
+}
+
+// holdRule разобранная запись списка удержания: тег или digest
+type holdRule struct {
+	protectedRule
+	digest string
+	reason string
+}
+
+// LegalHolds список удержания (legal hold): образы из него не удаляются, пока их не
+// снимут с удержания. Список ведется вне конфигурации очистки, например юристами или
+// службой комплаенса, и запрашивается перед каждым запуском
+type LegalHolds struct {
+	rules []holdRule
+}
+
+// FetchLegalHolds запрашивает список удержания по url. Сервис отвечает JSON-массивом
+// записей или объектом {"holds": [...]}; запись - строка со ссылкой или объект
+// {"reference": "payment-service:v1", "reason": "LEGAL-42"}. Token, если задан,
+// передается в заголовке Authorization: Bearer. Запрос выполняется клиентом client,
+// чтобы учитывались настройки транспорта программы
+func FetchLegalHolds(ctx context.Context, client *http.Client, url, token string) (*LegalHolds, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный адрес списка удержания: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса списка удержания: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("сервис списка удержания вернул статус %d", resp.StatusCode)
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("ошибка разбора списка удержания: %v", err)
+	}
+	return ParseLegalHolds(raw)
+}
+
+// ParseLegalHolds разбирает список удержания в формате FetchLegalHolds
+func ParseLegalHolds(data []byte) (*LegalHolds, error) {
+	var holds []Hold
+	if err := json.Unmarshal(data, &holds); err != nil {
+		var wrapped struct {
+			Holds []Hold `json:"holds"`
+		}
+		if err := json.Unmarshal(data, &wrapped); err != nil {
+			return nil, fmt.Errorf("ошибка разбора списка удержания: %v", err)
+		}
+		holds = wrapped.Holds
+	}
+
+	list := &LegalHolds{}
+	for _, hold := range holds {
+		rule, err := parseHoldRule(hold)
+		if err != nil {
+			return nil, err
+		}
+		list.rules = append(list.rules, rule)
+	}
+	return list, nil
+}
+
+// parseHoldRule разбирает ссылку записи удержания
+func parseHoldRule(hold Hold) (holdRule, error) {
+	if repository, digest, ok := strings.Cut(hold.Reference, "@"); ok {
+		if repository == "" || !strings.Contains(digest, ":") {
+			return holdRule{}, fmt.Errorf("запись удержания %q должна иметь вид <репозиторий>@<digest>", hold.Reference)
+		}
+		if _, err := path.Match(repository, ""); err != nil {
+			return holdRule{}, fmt.Errorf("некорректный шаблон %q: %v", repository, err)
+		}
+		return holdRule{protectedRule: protectedRule{repository: repository}, digest: digest, reason: hold.Reason}, nil
+	}
+	rule, err := parseProtectedRule(hold.Reference)
+	if err != nil {
+		return holdRule{}, err
+	}
+	return holdRule{protectedRule: rule, reason: hold.Reason}, nil
+}
+
+// Len возвращает количество записей
+func (h *LegalHolds) Len() int {
+	return len(h.rules)
+}
+
+// Match сообщает, находится ли образ на удержании, и возвращает причину
+func (h *LegalHolds) Match(img registry.ImageInfo) (reason string, held bool) {
+	for _, rule := range h.rules {
+		if ok, _ := path.Match(rule.repository, img.Repository); !ok {
+			continue
+		}
+		if rule.digest != "" {
+			if rule.digest == img.Digest {
+				return rule.reason, true
+			}
+			continue
+		}
+		if ok, _ := path.Match(rule.tag, img.Tag); ok {
+			return rule.reason, true
+		}
+	}
+	return "", false
+}
+
+// Keep переносит в сохраняемые кандидатов на удаление, находящихся на удержании, а также
+// образы с тем же digest: удаление манифеста по digest удалило бы и удерживаемый тег.
+// Сохраненные образы добавляются в plan.Held и возвращаются
+func (h *LegalHolds) Keep(plan *RepositoryPlan) []HeldImage {
+	reasons := make(map[string]string)
+	for _, images := range [][]registry.ImageInfo{plan.Keep, plan.Delete} {
+		for _, img := range images {
+			if reason, ok := h.Match(img); ok && reasons[img.Digest] == "" {
+				reasons[img.Digest] = reason
+			}
+		}
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	var held []HeldImage
+	var remove []registry.ImageInfo
+	for _, img := range plan.Delete {
+		reason, ok := reasons[img.Digest]
+		if !ok {
+			remove = append(remove, img)
+			continue
+		}
+		held = append(held, HeldImage{ImageInfo: img, Reason: reason})
+		plan.Keep = append(plan.Keep, img)
+	}
+	plan.Delete = remove
+	plan.Held = append(plan.Held, held...)
+	return held
+}
+
+// keepHeld сохраняет образы, находящиеся на удержании
+func (p *Planner) keepHeld(plan *RepositoryPlan) {
+	if p.Holds == nil {
+		return
+	}
+	for _, img := range p.Holds.Keep(plan) {
+		p.printf("  Образ %v на удержании, сохраняется\n", img)
+	}
+}
+
+// String возвращает образ в виде <репозиторий>:<тег> и причину удержания в скобках
+func (h HeldImage) String() string {
+	if h.Reason == "" {
+		return h.Repository + ":" + h.Tag
+	}
+	return h.Repository + ":" + h.Tag + " (" + h.Reason + ")"
+}
@@ -0,0 +1,137 @@
+package cleanup
+
+import (
+	"sort"
+	"strings"
+
+	"registryCleaner/pkg/registry"
+)
+
+// LayerUsage использование слоя образами registry
+type LayerUsage struct {
+	Digest string
+	Size   int64
+	// Images количество образов (различных манифестов), содержащих слой
+	Images int
+	// Repositories репозитории, образы которых содержат слой
+	Repositories []string
+	// Example один из тегов со слоем в виде репозиторий:тег
+	Example string
+}
+
+// Saved возвращает место, сэкономленное тем, что слой хранится один раз
+func (l LayerUsage) Saved() int64 {
+	return l.Size * int64(l.Images-1)
+}
+
+// LayerReport отчет о дедупликации слоев
+type LayerReport struct {
+	// Images количество различных манифестов
+	Images int
+	// ImagesWithoutLayers образы, слои которых backend не сообщает
+	ImagesWithoutLayers int
+	// Layers слои по убыванию количества образов, затем размера
+	Layers []LayerUsage
+	// StoredSize размер всех различных слоев, то есть занятое в хранилище место
+	StoredSize int64
+	// ReferencedSize суммарный размер слоев всех образов без учета дедупликации
+	ReferencedSize int64
+}
+
+// AnalyzeLayers подсчитывает, сколько образов ссылается на каждый слой. Образы считаются
+// по репозиторию и digest, поэтому несколько тегов одного манифеста учитываются один раз;
+// blob конфигурации образа не учитывается
+func AnalyzeLayers(images []registry.ImageInfo) *LayerReport {
+	report := &LayerReport{}
+	layers := make(map[string]*LayerUsage)
+	seenImages := make(map[string]bool)
+	seenRepos := make(map[string]map[string]bool)
+	for _, img := range images {
+		key := img.Repository + "@" + img.Digest
+		if seenImages[key] {
+			continue
+		}
+		seenImages[key] = true
+		report.Images++
+		if len(img.Blobs) == 0 {
+			report.ImagesWithoutLayers++
+			continue
+		}
+
+		for _, blob := range img.Blobs {
+			if strings.Contains(blob.MediaType, "config") {
+				continue
+			}
+			layer, ok := layers[blob.Digest]
+			if !ok {
+				layer = &LayerUsage{Digest: blob.Digest, Size: blob.Size, Example: img.Repository + ":" + img.Tag}
+				layers[blob.Digest] = layer
+				seenRepos[blob.Digest] = make(map[string]bool)
+				report.StoredSize += blob.Size
+			}
+			layer.Images++
+			report.ReferencedSize += blob.Size
+			if !seenRepos[blob.Digest][img.Repository] {
+				seenRepos[blob.Digest][img.Repository] = true
+				layer.Repositories = append(layer.Repositories, img.Repository)
+			}
+		}
+	}
+
+	for _, layer := range layers {
+		report.Layers = append(report.Layers, *layer)
+	}
+	sort.Slice(report.Layers, func(i, j int) bool {
+		a, b := report.Layers[i], report.Layers[j]
+		if a.Images != b.Images {
+			return a.Images > b.Images
+		}
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.Digest < b.Digest
+	})
+	return report
+}
+
+// Shared возвращает до n слоев, общих для нескольких образов, начиная с самых используемых
+func (r *LayerReport) Shared(n int) []LayerUsage {
+	var shared []LayerUsage
+	for _, layer := range r.Layers {
+		if layer.Images > 1 {
+			shared = append(shared, layer)
+		}
+	}
+	return firstLayers(shared, n)
+}
+
+// Unique возвращает до n слоев, принадлежащих одному образу, начиная с самых больших
+func (r *LayerReport) Unique(n int) []LayerUsage {
+	var unique []LayerUsage
+	for _, layer := range r.Layers {
+		if layer.Images == 1 {
+			unique = append(unique, layer)
+		}
+	}
+	sort.SliceStable(unique, func(i, j int) bool { return unique[i].Size > unique[j].Size })
+	return firstLayers(unique, n)
+}
+
+// UniqueSize возвращает суммарный размер слоев, принадлежащих одному образу
+func (r *LayerReport) UniqueSize() int64 {
+	var size int64
+	for _, layer := range r.Layers {
+		if layer.Images == 1 {
+			size += layer.Size
+		}
+	}
+	return size
+}
+
+// firstLayers возвращает первые n слоев, n <= 0 - все
+func firstLayers(layers []LayerUsage, n int) []LayerUsage {
+	if n > 0 && len(layers) > n {
+		return layers[:n]
+	}
+	return layers
+}
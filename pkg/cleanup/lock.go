@@ -0,0 +1,464 @@
+package cleanup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// Lock блокировка, не позволяющая двум экземплярам очищать один registry одновременно
+type Lock interface {
+	Acquire(ctx context.Context) error
+	Release(ctx context.Context) error
+}
+
+// LockInfo сведения о владельце блокировки
+type LockInfo struct {
+	Owner    string    `json:"owner"`
+	Registry string    `json:"registry"`
+	Acquired time.Time `json:"acquired"`
+	Expires  time.Time `json:"expires"`
+}
+
+// NewLockInfo описывает блокировку, которую берет текущий процесс
+func NewLockInfo(registry string, ttl time.Duration) LockInfo {
+	host, _ := os.Hostname()
+	now := time.Now().UTC()
+	return LockInfo{
+		Owner:    fmt.Sprintf("%s/%d", host, os.Getpid()),
+		Registry: registry,
+		Acquired: now,
+		Expires:  now.Add(ttl),
+	}
+}
+
+// ErrLockLost блокировку перехватил другой экземпляр, например после истечения ее срока
+var ErrLockLost = errors.New("блокировка перехвачена другим экземпляром")
+
+// Renewer блокировка с ограниченным сроком, который продлевается, пока идет очистка
+type Renewer interface {
+	Renew(ctx context.Context, ttl time.Duration) error
+}
+
+// FileLock локальная блокировка через файл, создаваемый атомарно
+type FileLock struct {
+	Path string
+	Info LockInfo
+}
+
+// Acquire создает файл блокировки. Содержимое записывается во временный файл, который
+// затем связывается с путем блокировки, поэтому другой экземпляр никогда не видит файл
+// блокировки пустым. Файл с истекшим сроком считается брошенным и перезаписывается,
+// файл, который не удается разобрать, считается занятым
+func (l *FileLock) Acquire(ctx context.Context) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		err := l.create()
+		if err == nil {
+			return nil
+		}
+		if !os.IsExist(err) {
+			return fmt.Errorf("ошибка создания файла блокировки %s: %v", l.Path, err)
+		}
+
+		holder, err := l.readHolder()
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("файл блокировки %s занят: %v. Если очистка не выполняется, удалите его вручную", l.Path, err)
+		}
+		if time.Now().Before(holder.Expires) {
+			return fmt.Errorf("очистка уже выполняется: %s (с %s, блокировка %s)",
+				holder.Owner, holder.Acquired.Format("2006-01-02 15:04:05"), l.Path)
+		}
+
+		fmt.Printf("Предупреждение: удаляем устаревший файл блокировки %s\n", l.Path)
+		if err := os.Remove(l.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("ошибка удаления устаревшей блокировки %s: %v", l.Path, err)
+		}
+	}
+
+	return fmt.Errorf("не удалось захватить блокировку %s", l.Path)
+}
+
+// Renew продлевает срок блокировки, если файл все еще принадлежит этому экземпляру.
+// Новое содержимое записывается во временный файл и атомарно заменяет прежний
+func (l *FileLock) Renew(ctx context.Context, ttl time.Duration) error {
+	holder, err := l.readHolder()
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("ошибка чтения файла блокировки %s: %v", l.Path, err)
+	}
+	if err != nil || holder.Owner != l.Info.Owner {
+		return fmt.Errorf("%w: %s", ErrLockLost, l.Path)
+	}
+
+	info := l.Info
+	info.Expires = time.Now().UTC().Add(ttl)
+	tmp, err := l.writeTemp(info)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.Path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("ошибка продления блокировки %s: %v", l.Path, err)
+	}
+	l.Info = info
+	return nil
+}
+
+// Release удаляет файл блокировки, если он принадлежит этому экземпляру
+func (l *FileLock) Release(ctx context.Context) error {
+	if holder, err := l.readHolder(); err == nil && holder.Owner != l.Info.Owner {
+		return fmt.Errorf("файл блокировки %s не удален: блокировкой владеет %s", l.Path, holder.Owner)
+	}
+	if err := os.Remove(l.Path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("ошибка удаления файла блокировки %s: %v", l.Path, err)
+	}
+	return nil
+}
+
+// create атомарно создает файл блокировки с заполненным содержимым. Ошибка
+// удовлетворяет os.IsExist, если файл блокировки уже есть
+func (l *FileLock) create() error {
+	tmp, err := l.writeTemp(l.Info)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	return os.Link(tmp, l.Path)
+}
+
+// writeTemp записывает сведения о блокировке во временный файл рядом с файлом
+// блокировки и возвращает его путь
+func (l *FileLock) writeTemp(info LockInfo) (string, error) {
+	file, err := os.CreateTemp(filepath.Dir(l.Path), filepath.Base(l.Path)+".*")
+	if err != nil {
+		return "", err
+	}
+	err = json.NewEncoder(file).Encode(info)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), 0o644)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// readHolder читает владельца из файла блокировки
+func (l *FileLock) readHolder() (LockInfo, error) {
+	var holder LockInfo
+	data, err := os.ReadFile(l.Path)
+	if err != nil {
+		return holder, err
+	}
+	if err := json.Unmarshal(data, &holder); err != nil {
+		return holder, fmt.Errorf("содержимое не разобрано: %v", err)
+	}
+	return holder, nil
+}
+
+// Пустой blob "{}" из спецификации OCI, используемый как config и слой манифеста-маркера
+const (
+	emptyBlobMediaType = "application/vnd.oci.empty.v1+json"
+	emptyBlobDigest    = "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"
+	emptyBlobContent   = "{}"
+)
+
+// Аннотации манифеста-маркера блокировки
+const (
+	lockOwnerAnnotation   = "io.registry-cleaner.lock.owner"
+	lockExpiresAnnotation = "io.registry-cleaner.lock.expires"
+)
+
+// registryLockSettle наименьшая задержка перед перечитыванием записанного маркера
+// блокировки в registry; к ней добавляется случайная задержка такой же длины
+const registryLockSettle = 2 * time.Second
+
+// RegistryLock блокировка в виде тега в самом registry, видимая всем экземплярам
+// независимо от хоста. Registry API не поддерживает условную запись манифеста (If-Match),
+// поэтому захват не атомарен: маркер перечитывается после случайной задержки, и экземпляр,
+// чью запись тега перезаписал другой, получает ошибку. Гонка остается, если другой экземпляр
+// прочитал тег до записи маркера, а записал свой маркер уже после перечитывания: задержка
+// лишь делает такое совпадение маловероятным
+type RegistryLock struct {
+	Client     *registry.Client
+	Repository string
+	Tag        string
+	Info       LockInfo
+	// Settle задержка перед перечитыванием маркера, по умолчанию registryLockSettle
+	Settle time.Duration
+
+	digest string
+}
+
+// ParseRegistryLock разбирает ссылку на маркер блокировки вида repository:tag
+func ParseRegistryLock(client *registry.Client, reference string, info LockInfo) (*RegistryLock, error) {
+	i := strings.LastIndex(reference, ":")
+	if i <= 0 || i == len(reference)-1 {
+		return nil, fmt.Errorf("неверный формат маркера блокировки %q, ожидается repository:tag", reference)
+	}
+	return &RegistryLock{Client: client, Repository: reference[:i], Tag: reference[i+1:], Info: info}, nil
+}
+
+// Acquire записывает манифест-маркер, если он отсутствует или просрочен. Если захват
+// не удался после записи, записанный маркер удаляется
+func (l *RegistryLock) Acquire(ctx context.Context) error {
+	holder, err := l.readHolder(ctx)
+	if err != nil {
+		return err
+	}
+	if holder != nil && time.Now().Before(holder.Expires) {
+		return fmt.Errorf("очистка уже выполняется: %s (блокировка в registry %s:%s до %s)",
+			holder.Owner, l.Repository, l.Tag, holder.Expires.Format("2006-01-02 15:04:05"))
+	}
+
+	if err := l.ensureEmptyBlob(ctx); err != nil {
+		return err
+	}
+
+	digest, err := l.writeMarker(ctx, l.Info)
+	if err != nil {
+		return err
+	}
+
+	// Перечитываем маркер, чтобы обнаружить одновременный захват другим экземпляром
+	err = l.settle(ctx)
+	if err == nil {
+		holder, err = l.readHolder(ctx)
+	}
+	if err == nil && (holder == nil || holder.Owner != l.Info.Owner) {
+		err = fmt.Errorf("блокировка в registry %s:%s перехвачена другим экземпляром", l.Repository, l.Tag)
+	}
+	if err != nil {
+		l.deleteMarker(ctx, digest)
+		return err
+	}
+
+	l.digest = digest
+	return nil
+}
+
+// settle ждет Settle и случайную добавку такой же длины, чтобы запись тега другим
+// экземпляром, начавшим захват одновременно, успела завершиться
+func (l *RegistryLock) settle(ctx context.Context) error {
+	delay := l.Settle
+	if delay <= 0 {
+		delay = registryLockSettle
+	}
+	timer := time.NewTimer(delay + rand.N(delay))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deleteMarker удаляет записанный этим экземпляром манифест-маркер после неудачного
+// захвата. Удаляется манифест по digest, поэтому маркер другого экземпляра, который
+// перезаписал тег, остается. Маркер удаляется и после отмены ctx
+func (l *RegistryLock) deleteMarker(ctx context.Context, digest string) {
+	err := l.Client.Delete(context.WithoutCancel(ctx), l.Repository, digest)
+	if err != nil && !errors.Is(err, registry.ErrNotFound) {
+		fmt.Printf("Предупреждение: не удалось удалить маркер блокировки %s@%s: %v\n", l.Repository, digest, err)
+	}
+}
+
+// Renew перезаписывает манифест-маркер с новым сроком, если маркер все еще принадлежит
+// этому экземпляру, и удаляет прежний манифест маркера
+func (l *RegistryLock) Renew(ctx context.Context, ttl time.Duration) error {
+	holder, err := l.readHolder(ctx)
+	if err != nil {
+		return err
+	}
+	if l.digest == "" || holder == nil || holder.Owner != l.Info.Owner {
+		return fmt.Errorf("%w: %s:%s", ErrLockLost, l.Repository, l.Tag)
+	}
+
+	info := l.Info
+	info.Expires = time.Now().UTC().Add(ttl)
+	digest, err := l.writeMarker(ctx, info)
+	if err != nil {
+		return err
+	}
+	previous := l.digest
+	l.digest, l.Info = digest, info
+	if previous != digest {
+		if err := l.Client.Delete(ctx, l.Repository, previous); err != nil && !errors.Is(err, registry.ErrNotFound) {
+			return fmt.Errorf("ошибка удаления прежнего маркера блокировки %s@%s: %v", l.Repository, previous, err)
+		}
+	}
+	return nil
+}
+
+// writeMarker записывает манифест-маркер со сведениями о блокировке и возвращает его digest
+func (l *RegistryLock) writeMarker(ctx context.Context, info LockInfo) (string, error) {
+	manifest, err := markerManifest(info)
+	if err != nil {
+		return "", err
+	}
+
+	digest, err := l.Client.PutManifest(ctx, l.Repository, l.Tag, "application/vnd.oci.image.manifest.v1+json", manifest)
+	if err != nil {
+		return "", fmt.Errorf("ошибка записи маркера блокировки: %v", err)
+	}
+	return digest, nil
+}
+
+// markerManifest возвращает манифест-маркер со сведениями о блокировке
+func markerManifest(info LockInfo) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"config":        registry.Descriptor{MediaType: emptyBlobMediaType, Digest: emptyBlobDigest, Size: int64(len(emptyBlobContent))},
+		"layers":        []registry.Descriptor{{MediaType: emptyBlobMediaType, Digest: emptyBlobDigest, Size: int64(len(emptyBlobContent))}},
+		"annotations": map[string]string{
+			lockOwnerAnnotation:   info.Owner,
+			lockExpiresAnnotation: info.Expires.Format(time.RFC3339),
+		},
+	})
+}
+
+// Release удаляет манифест-маркер
+func (l *RegistryLock) Release(ctx context.Context) error {
+	if l.digest == "" {
+		return nil
+	}
+	if err := l.Client.Delete(ctx, l.Repository, l.digest); err != nil {
+		return fmt.Errorf("ошибка удаления маркера блокировки %s:%s: %v", l.Repository, l.Tag, err)
+	}
+	l.digest = ""
+	return nil
+}
+
+// readHolder читает владельца из манифеста-маркера, nil если маркера нет
+func (l *RegistryLock) readHolder(ctx context.Context) (*LockInfo, error) {
+	raw, _, _, err := l.Client.GetManifest(ctx, l.Repository, l.Tag)
+	if errors.Is(err, registry.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения маркера блокировки: %v", err)
+	}
+
+	var manifest registry.Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("ошибка разбора маркера блокировки: %v", err)
+	}
+
+	expires, _ := time.Parse(time.RFC3339, manifest.Annotations[lockExpiresAnnotation])
+	return &LockInfo{Owner: manifest.Annotations[lockOwnerAnnotation], Expires: expires}, nil
+}
+
+// ensureEmptyBlob загружает пустой blob в репозиторий маркера, если его там нет
+func (l *RegistryLock) ensureEmptyBlob(ctx context.Context) error {
+	exists, err := l.Client.BlobExists(ctx, l.Repository, emptyBlobDigest)
+	if err != nil || exists {
+		return err
+	}
+
+	location, _, err := l.Client.StartBlobUpload(ctx, l.Repository, emptyBlobDigest, "")
+	if err != nil {
+		return err
+	}
+	return l.Client.UploadBlob(ctx, location, emptyBlobDigest, int64(len(emptyBlobContent)), strings.NewReader(emptyBlobContent))
+}
+
+// URLLock блокировка через внешний HTTP сервис: POST захватывает блокировку
+// (200/201 - успех, 409/423 - занята), PUT продлевает ее срок, DELETE освобождает ее.
+// Тело всех запросов - сведения о блокировке LockInfo
+type URLLock struct {
+	URL    string
+	Info   LockInfo
+	Client *http.Client
+}
+
+// Acquire запрашивает блокировку у внешнего сервиса
+func (l *URLLock) Acquire(ctx context.Context) error {
+	resp, err := l.do(ctx, "POST", l.Info)
+	if err != nil {
+		return fmt.Errorf("ошибка запроса блокировки %s: %v", l.URL, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return nil
+	case http.StatusConflict, http.StatusLocked:
+		message, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("очистка уже выполняется (блокировка %s занята): %s", l.URL, strings.TrimSpace(string(message)))
+	default:
+		return fmt.Errorf("получен статус %d при запросе блокировки %s", resp.StatusCode, l.URL)
+	}
+}
+
+// Renew продлевает блокировку во внешнем сервисе. Ответ 404, 409 или 423 означает,
+// что блокировка потеряна. Сервис, который не продлевает блокировки, отвечает 405 или
+// 501: срок такой блокировки определяет сам сервис
+func (l *URLLock) Renew(ctx context.Context, ttl time.Duration) error {
+	info := l.Info
+	info.Expires = time.Now().UTC().Add(ttl)
+	resp, err := l.do(ctx, "PUT", info)
+	if err != nil {
+		return fmt.Errorf("ошибка продления блокировки %s: %v", l.URL, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		l.Info = info
+		return nil
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil
+	case http.StatusNotFound, http.StatusConflict, http.StatusLocked:
+		return fmt.Errorf("%w: %s", ErrLockLost, l.URL)
+	default:
+		return fmt.Errorf("получен статус %d при продлении блокировки %s", resp.StatusCode, l.URL)
+	}
+}
+
+// Release освобождает блокировку во внешнем сервисе
+func (l *URLLock) Release(ctx context.Context) error {
+	resp, err := l.do(ctx, "DELETE", l.Info)
+	if err != nil {
+		return fmt.Errorf("ошибка освобождения блокировки %s: %v", l.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("получен статус %d при освобождении блокировки %s", resp.StatusCode, l.URL)
+	}
+	return nil
+}
+
+// do отправляет сервису блокировок запрос со сведениями о блокировке
+func (l *URLLock) do(ctx context.Context, method string, info LockInfo) (*http.Response, error) {
+	body, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, l.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return l.Client.Do(req)
+}
@@ -0,0 +1,306 @@
+package cleanup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"registryCleaner/pkg/registry"
+	"registryCleaner/pkg/registrytest"
+)
+
+// testLockInfo возвращает сведения о блокировке владельца owner со сроком ttl от текущего времени
+func testLockInfo(owner string, ttl time.Duration) LockInfo {
+	now := time.Now().UTC()
+	return LockInfo{Owner: owner, Registry: "registry.example.com", Acquired: now, Expires: now.Add(ttl)}
+}
+
+// writeLockFile записывает файл блокировки с содержимым data
+func writeLockFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// lockJSON кодирует сведения о блокировке
+func lockJSON(t *testing.T, info LockInfo) []byte {
+	t.Helper()
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestFileLockAcquire(t *testing.T) {
+	tests := []struct {
+		name string
+		// existing содержимое файла блокировки до захвата, nil - файла нет
+		existing func(t *testing.T) []byte
+		wantErr  bool
+	}{
+		{name: "файла нет"},
+		{
+			name:     "блокировка занята",
+			existing: func(t *testing.T) []byte { return lockJSON(t, testLockInfo("other/1", time.Hour)) },
+			wantErr:  true,
+		},
+		{
+			name:     "блокировка просрочена",
+			existing: func(t *testing.T) []byte { return lockJSON(t, testLockInfo("other/1", -time.Minute)) },
+		},
+		{
+			name:     "пустой файл считается занятым",
+			existing: func(t *testing.T) []byte { return []byte{} },
+			wantErr:  true,
+		},
+		{
+			name:     "неразбираемый файл считается занятым",
+			existing: func(t *testing.T) []byte { return []byte("{\"owner\":") },
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "cleaner.lock")
+			var existing []byte
+			if tt.existing != nil {
+				existing = tt.existing(t)
+				writeLockFile(t, path, existing)
+			}
+			lock := &FileLock{Path: path, Info: testLockInfo("self/1", time.Hour)}
+
+			err := lock.Acquire(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Acquire вернул %v, ошибка ожидалась: %v", err, tt.wantErr)
+			}
+
+			data, readErr := os.ReadFile(path)
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+			if tt.wantErr {
+				if string(data) != string(existing) {
+					t.Errorf("занятый файл блокировки изменен: %q", data)
+				}
+			} else {
+				var holder LockInfo
+				if err := json.Unmarshal(data, &holder); err != nil || holder.Owner != "self/1" {
+					t.Errorf("владелец блокировки %q (%v), ожидался self/1", holder.Owner, err)
+				}
+			}
+
+			// Временные файлы не остаются рядом с блокировкой
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != 1 {
+				t.Errorf("в каталоге блокировки %d файлов, ожидался 1", len(entries))
+			}
+		})
+	}
+}
+
+func TestFileLockConcurrentAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cleaner.lock")
+	first := &FileLock{Path: path, Info: testLockInfo("first/1", time.Hour)}
+	second := &FileLock{Path: path, Info: testLockInfo("second/1", time.Hour)}
+
+	if err := first.Acquire(context.Background()); err != nil {
+		t.Fatalf("первый Acquire: %v", err)
+	}
+	if err := second.Acquire(context.Background()); err == nil {
+		t.Fatal("второй экземпляр захватил занятую блокировку")
+	}
+	// Чужую блокировку Release не удаляет
+	if err := second.Release(context.Background()); err == nil {
+		t.Error("Release удалил чужую блокировку")
+	}
+	if err := first.Release(context.Background()); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if err := second.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire после освобождения: %v", err)
+	}
+}
+
+func TestFileLockRenew(t *testing.T) {
+	tests := []struct {
+		name string
+		// replace, если задан, подменяет файл блокировки после захвата
+		replace func(t *testing.T, path string)
+		wantErr error
+	}{
+		{name: "срок продлевается"},
+		{
+			name: "блокировку перехватил другой экземпляр",
+			replace: func(t *testing.T, path string) {
+				writeLockFile(t, path, lockJSON(t, testLockInfo("other/1", time.Hour)))
+			},
+			wantErr: ErrLockLost,
+		},
+		{
+			name:    "файл блокировки удален",
+			replace: func(t *testing.T, path string) { os.Remove(path) },
+			wantErr: ErrLockLost,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cleaner.lock")
+			lock := &FileLock{Path: path, Info: testLockInfo("self/1", time.Minute)}
+			if err := lock.Acquire(context.Background()); err != nil {
+				t.Fatalf("Acquire: %v", err)
+			}
+			if tt.replace != nil {
+				tt.replace(t, path)
+			}
+
+			err := lock.Renew(context.Background(), time.Hour)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Renew вернул %v, ожидалась ошибка %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Renew: %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var holder LockInfo
+			if err := json.Unmarshal(data, &holder); err != nil {
+				t.Fatal(err)
+			}
+			if holder.Owner != "self/1" || time.Until(holder.Expires) < 50*time.Minute {
+				t.Errorf("после продления блокировка %s до %s", holder.Owner, holder.Expires)
+			}
+		})
+	}
+}
+
+// competingRegistry встроенный registry, в котором другой экземпляр записывает свой маркер
+// блокировки сразу после первой записи маркера
+type competingRegistry struct {
+	*registrytest.Registry
+	client  *registry.Client
+	written atomic.Bool
+}
+
+func (r *competingRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.Registry.ServeHTTP(w, req)
+	if req.Method == http.MethodPut && strings.Contains(req.URL.Path, "/manifests/") && r.written.CompareAndSwap(false, true) {
+		other := &RegistryLock{Client: r.client, Repository: "ops/lock", Tag: "lock", Info: testLockInfo("other/1", time.Hour)}
+		other.writeMarker(req.Context(), other.Info)
+	}
+}
+
+func TestRegistryLockAcquire(t *testing.T) {
+	mock := registrytest.New()
+	server := httptest.NewServer(mock)
+	defer server.Close()
+	client := registry.NewClient(server.URL, "", "")
+
+	first := &RegistryLock{Client: client, Repository: "ops/lock", Tag: "lock", Info: testLockInfo("first/1", time.Hour), Settle: time.Millisecond}
+	if err := first.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	second := &RegistryLock{Client: client, Repository: "ops/lock", Tag: "lock", Info: testLockInfo("second/1", time.Hour), Settle: time.Millisecond}
+	if err := second.Acquire(context.Background()); err == nil {
+		t.Fatal("занятая блокировка захвачена повторно")
+	}
+
+	if err := first.Release(context.Background()); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if err := second.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire после освобождения: %v", err)
+	}
+}
+
+func TestRegistryLockAcquireLostRace(t *testing.T) {
+	competing := &competingRegistry{Registry: registrytest.New()}
+	server := httptest.NewServer(competing)
+	defer server.Close()
+	client := registry.NewClient(server.URL, "", "")
+	competing.client = client
+
+	lock := &RegistryLock{Client: client, Repository: "ops/lock", Tag: "lock", Info: testLockInfo("self/1", time.Hour), Settle: time.Millisecond}
+	if err := lock.Acquire(context.Background()); err == nil {
+		t.Fatal("захват не обнаружил маркер другого экземпляра")
+	}
+
+	holder, err := lock.readHolder(context.Background())
+	if err != nil || holder == nil || holder.Owner != "other/1" {
+		t.Fatalf("маркер %+v (%v), ожидался маркер other/1", holder, err)
+	}
+	// Проигравший экземпляр удаляет только свой манифест-маркер
+	manifest, err := markerManifest(lock.Info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if own := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest)); competing.HasManifest("ops/lock", own) {
+		t.Errorf("маркер проигравшего экземпляра %s не удален", own)
+	}
+}
+
+func TestURLLockRenew(t *testing.T) {
+	tests := []struct {
+		status  int
+		wantErr error
+		renewed bool
+	}{
+		{status: http.StatusOK, renewed: true},
+		{status: http.StatusNoContent, renewed: true},
+		{status: http.StatusMethodNotAllowed},
+		{status: http.StatusConflict, wantErr: ErrLockLost},
+		{status: http.StatusNotFound, wantErr: ErrLockLost},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			var received LockInfo
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPut {
+					t.Errorf("метод %s, ожидался PUT", r.Method)
+				}
+				json.NewDecoder(r.Body).Decode(&received)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			lock := &URLLock{URL: server.URL, Info: testLockInfo("self/1", time.Minute), Client: server.Client()}
+			err := lock.Renew(context.Background(), time.Hour)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Renew вернул %v, ожидалась ошибка %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Renew: %v", err)
+			}
+			if received.Owner != "self/1" || time.Until(received.Expires) < 50*time.Minute {
+				t.Errorf("сервис получил блокировку %s до %s", received.Owner, received.Expires)
+			}
+			if renewed := time.Until(lock.Info.Expires) > 50*time.Minute; renewed != tt.renewed {
+				t.Errorf("срок блокировки %s, продление ожидалось: %v", lock.Info.Expires, tt.renewed)
+			}
+		})
+	}
+}
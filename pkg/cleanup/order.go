@@ -0,0 +1,81 @@
+package cleanup
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"registryCleaner/pkg/registry"
+)
+
+// Порядок обработки репозиториев
+const (
+	// OrderCatalog порядок, в котором backend возвращает репозитории
+	OrderCatalog = "catalog"
+	// OrderLargest сначала самые большие репозитории, чтобы при ограничении времени
+	// или прерывании запуска было освобождено как можно больше места
+	OrderLargest = "largest"
+)
+
+// SortBySize упорядочивает репозитории по убыванию количества тегов - оценки размера
+// до составления планов. Теги запрашиваются одновременно для concurrency репозиториев;
+// репозитории, теги которых получить не удалось, идут последними
+func SortBySize(ctx context.Context, backend registry.Backend, repositories []string, concurrency int) []string {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	counts := make([]int, len(repositories))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, repo := range repositories {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			tags, err := backend.ListTags(ctx, repo)
+			if err != nil {
+				counts[i] = -1
+				return
+			}
+			counts[i] = len(tags)
+		}()
+	}
+	wg.Wait()
+
+	order := make([]int, len(repositories))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return counts[order[a]] > counts[order[b]]
+	})
+	sorted := make([]string, len(repositories))
+	for i, idx := range order {
+		sorted[i] = repositories[idx]
+	}
+	return sorted
+}
+
+// SortPlansBySize упорядочивает планы по убыванию освобождаемого места: суммарного
+// размера удаляемых образов, а при равном размере - их количества
+func SortPlansBySize(plans []*RepositoryPlan) {
+	sort.SliceStable(plans, func(a, b int) bool {
+		sizeA, sizeB := plans[a].DeleteSize(), plans[b].DeleteSize()
+		if sizeA != sizeB {
+			return sizeA > sizeB
+		}
+		return len(plans[a].Delete) > len(plans[b].Delete)
+	})
+}
+
+// DeleteSize возвращает суммарный размер удаляемых образов. Общие слои учитываются
+// в каждом образе, поэтому реально освобождается не больше этого значения
+func (p *RepositoryPlan) DeleteSize() int64 {
+	var size int64
+	for _, img := range p.Delete {
+		size += img.Size
+	}
+	return size
+}
@@ -0,0 +1,304 @@
+package cleanup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// planFileVersion версия формата файла плана
+const planFileVersion = 1
+
+// PlanFile сохраненный план очистки: точный набор digest для удаления и отпечатки
+// состояния репозиториев на момент составления плана
+type PlanFile struct {
+	Version     int                 `json:"version"`
+	Created     time.Time           `json:"created"`
+	RegistryURL string              `json:"registryURL"`
+	Backend     string              `json:"backend"`
+	Plans       []PlannedRepository `json:"plans"`
+}
+
+// PlannedRepository план очистки одного репозитория в файле плана
+type PlannedRepository struct {
+	Repository string `json:"repository"`
+	// TagsFingerprint и DigestsFingerprint отпечатки тегов и пар тег-digest репозитория
+	TagsFingerprint    string         `json:"tagsFingerprint"`
+	DigestsFingerprint string         `json:"digestsFingerprint"`
+	Keep               []PlannedImage `json:"keep"`
+	Delete             []PlannedImage `json:"delete"`
+}
+
+// PlannedImage образ в файле плана
+type PlannedImage struct {
+	Tag     string    `json:"tag"`
+	Digest  string    `json:"digest"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+}
+
+// NewPlanFile создает файл плана из составленных планов
+func NewPlanFile(registryURL, backend string, plans []*RepositoryPlan) *PlanFile {
+	file := &PlanFile{
+		Version:     planFileVersion,
+		Created:     time.Now().UTC(),
+		RegistryURL: registryURL,
+		Backend:     backend,
+		Plans:       []PlannedRepository{},
+	}
+	for _, plan := range plans {
+		if plan.Unchanged {
+			continue
+		}
+		file.Plans = append(file.Plans, PlannedRepository{
+			Repository:         plan.Repository,
+			TagsFingerprint:    tagsFingerprint(plan.Tags),
+			DigestsFingerprint: digestsFingerprint(append(append([]registry.ImageInfo(nil), plan.Keep...), plan.Delete...)),
+			Keep:               plannedImages(plan.Keep),
+			Delete:             plannedImages(plan.Delete),
+		})
+	}
+	return file
+}
+
+// plannedImages преобразует образы для записи в файл плана
+func plannedImages(images []registry.ImageInfo) []PlannedImage {
+	result := make([]PlannedImage, 0, len(images))
+	for _, img := range images {
+		result = append(result, PlannedImage{Tag: img.Tag, Digest: img.Digest, Created: img.Created, Size: img.Size})
+	}
+	return result
+}
+
+// LoadPlanFile загружает файл плана
+func LoadPlanFile(path string) (*PlanFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения плана %s: %v", path, err)
+	}
+	var file PlanFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("ошибка разбора плана %s: %v", path, err)
+	}
+	if file.Version != planFileVersion {
+		return nil, fmt.Errorf("неподдерживаемая версия плана %s: %d", path, file.Version)
+	}
+	return &file, nil
+}
+
+// Save атомарно записывает план в файл
+func (f *PlanFile) Save(path string) error {
+	return writeJSONFile(path, f)
+}
+
+// DeleteCount возвращает общее количество удаляемых образов
+func (f *PlanFile) DeleteCount() int {
+	count := 0
+	for _, plan := range f.Plans {
+		count += len(plan.Delete)
+	}
+	return count
+}
+
+// GuardPlans возвращает планы для проверки порогов DeleteGuard при применении плана:
+// проверенные планы verified и планы остальных репозиториев файла, которые входят в
+// общее количество образов registry по сохраненным тегам, но ничего не удаляют
+func (f *PlanFile) GuardPlans(verified []*RepositoryPlan) []*RepositoryPlan {
+	guarded := append([]*RepositoryPlan(nil), verified...)
+	checked := make(map[string]bool, len(verified))
+	for _, plan := range verified {
+		checked[plan.Repository] = true
+	}
+	for _, planned := range f.Plans {
+		if checked[planned.Repository] {
+			continue
+		}
+		plan := &RepositoryPlan{Repository: planned.Repository}
+		for _, img := range planned.Keep {
+			plan.Tags = append(plan.Tags, img.Tag)
+		}
+		for _, img := range planned.Delete {
+			plan.Tags = append(plan.Tags, img.Tag)
+		}
+		guarded = append(guarded, plan)
+	}
+	return guarded
+}
+
+// DeleteSize возвращает суммарный размер удаляемых образов
+func (p *PlannedRepository) DeleteSize() int64 {
+	var size int64
+	for _, img := range p.Delete {
+		size += img.Size
+	}
+	return size
+}
+
+// Drift расхождение между сохраненным планом и текущим состоянием registry
+type Drift struct {
+	Repository string
+	Tag        string
+	Digest     string
+	Reason     string
+}
+
+// Error возвращает описание расхождения
+func (d Drift) Error() string {
+	return fmt.Sprintf("%s:%s (%s): %s", d.Repository, d.Tag, d.Digest, d.Reason)
+}
+
+// Verify сверяет план репозитория с текущим состоянием registry и возвращает план
+// удаления только тех образов, которые не изменились. Образ пропускается с расхождением,
+// если его тег удален или указывает на другой digest, либо если на его digest теперь
+// указывают теги, не отобранные планом для удаления: удаление манифеста удалило бы и их.
+// Digest всех тегов запрашиваются одновременно для concurrency тегов
+func (p *PlannedRepository) Verify(ctx context.Context, backend registry.Backend, concurrency int) (*RepositoryPlan, []Drift, error) {
+	tags, err := backend.ListTags(ctx, p.Repository)
+	if err != nil {
+		return nil, nil, err
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	digests := make([]string, len(tags))
+	errs := make([]error, len(tags))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, tag := range tags {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			digests[i], errs[i] = backend.ResolveDigest(ctx, p.Repository, tag)
+		}()
+	}
+	wg.Wait()
+
+	current := make(map[string]string, len(tags))
+	tagsByDigest := make(map[string][]string)
+	for i, tag := range tags {
+		if errs[i] != nil {
+			// Без digest тег может указывать на удаляемый образ, поэтому учитывается
+			// как указывающий на любой digest
+			tagsByDigest[""] = append(tagsByDigest[""], tag)
+			continue
+		}
+		current[tag] = digests[i]
+		tagsByDigest[digests[i]] = append(tagsByDigest[digests[i]], tag)
+	}
+
+	planned := make(map[string]bool, len(p.Delete))
+	for _, img := range p.Delete {
+		planned[img.Tag] = true
+	}
+
+	plan := &RepositoryPlan{Repository: p.Repository, Tags: tags}
+	var drifts []Drift
+	for _, img := range p.Delete {
+		drift := Drift{Repository: p.Repository, Tag: img.Tag, Digest: img.Digest}
+		digest, ok := current[img.Tag]
+		switch {
+		case !ok && slices.Contains(tags, img.Tag):
+			drift.Reason = "не удалось получить текущий digest тега"
+		case !ok:
+			drift.Reason = "тег удален после составления плана"
+		case digest != img.Digest:
+			drift.Reason = fmt.Sprintf("тег теперь указывает на %s", digest)
+		default:
+			for _, tag := range append(tagsByDigest[img.Digest], tagsByDigest[""]...) {
+				if !planned[tag] {
+					drift.Reason = fmt.Sprintf("на образ указывает тег %s, не отобранный для удаления", tag)
+					break
+				}
+			}
+		}
+		if drift.Reason != "" {
+			drifts = append(drifts, drift)
+			continue
+		}
+		plan.Delete = append(plan.Delete, registry.ImageInfo{
+			Repository: p.Repository, Tag: img.Tag, Digest: img.Digest, Created: img.Created, Size: img.Size,
+		})
+	}
+
+	deleted := make(map[string]bool, len(plan.Delete))
+	for _, img := range plan.Delete {
+		deleted[img.Tag] = true
+	}
+	for _, tag := range tags {
+		if !deleted[tag] {
+			plan.Keep = append(plan.Keep, registry.ImageInfo{Repository: p.Repository, Tag: tag, Digest: current[tag]})
+		}
+	}
+	return plan, drifts, nil
+}
+
+// Изменения образа между двумя планами
+const (
+	// ChangeDeletable образ стал кандидатом на удаление
+	ChangeDeletable = "deletable"
+	// ChangeKept образ больше не удаляется
+	ChangeKept = "kept"
+	// ChangeGone образ, который удалялся, отсутствует в новом плане: он уже удален,
+	// либо репозиторий не рассматривался
+	ChangeGone = "gone"
+)
+
+// PlanChange изменение решения по образу между двумя планами
+type PlanChange struct {
+	Repository string
+	Image      PlannedImage
+	Change     string
+}
+
+// DiffPlans сравнивает решения по образам в двух планах. Образ определяется тегом
+// и digest, поэтому перенесенный тег считается другим образом. Изменения возвращаются
+// в порядке репозиториев и образов нового плана, затем исчезнувшие образы
+func DiffPlans(old, current *PlanFile) []PlanChange {
+	type key struct{ repository, tag, digest string }
+	oldDelete := make(map[key]bool)
+	for _, plan := range old.Plans {
+		for _, img := range plan.Keep {
+			oldDelete[key{plan.Repository, img.Tag, img.Digest}] = false
+		}
+		for _, img := range plan.Delete {
+			oldDelete[key{plan.Repository, img.Tag, img.Digest}] = true
+		}
+	}
+
+	var changes []PlanChange
+	seen := make(map[key]bool)
+	for _, plan := range current.Plans {
+		for _, img := range plan.Keep {
+			k := key{plan.Repository, img.Tag, img.Digest}
+			seen[k] = true
+			if oldDelete[k] {
+				changes = append(changes, PlanChange{Repository: plan.Repository, Image: img, Change: ChangeKept})
+			}
+		}
+		for _, img := range plan.Delete {
+			k := key{plan.Repository, img.Tag, img.Digest}
+			seen[k] = true
+			if wasDelete, ok := oldDelete[k]; !ok || !wasDelete {
+				changes = append(changes, PlanChange{Repository: plan.Repository, Image: img, Change: ChangeDeletable})
+			}
+		}
+	}
+
+	for _, plan := range old.Plans {
+		for _, img := range plan.Delete {
+			if !seen[key{plan.Repository, img.Tag, img.Digest}] {
+				changes = append(changes, PlanChange{Repository: plan.Repository, Image: img, Change: ChangeGone})
+			}
+		}
+	}
+	return changes
+}
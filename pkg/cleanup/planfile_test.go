@@ -0,0 +1,143 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPlannedRepositoryVerify(t *testing.T) {
+	errTag := errors.New("ошибка запроса")
+
+	tests := []struct {
+		name string
+		// setup изменяет registry после составления плана
+		setup   func(b *fakeBackend)
+		deleted []string
+		drifts  []string
+	}{
+		{
+			name:    "registry не изменился",
+			deleted: []string{"v1", "v2"},
+		},
+		{
+			name:    "тег удален",
+			setup:   func(b *fakeBackend) { delete(b.repositories["app"], "v1") },
+			deleted: []string{"v2"},
+			drifts:  []string{"v1"},
+		},
+		{
+			name:    "тег перенесен на другой образ",
+			setup:   func(b *fakeBackend) { b.repositories["app"]["v2"] = fakeImage{digest: testDigest("app:new")} },
+			deleted: []string{"v1"},
+			drifts:  []string{"v2"},
+		},
+		{
+			name:    "на образ указывает новый тег",
+			setup:   func(b *fakeBackend) { b.repositories["app"]["stable"] = b.repositories["app"]["v1"] },
+			deleted: []string{"v2"},
+			drifts:  []string{"v1"},
+		},
+		{
+			name:    "не получен digest удаляемого тега",
+			setup:   func(b *fakeBackend) { b.resolveErrs["v2"] = errTag },
+			deleted: []string{"v1"},
+			drifts:  []string{"v2"},
+		},
+		{
+			name:   "не получен digest сохраняемого тега",
+			setup:  func(b *fakeBackend) { b.resolveErrs["v3"] = errTag },
+			drifts: []string{"v1", "v2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend("app", "v1", "v2", "v3")
+			planned := PlannedRepository{
+				Repository: "app",
+				Keep:       []PlannedImage{{Tag: "v3", Digest: testDigest("app:v3")}},
+				Delete: []PlannedImage{
+					{Tag: "v1", Digest: testDigest("app:v1")},
+					{Tag: "v2", Digest: testDigest("app:v2")},
+				},
+			}
+			if tt.setup != nil {
+				tt.setup(backend)
+			}
+
+			plan, drifts, err := planned.Verify(context.Background(), backend, 2)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if got := imageTags(plan.Delete); !reflect.DeepEqual(got, tt.deleted) {
+				t.Errorf("удаляются %v, ожидалось %v", got, tt.deleted)
+			}
+			var driftTags []string
+			for _, drift := range drifts {
+				driftTags = append(driftTags, drift.Tag)
+			}
+			if !reflect.DeepEqual(driftTags, tt.drifts) {
+				t.Errorf("расхождения %v, ожидалось %v", drifts, tt.drifts)
+			}
+			// Все теги, кроме удаляемых, сохраняются: от них зависят пороги удаления
+			if len(plan.Keep)+len(plan.Delete) != len(plan.Tags) {
+				t.Errorf("сохраняются %v и удаляются %v из тегов %v", imageTags(plan.Keep), imageTags(plan.Delete), plan.Tags)
+			}
+		})
+	}
+}
+
+func TestPlannedRepositoryVerifyMissingRepository(t *testing.T) {
+	planned := PlannedRepository{Repository: "gone", Delete: []PlannedImage{{Tag: "v1", Digest: testDigest("gone:v1")}}}
+	if _, _, err := planned.Verify(context.Background(), newFakeBackend("app"), 1); err == nil {
+		t.Error("ожидалась ошибка для удаленного репозитория")
+	}
+}
+
+func TestPlanFileGuardPlans(t *testing.T) {
+	file := &PlanFile{Plans: []PlannedRepository{
+		{
+			Repository: "app",
+			Keep:       []PlannedImage{{Tag: "v3"}, {Tag: "v4"}},
+			Delete:     []PlannedImage{{Tag: "v1"}, {Tag: "v2"}},
+		},
+		{
+			Repository: "api",
+			Keep:       []PlannedImage{{Tag: "v1"}, {Tag: "v2"}, {Tag: "v3"}, {Tag: "v4"}},
+		},
+	}}
+	// После проверки плана v2 пропущен из-за расхождения
+	verified := guardPlan("app", 3, 1)
+
+	tests := []struct {
+		name       string
+		guard      DeleteGuard
+		violations int
+	}{
+		{
+			name:  "количество считается по проверенному плану",
+			guard: DeleteGuard{MaxCount: 1},
+		},
+		{
+			name:       "непроверенные репозитории входят в долю registry",
+			guard:      DeleteGuard{MaxPercent: 20},
+			violations: 1,
+		},
+		{
+			name:       "превышена доля в registry",
+			guard:      DeleteGuard{MaxPercent: 10},
+			violations: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := tt.guard.Check(file.GuardPlans([]*RepositoryPlan{verified}))
+			if len(violations) != tt.violations {
+				t.Errorf("нарушений %d, ожидалось %d: %v", len(violations), tt.violations, violations)
+			}
+		})
+	}
+}
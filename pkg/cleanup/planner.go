@@ -0,0 +1,515 @@
+// Package cleanup реализует составление и выполнение планов очистки репозиториев
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// RepositoryPlan план очистки одного репозитория
+type RepositoryPlan struct {
+	Repository string
+	// Tags все теги репозитория на момент составления плана
+	Tags   []string
+	Keep   []registry.ImageInfo
+	Delete []registry.ImageInfo
+	// Deleted образы, фактически удаленные при выполнении плана
+	Deleted []registry.ImageInfo
+	// Held образы, сохраненные из-за удержания
+	Held []HeldImage
+	// Unchanged репозиторий не изменился с прошлой очистки и пропущен
+	Unchanged bool
+	// Errors ошибки получения информации об отдельных тегах. Теги без digest не попадают
+	// в план, а теги без времени создания обрабатываются по Planner.CreatedFallback
+	Errors []error
+}
+
+// Total возвращает общее количество образов в плане
+func (p *RepositoryPlan) Total() int {
+	return len(p.Keep) + len(p.Delete)
+}
+
+// ShortDigest сокращает digest для вывода до алгоритма и первых 12 символов хэша.
+// Короткий или пустой digest, например от backend без digest, возвращается как есть
+func ShortDigest(digest string) string {
+	algorithm, hash, ok := strings.Cut(digest, ":")
+	if !ok || len(hash) <= 12 {
+		return digest
+	}
+	return algorithm + ":" + hash[:12]
+}
+
+// Действия Planner, если время создания образа получить не удалось
+const (
+	// CreatedFallbackSkip исключает образ из плана: он не удаляется и не учитывается
+	// политикой хранения
+	CreatedFallbackSkip = "skip"
+	// CreatedFallbackOldest считает образ старше всех остальных
+	CreatedFallbackOldest = "oldest"
+	// CreatedFallbackLastModified берет время из заголовка Last-Modified манифеста, если
+	// backend реализует registry.ModifiedTimeProvider; без него образ исключается из плана
+	CreatedFallbackLastModified = "last-modified"
+	// CreatedFallbackFail прекращает составление плана репозитория с ошибкой
+	CreatedFallbackFail = "fail"
+)
+
+// Planner собирает информацию об образах и составляет планы очистки по политике хранения
+type Planner struct {
+	Backend registry.Backend
+	Policy  Policy
+	// Concurrency максимальное число одновременно обрабатываемых тегов
+	Concurrency int
+	// Cache, если задан, хранит метаданные образов между запусками
+	Cache *MetadataCache
+	// State, если задан вместе с Incremental, позволяет пропускать неизменившиеся репозитории
+	State *State
+	// Incremental режим пропуска неизменившихся репозиториев, пустая строка - выключен
+	Incremental string
+	// TagTime, если задан, определяет время создания по имени тега; для таких тегов
+	// метаданные образа не запрашиваются, а размер известен только из кэша
+	TagTime *TagTimeParser
+	// Sort порядок образов для политики: SortCreated (по умолчанию) или SortBuildNumber
+	Sort string
+	// TieBreak порядок образов с одинаковым временем создания: TieBreakTag (по
+	// умолчанию), TieBreakTagReverse или TieBreakDigest
+	TieBreak string
+	// CreatedFallback действие, если время создания образа получить не удалось:
+	// CreatedFallbackSkip (по умолчанию), CreatedFallbackOldest,
+	// CreatedFallbackLastModified или CreatedFallbackFail
+	CreatedFallback string
+	// Protected, если задан, защищает теги от удаления независимо от политики
+	Protected *ProtectedTags
+	// Tenants, если задан, разделяет репозитории между командами: репозитории команды
+	// очищаются по ее политике с учетом ее неизменяемых тегов, остальные - по Policy
+	Tenants *Tenants
+	// Holds, если задан, защищает от удаления образы из внешнего списка удержания
+	Holds *LegalHolds
+	// Platform, если задана в виде os/architecture[/variant], ограничивает очистку
+	// образами этой платформы: остальные образы и артефакты без платформы не
+	// учитываются политикой и не удаляются
+	Platform string
+	// Location часовой пояс вывода времени создания, по умолчанию локальный
+	Location *time.Location
+	// SweepSignatures исключает подписи, аттестации и SBOM cosign (sha256-<digest>.sig,
+	// .att, .sbom) из политики хранения и удаляет их, только если образа, к которому
+	// они относятся, нет в репозитории или он удаляется
+	SweepSignatures bool
+	// Signatures, если задан, защищает от удаления образы, подписанные доверенными ключами,
+	// кроме подходящих под DeleteSigned
+	Signatures   *SignatureVerifier
+	DeleteSigned *ProtectedTags
+	// SoftDelete, если задан, откладывает удаление кандидатов до истечения срока
+	// ожидания с их первой отметки
+	SoftDelete *SoftDelete
+	// Log получает ход работы, по умолчанию os.Stdout
+	Log io.Writer
+}
+
+// printf выводит сообщение о ходе работы
+func (p *Planner) printf(format string, args ...interface{}) {
+	out := p.Log
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, format, args...)
+}
+
+// Plan собирает информацию об образах репозитория и определяет по политике,
+// какие из них будут удалены
+func (p *Planner) Plan(ctx context.Context, repository string) (*RepositoryPlan, error) {
+	plan := &RepositoryPlan{Repository: repository}
+	p.printf("Обработка репозитория: %s\n", repository)
+	policy := p.Policy
+	tenant := p.Tenants.Owner(repository)
+	if tenant != nil {
+		p.printf("  Репозиторий команды %s, политика команды: %s\n", tenant.Name, tenant.Description)
+		policy = tenant.Policy
+	}
+
+	tags, err := p.Backend.ListTags(ctx, repository)
+	if err != nil {
+		return nil, err
+	}
+	// Образы в карантине удаляются только подкомандой purge-quarantine
+	tags, quarantined := withoutQuarantine(tags)
+	if quarantined > 0 {
+		p.printf("  Тегов карантина: %d, не учитываются\n", quarantined)
+	}
+	plan.Tags = tags
+
+	previous := p.previousState(repository)
+	// Отложенные удаления должны выполниться, даже если репозиторий не изменился
+	if p.SoftDelete != nil && p.SoftDelete.Marked(repository) {
+		previous = nil
+	}
+	if p.Incremental == IncrementalTags && previous != nil && previous.TagsFingerprint == tagsFingerprint(tags) {
+		p.printf("  Список тегов %s не изменился с прошлой очистки, пропускаем\n", repository)
+		plan.Unchanged = true
+		return plan, nil
+	}
+
+	// Подписи, оставшиеся от удаленных образов, проверяются независимо от политики
+	sweep := p.SweepSignatures && countSignatures(tags) > 0
+	if prefilter, ok := policy.(Prefilter); ok && !sweep && prefilter.Skip(tags) {
+		p.printf("  В репозитории %s только %d тегов, пропускаем\n", repository, len(tags))
+		if p.SoftDelete != nil {
+			p.SoftDelete.Apply(plan, time.Now())
+		}
+		return plan, nil
+	}
+
+	images, tagErrs, err := p.fetchImages(ctx, repository, tags)
+	if err != nil {
+		return nil, err
+	}
+	plan.Errors = tagErrs
+
+	if p.Incremental == IncrementalDigests && previous != nil &&
+		previous.TagsFingerprint == tagsFingerprint(tags) && previous.DigestsFingerprint == digestsFingerprint(images) {
+		p.printf("  Теги и digest %s не изменились с прошлой очистки, пропускаем\n", repository)
+		plan.Unchanged = true
+		return plan, nil
+	}
+
+	var signatures []registry.ImageInfo
+	if p.SweepSignatures {
+		images, signatures = splitSignatures(images)
+	}
+	all := images
+	images = p.filterPlatform(images)
+	SortImages(images, p.Sort, p.TieBreak)
+
+	plan.Keep, plan.Delete = policy.Select(images)
+	p.keepProtected(plan, tenant)
+	p.keepHeld(plan)
+	p.keepImmutable(ctx, plan)
+	p.keepSigned(ctx, plan)
+	p.sweepSignatures(ctx, plan, all, signatures)
+	var deferred map[string]time.Time
+	if p.SoftDelete != nil {
+		deferred = p.SoftDelete.Apply(plan, time.Now())
+	}
+
+	remove := make(map[string]bool, len(plan.Delete))
+	for _, img := range plan.Delete {
+		remove[img.Tag] = true
+	}
+
+	if p.Sort == SortBuildNumber {
+		p.printf("  Образы отсортированы по номеру сборки (новые первыми):\n")
+	} else {
+		p.printf("  Образы отсортированы по времени создания (новые первыми):\n")
+	}
+	for i, img := range images {
+		status := "сохранить"
+		if remove[img.Tag] {
+			status = "удалить"
+		} else if due, ok := deferred[img.Tag]; ok {
+			status = "отложить до " + FormatTime(due, p.Location)
+		}
+		p.printf("    %d. %s:%s (%s) - %s\n", i+1, img.Repository, img.Tag,
+			FormatTime(img.Created, p.Location), status)
+	}
+
+	return plan, nil
+}
+
+// Images получает информацию обо всех образах репозитория без применения политики
+func (p *Planner) Images(ctx context.Context, repository string) ([]registry.ImageInfo, []error, error) {
+	tags, err := p.Backend.ListTags(ctx, repository)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tags) == 0 {
+		return nil, nil, nil
+	}
+	return p.fetchImages(ctx, repository, tags)
+}
+
+// keepImmutable переносит в сохраняемые образы, теги которых registry не позволяет удалить,
+// а также образы с тем же digest: registry вроде Harbor удаляет образ по digest вместе со
+// всеми тегами. Проверяются только теги, digest которых есть среди удаляемых
+func (p *Planner) keepImmutable(ctx context.Context, plan *RepositoryPlan) {
+	checker, ok := p.Backend.(registry.ImmutableChecker)
+	if !ok {
+		return
+	}
+
+	deleting := make(map[string]bool, len(plan.Delete))
+	for _, img := range plan.Delete {
+		deleting[img.Digest] = true
+	}
+	digests := make(map[string]bool)
+	tags := make(map[string]bool)
+	for _, images := range [][]registry.ImageInfo{plan.Keep, plan.Delete} {
+		for _, img := range images {
+			if !deleting[img.Digest] || digests[img.Digest] {
+				continue
+			}
+			immutable, err := checker.Immutable(ctx, img.Repository, img.Tag)
+			if err != nil {
+				p.printf("  Предупреждение: не удалось проверить неизменяемость %s:%s, образ сохраняется: %v\n", img.Repository, img.Tag, err)
+				immutable = true
+			}
+			if immutable {
+				digests[img.Digest] = true
+				tags[img.Tag] = true
+			}
+		}
+	}
+
+	var remove []registry.ImageInfo
+	for _, img := range plan.Delete {
+		if !digests[img.Digest] {
+			remove = append(remove, img)
+			continue
+		}
+		if tags[img.Tag] {
+			p.printf("  Тег %s:%s неизменяемый, образ сохраняется\n", img.Repository, img.Tag)
+		} else {
+			p.printf("  Тег %s:%s указывает на образ неизменяемого тега, образ сохраняется\n", img.Repository, img.Tag)
+		}
+		plan.Keep = append(plan.Keep, img)
+	}
+	plan.Delete = remove
+}
+
+// filterPlatform оставляет образы, содержащие платформу Platform
+func (p *Planner) filterPlatform(images []registry.ImageInfo) []registry.ImageInfo {
+	if p.Platform == "" {
+		return images
+	}
+
+	var filtered []registry.ImageInfo
+	for _, img := range images {
+		if hasPlatform(img, p.Platform) {
+			filtered = append(filtered, img)
+			continue
+		}
+		if len(img.Platforms) == 0 {
+			p.printf("  Образ %s:%s не содержит сведений о платформе, пропускаем\n", img.Repository, img.Tag)
+		} else {
+			p.printf("  Образ %s:%s не содержит платформы %s, пропускаем\n", img.Repository, img.Tag, p.Platform)
+		}
+	}
+	return filtered
+}
+
+// hasPlatform сообщает, содержит ли образ платформу вида os/architecture[/variant]
+func hasPlatform(img registry.ImageInfo, platform string) bool {
+	for _, candidate := range img.Platforms {
+		if candidate.Matches(platform) {
+			return true
+		}
+	}
+	return false
+}
+
+// previousState возвращает состояние репозитория после прошлой очистки
+func (p *Planner) previousState(repository string) *RepositoryState {
+	if p.State == nil || p.Incremental == "" {
+		return nil
+	}
+	return p.State.Repository(repository)
+}
+
+// tagResult результат получения информации об одном теге
+type tagResult struct {
+	image registry.ImageInfo
+	// err ошибка получения digest, тег пропускается
+	err error
+	// createdErr ошибка получения времени создания, образ обрабатывается по CreatedFallback
+	createdErr error
+	// skip образ без времени создания исключается из плана
+	skip bool
+	// pulledErr ошибка получения времени скачивания, образ считается используемым
+	pulledErr error
+}
+
+// setMeta заполняет образ метаданными
+func (r *tagResult) setMeta(meta registry.ImageMeta) {
+	r.image.Created, r.image.Size, r.image.Labels, r.image.Annotations = meta.Created, meta.Size, meta.Labels, meta.Annotations
+	r.image.Blobs, r.image.Platforms = meta.Blobs, meta.Platforms
+}
+
+// createdFallback заполняет время создания образа, которое не удалось получить, по
+// CreatedFallback или отмечает, что образ исключается из плана
+func (p *Planner) createdFallback(ctx context.Context, result *tagResult) {
+	switch p.CreatedFallback {
+	case CreatedFallbackOldest:
+		// Нулевое время раньше любого времени создания, в том числе эпохи Unix у
+		// воспроизводимых сборок
+		result.image.Created = time.Time{}
+	case CreatedFallbackLastModified:
+		provider, ok := p.Backend.(registry.ModifiedTimeProvider)
+		if !ok {
+			result.skip = true
+			return
+		}
+		modified, err := provider.ManifestModified(ctx, result.image.Repository, result.image.Digest)
+		if err != nil {
+			result.createdErr = fmt.Errorf("%w; %v", result.createdErr, err)
+			result.skip = true
+			return
+		}
+		result.image.Created = modified
+	case CreatedFallbackFail:
+	default:
+		result.skip = true
+	}
+}
+
+// fetchImages получает digest и время создания для всех тегов репозитория,
+// выполняя до Concurrency запросов одновременно. Ошибки отдельных тегов выводятся
+// и возвращаются вместе с образами; ошибка возвращается, только если не удалось
+// обработать ни один тег
+func (p *Planner) fetchImages(ctx context.Context, repository string, tags []string) ([]registry.ImageInfo, []error, error) {
+	concurrency := p.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]tagResult, len(tags))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, tag := range tags {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = p.fetchImage(ctx, repository, tag)
+		}()
+	}
+	wg.Wait()
+
+	// Выводим результаты в исходном порядке тегов, чтобы вывод не перемешивался
+	var images []registry.ImageInfo
+	var errs, tagErrs []error
+	for _, result := range results {
+		img := result.image
+		if result.err != nil {
+			p.printf("  Предупреждение: не удалось получить digest для %s:%s: %v\n", repository, img.Tag, result.err)
+			errs = append(errs, result.err)
+			tagErrs = append(tagErrs, fmt.Errorf("%s:%s: не удалось получить digest: %w", repository, img.Tag, result.err))
+			continue
+		}
+		if result.createdErr != nil {
+			err := fmt.Errorf("%s:%s: не удалось получить время создания: %w", repository, img.Tag, result.createdErr)
+			switch {
+			case p.CreatedFallback == CreatedFallbackFail:
+				return nil, nil, err
+			case result.skip:
+				p.printf("  Предупреждение: не удалось получить время создания для %s:%s, образ не учитывается политикой и не удаляется: %v\n", repository, img.Tag, result.createdErr)
+				tagErrs = append(tagErrs, err)
+				continue
+			case p.CreatedFallback == CreatedFallbackOldest:
+				p.printf("  Предупреждение: не удалось получить время создания для %s:%s, образ считается самым старым: %v\n", repository, img.Tag, result.createdErr)
+			default:
+				p.printf("  Предупреждение: не удалось получить время создания для %s:%s, используем Last-Modified манифеста: %v\n", repository, img.Tag, result.createdErr)
+			}
+			tagErrs = append(tagErrs, err)
+		}
+		if result.pulledErr != nil {
+			p.printf("  Предупреждение: не удалось получить время скачивания для %s:%s, образ считается используемым: %v\n", repository, img.Tag, result.pulledErr)
+			tagErrs = append(tagErrs, fmt.Errorf("%s:%s: не удалось получить время скачивания: %w", repository, img.Tag, result.pulledErr))
+		}
+
+		images = append(images, img)
+		p.printf("  Образ %s:%s создан %s\n", repository, img.Tag, FormatTime(img.Created, p.Location))
+	}
+
+	if len(errs) > 0 {
+		p.printf("  Не удалось получить информацию о %d из %d тегов\n", len(errs), len(tags))
+		if len(images) == 0 {
+			return nil, nil, fmt.Errorf("не удалось получить информацию ни об одном теге: %w", errors.Join(errs...))
+		}
+	}
+
+	return images, tagErrs, nil
+}
+
+// fetchImage получает digest и время создания одного тега. Backend, реализующий
+// TagResolver, получает их за один проход по манифесту
+func (p *Planner) fetchImage(ctx context.Context, repository, tag string) tagResult {
+	result := tagResult{image: registry.ImageInfo{Repository: repository, Tag: tag}}
+
+	var tagTime time.Time
+	var fromTag bool
+	if p.TagTime != nil {
+		tagTime, fromTag = p.TagTime.Parse(tag)
+	}
+
+	// Метаданные запрашиваются, если их нет в кэше. Платформа известна только из
+	// метаданных, поэтому с фильтром по платформе они запрашиваются и для тегов со
+	// временем в имени
+	var meta registry.ImageMeta
+	var metaErr error
+	var cached, requested bool
+	wantMeta := func(digest string) bool {
+		if p.Cache != nil {
+			meta, cached = p.Cache.Get(digest)
+		}
+		requested = !cached && (!fromTag || p.Platform != "")
+		return requested
+	}
+
+	if resolver, ok := p.Backend.(registry.TagResolver); ok {
+		details, err := resolver.ResolveTag(ctx, repository, tag, wantMeta)
+		if err != nil {
+			result.err = err
+			return result
+		}
+		result.image.Digest = details.Digest
+		if requested {
+			meta, metaErr = details.Meta, details.MetaErr
+		}
+	} else {
+		result.image.Digest, result.err = p.Backend.ResolveDigest(ctx, repository, tag)
+		if result.err != nil {
+			return result
+		}
+		if wantMeta(result.image.Digest) {
+			meta, metaErr = p.Backend.GetImageMeta(ctx, repository, tag)
+		}
+	}
+
+	// Время скачивания меняется, поэтому не кэшируется
+	if provider, ok := p.Backend.(registry.PullTimeProvider); ok {
+		result.image.LastPulled, result.pulledErr = provider.LastPulled(ctx, repository, tag)
+		if result.pulledErr != nil {
+			result.image.LastPulled = time.Now() // Считаем образ используемым, чтобы не удалить его по ошибке
+		}
+	}
+
+	switch {
+	case cached:
+		result.setMeta(meta)
+	case !requested:
+	case metaErr != nil:
+		if !fromTag {
+			result.createdErr = metaErr
+			p.createdFallback(ctx, &result)
+			return result
+		}
+	default:
+		result.setMeta(meta)
+		if p.Cache != nil {
+			p.Cache.Put(result.image.Digest, meta)
+		}
+	}
+	if fromTag {
+		result.image.Created = tagTime
+	}
+
+	return result
+}
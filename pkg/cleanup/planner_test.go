@@ -0,0 +1,227 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestPlannerPlan(t *testing.T) {
+	errTag := errors.New("ошибка запроса")
+
+	tests := []struct {
+		name     string
+		tags     []string
+		keepLast int
+		setup    func(b *fakeBackend)
+		fallback string
+		// keep и remove ожидаемые теги, новые первыми
+		keep, remove []string
+		errors       int
+		wantErr      bool
+	}{
+		{
+			name:     "сохраняются новейшие",
+			tags:     []string{"v1", "v2", "v3", "v4"},
+			keepLast: 2,
+			keep:     []string{"v4", "v3"},
+			remove:   []string{"v2", "v1"},
+		},
+		{
+			name:     "тегов не больше keep-last",
+			tags:     []string{"v1", "v2"},
+			keepLast: 2,
+		},
+		{
+			name:     "тег без digest не попадает в план",
+			tags:     []string{"v1", "v2", "v3", "v4"},
+			keepLast: 2,
+			setup:    func(b *fakeBackend) { b.resolveErrs["v1"] = errTag },
+			keep:     []string{"v4", "v3"},
+			remove:   []string{"v2"},
+			errors:   1,
+		},
+		{
+			name:     "ни одного тега с digest",
+			tags:     []string{"v1", "v2", "v3"},
+			keepLast: 1,
+			setup: func(b *fakeBackend) {
+				for _, tag := range []string{"v1", "v2", "v3"} {
+					b.resolveErrs[tag] = errTag
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name:     "без времени создания образ пропускается",
+			tags:     []string{"v1", "v2", "v3", "v4"},
+			keepLast: 2,
+			setup:    func(b *fakeBackend) { b.metaErrs["v2"] = errTag },
+			keep:     []string{"v4", "v3"},
+			remove:   []string{"v1"},
+			errors:   1,
+		},
+		{
+			name:     "без времени создания образ считается самым старым",
+			tags:     []string{"v1", "v2", "v3", "v4"},
+			keepLast: 2,
+			setup:    func(b *fakeBackend) { b.metaErrs["v4"] = errTag },
+			fallback: CreatedFallbackOldest,
+			keep:     []string{"v3", "v2"},
+			remove:   []string{"v1", "v4"},
+			errors:   1,
+		},
+		{
+			name:     "без времени создания план не составляется",
+			tags:     []string{"v1", "v2", "v3"},
+			keepLast: 1,
+			setup:    func(b *fakeBackend) { b.metaErrs["v2"] = errTag },
+			fallback: CreatedFallbackFail,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newFakeBackend("app", tt.tags...)
+			if tt.setup != nil {
+				tt.setup(backend)
+			}
+			planner := &Planner{
+				Backend:         backend,
+				Policy:          KeepLastPolicy{N: tt.keepLast},
+				Concurrency:     2,
+				CreatedFallback: tt.fallback,
+				Log:             io.Discard,
+			}
+
+			plan, err := planner.Plan(context.Background(), "app")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ожидалась ошибка, получен план %+v", plan)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Plan: %v", err)
+			}
+			if got := imageTags(plan.Keep); !reflect.DeepEqual(got, tt.keep) {
+				t.Errorf("сохраняются %v, ожидалось %v", got, tt.keep)
+			}
+			if got := imageTags(plan.Delete); !reflect.DeepEqual(got, tt.remove) {
+				t.Errorf("удаляются %v, ожидалось %v", got, tt.remove)
+			}
+			if len(plan.Errors) != tt.errors {
+				t.Errorf("ошибок тегов %d, ожидалось %d: %v", len(plan.Errors), tt.errors, plan.Errors)
+			}
+			if !reflect.DeepEqual(plan.Tags, tt.tags) {
+				t.Errorf("теги плана %v, ожидалось %v", plan.Tags, tt.tags)
+			}
+		})
+	}
+}
+
+func TestPlannerImmutable(t *testing.T) {
+	tests := []struct {
+		name      string
+		immutable []string
+		keep      []string
+		remove    []string
+	}{
+		{name: "без неизменяемых тегов", keep: []string{"v4", "v3"}, remove: []string{"v2", "v1", "build-1"}},
+		{name: "неизменяемый тег сохраняется", immutable: []string{"v2"}, keep: []string{"v4", "v3", "v2"}, remove: []string{"v1", "build-1"}},
+		{
+			name:      "тег того же образа сохраняется вместе с неизменяемым",
+			immutable: []string{"build-1"},
+			keep:      []string{"v4", "v3", "v1", "build-1"},
+			remove:    []string{"v2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &immutableBackend{fakeBackend: newFakeBackend("app", "v1", "v2", "v3", "v4"), immutable: make(map[string]bool)}
+			// build-1 указывает на тот же образ, что и v1
+			backend.repositories["app"]["build-1"] = backend.repositories["app"]["v1"]
+			for _, tag := range tt.immutable {
+				backend.immutable[tag] = true
+			}
+			planner := &Planner{Backend: backend, Policy: KeepLastPolicy{N: 2}, Log: io.Discard}
+
+			plan, err := planner.Plan(context.Background(), "app")
+			if err != nil {
+				t.Fatalf("Plan: %v", err)
+			}
+			if got := imageTags(plan.Keep); !reflect.DeepEqual(got, tt.keep) {
+				t.Errorf("сохраняются %v, ожидалось %v", got, tt.keep)
+			}
+			if got := imageTags(plan.Delete); !reflect.DeepEqual(got, tt.remove) {
+				t.Errorf("удаляются %v, ожидалось %v", got, tt.remove)
+			}
+		})
+	}
+}
+
+func TestPlannerIncremental(t *testing.T) {
+	tests := []struct {
+		name      string
+		previous  []string
+		current   []string
+		unchanged bool
+	}{
+		{name: "теги не изменились", previous: []string{"v1", "v2", "v3"}, current: []string{"v1", "v2", "v3"}, unchanged: true},
+		{name: "добавлен тег", previous: []string{"v1", "v2"}, current: []string{"v1", "v2", "v3"}},
+		{name: "нет прошлого состояния", current: []string{"v1", "v2", "v3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := LoadState(t.TempDir() + "/state.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.previous != nil {
+				state.SetRepository("app", &RepositoryState{TagsFingerprint: tagsFingerprint(tt.previous)})
+			}
+			planner := &Planner{
+				Backend:     newFakeBackend("app", tt.current...),
+				Policy:      KeepLastPolicy{N: 1},
+				State:       state,
+				Incremental: IncrementalTags,
+				Log:         io.Discard,
+			}
+
+			plan, err := planner.Plan(context.Background(), "app")
+			if err != nil {
+				t.Fatalf("Plan: %v", err)
+			}
+			if plan.Unchanged != tt.unchanged {
+				t.Errorf("Unchanged = %v, ожидалось %v", plan.Unchanged, tt.unchanged)
+			}
+			if !tt.unchanged && len(plan.Delete) != len(tt.current)-1 {
+				t.Errorf("удаляется %d образов, ожидалось %d", len(plan.Delete), len(tt.current)-1)
+			}
+		})
+	}
+}
+
+func TestShortDigest(t *testing.T) {
+	tests := []struct {
+		digest string
+		want   string
+	}{
+		{digest: "sha256:0123456789abcdef0123", want: "sha256:0123456789ab"},
+		{digest: "sha256:0123456789ab", want: "sha256:0123456789ab"},
+		{digest: "sha256:abc", want: "sha256:abc"},
+		{digest: "abc", want: "abc"},
+		{digest: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := ShortDigest(tt.digest); got != tt.want {
+			t.Errorf("ShortDigest(%q) = %q, ожидалось %q", tt.digest, got, tt.want)
+		}
+	}
+}
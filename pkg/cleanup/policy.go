@@ -0,0 +1,72 @@
+package cleanup
+
+import (
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// Policy политика хранения: решает, какие образы репозитория сохранить, а какие удалить
+type Policy interface {
+	// Select получает образы, отсортированные от новых к старым, и разделяет их
+	// на сохраняемые и удаляемые
+	Select(images []registry.ImageInfo) (keep, remove []registry.ImageInfo)
+}
+
+// Prefilter необязательный интерфейс политики, позволяющий пропустить репозиторий
+// по одному списку тегов, не запрашивая метаданные образов
+type Prefilter interface {
+	Skip(tags []string) bool
+}
+
+// KeepLastPolicy сохраняет N самых новых образов и удаляет остальные
+type KeepLastPolicy struct {
+	N int
+}
+
+// Select сохраняет первые N образов. Емкость keep ограничена, чтобы политики-обертки,
+// добавляющие в него образы, не перезаписывали исходный срез
+func (p KeepLastPolicy) Select(images []registry.ImageInfo) (keep, remove []registry.ImageInfo) {
+	if len(images) <= p.N {
+		return images[:len(images):len(images)], nil
+	}
+	return images[:p.N:p.N], images[p.N:]
+}
+
+// Skip пропускает репозитории, в которых не больше N тегов
+func (p KeepLastPolicy) Skip(tags []string) bool {
+	return len(tags) <= p.N
+}
+
+// UnpulledPolicy удаляет из кандидатов базовой политики только образы, которые не скачивались
+// дольше MaxAge. Образ, который ни разу не скачивался, считается используемым с момента создания.
+// Время скачивания заполняет Planner, если backend реализует registry.PullTimeProvider
+type UnpulledPolicy struct {
+	Base   Policy
+	MaxAge time.Duration
+	// Location, если задан, определяет календарь, по которому отсчитываются сутки MaxAge
+	Location *time.Location
+}
+
+// Select сохраняет кандидатов на удаление, которые скачивались или были созданы позже MaxAge назад
+func (p UnpulledPolicy) Select(images []registry.ImageInfo) (keep, remove []registry.ImageInfo) {
+	keep, candidates := p.Base.Select(images)
+	threshold := Cutoff(time.Now(), p.MaxAge, p.Location)
+	for _, img := range candidates {
+		lastUsed := img.LastPulled
+		if img.Created.After(lastUsed) {
+			lastUsed = img.Created
+		}
+		if lastUsed.Before(threshold) {
+			remove = append(remove, img)
+		} else {
+			keep = append(keep, img)
+		}
+	}
+	return keep, remove
+}
+
+// Skip пропускает репозиторий, если его пропускает базовая политика
+func (p UnpulledPolicy) Skip(tags []string) bool {
+	return baseSkip(p.Base, tags)
+}
@@ -0,0 +1,148 @@
+package cleanup
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"registryCleaner/pkg/registry"
+)
+
+// protectedRule шаблоны репозитория и тега в синтаксисе path.Match
+type protectedRule struct {
+	repository string
+	tag        string
+}
+
+// ProtectedTags список неизменяемых тегов: образы этих тегов никогда не удаляются,
+// какой бы ни была политика хранения
+type ProtectedTags struct {
+	rules []protectedRule
+}
+
+// LoadProtectedTags загружает список из файла со строками вида <репозиторий>:<тег>,
+// где обе части могут содержать шаблоны *, ? и [...], например base-images/*:v* или
+// payment-service:release-2024.*. Пустые строки и комментарии # пропускаются
+func LoadProtectedTags(filename string) (*ProtectedTags, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия списка неизменяемых тегов: %v", err)
+	}
+	defer f.Close()
+
+	protected := &ProtectedTags{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		rule, err := parseProtectedRule(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, line, err)
+		}
+		protected.rules = append(protected.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения списка неизменяемых тегов: %v", err)
+	}
+	return protected, nil
+}
+
+// ParseProtectedTags создает список из записей вида <репозиторий>:<тег> с шаблонами, как
+// в файле LoadProtectedTags
+func ParseProtectedTags(entries []string) (*ProtectedTags, error) {
+	protected := &ProtectedTags{}
+	for _, entry := range entries {
+		rule, err := parseProtectedRule(entry)
+		if err != nil {
+			return nil, err
+		}
+		protected.rules = append(protected.rules, rule)
+	}
+	return protected, nil
+}
+
+// parseProtectedRule разбирает запись <репозиторий>:<тег>
+func parseProtectedRule(entry string) (protectedRule, error) {
+	i := strings.LastIndex(entry, ":")
+	if i <= 0 || i == len(entry)-1 {
+		return protectedRule{}, fmt.Errorf("запись %q должна иметь вид <репозиторий>:<тег>", entry)
+	}
+	rule := protectedRule{repository: entry[:i], tag: entry[i+1:]}
+	for _, pattern := range []string{rule.repository, rule.tag} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return protectedRule{}, fmt.Errorf("некорректный шаблон %q: %v", pattern, err)
+		}
+	}
+	return rule, nil
+}
+
+// Len возвращает количество записей
+func (p *ProtectedTags) Len() int {
+	return len(p.rules)
+}
+
+// Match сообщает, защищен ли тег репозитория
+func (p *ProtectedTags) Match(repository, tag string) bool {
+	for _, rule := range p.rules {
+		repoOK, _ := path.Match(rule.repository, repository)
+		tagOK, _ := path.Match(rule.tag, tag)
+		if repoOK && tagOK {
+			return true
+		}
+	}
+	return false
+}
+
+// Keep переносит в сохраняемые кандидатов на удаление с защищенными тегами, а также
+// образы с тем же digest: удаление манифеста по digest удалило бы и защищенный тег.
+// Возвращает сохраненные образы
+func (p *ProtectedTags) Keep(plan *RepositoryPlan) []registry.ImageInfo {
+	digests := make(map[string]bool)
+	for _, images := range [][]registry.ImageInfo{plan.Keep, plan.Delete} {
+		for _, img := range images {
+			if p.Match(img.Repository, img.Tag) {
+				digests[img.Digest] = true
+			}
+		}
+	}
+	if len(digests) == 0 {
+		return nil
+	}
+
+	var kept, remove []registry.ImageInfo
+	for _, img := range plan.Delete {
+		if !digests[img.Digest] {
+			remove = append(remove, img)
+			continue
+		}
+		kept = append(kept, img)
+		plan.Keep = append(plan.Keep, img)
+	}
+	plan.Delete = remove
+	return kept
+}
+
+// keepProtected сохраняет образы защищенных тегов общего списка и списка команды
+// tenant, если репозиторий ей принадлежит
+func (p *Planner) keepProtected(plan *RepositoryPlan, tenant *Tenant) {
+	lists := []*ProtectedTags{p.Protected}
+	if tenant != nil {
+		lists = append(lists, tenant.Protected)
+	}
+	for _, list := range lists {
+		if list == nil {
+			continue
+		}
+		for _, img := range list.Keep(plan) {
+			if list.Match(img.Repository, img.Tag) {
+				p.printf("  Тег %s:%s в списке неизменяемых тегов, образ сохраняется\n", img.Repository, img.Tag)
+			} else {
+				p.printf("  Тег %s:%s указывает на образ неизменяемого тега, образ сохраняется\n", img.Repository, img.Tag)
+			}
+		}
+	}
+}
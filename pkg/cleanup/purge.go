@@ -0,0 +1,111 @@
+package cleanup
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// PurgeFilter фильтр в формате acr purge --filter: <регулярное выражение репозитория>:<регулярное
+// выражение тега>. Выражение репозитория должно совпадать с именем целиком, выражение тега -
+// с любой его частью
+type PurgeFilter struct {
+	Repository *regexp.Regexp
+	Tag        *regexp.Regexp
+}
+
+// ParsePurgeFilter разбирает фильтр вида "samples/.*:^v1\..*"
+func ParsePurgeFilter(s string) (PurgeFilter, error) {
+	repo, tag, ok := strings.Cut(s, ":")
+	if !ok || repo == "" || tag == "" {
+		return PurgeFilter{}, fmt.Errorf("фильтр %q должен иметь вид <репозиторий>:<тег>", s)
+	}
+
+	repoRe, err := regexp.Compile("^(?:" + repo + ")$")
+	if err != nil {
+		return PurgeFilter{}, fmt.Errorf("некорректное выражение репозитория в фильтре %q: %v", s, err)
+	}
+	tagRe, err := regexp.Compile(tag)
+	if err != nil {
+		return PurgeFilter{}, fmt.Errorf("некорректное выражение тега в фильтре %q: %v", s, err)
+	}
+	return PurgeFilter{Repository: repoRe, Tag: tagRe}, nil
+}
+
+// Match сообщает, подходит ли образ под фильтр
+func (f PurgeFilter) Match(img registry.ImageInfo) bool {
+	return f.Repository.MatchString(img.Repository) && f.Tag.MatchString(img.Tag)
+}
+
+// agoUnit длительность в формате acr purge --ago: дни и любые единицы time.ParseDuration
+var agoUnit = regexp.MustCompile(`^(\d+)d(.*)$`)
+
+// ParseAgo разбирает длительность вида "30d", "2d3h6m" или "12h"
+func ParseAgo(s string) (time.Duration, error) {
+	var days time.Duration
+	rest := s
+	if m := agoUnit.FindStringSubmatch(s); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return 0, fmt.Errorf("некорректная длительность %q: %v", s, err)
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		rest = m[2]
+	}
+	if rest == "" {
+		return days, nil
+	}
+
+	d, err := time.ParseDuration(rest)
+	if err != nil {
+		return 0, fmt.Errorf("некорректная длительность %q: %v", s, err)
+	}
+	return days + d, nil
+}
+
+// PurgePolicy удаляет из кандидатов базовой политики только образы, подходящие хотя бы
+// под один фильтр и созданные раньше Ago назад. Пустой список фильтров подходит под все
+// образы, нулевой Ago не ограничивает возраст
+type PurgePolicy struct {
+	Base    Policy
+	Filters []PurgeFilter
+	Ago     time.Duration
+	// Location, если задан, определяет календарь, по которому отсчитываются сутки Ago
+	Location *time.Location
+}
+
+// Select сохраняет кандидатов на удаление, не подходящих под фильтры или более новых, чем Ago
+func (p PurgePolicy) Select(images []registry.ImageInfo) (keep, remove []registry.ImageInfo) {
+	keep, candidates := p.Base.Select(images)
+	threshold := Cutoff(time.Now(), p.Ago, p.Location)
+	for _, img := range candidates {
+		if p.match(img) && (p.Ago == 0 || img.Created.Before(threshold)) {
+			remove = append(remove, img)
+		} else {
+			keep = append(keep, img)
+		}
+	}
+	return keep, remove
+}
+
+// match сообщает, подходит ли образ хотя бы под один фильтр
+func (p PurgePolicy) match(img registry.ImageInfo) bool {
+	if len(p.Filters) == 0 {
+		return true
+	}
+	for _, f := range p.Filters {
+		if f.Match(img) {
+			return true
+		}
+	}
+	return false
+}
+
+// Skip пропускает репозиторий, если его пропускает базовая политика
+func (p PurgePolicy) Skip(tags []string) bool {
+	return baseSkip(p.Base, tags)
+}
@@ -0,0 +1,238 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// Способы карантина образов вместо удаления
+const (
+	// QuarantineRepository копирует образ в репозиторий <префикс>/<репозиторий>
+	QuarantineRepository = "repository"
+	// QuarantineTag оставляет образ в том же репозитории под тегом карантина и
+	// удаляет только исходный тег
+	QuarantineTag = "tag"
+)
+
+// quarantineTimeLayout формат времени помещения в карантин в теге, всегда в UTC
+const quarantineTimeLayout = "20060102-150405"
+
+// quarantineTag тег карантина: zz-trash-<время>-<исходный тег>. Префикс zz сортирует
+// такие теги после остальных в списках registry
+var quarantineTag = regexp.MustCompile(`^zz-trash-(\d{8}-\d{6})-`)
+
+// maxTagLength максимальная длина тега по спецификации OCI distribution
+const maxTagLength = 128
+
+// QuarantineTagName возвращает тег карантина для исходного тега. Длинный исходный тег
+// обрезается до допустимой длины тега
+func QuarantineTagName(tag string, at time.Time) string {
+	name := "zz-trash-" + at.UTC().Format(quarantineTimeLayout) + "-" + tag
+	if len(name) > maxTagLength {
+		name = name[:maxTagLength]
+	}
+	return name
+}
+
+// QuarantinedAt возвращает время помещения в карантин по тегу карантина; ok = false,
+// если тег не является тегом карантина
+func QuarantinedAt(tag string) (at time.Time, ok bool) {
+	m := quarantineTag.FindStringSubmatch(tag)
+	if m == nil {
+		return time.Time{}, false
+	}
+	at, err := time.ParseInLocation(quarantineTimeLayout, m[1], time.UTC)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+// withoutQuarantine возвращает теги без тегов карантина и количество пропущенных
+func withoutQuarantine(tags []string) ([]string, int) {
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if !quarantineTag.MatchString(tag) {
+			result = append(result, tag)
+		}
+	}
+	return result, len(tags) - len(result)
+}
+
+// Quarantine помещает образы в карантин перед удалением: манифест копируется под тегом
+// карантина в репозиторий Prefix/<репозиторий> или, в режиме QuarantineTag, в тот же
+// репозиторий. Образы в карантине не учитываются Planner и удаляются Purge
+type Quarantine struct {
+	Client *registry.Client
+	Mode   string
+	Prefix string
+	// Protected и Holds, если заданы, защищают образы в карантине от удаления Purge так
+	// же, как при очистке
+	Protected *ProtectedTags
+	Holds     *LegalHolds
+	// Log получает ход работы, по умолчанию os.Stdout
+	Log io.Writer
+
+	archiver *registry.Archiver
+}
+
+// NewQuarantine создает карантин в том же registry, что и очищаемые образы
+func NewQuarantine(client *registry.Client, mode, prefix string) *Quarantine {
+	archivePrefix := prefix
+	if mode == QuarantineTag {
+		archivePrefix = ""
+	}
+	return &Quarantine{Client: client, Mode: mode, Prefix: prefix, archiver: registry.NewArchiver(client, client, archivePrefix)}
+}
+
+// printf выводит сообщение о ходе работы
+func (q *Quarantine) printf(format string, args ...interface{}) {
+	out := q.Log
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, format, args...)
+}
+
+// Repository возвращает репозиторий, в котором хранится карантин образов repository
+func (q *Quarantine) Repository(repository string) string {
+	if q.Mode == QuarantineTag {
+		return repository
+	}
+	return q.Prefix + "/" + repository
+}
+
+// Copy копирует образ под тегом карантина и проверяет копию. Возвращает тег карантина;
+// удалять исходный образ можно только при отсутствии ошибки
+func (q *Quarantine) Copy(ctx context.Context, img registry.ImageInfo) (string, error) {
+	copied := img
+	copied.Tag = QuarantineTagName(img.Tag, time.Now())
+	if err := q.archiver.ArchiveImage(ctx, copied); err != nil {
+		return "", err
+	}
+	return copied.Tag, nil
+}
+
+// Purge удаляет из репозитория repository образы, помещенные в карантин раньше before.
+// Образ удаляется по digest, если на него не указывают другие теги, иначе удаляется
+// только тег карантина. Образы неизменяемых тегов и находящиеся на удержании не
+// удаляются. Возвращает количество удаленных тегов и ошибки отдельных тегов
+func (q *Quarantine) Purge(ctx context.Context, repository string, before time.Time) (int, []error, error) {
+	tags, err := q.Client.ListTags(ctx, repository)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var expired []string
+	for _, tag := range tags {
+		if at, ok := QuarantinedAt(tag); ok && at.Before(before) {
+			expired = append(expired, tag)
+		}
+	}
+	if len(expired) == 0 {
+		return 0, nil, nil
+	}
+
+	// Удаление по digest удалило бы и остальные теги того же образа
+	digests := make(map[string]string, len(tags))
+	shared := make(map[string]int)
+	var errs []error
+	for _, tag := range tags {
+		digest, err := q.Client.ResolveDigest(ctx, repository, tag)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%s: не удалось получить digest: %w", repository, tag, err))
+			continue
+		}
+		digests[tag] = digest
+		shared[digest]++
+	}
+
+	// Если digest какого-то тега неизвестен, по digest ничего не удаляется
+	unresolved := len(errs) > 0
+	kept := q.keep(repository, tags, expired, digests)
+
+	purged := 0
+	for _, tag := range expired {
+		digest, ok := digests[tag]
+		if !ok || kept[tag] {
+			continue
+		}
+		if shared[digest] == 1 && !unresolved {
+			err = q.Client.Delete(ctx, repository, digest)
+		} else {
+			err = q.Client.DeleteTag(ctx, repository, tag)
+		}
+		if err != nil {
+			q.printf("  Ошибка при удалении %s:%s: %v\n", repository, tag, err)
+			errs = append(errs, fmt.Errorf("%s:%s: %w", repository, tag, err))
+			continue
+		}
+		shared[digest]--
+		q.printf("  Удален из карантина %s:%s\n", repository, tag)
+		purged++
+	}
+	return purged, errs, nil
+}
+
+// keep возвращает теги карантина из expired, образы которых защищены списком
+// неизменяемых тегов или находятся на удержании, в том числе через другие теги с тем же
+// digest. Теги карантина сверяются со списками по исходным репозиторию и тегу
+func (q *Quarantine) keep(repository string, tags, expired []string, digests map[string]string) map[string]bool {
+	if q.Protected == nil && q.Holds == nil {
+		return nil
+	}
+	original := repository
+	if q.Mode != QuarantineTag && q.Prefix != "" {
+		original = strings.TrimPrefix(repository, q.Prefix+"/")
+	}
+
+	isExpired := make(map[string]bool, len(expired))
+	for _, tag := range expired {
+		isExpired[tag] = true
+	}
+	plan := &RepositoryPlan{Repository: original}
+	for _, tag := range tags {
+		digest, ok := digests[tag]
+		if !ok {
+			continue
+		}
+		img := registry.ImageInfo{Repository: original, Tag: quarantineTag.ReplaceAllString(tag, ""), Digest: digest}
+		if isExpired[tag] {
+			plan.Delete = append(plan.Delete, img)
+		} else {
+			plan.Keep = append(plan.Keep, img)
+		}
+	}
+
+	if q.Protected != nil {
+		for _, img := range q.Protected.Keep(plan) {
+			q.printf("  Образ %s:%s защищен списком неизменяемых тегов, не удаляется из карантина\n", img.Repository, img.Tag)
+		}
+	}
+	if q.Holds != nil {
+		for _, img := range q.Holds.Keep(plan) {
+			q.printf("  Образ %v на удержании, не удаляется из карантина\n", img)
+		}
+	}
+
+	// Теги карантина одного исходного тега и образа защищены или удаляются вместе
+	deletable := make(map[string]bool, len(plan.Delete))
+	for _, img := range plan.Delete {
+		deletable[img.Tag+"@"+img.Digest] = true
+	}
+	kept := make(map[string]bool)
+	for _, tag := range expired {
+		digest, ok := digests[tag]
+		if ok && !deletable[quarantineTag.ReplaceAllString(tag, "")+"@"+digest] {
+			kept[tag] = true
+		}
+	}
+	return kept
+}
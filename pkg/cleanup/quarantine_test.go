@@ -0,0 +1,113 @@
+package cleanup_test
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"registryCleaner/pkg/cleanup"
+	"registryCleaner/pkg/registry"
+	"registryCleaner/pkg/registrytest"
+)
+
+func TestQuarantineTagName(t *testing.T) {
+	at := time.Date(2025, 3, 1, 12, 30, 45, 0, time.FixedZone("MSK", 3*60*60))
+	name := cleanup.QuarantineTagName("v1", at)
+	if name != "zz-trash-20250301-093045-v1" {
+		t.Errorf("тег карантина %q, ожидался zz-trash-20250301-093045-v1", name)
+	}
+	if got, ok := cleanup.QuarantinedAt(name); !ok || !got.Equal(at) {
+		t.Errorf("QuarantinedAt(%q) = %s, %v; ожидалось %s", name, got, ok, at)
+	}
+
+	long := cleanup.QuarantineTagName(strings.Repeat("a", 128), at)
+	if len(long) != 128 {
+		t.Errorf("длина тега карантина %d, ожидалось 128", len(long))
+	}
+	if _, ok := cleanup.QuarantinedAt(long); !ok {
+		t.Errorf("обрезанный тег %q не распознан как тег карантина", long)
+	}
+
+	for _, tag := range []string{"v1", "zz-trash-v1", "zz-trash-2025-v1"} {
+		if _, ok := cleanup.QuarantinedAt(tag); ok {
+			t.Errorf("тег %q распознан как тег карантина", tag)
+		}
+	}
+}
+
+func TestQuarantinePurge(t *testing.T) {
+	mock := registrytest.New()
+	server := httptest.NewServer(mock)
+	defer server.Close()
+	client := registry.NewClient(server.URL, "", "")
+
+	now := time.Now()
+	expired := cleanup.QuarantineTagName("v1", now.Add(-48*time.Hour))
+	fresh := cleanup.QuarantineTagName("v2", now.Add(-time.Hour))
+	mock.Seed("app", expired, now.Add(-72*time.Hour))
+	mock.Seed("app", fresh, now.Add(-72*time.Hour))
+	mock.Seed("app", "v3", now.Add(-72*time.Hour))
+
+	quarantine := cleanup.NewQuarantine(client, cleanup.QuarantineTag, "")
+	quarantine.Log = io.Discard
+	purged, errs, err := quarantine.Purge(context.Background(), "app", now.Add(-24*time.Hour))
+	if err != nil || len(errs) > 0 {
+		t.Fatalf("Purge: %v %v", err, errs)
+	}
+	if purged != 1 {
+		t.Errorf("удалено из карантина %d, ожидалось 1", purged)
+	}
+	if got, want := mock.Tags("app"), []string{"v3", fresh}; !reflect.DeepEqual(got, want) {
+		t.Errorf("остались теги %v, ожидалось %v", got, want)
+	}
+}
+
+func TestQuarantinePurgeProtected(t *testing.T) {
+	mock := registrytest.New()
+	server := httptest.NewServer(mock)
+	defer server.Close()
+	client := registry.NewClient(server.URL, "", "")
+
+	now := time.Now()
+	at := now.Add(-48 * time.Hour)
+	protected := mock.Seed("trash/app", cleanup.QuarantineTagName("v1", at), now.Add(-72*time.Hour))
+	held := mock.Seed("trash/app", cleanup.QuarantineTagName("v2", at), now.Add(-72*time.Hour))
+	// Тег карантина другого исходного тега того же образа, что и защищенный
+	mock.Tag("trash/app", cleanup.QuarantineTagName("v1-alias", at), protected)
+	mock.Seed("trash/app", cleanup.QuarantineTagName("v3", at), now.Add(-72*time.Hour))
+
+	quarantine := cleanup.NewQuarantine(client, cleanup.QuarantineRepository, "trash")
+	quarantine.Log = io.Discard
+	var err error
+	if quarantine.Protected, err = cleanup.ParseProtectedTags([]string{"app:v1"}); err != nil {
+		t.Fatal(err)
+	}
+	if quarantine.Holds, err = cleanup.ParseLegalHolds([]byte(`["app@` + held + `"]`)); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, errs, err := quarantine.Purge(context.Background(), "trash/app", now.Add(-24*time.Hour))
+	if err != nil || len(errs) > 0 {
+		t.Fatalf("Purge: %v %v", err, errs)
+	}
+	if purged != 1 {
+		t.Errorf("удалено из карантина %d, ожидалось 1", purged)
+	}
+	want := []string{
+		cleanup.QuarantineTagName("v1", at),
+		cleanup.QuarantineTagName("v1-alias", at),
+		cleanup.QuarantineTagName("v2", at),
+	}
+	if got := mock.Tags("trash/app"); !reflect.DeepEqual(got, want) {
+		t.Errorf("остались теги %v, ожидалось %v", got, want)
+	}
+	for _, digest := range []string{protected, held} {
+		if !mock.HasManifest("trash/app", digest) {
+			t.Errorf("удален защищенный образ %s", digest)
+		}
+	}
+}
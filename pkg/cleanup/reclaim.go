@@ -0,0 +1,51 @@
+package cleanup
+
+import "registryCleaner/pkg/registry"
+
+// EstimateReclaimed оценивает место, которое освободит garbage collection после выполнения
+// планов, по репозиториям. Blob учитывается один раз и только если на него не ссылается ни
+// один сохраняемый или неудаленный образ любого из планов: общие слои остаются в registry.
+// Blob, общий для нескольких репозиториев, относится к первому из них. Для образов, слои
+// которых backend не сообщает, учитывается их полный размер. Образы репозиториев вне планов
+// неизвестны, поэтому оценка остается верхней границей
+func EstimateReclaimed(plans []*RepositoryPlan) map[string]int64 {
+	referenced := make(map[string]bool)
+	for _, plan := range plans {
+		deleted := make(map[string]bool, len(plan.Deleted))
+		for _, img := range plan.Deleted {
+			deleted[img.Digest] = true
+		}
+		for _, images := range [][]registry.ImageInfo{plan.Keep, plan.Delete} {
+			for _, img := range images {
+				if deleted[img.Digest] {
+					continue
+				}
+				for _, blob := range img.Blobs {
+					referenced[blob.Digest] = true
+				}
+			}
+		}
+	}
+
+	reclaimed := make(map[string]int64)
+	counted := make(map[string]bool)
+	for _, plan := range plans {
+		for _, img := range plan.Deleted {
+			if len(img.Blobs) == 0 {
+				if !counted[img.Digest] {
+					counted[img.Digest] = true
+					reclaimed[plan.Repository] += img.Size
+				}
+				continue
+			}
+			for _, blob := range img.Blobs {
+				if referenced[blob.Digest] || counted[blob.Digest] {
+					continue
+				}
+				counted[blob.Digest] = true
+				reclaimed[plan.Repository] += blob.Size
+			}
+		}
+	}
+	return reclaimed
+}
@@ -0,0 +1,197 @@
+package cleanup_test
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"registryCleaner/pkg/cleanup"
+	"registryCleaner/pkg/registry"
+	"registryCleaner/pkg/registrytest"
+)
+
+// seedBase время создания самого старого образа во встроенном registry
+var seedBase = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestRegistry запускает встроенный registry и возвращает клиент для него
+func newTestRegistry(t *testing.T) (*registrytest.Registry, *registry.Client) {
+	t.Helper()
+	mock := registrytest.New()
+	server := httptest.NewServer(mock)
+	t.Cleanup(server.Close)
+	return mock, registry.NewClient(server.URL, "", "")
+}
+
+// cleanRepository составляет и выполняет план очистки репозитория
+func cleanRepository(t *testing.T, client *registry.Client, planner *cleanup.Planner, repository string) (*cleanup.RepositoryPlan, error) {
+	t.Helper()
+	planner.Backend = client
+	planner.Log = io.Discard
+	plan, err := planner.Plan(context.Background(), repository)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	executor := &cleanup.Executor{Backend: client, Log: io.Discard}
+	return plan, executor.Execute(context.Background(), plan)
+}
+
+func TestCleanupRegistry(t *testing.T) {
+	tests := []struct {
+		name string
+		// seed заполняет репозиторий app и возвращает digest манифестов, которые должны
+		// быть удалены
+		seed    func(mock *registrytest.Registry) []string
+		planner cleanup.Planner
+		left    []string
+		deleted int
+	}{
+		{
+			name: "образы по одному тегу",
+			seed: func(mock *registrytest.Registry) []string {
+				var removed []string
+				for i, tag := range []string{"v1", "v2", "v3", "v4", "v5"} {
+					digest := mock.Seed("app", tag, seedBase.Add(time.Duration(i)*time.Hour))
+					if i < 3 {
+						removed = append(removed, digest)
+					}
+				}
+				return removed
+			},
+			planner: cleanup.Planner{Policy: cleanup.KeepLastPolicy{N: 2}},
+			left:    []string{"v4", "v5"},
+			deleted: 3,
+		},
+		{
+			name: "manifest list удаляется по digest",
+			seed: func(mock *registrytest.Registry) []string {
+				old := mock.SeedIndex("app", "v1", seedBase, "linux/amd64", "linux/arm64")
+				mock.SeedIndex("app", "v2", seedBase.Add(time.Hour), "linux/amd64", "linux/arm64")
+				mock.Seed("app", "v3", seedBase.Add(2*time.Hour))
+				return []string{old}
+			},
+			planner: cleanup.Planner{Policy: cleanup.KeepLastPolicy{N: 2}},
+			left:    []string{"v2", "v3"},
+			deleted: 1,
+		},
+		{
+			name: "фильтр платформы",
+			seed: func(mock *registrytest.Registry) []string {
+				old := mock.SeedIndex("app", "v1", seedBase, "linux/amd64", "linux/arm64")
+				mock.SeedIndex("app", "v2", seedBase.Add(time.Hour), "linux/arm64")
+				mock.SeedIndex("app", "v3", seedBase.Add(2*time.Hour), "linux/amd64")
+				return []string{old}
+			},
+			planner: cleanup.Planner{Policy: cleanup.KeepLastPolicy{N: 1}, Platform: "linux/amd64"},
+			left:    []string{"v2", "v3"},
+			deleted: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, client := newTestRegistry(t)
+			removed := tt.seed(mock)
+
+			planner := tt.planner
+			plan, err := cleanRepository(t, client, &planner, "app")
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if len(plan.Deleted) != tt.deleted {
+				t.Errorf("удалено образов %d, ожидалось %d", len(plan.Deleted), tt.deleted)
+			}
+			if got := mock.Tags("app"); !reflect.DeepEqual(got, tt.left) {
+				t.Errorf("остались теги %v, ожидалось %v", got, tt.left)
+			}
+			for _, digest := range removed {
+				if mock.HasManifest("app", digest) {
+					t.Errorf("манифест %s не удален", digest)
+				}
+			}
+		})
+	}
+}
+
+func TestCleanupRegistryDeleteByDigest(t *testing.T) {
+	mock, client := newTestRegistry(t)
+	old := mock.Seed("app", "v1", seedBase)
+	mock.Seed("app", "v2", seedBase.Add(time.Hour))
+	mock.Seed("app", "v3", seedBase.Add(2*time.Hour))
+	// Второй тег того же манифеста удаляется вместе с ним
+	mock.Tag("app", "build-1", old)
+
+	plan, err := cleanRepository(t, client, &cleanup.Planner{Policy: cleanup.KeepLastPolicy{N: 2}}, "app")
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := mock.Tags("app"); !reflect.DeepEqual(got, []string{"v2", "v3"}) {
+		t.Errorf("остались теги %v, ожидалось [v2 v3]", got)
+	}
+	if mock.HasManifest("app", old) {
+		t.Errorf("манифест %s не удален", old)
+	}
+	if len(plan.Deleted) != 2 {
+		t.Errorf("удалено образов %d, ожидалось 2", len(plan.Deleted))
+	}
+}
+
+func TestCleanupRegistryNotFound(t *testing.T) {
+	mock, client := newTestRegistry(t)
+	for i, tag := range []string{"v1", "v2", "v3"} {
+		mock.Seed("app", tag, seedBase.Add(time.Duration(i)*time.Hour))
+	}
+
+	planner := &cleanup.Planner{Backend: client, Policy: cleanup.KeepLastPolicy{N: 1}, Log: io.Discard}
+	plan, err := planner.Plan(context.Background(), "app")
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	// Образ удалили после составления плана, например другим экземпляром
+	deleted := plan.Delete[0]
+	if err := client.Delete(context.Background(), "app", deleted.Digest); err != nil {
+		t.Fatal(err)
+	}
+
+	err = (&cleanup.Executor{Backend: client, Log: io.Discard}).Execute(context.Background(), plan)
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("Execute вернул %v, ожидалась ошибка 404", err)
+	}
+	if len(plan.Deleted) != 1 || plan.Deleted[0].Tag == deleted.Tag {
+		t.Errorf("удалены %v, ожидался только образ, кроме %s", plan.Deleted, deleted.Tag)
+	}
+	if got := mock.Tags("app"); !reflect.DeepEqual(got, []string{"v3"}) {
+		t.Errorf("остались теги %v, ожидалось [v3]", got)
+	}
+}
+
+func TestCleanupRegistryMissingRepository(t *testing.T) {
+	_, client := newTestRegistry(t)
+	planner := &cleanup.Planner{Backend: client, Policy: cleanup.KeepLastPolicy{N: 1}, Log: io.Discard}
+	plan, err := planner.Plan(context.Background(), "missing")
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("Plan вернул план %+v и ошибку %v, ожидалась ошибка 404", plan, err)
+	}
+}
+
+func TestCleanupRegistryDeleteDisabled(t *testing.T) {
+	mock, client := newTestRegistry(t)
+	mock.DeleteDisabled = true
+	for i, tag := range []string{"v1", "v2"} {
+		mock.Seed("app", tag, seedBase.Add(time.Duration(i)*time.Hour))
+	}
+
+	plan, err := cleanRepository(t, client, &cleanup.Planner{Policy: cleanup.KeepLastPolicy{N: 1}}, "app")
+	if err == nil {
+		t.Fatal("Execute без ошибки, хотя registry не поддерживает удаление")
+	}
+	if len(plan.Deleted) != 0 {
+		t.Errorf("удалены %v", plan.Deleted)
+	}
+	if got := mock.Tags("app"); !reflect.DeepEqual(got, []string{"v1", "v2"}) {
+		t.Errorf("остались теги %v, ожидалось [v1 v2]", got)
+	}
+}
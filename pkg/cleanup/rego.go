@@ -0,0 +1,136 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/open-policy-agent/opa/v1/rego"
+
+	"registryCleaner/pkg/registry"
+)
+
+// DefaultRegoQuery запрос, результатом которого должен быть список тегов для удаления
+const DefaultRegoQuery = "data.registry_cleaner.delete"
+
+// RegoImage образ репозитория во входных данных Rego-политики
+type RegoImage struct {
+	ExecCandidate
+	// Candidate образ отобран базовой политикой для удаления
+	Candidate bool `json:"candidate"`
+}
+
+// RegoInput входные данные Rego-политики: все образы репозитория от новых к старым
+type RegoInput struct {
+	Repository string      `json:"repository"`
+	Images     []RegoImage `json:"images"`
+}
+
+// RegoPolicy вычисляет Rego-политику OPA над всеми образами репозитория. Запрос должен
+// вернуть множество или массив тегов (или digest) для удаления; удаляются только те
+// из них, которые отобрала базовая политика. Если политику не удалось вычислить,
+// все образы репозитория сохраняются
+type RegoPolicy struct {
+	Base Policy
+	// Log получает предупреждения, по умолчанию os.Stdout
+	Log io.Writer
+
+	query rego.PreparedEvalQuery
+}
+
+// LoadRegoPolicy загружает политику из .rego файла, каталога или bundle (.tar.gz)
+// и подготавливает запрос query
+func LoadRegoPolicy(ctx context.Context, base Policy, path, query string) (*RegoPolicy, error) {
+	if query == "" {
+		query = DefaultRegoQuery
+	}
+
+	load := rego.LoadBundle(path)
+	if strings.HasSuffix(path, ".rego") {
+		load = rego.Load([]string{path}, nil)
+	}
+
+	prepared, err := rego.New(rego.Query(query), load).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки Rego-политики %s: %v", path, err)
+	}
+
+	return &RegoPolicy{Base: base, query: prepared}, nil
+}
+
+// printf выводит предупреждение
+func (p *RegoPolicy) printf(format string, args ...interface{}) {
+	out := p.Log
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, format, args...)
+}
+
+// Select отбирает образы базовой политикой и оставляет среди удаляемых только те,
+// которые вернула Rego-политика
+func (p *RegoPolicy) Select(images []registry.ImageInfo) (keep, remove []registry.ImageInfo) {
+	keep, candidates := p.Base.Select(images)
+	if len(candidates) == 0 {
+		return keep, nil
+	}
+
+	selected, err := p.evaluate(images, candidates)
+	if err != nil {
+		p.printf("  Предупреждение: Rego-политика не вычислена для %s, образы сохраняются: %v\n", candidates[0].Repository, err)
+		return append(keep, candidates...), nil
+	}
+
+	for _, img := range candidates {
+		if selected[img.Tag] || selected[img.Digest] {
+			remove = append(remove, img)
+		} else {
+			keep = append(keep, img)
+		}
+	}
+	return keep, remove
+}
+
+// Skip пропускает репозиторий, если его пропускает базовая политика
+func (p *RegoPolicy) Skip(tags []string) bool {
+	return baseSkip(p.Base, tags)
+}
+
+// evaluate вычисляет политику и возвращает множество тегов и digest для удаления
+func (p *RegoPolicy) evaluate(images, candidates []registry.ImageInfo) (map[string]bool, error) {
+	isCandidate := make(map[string]bool, len(candidates))
+	for _, img := range candidates {
+		isCandidate[img.Tag] = true
+	}
+
+	input := RegoInput{Repository: candidates[0].Repository}
+	for _, img := range images {
+		input.Images = append(input.Images, RegoImage{ExecCandidate: newExecCandidate(img), Candidate: isCandidate[img.Tag]})
+	}
+
+	results, err := p.query.Eval(context.Background(), rego.EvalInput(input))
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]bool)
+	if len(results) == 0 || len(results[0].Expressions) == 0 {
+		// Неопределенный результат означает, что удалять нечего
+		return selected, nil
+	}
+
+	values, ok := results[0].Expressions[0].Value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("запрос вернул %T вместо множества тегов", results[0].Expressions[0].Value)
+	}
+	for _, value := range values {
+		ref, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("запрос вернул %v вместо тега", value)
+		}
+		selected[ref] = true
+	}
+	return selected, nil
+}
@@ -0,0 +1,148 @@
+package cleanup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// maxSBOMSize ограничение размера прикрепленного SBOM, читаемого в память
+const maxSBOMSize = 256 << 20
+
+// sbomExtensions расширения файлов SBOM по типу содержимого
+var sbomExtensions = map[string]string{
+	"text/spdx":                      ".spdx",
+	"text/spdx+json":                 ".spdx.json",
+	"application/vnd.cyclonedx+xml":  ".cdx.xml",
+	"application/vnd.cyclonedx+json": ".cdx.json",
+	"application/vnd.syft+json":      ".syft.json",
+}
+
+// SBOMArchiver сохраняет SBOM удаляемых образов в каталог Dir, чтобы сведения о составе
+// и лицензиях образа пережили его удаление. Используется SBOM, прикрепленный cosign
+// (тег sha256-<digest>.sbom), а без него, если задан Command, SBOM создается программой
+// syft. Файл называется <репозиторий>_<тег>_<digest>.<формат>, поэтому SBOM разных
+// образов с одним тегом не перезаписывают друг друга
+type SBOMArchiver struct {
+	Client *registry.Client
+	Dir    string
+	// Command программа syft; пустая строка - сохранять только прикрепленные SBOM
+	Command string
+	// Registry адрес registry в ссылках на образы: host[:port]
+	Registry string
+	// Insecure обращаться к registry по HTTP
+	Insecure bool
+	// Username и Password учетные данные registry, передаются syft через окружение
+	Username string
+	Password string
+	// Timeout ограничение времени создания одного SBOM, 0 - без ограничения
+	Timeout time.Duration
+}
+
+// Archive сохраняет SBOM образа и возвращает путь к файлу. Ошибка возвращается, если
+// SBOM не удалось ни получить из registry, ни создать
+func (a *SBOMArchiver) Archive(ctx context.Context, img registry.ImageInfo) (string, error) {
+	if img.Digest == "" {
+		return "", fmt.Errorf("digest образа неизвестен")
+	}
+
+	content, ext, err := a.attached(ctx, img)
+	if errors.Is(err, registry.ErrNotFound) {
+		if a.Command == "" {
+			return "", fmt.Errorf("у образа нет прикрепленного SBOM, а создание SBOM программой syft не включено")
+		}
+		content, ext, err = a.generate(ctx, img)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(a.Dir, 0o755); err != nil {
+		return "", fmt.Errorf("ошибка создания каталога SBOM: %v", err)
+	}
+	name := strings.ReplaceAll(img.Repository, "/", "_") + "_" + img.Tag + "_" +
+		strings.Replace(img.Digest, ":", "-", 1) + ext
+	path := filepath.Join(a.Dir, name)
+	if err := writeFile(path, content); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// attached получает SBOM, прикрепленный к образу командой cosign attach sbom: первый слой
+// манифеста тега sha256-<digest>.sbom. Если тега нет, ошибка оборачивает registry.ErrNotFound
+func (a *SBOMArchiver) attached(ctx context.Context, img registry.ImageInfo) ([]byte, string, error) {
+	tag := strings.Replace(img.Digest, ":", "-", 1) + ".sbom"
+	raw, _, _, err := a.Client.GetManifest(ctx, img.Repository, tag)
+	if err != nil {
+		return nil, "", err
+	}
+	var manifest registry.Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, "", fmt.Errorf("ошибка разбора манифеста SBOM %s:%s: %v", img.Repository, tag, err)
+	}
+	if len(manifest.Layers) == 0 {
+		return nil, "", fmt.Errorf("манифест SBOM %s:%s не содержит слоев", img.Repository, tag)
+	}
+	layer := manifest.Layers[0]
+
+	blob, _, err := a.Client.GetBlob(ctx, img.Repository, layer.Digest)
+	if err != nil {
+		return nil, "", err
+	}
+	defer blob.Close()
+	content, err := io.ReadAll(io.LimitReader(blob, maxSBOMSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка чтения SBOM %s:%s: %v", img.Repository, tag, err)
+	}
+	if len(content) > maxSBOMSize {
+		return nil, "", fmt.Errorf("SBOM %s:%s больше %d МБ", img.Repository, tag, maxSBOMSize>>20)
+	}
+	sum := sha256.Sum256(content)
+	if computed := "sha256:" + hex.EncodeToString(sum[:]); computed != layer.Digest {
+		return nil, "", fmt.Errorf("digest SBOM %s:%s не совпадает с содержимым (%s)", img.Repository, tag, computed)
+	}
+
+	ext, ok := sbomExtensions[strings.ToLower(layer.MediaType)]
+	if !ok {
+		ext = ".json"
+	}
+	return content, ext, nil
+}
+
+// generate создает SBOM образа в формате SPDX JSON программой syft. Образ передается по digest
+func (a *SBOMArchiver) generate(ctx context.Context, img registry.ImageInfo) ([]byte, string, error) {
+	if a.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.Timeout)
+		defer cancel()
+	}
+	args := []string{"scan", "--quiet", "--output", "spdx-json", "registry:" + a.Registry + "/" + img.Repository + "@" + img.Digest}
+	var env []string
+	if a.Insecure {
+		env = append(env, "SYFT_REGISTRY_INSECURE_USE_HTTP=true")
+	}
+	if a.Username != "" {
+		env = append(env, "SYFT_REGISTRY_AUTH_AUTHORITY="+a.Registry,
+			"SYFT_REGISTRY_AUTH_USERNAME="+a.Username, "SYFT_REGISTRY_AUTH_PASSWORD="+a.Password)
+	}
+
+	output, err := runCommand(ctx, a.Command, args, env)
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка создания SBOM программой syft: %v", err)
+	}
+	if !json.Valid(output) {
+		return nil, "", fmt.Errorf("syft вернул некорректный SBOM")
+	}
+	return output, ".spdx.json", nil
+}
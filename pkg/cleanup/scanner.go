@@ -0,0 +1,338 @@
+package cleanup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// Scanner сканер безопасности или качества образов. Итоги сканирования учитывают
+// VulnerabilityPolicy и, через ScanPolicy, остальные политики хранения, поэтому политики
+// не зависят от конкретного сканера
+type Scanner interface {
+	// Scan возвращает итоги сканирования образа; ok = false, если сведений об образе нет
+	Scan(ctx context.Context, img registry.ImageInfo) (result registry.ScanResult, ok bool, err error)
+}
+
+var (
+	_ Scanner = (*TrivyScanner)(nil)
+	_ Scanner = (*GrypeScanner)(nil)
+	_ Scanner = (*HTTPScanner)(nil)
+	_ Scanner = BackendScanner{}
+)
+
+// scanEntry запомненный результат сканирования
+type scanEntry struct {
+	result registry.ScanResult
+	ok     bool
+	err    error
+}
+
+// ScanCache запоминает итоги сканирования по digest, чтобы образ с одним digest
+// сканировался за запуск один раз, сколько бы политик и тегов его ни учитывали
+type ScanCache struct {
+	Scanner Scanner
+	// Timeout ограничение времени сканирования одного образа, 0 - без ограничения
+	Timeout time.Duration
+	// Log получает предупреждения, по умолчанию os.Stdout
+	Log io.Writer
+
+	mu      sync.Mutex
+	results map[string]scanEntry
+}
+
+// NewScanCache создает кэш итогов сканирования сканером scanner
+func NewScanCache(scanner Scanner, timeout time.Duration) *ScanCache {
+	return &ScanCache{Scanner: scanner, Timeout: timeout, results: make(map[string]scanEntry)}
+}
+
+// printf выводит предупреждение
+func (c *ScanCache) printf(format string, args ...interface{}) {
+	out := c.Log
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, format, args...)
+}
+
+// Get возвращает запомненные итоги сканирования образа или сканирует его. Об ошибке
+// сканирования предупреждает один раз
+func (c *ScanCache) Get(img registry.ImageInfo) (registry.ScanResult, bool, error) {
+	c.mu.Lock()
+	entry, found := c.results[img.Digest]
+	c.mu.Unlock()
+	if found && img.Digest != "" {
+		return entry.result, entry.ok, entry.err
+	}
+
+	ctx := context.Background()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	entry.result, entry.ok, entry.err = c.Scanner.Scan(ctx, img)
+	if entry.err != nil {
+		c.printf("  Предупреждение: не удалось просканировать %s:%s: %v\n", img.Repository, img.Tag, entry.err)
+	}
+
+	if img.Digest != "" {
+		c.mu.Lock()
+		c.results[img.Digest] = entry
+		c.mu.Unlock()
+	}
+	return entry.result, entry.ok, entry.err
+}
+
+// ScanPolicy сканирует все образы репозитория до базовой политики и передает ей итоги
+// сканирования в ImageInfo.Scan: их учитывают CEL (vulnerabilities и scan), Rego и
+// внешние политики (поле scan). Образы сканируются параллельно, не больше Concurrency
+// одновременно
+type ScanPolicy struct {
+	Base        Policy
+	Scans       *ScanCache
+	Concurrency int
+}
+
+// Select сканирует образы и отбирает их базовой политикой
+func (p *ScanPolicy) Select(images []registry.ImageInfo) (keep, remove []registry.ImageInfo) {
+	scanned := append([]registry.ImageInfo(nil), images...)
+	sem := make(chan struct{}, max(p.Concurrency, 1))
+	var wg sync.WaitGroup
+	for i := range scanned {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if result, ok, err := p.Scans.Get(scanned[i]); err == nil && ok {
+				scanned[i].Scan = &result
+			}
+		}()
+	}
+	wg.Wait()
+	return p.Base.Select(scanned)
+}
+
+// Skip пропускает репозиторий, если его пропускает базовая политика
+func (p *ScanPolicy) Skip(tags []string) bool {
+	return baseSkip(p.Base, tags)
+}
+
+// BackendScanner берет итоги сканирования, которые ведет сам registry, например Harbor
+type BackendScanner struct {
+	Provider registry.VulnerabilityProvider
+}
+
+// Scan возвращает уязвимости тега по данным registry
+func (s BackendScanner) Scan(ctx context.Context, img registry.ImageInfo) (registry.ScanResult, bool, error) {
+	v, ok, err := s.Provider.Vulnerabilities(ctx, img.Repository, img.Tag)
+	return registry.ScanResult{Vulnerabilities: v}, ok, err
+}
+
+// runCommand запускает программу сканирования или проверки и возвращает ее stdout
+func runCommand(ctx context.Context, command string, args, env []string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// TrivyScanner сканирует образы программой trivy, при заданном Server - в режиме клиента
+// сервера Trivy. Образ передается по digest, поэтому отчет относится именно к нему
+type TrivyScanner struct {
+	// Command программа trivy, по умолчанию trivy из PATH
+	Command string
+	// Server адрес сервера Trivy, пустая строка - сканировать локально
+	Server string
+	// Registry адрес registry в ссылках на образы: host[:port]
+	Registry string
+	// Insecure обращаться к registry по HTTP
+	Insecure bool
+	// Username и Password учетные данные registry, передаются trivy через окружение
+	Username string
+	Password string
+}
+
+// trivyReport часть отчета trivy в формате JSON с уровнями важности уязвимостей
+type trivyReport struct {
+	Results []struct {
+		Vulnerabilities []struct {
+			Severity string `json:"Severity"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+// Scan сканирует образ и возвращает количество уязвимостей по уровням важности
+func (s *TrivyScanner) Scan(ctx context.Context, img registry.ImageInfo) (registry.ScanResult, bool, error) {
+	if img.Digest == "" {
+		return registry.ScanResult{}, false, fmt.Errorf("digest образа неизвестен")
+	}
+	command := s.Command
+	if command == "" {
+		command = "trivy"
+	}
+	args := []string{"image", "--quiet", "--format", "json", "--scanners", "vuln"}
+	if s.Server != "" {
+		args = append(args, "--server", s.Server)
+	}
+	if s.Insecure {
+		args = append(args, "--insecure")
+	}
+	args = append(args, s.Registry+"/"+img.Repository+"@"+img.Digest)
+	var env []string
+	if s.Username != "" {
+		env = append(env, "TRIVY_USERNAME="+s.Username, "TRIVY_PASSWORD="+s.Password)
+	}
+
+	output, err := runCommand(ctx, command, args, env)
+	if err != nil {
+		return registry.ScanResult{}, false, err
+	}
+	var report trivyReport
+	if err := json.Unmarshal(output, &report); err != nil {
+		return registry.ScanResult{}, false, fmt.Errorf("ошибка разбора отчета trivy: %v", err)
+	}
+	v := make(registry.Vulnerabilities)
+	for _, result := range report.Results {
+		for _, vuln := range result.Vulnerabilities {
+			v[strings.ToUpper(vuln.Severity)]++
+		}
+	}
+	return registry.ScanResult{Vulnerabilities: v}, true, nil
+}
+
+// GrypeScanner сканирует образы программой grype. Образ передается по digest
+type GrypeScanner struct {
+	// Command программа grype, по умолчанию grype из PATH
+	Command string
+	// Registry адрес registry в ссылках на образы: host[:port]
+	Registry string
+	// Insecure обращаться к registry по HTTP
+	Insecure bool
+	// Username и Password учетные данные registry, передаются grype через окружение
+	Username string
+	Password string
+}
+
+// grypeReport часть отчета grype в формате JSON с уровнями важности уязвимостей
+type grypeReport struct {
+	Matches []struct {
+		Vulnerability struct {
+			Severity string `json:"severity"`
+		} `json:"vulnerability"`
+	} `json:"matches"`
+}
+
+// Scan сканирует образ и возвращает количество уязвимостей по уровням важности. Уровень
+// Negligible grype учитывается как LOW
+func (s *GrypeScanner) Scan(ctx context.Context, img registry.ImageInfo) (registry.ScanResult, bool, error) {
+	if img.Digest == "" {
+		return registry.ScanResult{}, false, fmt.Errorf("digest образа неизвестен")
+	}
+	command := s.Command
+	if command == "" {
+		command = "grype"
+	}
+	args := []string{"--quiet", "--output", "json", "registry:" + s.Registry + "/" + img.Repository + "@" + img.Digest}
+	var env []string
+	if s.Insecure {
+		env = append(env, "GRYPE_REGISTRY_INSECURE_USE_HTTP=true")
+	}
+	if s.Username != "" {
+		env = append(env, "GRYPE_REGISTRY_AUTH_AUTHORITY="+s.Registry,
+			"GRYPE_REGISTRY_AUTH_USERNAME="+s.Username, "GRYPE_REGISTRY_AUTH_PASSWORD="+s.Password)
+	}
+
+	output, err := runCommand(ctx, command, args, env)
+	if err != nil {
+		return registry.ScanResult{}, false, err
+	}
+	var report grypeReport
+	if err := json.Unmarshal(output, &report); err != nil {
+		return registry.ScanResult{}, false, fmt.Errorf("ошибка разбора отчета grype: %v", err)
+	}
+	v := make(registry.Vulnerabilities)
+	for _, match := range report.Matches {
+		severity := strings.ToUpper(match.Vulnerability.Severity)
+		if severity == "NEGLIGIBLE" {
+			severity = "LOW"
+		}
+		v[severity]++
+	}
+	return registry.ScanResult{Vulnerabilities: v}, true, nil
+}
+
+// HTTPScanner получает итоги сканирования от внешнего HTTP-сервиса: образ передается
+// запросом POST в формате ExecCandidate, сервис отвечает registry.ScanResult в формате
+// JSON, например {"vulnerabilities": {"CRITICAL": 1}, "attributes": {"license": "denied"}}.
+// Статус 404 означает, что сведений об образе нет
+type HTTPScanner struct {
+	URL string
+	// Token, если задан, передается в заголовке Authorization: Bearer
+	Token  string
+	Client *http.Client
+}
+
+// Scan запрашивает итоги сканирования образа у сервиса
+func (s *HTTPScanner) Scan(ctx context.Context, img registry.ImageInfo) (registry.ScanResult, bool, error) {
+	body, err := candidateJSON(img)
+	if err != nil {
+		return registry.ScanResult{}, false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return registry.ScanResult{}, false, fmt.Errorf("некорректный адрес сканера: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return registry.ScanResult{}, false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return registry.ScanResult{}, false, nil
+	default:
+		return registry.ScanResult{}, false, fmt.Errorf("сканер вернул статус %d", resp.StatusCode)
+	}
+
+	var result registry.ScanResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return registry.ScanResult{}, false, fmt.Errorf("ошибка разбора ответа сканера: %v", err)
+	}
+	// Уровни важности сравниваются в верхнем регистре, как в Severities
+	v := make(registry.Vulnerabilities, len(result.Vulnerabilities))
+	for severity, n := range result.Vulnerabilities {
+		v[strings.ToUpper(severity)] += n
+	}
+	result.Vulnerabilities = v
+	return result, true, nil
+}
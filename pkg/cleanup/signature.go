@@ -0,0 +1,102 @@
+package cleanup
+
+import (
+	"context"
+	"regexp"
+
+	"registryCleaner/pkg/registry"
+)
+
+// signatureTag тег подписи, аттестации или SBOM, которые cosign сохраняет рядом с
+// образом: sha256-<digest образа>.sig, .att или .sbom
+var signatureTag = regexp.MustCompile(`^sha256-([0-9a-f]{64})\.(sig|att|sbom)$`)
+
+// SignatureSubject возвращает digest образа, к которому относится тег подписи cosign;
+// ok = false, если тег не является тегом подписи
+func SignatureSubject(tag string) (digest string, ok bool) {
+	m := signatureTag.FindStringSubmatch(tag)
+	if m == nil {
+		return "", false
+	}
+	return "sha256:" + m[1], true
+}
+
+// countSignatures возвращает количество тегов подписей cosign
+func countSignatures(tags []string) int {
+	n := 0
+	for _, tag := range tags {
+		if signatureTag.MatchString(tag) {
+			n++
+		}
+	}
+	return n
+}
+
+// splitSignatures отделяет подписи cosign от остальных образов
+func splitSignatures(images []registry.ImageInfo) (rest, signatures []registry.ImageInfo) {
+	for _, img := range images {
+		if signatureTag.MatchString(img.Tag) {
+			signatures = append(signatures, img)
+		} else {
+			rest = append(rest, img)
+		}
+	}
+	return rest, signatures
+}
+
+// sweepSignatures добавляет в план подписи cosign: подпись удаляется, если образа, к
+// которому она относится, нет в репозитории или он удаляется по плану, иначе сохраняется.
+// images все образы репозитория, кроме подписей, в том числе не прошедшие фильтр по
+// платформе. Образ без тега ищется запросом манифеста по digest, если backend реализует
+// registry.ManifestChecker; без него такие подписи сохраняются
+func (p *Planner) sweepSignatures(ctx context.Context, plan *RepositoryPlan, images, signatures []registry.ImageInfo) {
+	if len(signatures) == 0 {
+		return
+	}
+
+	tagged := make(map[string]bool, len(images))
+	for _, img := range images {
+		tagged[img.Digest] = true
+	}
+	deleted := make(map[string]bool, len(plan.Delete))
+	for _, img := range plan.Delete {
+		deleted[img.Digest] = true
+	}
+	// Образ, digest которого есть и среди сохраняемых, например из-за неизменяемого
+	// тега, остается в репозитории
+	for _, img := range plan.Keep {
+		delete(deleted, img.Digest)
+	}
+	checker, _ := p.Backend.(registry.ManifestChecker)
+
+	swept := &RepositoryPlan{Repository: plan.Repository}
+	for _, sig := range signatures {
+		subject, _ := SignatureSubject(sig.Tag)
+		switch {
+		case deleted[subject]:
+			p.printf("  Подпись %s:%s относится к удаляемому образу, удаляется\n", sig.Repository, sig.Tag)
+			swept.Delete = append(swept.Delete, sig)
+		case tagged[subject]:
+			swept.Keep = append(swept.Keep, sig)
+		case checker == nil:
+			swept.Keep = append(swept.Keep, sig)
+		default:
+			exists, err := checker.ManifestExists(ctx, sig.Repository, subject)
+			if err != nil {
+				p.printf("  Предупреждение: не удалось проверить образ подписи %s:%s, подпись сохраняется: %v\n", sig.Repository, sig.Tag, err)
+				exists = true
+			}
+			if exists {
+				swept.Keep = append(swept.Keep, sig)
+				continue
+			}
+			p.printf("  Подпись %s:%s относится к отсутствующему образу, удаляется\n", sig.Repository, sig.Tag)
+			swept.Delete = append(swept.Delete, sig)
+		}
+	}
+
+	p.keepProtected(swept, p.Tenants.Owner(plan.Repository))
+	p.keepImmutable(ctx, swept)
+	plan.Keep = append(plan.Keep, swept.Keep...)
+	plan.Delete = append(plan.Delete, swept.Delete...)
+}
@@ -0,0 +1,127 @@
+package cleanup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// Mark отметка кандидата на удаление: digest тега и время, когда он впервые оказался
+// кандидатом
+type Mark struct {
+	Digest string    `json:"digest"`
+	Marked time.Time `json:"marked"`
+}
+
+// SoftDelete двухфазное удаление: запуск отмечает кандидатов на удаление, а удаляются
+// они одним из следующих запусков, если с отметки прошло не меньше Grace и образ все еще
+// подлежит удалению. Ошибка в политике или временный сбой метаданных успевают
+// обнаружиться до того, как образы будут удалены
+type SoftDelete struct {
+	Path  string
+	Grace time.Duration
+
+	mu sync.Mutex
+	// repositories отметки по репозиториям и тегам
+	repositories map[string]map[string]Mark
+}
+
+// softDeleteFile содержимое файла отметок
+type softDeleteFile struct {
+	Repositories map[string]map[string]Mark `json:"repositories"`
+}
+
+// LoadSoftDelete загружает отметки из файла, отсутствующий файл означает отсутствие отметок
+func LoadSoftDelete(path string, grace time.Duration) (*SoftDelete, error) {
+	s := &SoftDelete{Path: path, Grace: grace, repositories: make(map[string]map[string]Mark)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения отметок удаления %s: %v", path, err)
+	}
+
+	var file softDeleteFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("ошибка разбора отметок удаления %s: %v", path, err)
+	}
+	if file.Repositories != nil {
+		s.repositories = file.Repositories
+	}
+	return s, nil
+}
+
+// Len возвращает количество отмеченных тегов
+func (s *SoftDelete) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, marks := range s.repositories {
+		n += len(marks)
+	}
+	return n
+}
+
+// Marked сообщает, есть ли в репозитории отмеченные теги
+func (s *SoftDelete) Marked(repository string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.repositories[repository]) > 0
+}
+
+// Apply отмечает кандидатов на удаление из плана и переносит в сохраняемые тех, с отметки
+// которых прошло меньше Grace. Отметка сбрасывается, если тег перестал быть кандидатом
+// или стал указывать на другой digest. Возвращает время, после которого будут удалены
+// отложенные теги
+func (s *SoftDelete) Apply(plan *RepositoryPlan, now time.Time) map[string]time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.repositories[plan.Repository]
+	marks := make(map[string]Mark, len(plan.Delete))
+	// Удаление по digest удалило бы и отложенные теги того же образа, поэтому все теги
+	// digest откладываются до самой поздней из их отметок
+	due := make(map[string]time.Time)
+	for _, img := range plan.Delete {
+		mark, ok := previous[img.Tag]
+		if !ok || mark.Digest != img.Digest {
+			mark = Mark{Digest: img.Digest, Marked: now.UTC()}
+		}
+		marks[img.Tag] = mark
+		if at := mark.Marked.Add(s.Grace); at.After(due[img.Digest]) {
+			due[img.Digest] = at
+		}
+	}
+
+	deferred := make(map[string]time.Time)
+	var remove []registry.ImageInfo
+	for _, img := range plan.Delete {
+		if at := due[img.Digest]; now.Before(at) {
+			deferred[img.Tag] = at
+			plan.Keep = append(plan.Keep, img)
+			continue
+		}
+		remove = append(remove, img)
+	}
+	plan.Delete = remove
+
+	if len(marks) == 0 {
+		delete(s.repositories, plan.Repository)
+	} else {
+		s.repositories[plan.Repository] = marks
+	}
+	return deferred
+}
+
+// Save атомарно записывает отметки в файл
+func (s *SoftDelete) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeJSONFile(s.Path, softDeleteFile{Repositories: s.repositories})
+}
@@ -0,0 +1,135 @@
+package cleanup
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"registryCleaner/pkg/registry"
+)
+
+// Порядок образов, в котором их получает политика хранения
+const (
+	// SortCreated по времени создания, новые первыми
+	SortCreated = "created"
+	// SortBuildNumber по номеру сборки в теге (build-1234), большие номера первыми.
+	// Сборки, переиспользующие слои, часто имеют неверное время создания, а номер
+	// сборки растет монотонно
+	SortBuildNumber = "build-number"
+)
+
+// Порядок образов с одинаковым временем создания, например у воспроизводимых сборок.
+// Без него порядок зависел бы от порядка тегов в ответе registry
+const (
+	// TieBreakTag по тегу в естественном порядке: build-10 считается новее build-9
+	TieBreakTag = "tag"
+	// TieBreakTagReverse по тегу в обратном естественном порядке: build-9 считается
+	// новее build-10
+	TieBreakTagReverse = "tag-reverse"
+	// TieBreakDigest по digest манифеста, образы одного манифеста - по тегу
+	TieBreakDigest = "digest"
+)
+
+// buildNumber последнее число в теге
+var buildNumber = regexp.MustCompile(`\d+`)
+
+// SortImages упорядочивает образы от новых к старым согласно strategy. При сортировке
+// по номеру сборки теги без номера, например latest, считаются новее любой сборки,
+// чтобы политика не удалила их из-за отсутствия номера; между собой они упорядочены
+// по времени создания. Образы с одинаковым временем упорядочиваются по tieBreak, пустое
+// значение - TieBreakTag
+func SortImages(images []registry.ImageInfo, strategy, tieBreak string) {
+	if strategy != SortBuildNumber {
+		sort.SliceStable(images, func(i, j int) bool {
+			return newer(images[i], images[j], tieBreak)
+		})
+		return
+	}
+
+	numbers := make(map[string]uint64, len(images))
+	for _, img := range images {
+		if n, ok := tagBuildNumber(img.Tag); ok {
+			numbers[img.Tag] = n
+		}
+	}
+	sort.SliceStable(images, func(i, j int) bool {
+		a, okA := numbers[images[i].Tag]
+		b, okB := numbers[images[j].Tag]
+		switch {
+		case okA && okB && a != b:
+			return a > b
+		case okA != okB:
+			return !okA
+		default:
+			return newer(images[i], images[j], tieBreak)
+		}
+	})
+}
+
+// newer сообщает, что образ a новее b: создан позже, а при одинаковом времени создания
+// следует раньше по tieBreak
+func newer(a, b registry.ImageInfo, tieBreak string) bool {
+	if !a.Created.Equal(b.Created) {
+		return a.Created.After(b.Created)
+	}
+	switch tieBreak {
+	case TieBreakTagReverse:
+		return naturalLess(a.Tag, b.Tag)
+	case TieBreakDigest:
+		if a.Digest != b.Digest {
+			return a.Digest < b.Digest
+		}
+		return naturalLess(b.Tag, a.Tag)
+	default:
+		return naturalLess(b.Tag, a.Tag)
+	}
+}
+
+// naturalLess сравнивает строки в естественном порядке: последовательности цифр
+// сравниваются как числа, поэтому v1.9 меньше v1.10
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		chunkA, chunkB := naturalChunk(a), naturalChunk(b)
+		if chunkA != chunkB {
+			if isDigit(chunkA[0]) && isDigit(chunkB[0]) {
+				numA, numB := strings.TrimLeft(chunkA, "0"), strings.TrimLeft(chunkB, "0")
+				if len(numA) != len(numB) {
+					return len(numA) < len(numB)
+				}
+				if numA != numB {
+					return numA < numB
+				}
+				// Равные числа с разным количеством ведущих нулей
+				return len(chunkA) < len(chunkB)
+			}
+			return chunkA < chunkB
+		}
+		a, b = a[len(chunkA):], b[len(chunkB):]
+	}
+	return len(a) < len(b)
+}
+
+// naturalChunk возвращает начало строки из одних цифр или из одних нецифровых символов
+func naturalChunk(s string) string {
+	digit := isDigit(s[0])
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digit {
+		i++
+	}
+	return s[:i]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// tagBuildNumber возвращает последнее число в теге
+func tagBuildNumber(tag string) (uint64, bool) {
+	all := buildNumber.FindAllString(tag, -1)
+	if len(all) == 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(all[len(all)-1], 10, 64)
+	return n, err == nil
+}
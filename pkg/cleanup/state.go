@@ -0,0 +1,244 @@
+package cleanup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// Режимы инкрементальной очистки
+const (
+	// IncrementalTags пропускает репозиторий, если не изменился список тегов
+	IncrementalTags = "tags"
+	// IncrementalDigests дополнительно сравнивает digest тегов, замечая перезаписанные теги
+	IncrementalDigests = "digests"
+)
+
+// State состояние очистки, сохраняемое между запусками
+type State struct {
+	Repositories map[string]*RepositoryState `json:"repositories"`
+	// Checkpoint прогресс текущего или прерванного запуска, nil после успешного завершения
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// RepositoryState состояние репозитория после последней успешной очистки. Репозитории,
+// записанные в состояние, считаются управляемыми очисткой
+type RepositoryState struct {
+	TagsFingerprint    string    `json:"tagsFingerprint"`
+	DigestsFingerprint string    `json:"digestsFingerprint,omitempty"`
+	Cleaned            time.Time `json:"cleaned"`
+	// FirstSeen время, когда репозиторий взят под управление
+	FirstSeen time.Time `json:"firstSeen,omitempty"`
+	// Policy описание политики, по которой выполнена последняя очистка
+	Policy string `json:"policy,omitempty"`
+	// Kept и Deleted решения последней очистки: количество сохраненных образов и
+	// удаленные теги
+	Kept    int      `json:"kept,omitempty"`
+	Deleted []string `json:"deleted,omitempty"`
+}
+
+// Checkpoint прогресс запуска, позволяющий продолжить его после прерывания
+type Checkpoint struct {
+	Registry  string         `json:"registry"`
+	Started   time.Time      `json:"started"`
+	Completed []string       `json:"completed"`
+	Deleted   map[string]int `json:"deleted"`
+}
+
+// IsCompleted проверяет, был ли репозиторий полностью обработан
+func (c *Checkpoint) IsCompleted(repository string) bool {
+	for _, completed := range c.Completed {
+		if completed == repository {
+			return true
+		}
+	}
+	return false
+}
+
+// TotalDeleted возвращает количество образов, удаленных с начала запуска
+func (c *Checkpoint) TotalDeleted() int {
+	total := 0
+	for _, n := range c.Deleted {
+		total += n
+	}
+	return total
+}
+
+// LoadState загружает состояние из файла, отсутствующий файл означает пустое состояние
+func LoadState(path string) (*State, error) {
+	state := &State{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("ошибка чтения состояния %s: %v", path, err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, state); err != nil {
+			return nil, fmt.Errorf("ошибка разбора состояния %s: %v", path, err)
+		}
+	}
+
+	if state.Repositories == nil {
+		state.Repositories = make(map[string]*RepositoryState)
+	}
+	return state, nil
+}
+
+// Repository возвращает сохраненное состояние репозитория или nil
+func (s *State) Repository(repository string) *RepositoryState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Repositories[repository]
+}
+
+// SetRepository сохраняет состояние репозитория
+func (s *State) SetRepository(repository string, state *RepositoryState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Repositories[repository] = state
+}
+
+// Adopt берет репозиторий под управление: записывает его в состояние без результатов
+// очистки. Возвращает false, если репозиторий уже управляется
+func (s *State) Adopt(repository string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Repositories[repository] != nil {
+		return false
+	}
+	s.Repositories[repository] = &RepositoryState{FirstSeen: time.Now().UTC()}
+	return true
+}
+
+// Save атомарно записывает состояние в файл
+func (s *State) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save()
+}
+
+// save записывает состояние, вызывающий должен держать s.mu
+func (s *State) save() error {
+	return writeJSONFile(s.path, s)
+}
+
+// InterruptedCheckpoint возвращает прогресс прерванного запуска для registry или nil
+func (s *State) InterruptedCheckpoint(registry string) *Checkpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Checkpoint == nil || s.Checkpoint.Registry != registry {
+		return nil
+	}
+	return s.Checkpoint
+}
+
+// StartCheckpoint начинает отслеживание прогресса нового запуска
+func (s *State) StartCheckpoint(registry string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Checkpoint = &Checkpoint{Registry: registry, Started: time.Now().UTC(), Deleted: make(map[string]int)}
+	return s.save()
+}
+
+// CheckpointDeletion записывает удаление образа, чтобы оно не потерялось при прерывании
+func (s *State) CheckpointDeletion(repository string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Checkpoint == nil {
+		return nil
+	}
+	if s.Checkpoint.Deleted == nil {
+		s.Checkpoint.Deleted = make(map[string]int)
+	}
+	s.Checkpoint.Deleted[repository]++
+	return s.save()
+}
+
+// CheckpointRepository отмечает репозиторий как полностью обработанный
+func (s *State) CheckpointRepository(repository string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Checkpoint == nil {
+		return nil
+	}
+	s.Checkpoint.Completed = append(s.Checkpoint.Completed, repository)
+	return s.save()
+}
+
+// FinishCheckpoint сбрасывает прогресс после успешного завершения запуска
+func (s *State) FinishCheckpoint() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Checkpoint = nil
+}
+
+// tagsFingerprint вычисляет отпечаток списка тегов, не зависящий от порядка
+func tagsFingerprint(tags []string) string {
+	sorted := append([]string(nil), tags...)
+	sort.Strings(sorted)
+
+	hash := sha256.New()
+	for _, tag := range sorted {
+		hash.Write([]byte(tag + "\n"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// digestsFingerprint вычисляет отпечаток пар тег-digest, не зависящий от порядка
+func digestsFingerprint(images []registry.ImageInfo) string {
+	pairs := make([]string, 0, len(images))
+	for _, img := range images {
+		pairs = append(pairs, img.Tag+"@"+img.Digest)
+	}
+	return tagsFingerprint(pairs)
+}
+
+// RecordRepository запоминает отпечаток репозитория после успешной очистки, чтобы в
+// инкрементальном режиме следующий запуск мог его пропустить, а также политику очистки
+// policy и ее решения
+func (s *State) RecordRepository(plan *RepositoryPlan, policy string) {
+	if plan.Unchanged {
+		return
+	}
+
+	deleted := make(map[string]bool, len(plan.Delete))
+	for _, img := range plan.Delete {
+		deleted[img.Tag] = true
+	}
+	var remaining []string
+	for _, tag := range plan.Tags {
+		if !deleted[tag] {
+			remaining = append(remaining, tag)
+		}
+	}
+
+	now := time.Now().UTC()
+	state := &RepositoryState{
+		TagsFingerprint: tagsFingerprint(remaining),
+		Cleaned:         now,
+		FirstSeen:       now,
+		Policy:          policy,
+		Kept:            len(plan.Keep),
+	}
+	if previous := s.Repository(plan.Repository); previous != nil && !previous.FirstSeen.IsZero() {
+		state.FirstSeen = previous.FirstSeen
+	}
+	for _, img := range plan.Deleted {
+		state.Deleted = append(state.Deleted, img.Tag)
+	}
+	// Digest известны, только если метаданные тегов запрашивались
+	if plan.Total() > 0 {
+		state.DigestsFingerprint = digestsFingerprint(plan.Keep)
+	}
+	s.SetRepository(plan.Repository, state)
+}
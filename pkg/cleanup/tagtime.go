@@ -0,0 +1,70 @@
+package cleanup
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// TagTimeParser извлекает время создания образа из имени тега, например 20240115-1130
+// или v2024.01.15. Такое время не зависит от кэшированных слоев сборки и не требует
+// загрузки конфигурации образа
+type TagTimeParser struct {
+	// Pattern находит в теге часть со временем: первую группу захвата или все совпадение
+	Pattern *regexp.Regexp
+	// Layout формат времени в нотации пакета time, например 20060102-1504
+	Layout string
+	// Location часовой пояс времени в тегах без указания пояса, по умолчанию UTC
+	Location *time.Location
+}
+
+// NewTagTimeParser создает разбор времени по формату layout. Если pattern пуст, выражение
+// строится из формата: каждая цифра формата соответствует любой цифре тега
+func NewTagTimeParser(layout, pattern string) (*TagTimeParser, error) {
+	if layout == "" {
+		return nil, fmt.Errorf("не задан формат времени в теге")
+	}
+	if pattern == "" {
+		pattern = layoutPattern(layout)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("некорректное выражение времени в теге: %v", err)
+	}
+	return &TagTimeParser{Pattern: re, Layout: layout}, nil
+}
+
+// layoutPattern строит регулярное выражение из числового формата времени
+func layoutPattern(layout string) string {
+	var b strings.Builder
+	for _, r := range regexp.QuoteMeta(layout) {
+		if r >= '0' && r <= '9' {
+			b.WriteString(`\d`)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Parse возвращает время из тега; ok = false, если тег не содержит времени в формате Layout
+func (p *TagTimeParser) Parse(tag string) (t time.Time, ok bool) {
+	m := p.Pattern.FindStringSubmatch(tag)
+	if m == nil {
+		return time.Time{}, false
+	}
+	value := m[0]
+	if len(m) > 1 {
+		value = m[1]
+	}
+	loc := p.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t, err := time.ParseInLocation(p.Layout, value, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,115 @@
+package cleanup
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Tenant команда, владеющая пространствами имен registry. Ее политика хранения и
+// неизменяемые теги действуют только на репозитории этих пространств имен
+type Tenant struct {
+	Name string
+	// Namespaces пространства имен команды, например payments или ml/models: команде
+	// принадлежит репозиторий с таким именем и все репозитории внутри него
+	Namespaces []string
+	Policy     Policy
+	// Description описание политики команды для вывода и файла состояния
+	Description string
+	// Protected, если задан, защищает теги репозиториев команды в дополнение к общему
+	// списку Planner.Protected
+	Protected *ProtectedTags
+}
+
+// Tenants команды registry. Пространства имен разных команд не пересекаются, поэтому у
+// репозитория не больше одного владельца. Репозитории вне пространств имен команд
+// очищаются по общей политике Planner
+type Tenants struct {
+	// tenants упорядочены по имени
+	tenants []*Tenant
+}
+
+// NewTenants проверяет, что пространства имен команд не пересекаются, а неизменяемые
+// теги каждой команды относятся только к ее пространствам имен
+func NewTenants(tenants []*Tenant) (*Tenants, error) {
+	owners := make(map[string]string)
+	names := make(map[string]bool)
+	for _, tenant := range tenants {
+		if tenant.Name == "" {
+			return nil, fmt.Errorf("у команды не указано имя")
+		}
+		if names[tenant.Name] {
+			return nil, fmt.Errorf("команда %s указана несколько раз", tenant.Name)
+		}
+		names[tenant.Name] = true
+		if len(tenant.Namespaces) == 0 {
+			return nil, fmt.Errorf("у команды %s не указаны пространства имен", tenant.Name)
+		}
+		for i, ns := range tenant.Namespaces {
+			ns = strings.Trim(ns, "/")
+			if ns == "" || strings.ContainsAny(ns, "*?[") {
+				return nil, fmt.Errorf("некорректное пространство имен %q команды %s", tenant.Namespaces[i], tenant.Name)
+			}
+			tenant.Namespaces[i] = ns
+		}
+		for _, ns := range tenant.Namespaces {
+			for other, owner := range owners {
+				if inNamespace(ns, other) || inNamespace(other, ns) {
+					return nil, fmt.Errorf("пространство имен %s команды %s пересекается с %s команды %s", ns, tenant.Name, other, owner)
+				}
+			}
+			owners[ns] = tenant.Name
+		}
+		if tenant.Protected != nil {
+			for _, rule := range tenant.Protected.rules {
+				if !tenant.owns(rule.repository) {
+					return nil, fmt.Errorf("неизменяемые теги команды %s: шаблон %s относится к репозиториям вне ее пространств имен %s",
+						tenant.Name, rule.repository, strings.Join(tenant.Namespaces, ", "))
+				}
+			}
+		}
+	}
+
+	sorted := append([]*Tenant(nil), tenants...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return &Tenants{tenants: sorted}, nil
+}
+
+// inNamespace сообщает, находится ли репозиторий или шаблон репозитория в пространстве
+// имен ns. Шаблон, который начинается с ns/, совпадает только с репозиториями внутри ns
+func inNamespace(repository, ns string) bool {
+	return repository == ns || strings.HasPrefix(repository, ns+"/")
+}
+
+// owns сообщает, принадлежит ли репозиторий команде
+func (t *Tenant) owns(repository string) bool {
+	for _, ns := range t.Namespaces {
+		if inNamespace(repository, ns) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len возвращает количество команд
+func (t *Tenants) Len() int {
+	return len(t.tenants)
+}
+
+// All возвращает команды, упорядоченные по имени
+func (t *Tenants) All() []*Tenant {
+	return t.tenants
+}
+
+// Owner возвращает команду, владеющую репозиторием, или nil
+func (t *Tenants) Owner(repository string) *Tenant {
+	if t == nil {
+		return nil
+	}
+	for _, tenant := range t.tenants {
+		if tenant.owns(repository) {
+			return tenant
+		}
+	}
+	return nil
+}
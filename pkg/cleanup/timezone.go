@@ -0,0 +1,28 @@
+package cleanup
+
+import "time"
+
+// TimeLayout формат вывода времени создания образов и запусков
+const TimeLayout = "2006-01-02 15:04:05"
+
+// FormatTime выводит время в часовом поясе loc, nil - в локальном. Время создания из
+// registry обычно в UTC, поэтому без приведения образы и запуски выводились бы вперемешку
+// в UTC и локальном времени
+func FormatTime(t time.Time, loc *time.Location) string {
+	if loc == nil {
+		loc = time.Local
+	}
+	return t.In(loc).Format(TimeLayout)
+}
+
+// Cutoff возвращает границу возраста age, отсчитанного от now. С часовым поясом loc целые
+// сутки age отсчитываются по его календарю: граница "старше 30 дней" приходится на то же
+// время суток 30 дней назад и не сдвигается на час при переходе на летнее время. Без
+// часового пояса age вычитается как длительность
+func Cutoff(now time.Time, age time.Duration, loc *time.Location) time.Time {
+	if loc == nil {
+		return now.Add(-age)
+	}
+	days := age / (24 * time.Hour)
+	return now.In(loc).AddDate(0, 0, -int(days)).Add(-(age - days*24*time.Hour))
+}
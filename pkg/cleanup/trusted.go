@@ -0,0 +1,181 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// TrustedIdentity удостоверение подписи без ключа (Fulcio): издатель OIDC и регулярное
+// выражение идентичности в сертификате
+type TrustedIdentity struct {
+	Issuer   string
+	Identity string
+}
+
+// ParseTrustedIdentity разбирает удостоверение вида <издатель>=<выражение идентичности>,
+// например https://token.actions.githubusercontent.com=^https://github.com/acme/
+func ParseTrustedIdentity(s string) (TrustedIdentity, error) {
+	issuer, identity, ok := strings.Cut(s, "=")
+	if !ok || issuer == "" || identity == "" {
+		return TrustedIdentity{}, fmt.Errorf("удостоверение %q должно иметь вид <издатель OIDC>=<выражение идентичности>", s)
+	}
+	return TrustedIdentity{Issuer: issuer, Identity: identity}, nil
+}
+
+// notVerified фрагменты ошибок cosign verify, означающие, что доверенной подписи нет:
+// подписи сделаны другим ключом или другой идентичностью
+var notVerified = []string{
+	"no matching signatures",
+	"no signatures found",
+	"none of the expected identities matched",
+}
+
+// SignatureVerifier проверяет подписи образов программой cosign. Подписанные доверенным
+// ключом или удостоверением образы считаются выпусками и не удаляются автоматически
+type SignatureVerifier struct {
+	// Command программа cosign, по умолчанию cosign из PATH
+	Command string
+	// Keys открытые ключи: файлы или URI KMS в формате cosign
+	Keys []string
+	// Identities удостоверения подписей без ключа
+	Identities []TrustedIdentity
+	// Registry адрес registry в ссылках на образы: host[:port]
+	Registry string
+	// Insecure обращаться к registry по HTTP
+	Insecure bool
+	// Timeout ограничение времени одной проверки, 0 - без ограничения
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	verified map[string]bool
+}
+
+// Verify сообщает, подписан ли образ хотя бы одним доверенным ключом или удостоверением.
+// Ошибка возвращается, если подпись проверить не удалось, например из-за недоступности
+// registry. Результат запоминается по digest
+func (v *SignatureVerifier) Verify(ctx context.Context, img registry.ImageInfo) (bool, error) {
+	v.mu.Lock()
+	verified, ok := v.verified[img.Digest]
+	v.mu.Unlock()
+	if ok {
+		return verified, nil
+	}
+
+	command := v.Command
+	if command == "" {
+		command = "cosign"
+	}
+	ref := v.Registry + "/" + img.Repository + "@" + img.Digest
+	var checks [][]string
+	for _, key := range v.Keys {
+		checks = append(checks, []string{"--key", key})
+	}
+	for _, id := range v.Identities {
+		checks = append(checks, []string{"--certificate-oidc-issuer", id.Issuer, "--certificate-identity-regexp", id.Identity})
+	}
+
+	var failure error
+	for _, check := range checks {
+		args := append([]string{"verify", "--output", "json"}, check...)
+		if v.Insecure {
+			args = append(args, "--allow-http-registry")
+		}
+		args = append(args, ref)
+
+		err := v.run(ctx, command, args)
+		if err == nil {
+			verified = true
+			break
+		}
+		if !isNotVerified(err) {
+			failure = err
+		}
+	}
+	// Без доверенной подписи ошибка любой проверки оставляет результат неизвестным
+	if !verified && failure != nil {
+		return false, failure
+	}
+
+	v.mu.Lock()
+	if v.verified == nil {
+		v.verified = make(map[string]bool)
+	}
+	v.verified[img.Digest] = verified
+	v.mu.Unlock()
+	return verified, nil
+}
+
+// run выполняет одну проверку cosign verify с ограничением времени Timeout
+func (v *SignatureVerifier) run(ctx context.Context, command string, args []string) error {
+	if v.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.Timeout)
+		defer cancel()
+	}
+	_, err := runCommand(ctx, command, args, nil)
+	return err
+}
+
+// isNotVerified сообщает, означает ли ошибка cosign verify отсутствие доверенной подписи
+func isNotVerified(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range notVerified {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// keepSigned переносит в сохраняемые кандидатов на удаление, подписанных доверенным ключом,
+// кроме подходящих под DeleteSigned, а также образы с тем же digest. Проверяются только
+// образы, для которых в репозитории есть тег подписи cosign sha256-<digest>.sig: без него
+// образ не подписан. Если подпись проверить не удалось, образ сохраняется
+func (p *Planner) keepSigned(ctx context.Context, plan *RepositoryPlan) {
+	if p.Signatures == nil {
+		return
+	}
+
+	signed := make(map[string]bool)
+	for _, tag := range plan.Tags {
+		if subject, ok := SignatureSubject(tag); ok && strings.HasSuffix(tag, ".sig") {
+			signed[subject] = true
+		}
+	}
+	if len(signed) == 0 {
+		return
+	}
+
+	keep := make(map[string]bool)
+	for _, img := range plan.Delete {
+		if _, checked := keep[img.Digest]; checked || !signed[img.Digest] {
+			continue
+		}
+		if p.DeleteSigned != nil && p.DeleteSigned.Match(img.Repository, img.Tag) {
+			continue
+		}
+		verified, err := p.Signatures.Verify(ctx, img)
+		if err != nil {
+			p.printf("  Предупреждение: не удалось проверить подпись %s:%s, образ сохраняется: %v\n", img.Repository, img.Tag, err)
+			verified = true
+		} else if verified {
+			p.printf("  Образ %s:%s подписан доверенным ключом, сохраняется\n", img.Repository, img.Tag)
+		}
+		keep[img.Digest] = verified
+	}
+
+	var remove []registry.ImageInfo
+	for _, img := range plan.Delete {
+		if keep[img.Digest] {
+			plan.Keep = append(plan.Keep, img)
+			continue
+		}
+		remove = append(remove, img)
+	}
+	plan.Delete = remove
+}
@@ -0,0 +1,145 @@
+package cleanup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// ParseVulnerableRule разбирает правило удаления уязвимых образов вида "critical:30d":
+// уровень важности и возраст, старше которого удаляются образы с уязвимостями этого
+// уровня и выше. Без возраста правило относится к образам любого возраста
+func ParseVulnerableRule(s string) (severity string, age time.Duration, err error) {
+	level, ago, hasAge := strings.Cut(s, ":")
+	if severity, err = registry.ParseSeverity(level); err != nil {
+		return "", 0, err
+	}
+	if hasAge {
+		if age, err = ParseAgo(ago); err != nil {
+			return "", 0, err
+		}
+	}
+	return severity, age, nil
+}
+
+// VulnerabilityPolicy учитывает уязвимости образов, найденные сканером Scans:
+//   - с DeleteSeverity образы уровня DeleteSeverity и выше, созданные раньше DeleteAge
+//     назад, удаляются независимо от базовой политики;
+//   - с KeepClean новейший образ репозитория без уязвимостей уровня KeepClean и выше
+//     сохраняется, даже если базовая политика его удаляет.
+//
+// Сканируются только образы, для которых это нужно: сохраняемые старше DeleteAge и
+// образы от новейшего до первого без уязвимостей
+type VulnerabilityPolicy struct {
+	Base  Policy
+	Scans *ScanCache
+	// DeleteSeverity и DeleteAge правило удаления уязвимых образов, пустой уровень - выключено
+	DeleteSeverity string
+	DeleteAge      time.Duration
+	// KeepClean уровень важности для сохранения новейшего образа без уязвимостей,
+	// пустая строка - выключено
+	KeepClean string
+	// Location, если задан, определяет календарь, по которому отсчитываются сутки DeleteAge
+	Location *time.Location
+	// Log получает ход работы, по умолчанию os.Stdout
+	Log io.Writer
+}
+
+// printf выводит сообщение о ходе работы
+func (p *VulnerabilityPolicy) printf(format string, args ...interface{}) {
+	out := p.Log
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, format, args...)
+}
+
+// Select отбирает образы базовой политикой и применяет правила уязвимостей
+func (p *VulnerabilityPolicy) Select(images []registry.ImageInfo) (keep, remove []registry.ImageInfo) {
+	keep, remove = p.Base.Select(images)
+
+	if p.DeleteSeverity != "" {
+		threshold := Cutoff(time.Now(), p.DeleteAge, p.Location)
+		var kept []registry.ImageInfo
+		for _, img := range keep {
+			if p.DeleteAge == 0 || img.Created.Before(threshold) {
+				if n := p.vulnerable(img, p.DeleteSeverity); n > 0 {
+					p.printf("  Образ %s:%s содержит уязвимостей уровня %s и выше: %d, удаляется\n",
+						img.Repository, img.Tag, strings.ToLower(p.DeleteSeverity), n)
+					remove = append(remove, img)
+					continue
+				}
+			}
+			kept = append(kept, img)
+		}
+		keep = kept
+	}
+
+	if p.KeepClean != "" {
+		keep, remove = p.keepClean(images, keep, remove)
+	}
+	return keep, remove
+}
+
+// keepClean сохраняет новейший образ без уязвимостей уровня KeepClean и выше. Кандидат на
+// удаление, который не удалось просканировать, тоже сохраняется: он может оказаться
+// единственным образом без уязвимостей
+func (p *VulnerabilityPolicy) keepClean(images, keep, remove []registry.ImageInfo) ([]registry.ImageInfo, []registry.ImageInfo) {
+	removing := make(map[string]bool, len(remove))
+	for _, img := range remove {
+		removing[img.Tag] = true
+	}
+
+	rescued := make(map[string]bool)
+	for _, img := range images {
+		result, ok, err := p.Scans.Get(img)
+		if err != nil {
+			if removing[img.Tag] {
+				rescued[img.Tag] = true
+			}
+			continue
+		}
+		if !ok || result.Vulnerabilities.AtLeast(p.KeepClean) > 0 {
+			continue
+		}
+		if removing[img.Tag] {
+			p.printf("  Образ %s:%s - новейший без уязвимостей уровня %s и выше, сохраняется\n",
+				img.Repository, img.Tag, strings.ToLower(p.KeepClean))
+			rescued[img.Tag] = true
+		}
+		break
+	}
+	if len(rescued) == 0 {
+		return keep, remove
+	}
+
+	var rest []registry.ImageInfo
+	for _, img := range remove {
+		if rescued[img.Tag] {
+			keep = append(keep, img)
+		} else {
+			rest = append(rest, img)
+		}
+	}
+	return keep, rest
+}
+
+// vulnerable возвращает количество уязвимостей образа уровня severity и выше, 0 - если
+// сведений нет или сканирование не удалось
+func (p *VulnerabilityPolicy) vulnerable(img registry.ImageInfo, severity string) int {
+	result, ok, err := p.Scans.Get(img)
+	if err != nil || !ok {
+		return 0
+	}
+	return result.Vulnerabilities.AtLeast(severity)
+}
+
+// Skip пропускает репозиторий, если его пропускает базовая политика. С правилом удаления
+// уязвимых образов репозиторий не пропускается: уязвимым может быть любой из образов
+func (p *VulnerabilityPolicy) Skip(tags []string) bool {
+	return p.DeleteSeverity == "" && baseSkip(p.Base, tags)
+}
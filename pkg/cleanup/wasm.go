@@ -0,0 +1,116 @@
+package cleanup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+
+	"registryCleaner/pkg/registry"
+)
+
+// WasmPolicy работает так же, как ExecPolicy, но вместо внешней программы выполняет
+// WASI-модуль внутри процесса. Модуль не получает доступа к файловой системе, сети
+// и переменным окружения: для каждого кандидата на удаление он запускается заново,
+// читает ExecCandidate в формате JSON из stdin и выводит keep или delete в stdout
+type WasmPolicy struct {
+	Base Policy
+	// Timeout ограничение времени одного запуска модуля, 0 - без ограничения
+	Timeout time.Duration
+	// Log получает предупреждения, по умолчанию os.Stdout
+	Log io.Writer
+
+	runtime wazero.Runtime
+	module  wazero.CompiledModule
+}
+
+// LoadWasmPolicy компилирует WASI-модуль из файла path
+func LoadWasmPolicy(ctx context.Context, base Policy, path string) (*WasmPolicy, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения модуля %s: %v", path, err)
+	}
+
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("ошибка инициализации WASI: %v", err)
+	}
+
+	module, err := runtime.CompileModule(ctx, code)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("ошибка компиляции модуля %s: %v", path, err)
+	}
+
+	return &WasmPolicy{Base: base, runtime: runtime, module: module}, nil
+}
+
+// Close освобождает ресурсы среды выполнения модуля
+func (p *WasmPolicy) Close(ctx context.Context) error {
+	return p.runtime.Close(ctx)
+}
+
+// printf выводит предупреждение
+func (p *WasmPolicy) printf(format string, args ...interface{}) {
+	out := p.Log
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, format, args...)
+}
+
+// Select отбирает образы базовой политикой и запрашивает решение модуля по каждому кандидату на удаление
+func (p *WasmPolicy) Select(images []registry.ImageInfo) (keep, remove []registry.ImageInfo) {
+	return confirmCandidates(p.Base, images, p.decide, p.printf)
+}
+
+// Skip пропускает репозиторий, если его пропускает базовая политика
+func (p *WasmPolicy) Skip(tags []string) bool {
+	return baseSkip(p.Base, tags)
+}
+
+// decide запускает модуль для одного образа
+func (p *WasmPolicy) decide(img registry.ImageInfo) (string, error) {
+	input, err := candidateJSON(img)
+	if err != nil {
+		return "", err
+	}
+
+	ctx := context.Background()
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	config := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs("policy").
+		WithStdin(bytes.NewReader(input)).
+		WithStdout(&stdout).
+		WithStderr(&stderr)
+
+	module, err := p.runtime.InstantiateModule(ctx, p.module, config)
+	if module != nil {
+		module.Close(ctx)
+	}
+	var exitErr *sys.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 0) {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%v: %s", err, msg)
+		}
+		return "", err
+	}
+
+	return parseDecision(stdout.Bytes())
+}
@@ -0,0 +1,385 @@
+// Package dockerhub реализует registry.Backend поверх API Docker Hub. Hub не предоставляет
+// _catalog и удаление манифестов, поэтому репозитории и теги получаются через hub.docker.com,
+// а теги удаляются по одному. Клиент выдерживает паузы, которых требуют ограничения частоты запросов
+package dockerhub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// DefaultBaseURL адрес API Docker Hub
+const DefaultBaseURL = "https://hub.docker.com"
+
+// pageSize количество элементов на странице ответов Hub API
+const pageSize = 100
+
+// maxRateLimitRetries количество повторов запроса, отклоненного ограничением частоты
+const maxRateLimitRetries = 5
+
+// Client клиент API Docker Hub. Репозитории берутся из пространств имен Namespaces
+// и именуются <пространство имен>/<репозиторий>
+type Client struct {
+	BaseURL  string
+	Username string
+	// Password пароль или персональный токен доступа
+	Password   string
+	Namespaces []string
+	Client     *http.Client
+	// Log получает сообщения об ожидании из-за ограничений частоты, по умолчанию os.Stdout
+	Log io.Writer
+
+	mu      sync.Mutex
+	token   string
+	resetAt time.Time
+	tags    map[string][]tag
+}
+
+// tag тег репозитория Hub
+type tag struct {
+	Name          string    `json:"name"`
+	Digest        string    `json:"digest"`
+	FullSize      int64     `json:"full_size"`
+	LastUpdated   time.Time `json:"last_updated"`
+	TagLastPushed time.Time `json:"tag_last_pushed"`
+	TagLastPulled time.Time `json:"tag_last_pulled"`
+}
+
+var (
+	_ registry.Backend          = (*Client)(nil)
+	_ registry.PullTimeProvider = (*Client)(nil)
+)
+
+// NewClient создает клиент Docker Hub. Если пространства имен не заданы,
+// очищаются репозитории пользователя username
+func NewClient(baseURL, username, password string, namespaces []string) *Client {
+	if len(namespaces) == 0 {
+		namespaces = []string{username}
+	}
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Username:   username,
+		Password:   password,
+		Namespaces: namespaces,
+		Client:     &http.Client{Timeout: 30 * time.Second},
+		tags:       make(map[string][]tag),
+	}
+}
+
+// printf выводит сообщение
+func (c *Client) printf(format string, args ...interface{}) {
+	out := c.Log
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, format, args...)
+}
+
+// login получает JWT по имени пользователя и паролю
+func (c *Client) login(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	token := c.token
+	c.mu.Unlock()
+	if token != "" {
+		return token, nil
+	}
+
+	body, _ := json.Marshal(map[string]string{"username": c.Username, "password": c.Password})
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/v2/users/login", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ошибка входа в Docker Hub: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("получен статус %d при входе в Docker Hub", resp.StatusCode)
+	}
+
+	var result struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("ошибка декодирования ответа входа в Docker Hub: %v", err)
+	}
+
+	c.mu.Lock()
+	c.token = result.Token
+	c.mu.Unlock()
+	return result.Token, nil
+}
+
+// waitRateLimit ожидает сброса ограничения частоты, о котором сообщил предыдущий ответ
+func (c *Client) waitRateLimit(ctx context.Context) error {
+	c.mu.Lock()
+	delay := time.Until(c.resetAt)
+	c.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	c.printf("  Достигнуто ограничение частоты запросов Docker Hub, ожидание %s\n", delay.Round(time.Second))
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rememberRateLimit запоминает момент сброса ограничения частоты по заголовкам ответа
+func (c *Client) rememberRateLimit(resp *http.Response) {
+	var resetAt time.Time
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			resetAt = time.Now().Add(time.Duration(seconds) * time.Second)
+		} else {
+			resetAt = time.Now().Add(time.Minute)
+		}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			resetAt = time.Unix(reset, 0)
+		}
+	}
+	if resetAt.IsZero() {
+		return
+	}
+
+	c.mu.Lock()
+	if resetAt.After(c.resetAt) {
+		c.resetAt = resetAt
+	}
+	c.mu.Unlock()
+}
+
+// do выполняет запрос к Hub API с JWT. Запросы, отклоненные ограничением частоты,
+// повторяются после его сброса, а при истечении JWT выполняется повторный вход
+func (c *Client) do(ctx context.Context, method, target string) (*http.Response, error) {
+	if !strings.HasPrefix(target, "http") {
+		target = c.BaseURL + target
+	}
+
+	relogged := false
+	for attempt := 0; ; attempt++ {
+		if err := c.waitRateLimit(ctx); err != nil {
+			return nil, err
+		}
+		token, err := c.login(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		c.rememberRateLimit(resp)
+
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests && attempt < maxRateLimitRetries:
+			resp.Body.Close()
+		case resp.StatusCode == http.StatusUnauthorized && !relogged:
+			resp.Body.Close()
+			relogged = true
+			c.mu.Lock()
+			c.token = ""
+			c.mu.Unlock()
+		default:
+			return resp, nil
+		}
+	}
+}
+
+// getPages получает все страницы списка по адресу path, следуя полю next
+func getPages[T any](ctx context.Context, c *Client, path string) ([]T, error) {
+	var items []T
+	for next := path + "?page_size=" + fmt.Sprint(pageSize); next != ""; {
+		resp, err := c.do(ctx, "GET", next)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Next    string `json:"next"`
+			Results []T    `json:"results"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("получен статус %d при запросе %s", resp.StatusCode, path)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка декодирования ответа %s: %v", path, err)
+		}
+
+		items = append(items, page.Results...)
+		next = page.Next
+	}
+	return items, nil
+}
+
+// repositoryPath возвращает путь репозитория в Hub API
+func repositoryPath(name string) string {
+	return "/v2/repositories/" + name
+}
+
+// ListRepositories получает репозитории всех пространств имен
+func (c *Client) ListRepositories(ctx context.Context) ([]string, error) {
+	var names []string
+	for _, namespace := range c.Namespaces {
+		repositories, err := getPages[struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		}](ctx, c, "/v2/repositories/"+url.PathEscape(namespace)+"/")
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при получении репозиториев %s: %v", namespace, err)
+		}
+		for _, r := range repositories {
+			names = append(names, r.Namespace+"/"+r.Name)
+		}
+	}
+	return names, nil
+}
+
+// ListTags получает теги репозитория. Они запоминаются до следующего вызова
+// ListTags для этого репозитория
+func (c *Client) ListTags(ctx context.Context, name string) ([]string, error) {
+	tags, err := getPages[tag](ctx, c, repositoryPath(name)+"/tags")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении тегов для %s: %v", name, err)
+	}
+
+	c.mu.Lock()
+	c.tags[name] = tags
+	c.mu.Unlock()
+
+	var names []string
+	for _, t := range tags {
+		names = append(names, t.Name)
+	}
+	return names, nil
+}
+
+// findTag возвращает тег репозитория
+func (c *Client) findTag(ctx context.Context, name, tagName string) (tag, error) {
+	c.mu.Lock()
+	tags, ok := c.tags[name]
+	c.mu.Unlock()
+
+	if !ok {
+		if _, err := c.ListTags(ctx, name); err != nil {
+			return tag{}, err
+		}
+		c.mu.Lock()
+		tags = c.tags[name]
+		c.mu.Unlock()
+	}
+
+	for _, t := range tags {
+		if t.Name == tagName {
+			return t, nil
+		}
+	}
+	return tag{}, fmt.Errorf("тег %s:%s: %w", name, tagName, registry.ErrNotFound)
+}
+
+// ResolveDigest возвращает digest манифеста тега
+func (c *Client) ResolveDigest(ctx context.Context, name, tagName string) (string, error) {
+	t, err := c.findTag(ctx, name, tagName)
+	if err != nil {
+		return "", err
+	}
+	// У тегов, загруженных до перехода Hub на digest, его нет: удаление по пустому digest
+	// затронуло бы все такие теги репозитория
+	if t.Digest == "" {
+		return "", fmt.Errorf("Docker Hub не сообщил digest тега %s:%s", name, tagName)
+	}
+	return t.Digest, nil
+}
+
+// GetImageMeta возвращает время последней загрузки тега в Hub в качестве времени создания и размер образа
+func (c *Client) GetImageMeta(ctx context.Context, name, tagName string) (registry.ImageMeta, error) {
+	t, err := c.findTag(ctx, name, tagName)
+	if err != nil {
+		return registry.ImageMeta{}, err
+	}
+
+	created := t.TagLastPushed
+	if created.IsZero() {
+		created = t.LastUpdated
+	}
+	return registry.ImageMeta{Created: created, Size: t.FullSize}, nil
+}
+
+// LastPulled возвращает время последнего скачивания тега
+func (c *Client) LastPulled(ctx context.Context, name, tagName string) (time.Time, error) {
+	t, err := c.findTag(ctx, name, tagName)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.TagLastPulled, nil
+}
+
+// Delete удаляет все теги репозитория, указывающие на digest
+func (c *Client) Delete(ctx context.Context, name, digest string) error {
+	if digest == "" {
+		return fmt.Errorf("удаление %s без digest запрещено", name)
+	}
+	c.mu.Lock()
+	var tags []string
+	for _, t := range c.tags[name] {
+		if t.Digest == digest {
+			tags = append(tags, t.Name)
+		}
+	}
+	c.mu.Unlock()
+
+	if len(tags) == 0 {
+		return fmt.Errorf("не найдено тегов %s с digest %s", name, digest)
+	}
+
+	for _, t := range tags {
+		resp, err := c.do(ctx, "DELETE", repositoryPath(name)+"/tags/"+url.PathEscape(t)+"/")
+		if err != nil {
+			return fmt.Errorf("ошибка выполнения DELETE запроса: %v", err)
+		}
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		case http.StatusForbidden:
+			return fmt.Errorf("нет прав на удаление %s:%s (токену нужен доступ Read, Write, Delete)", name, t)
+		case http.StatusTooManyRequests:
+			return fmt.Errorf("ограничение частоты запросов Docker Hub не сбросилось при удалении %s:%s", name, t)
+		default:
+			return fmt.Errorf("получен статус %d при удалении %s:%s", resp.StatusCode, name, t)
+		}
+	}
+	return nil
+}
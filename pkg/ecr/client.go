@@ -0,0 +1,220 @@
+// Package ecr реализует registry.Backend поверх API Amazon ECR. ECR не поддерживает
+// _catalog и удаление манифестов Registry API, поэтому репозитории и образы получаются
+// через DescribeRepositories и DescribeImages, а удаляются через BatchDeleteImage
+package ecr
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+
+	"registryCleaner/pkg/registry"
+)
+
+// batchSize максимальное количество образов в одном запросе BatchDeleteImage
+const batchSize = 100
+
+// API методы ECR, которые использует Client
+type API interface {
+	ecr.DescribeRepositoriesAPIClient
+	ecr.DescribeImagesAPIClient
+	BatchDeleteImage(ctx context.Context, params *ecr.BatchDeleteImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchDeleteImageOutput, error)
+}
+
+// Client backend Amazon ECR
+type Client struct {
+	API API
+	// RegistryID идентификатор аккаунта AWS, пустая строка - аккаунт из учетных данных
+	RegistryID string
+
+	mu     sync.Mutex
+	images map[string][]types.ImageDetail
+}
+
+var (
+	_ registry.Backend          = (*Client)(nil)
+	_ registry.PullTimeProvider = (*Client)(nil)
+	_ registry.BulkDeleter      = (*Client)(nil)
+)
+
+// registryHost адрес приватного registry ECR: <аккаунт>.dkr.ecr.<регион>.amazonaws.com
+var registryHost = regexp.MustCompile(`^(\d{12})\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com(\.cn)?$`)
+
+// NewClient создает клиент ECR с учетными данными из стандартной цепочки AWS SDK
+// (переменные окружения, профили, роль задания или экземпляра). Если registryURL
+// является адресом приватного registry ECR, аккаунт и регион берутся из него
+func NewClient(ctx context.Context, registryURL string) (*Client, error) {
+	host := registryURL
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	host = strings.TrimSuffix(host, "/")
+
+	var registryID string
+	var options []func(*config.LoadOptions) error
+	if m := registryHost.FindStringSubmatch(host); m != nil {
+		registryID = m[1]
+		options = append(options, config.WithRegion(m[2]))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки конфигурации AWS: %v", err)
+	}
+
+	return &Client{API: ecr.NewFromConfig(cfg), RegistryID: registryID, images: make(map[string][]types.ImageDetail)}, nil
+}
+
+// registryID возвращает идентификатор аккаунта для запросов
+func (c *Client) registryID() *string {
+	if c.RegistryID == "" {
+		return nil
+	}
+	return aws.String(c.RegistryID)
+}
+
+// ListRepositories получает список репозиториев ECR
+func (c *Client) ListRepositories(ctx context.Context) ([]string, error) {
+	var names []string
+	pages := ecr.NewDescribeRepositoriesPaginator(c.API, &ecr.DescribeRepositoriesInput{RegistryId: c.registryID()})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при получении списка репозиториев: %v", err)
+		}
+		for _, repo := range page.Repositories {
+			names = append(names, aws.ToString(repo.RepositoryName))
+		}
+	}
+	return names, nil
+}
+
+// ListTags получает образы репозитория и возвращает их теги. Образы запоминаются
+// до следующего вызова ListTags для этого репозитория
+func (c *Client) ListTags(ctx context.Context, repository string) ([]string, error) {
+	var images []types.ImageDetail
+	pages := ecr.NewDescribeImagesPaginator(c.API, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(repository),
+		RegistryId:     c.registryID(),
+		Filter:         &types.DescribeImagesFilter{TagStatus: types.TagStatusTagged},
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при получении образов %s: %v", repository, err)
+		}
+		images = append(images, page.ImageDetails...)
+	}
+
+	c.mu.Lock()
+	c.images[repository] = images
+	c.mu.Unlock()
+
+	var tags []string
+	for _, img := range images {
+		tags = append(tags, img.ImageTags...)
+	}
+	return tags, nil
+}
+
+// findImage возвращает образ, помеченный тегом
+func (c *Client) findImage(ctx context.Context, repository, tag string) (types.ImageDetail, error) {
+	c.mu.Lock()
+	images, ok := c.images[repository]
+	c.mu.Unlock()
+
+	if !ok {
+		if _, err := c.ListTags(ctx, repository); err != nil {
+			return types.ImageDetail{}, err
+		}
+		c.mu.Lock()
+		images = c.images[repository]
+		c.mu.Unlock()
+	}
+
+	for _, img := range images {
+		for _, t := range img.ImageTags {
+			if t == tag {
+				return img, nil
+			}
+		}
+	}
+	return types.ImageDetail{}, fmt.Errorf("тег %s:%s: %w", repository, tag, registry.ErrNotFound)
+}
+
+// ResolveDigest возвращает digest образа, помеченного тегом
+func (c *Client) ResolveDigest(ctx context.Context, repository, tag string) (string, error) {
+	img, err := c.findImage(ctx, repository, tag)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(img.ImageDigest), nil
+}
+
+// GetImageMeta возвращает время загрузки образа в ECR в качестве времени создания и его размер
+func (c *Client) GetImageMeta(ctx context.Context, repository, tag string) (registry.ImageMeta, error) {
+	img, err := c.findImage(ctx, repository, tag)
+	if err != nil {
+		return registry.ImageMeta{}, err
+	}
+	return registry.ImageMeta{Created: aws.ToTime(img.ImagePushedAt), Size: aws.ToInt64(img.ImageSizeInBytes)}, nil
+}
+
+// LastPulled возвращает время последнего скачивания, зарегистрированное ECR
+func (c *Client) LastPulled(ctx context.Context, repository, tag string) (time.Time, error) {
+	img, err := c.findImage(ctx, repository, tag)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return aws.ToTime(img.LastRecordedPullTime), nil
+}
+
+// Delete удаляет образ по digest вместе со всеми его тегами
+func (c *Client) Delete(ctx context.Context, repository, digest string) error {
+	return c.DeleteImages(ctx, repository, []registry.ImageInfo{{Repository: repository, Digest: digest}})
+}
+
+// DeleteImages удаляет образы по digest запросами BatchDeleteImage до batchSize образов
+func (c *Client) DeleteImages(ctx context.Context, repository string, images []registry.ImageInfo) error {
+	var ids []types.ImageIdentifier
+	seen := make(map[string]bool)
+	for _, img := range images {
+		if !seen[img.Digest] {
+			seen[img.Digest] = true
+			ids = append(ids, types.ImageIdentifier{ImageDigest: aws.String(img.Digest)})
+		}
+	}
+
+	for start := 0; start < len(ids); start += batchSize {
+		end := min(start+batchSize, len(ids))
+		out, err := c.API.BatchDeleteImage(ctx, &ecr.BatchDeleteImageInput{
+			RepositoryName: aws.String(repository),
+			RegistryId:     c.registryID(),
+			ImageIds:       ids[start:end],
+		})
+		if err != nil {
+			return fmt.Errorf("ошибка удаления образов %s: %v", repository, err)
+		}
+		for _, failure := range out.Failures {
+			// Уже удаленный образ не считается ошибкой
+			if failure.FailureCode == types.ImageFailureCodeImageNotFound {
+				continue
+			}
+			var digest string
+			if failure.ImageId != nil {
+				digest = aws.ToString(failure.ImageId.ImageDigest)
+			}
+			return fmt.Errorf("не удалось удалить %s@%s: %s: %s", repository, digest,
+				failure.FailureCode, aws.ToString(failure.FailureReason))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package gc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// azureAPIVersion версия REST API Blob Storage
+const azureAPIVersion = "2021-08-06"
+
+// AzureStorage хранилище registry с драйвером azure. Запросы подписываются ключом
+// учетной записи хранения (Shared Key)
+type AzureStorage struct {
+	// BaseURL адрес контейнера, например https://<учетная запись>.blob.core.windows.net/<контейнер>
+	BaseURL   string
+	Account   string
+	Container string
+	// Root префикс имен blob (rootdirectory в конфигурации registry)
+	Root   string
+	Client *http.Client
+
+	key []byte
+}
+
+var _ Storage = (*AzureStorage)(nil)
+
+// NewAzureStorage создает хранилище для контейнера учетной записи account с ключом accountKey
+// в base64. Непустой endpoint задает адрес Blob-сервиса вместо
+// https://<учетная запись>.blob.core.windows.net, например эмулятора Azurite
+func NewAzureStorage(account, accountKey, container, root, endpoint string) (*AzureStorage, error) {
+	key, err := base64.StdEncoding.DecodeString(accountKey)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный ключ учетной записи хранения Azure: %v", err)
+	}
+	if endpoint == "" {
+		endpoint = "https://" + account + ".blob.core.windows.net"
+	}
+	return &AzureStorage{
+		BaseURL:   strings.TrimSuffix(endpoint, "/") + "/" + container,
+		Account:   account,
+		Container: container,
+		Root:      strings.Trim(root, "/"),
+		Client:    &http.Client{Timeout: 30 * time.Second},
+		key:       key,
+	}, nil
+}
+
+// name возвращает имя blob по пути относительно корня хранилища
+func (s *AzureStorage) name(path string) string {
+	if s.Root == "" {
+		return path
+	}
+	return s.Root + "/" + path
+}
+
+// do выполняет подписанный запрос к Blob-сервису
+func (s *AzureStorage) do(ctx context.Context, method, name string, query url.Values) (*http.Response, error) {
+	target := s.BaseURL
+	if name != "" {
+		target += "/" + (&url.URL{Path: name}).EscapedPath()
+	}
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	req.Header.Set("Authorization", "SharedKey "+s.Account+":"+s.sign(req))
+	return s.Client.Do(req)
+}
+
+// sign вычисляет подпись Shared Key запроса без тела
+func (s *AzureStorage) sign(req *http.Request) string {
+	var headers []string
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-ms-") {
+			headers = append(headers, lower+":"+strings.TrimSpace(req.Header.Get(name)))
+		}
+	}
+	sort.Strings(headers)
+
+	resource := "/" + s.Account + req.URL.EscapedPath()
+	query := req.URL.Query()
+	var params []string
+	for name := range query {
+		params = append(params, name)
+	}
+	sort.Strings(params)
+	for _, name := range params {
+		values := query[name]
+		sort.Strings(values)
+		resource += "\n" + strings.ToLower(name) + ":" + strings.Join(values, ",")
+	}
+
+	// VERB, 11 стандартных заголовков (пустые для запросов без тела), x-ms-* заголовки и ресурс
+	stringToSign := req.Method + strings.Repeat("\n", 12) + strings.Join(headers, "\n") + "\n" + resource
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// List перечисляет blob с префиксом prefix
+func (s *AzureStorage) List(ctx context.Context, prefix string, fn func(Object) error) error {
+	root := s.name("")
+	query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {s.name(prefix)}}
+	for {
+		resp, err := s.do(ctx, "GET", "", query)
+		if err != nil {
+			return err
+		}
+
+		var page struct {
+			Blobs []struct {
+				Name       string `xml:"Name"`
+				Properties struct {
+					LastModified  string `xml:"Last-Modified"`
+					ContentLength int64  `xml:"Content-Length"`
+				} `xml:"Properties"`
+			} `xml:"Blobs>Blob"`
+			NextMarker string `xml:"NextMarker"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("получен статус %d при получении blob контейнера %s", resp.StatusCode, s.Container)
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("ошибка декодирования списка blob: %v", err)
+		}
+
+		for _, blob := range page.Blobs {
+			modified, _ := time.Parse(http.TimeFormat, blob.Properties.LastModified)
+			err := fn(Object{Path: strings.TrimPrefix(blob.Name, root), Size: blob.Properties.ContentLength, Modified: modified})
+			if err != nil {
+				return err
+			}
+		}
+		if page.NextMarker == "" {
+			return nil
+		}
+		query.Set("marker", page.NextMarker)
+	}
+}
+
+// Read читает blob
+func (s *AzureStorage) Read(ctx context.Context, path string) ([]byte, error) {
+	resp, err := s.do(ctx, "GET", s.name(path), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%s: %w", path, fs.ErrNotExist)
+	default:
+		return nil, fmt.Errorf("получен статус %d при чтении %s", resp.StatusCode, path)
+	}
+}
+
+// Delete удаляет все blob с префиксом prefix
+func (s *AzureStorage) Delete(ctx context.Context, prefix string) error {
+	var names []string
+	err := s.List(ctx, prefix, func(obj Object) error {
+		names = append(names, s.name(obj.Path))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		resp, err := s.do(ctx, "DELETE", name, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNotFound {
+			return fmt.Errorf("получен статус %d при удалении %s", resp.StatusCode, name)
+		}
+	}
+	return nil
+}
+
+// String возвращает адрес контейнера
+func (s *AzureStorage) String() string {
+	return s.BaseURL + "/" + s.Root
+}
@@ -0,0 +1,165 @@
+package gc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultDockerHost адрес Docker Engine API по умолчанию
+const DefaultDockerHost = "unix:///var/run/docker.sock"
+
+// DockerRunner выполняет команду garbage collection в контейнере registry через Docker Engine API.
+// Команда разбивается на аргументы по пробелам и выполняется без оболочки
+type DockerRunner struct {
+	Container string
+
+	baseURL string
+	client  *http.Client
+}
+
+// NewDockerRunner создает runner для Docker Engine по адресу host: unix:///path/docker.sock
+// или tcp://host:port
+func NewDockerRunner(host, container string) (*DockerRunner, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный адрес Docker %q: %v", host, err)
+	}
+
+	r := &DockerRunner{Container: container, client: &http.Client{}}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		r.baseURL = "http://docker"
+		r.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+	case "tcp", "http":
+		r.baseURL = "http://" + u.Host
+	default:
+		return nil, fmt.Errorf("неподдерживаемый адрес Docker %q, допустимо unix:// или tcp://", host)
+	}
+	return r, nil
+}
+
+// post выполняет POST запрос к Docker Engine API с JSON телом
+func (r *DockerRunner) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", r.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return r.client.Do(req)
+}
+
+// Run создает exec-сессию в контейнере, дожидается завершения команды и проверяет код выхода
+func (r *DockerRunner) Run(ctx context.Context, command string) (string, error) {
+	resp, err := r.post(ctx, "/containers/"+url.PathEscape(r.Container)+"/exec", map[string]interface{}{
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Cmd":          strings.Fields(command),
+	})
+	if err != nil {
+		return "", fmt.Errorf("ошибка подключения к Docker: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+	case http.StatusNotFound:
+		return "", fmt.Errorf("контейнер %s не найден", r.Container)
+	case http.StatusConflict:
+		return "", fmt.Errorf("контейнер %s не запущен", r.Container)
+	default:
+		return "", fmt.Errorf("получен статус %d при создании exec в контейнере %s", resp.StatusCode, r.Container)
+	}
+
+	var exec struct {
+		ID string `json:"Id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&exec); err != nil {
+		return "", fmt.Errorf("ошибка декодирования ответа Docker: %v", err)
+	}
+
+	start, err := r.post(ctx, "/exec/"+exec.ID+"/start", map[string]bool{"Detach": false, "Tty": false})
+	if err != nil {
+		return "", fmt.Errorf("ошибка запуска garbage collection: %v", err)
+	}
+	defer start.Body.Close()
+
+	if start.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("получен статус %d при запуске garbage collection", start.StatusCode)
+	}
+
+	output, err := demux(start.Body)
+	if err != nil {
+		return output, fmt.Errorf("ошибка чтения вывода garbage collection: %v", err)
+	}
+
+	code, err := r.exitCode(ctx, exec.ID)
+	if err != nil {
+		return output, err
+	}
+	if code != 0 {
+		return output, fmt.Errorf("garbage collection завершился с кодом %d", code)
+	}
+	return output, nil
+}
+
+// exitCode возвращает код выхода завершенной exec-сессии
+func (r *DockerRunner) exitCode(ctx context.Context, id string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", r.baseURL+"/exec/"+id+"/json", nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения результата garbage collection: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var inspect struct {
+		ExitCode int  `json:"ExitCode"`
+		Running  bool `json:"Running"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&inspect); err != nil {
+		return 0, fmt.Errorf("ошибка декодирования результата garbage collection: %v", err)
+	}
+	if inspect.Running {
+		return 0, fmt.Errorf("garbage collection еще выполняется")
+	}
+	return inspect.ExitCode, nil
+}
+
+// demux объединяет stdout и stderr из мультиплексированного потока Docker: каждый кадр
+// начинается с 8-байтового заголовка, в последних 4 байтах которого записан размер кадра
+func demux(r io.Reader) (string, error) {
+	var out strings.Builder
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return out.String(), nil
+			}
+			return out.String(), err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(&out, r, size); err != nil {
+			return out.String(), err
+		}
+	}
+}
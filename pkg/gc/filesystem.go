@@ -0,0 +1,61 @@
+package gc
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FilesystemStorage хранилище registry с драйвером filesystem
+type FilesystemStorage struct {
+	// Root корневой каталог хранилища (rootdirectory в конфигурации registry)
+	Root string
+}
+
+var _ Storage = (*FilesystemStorage)(nil)
+
+// List обходит файлы каталога prefix. Отсутствующий каталог считается пустым
+func (s *FilesystemStorage) List(ctx context.Context, prefix string, fn func(Object) error) error {
+	root := filepath.Join(s.Root, filepath.FromSlash(prefix))
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.Root, path)
+		if err != nil {
+			return err
+		}
+		return fn(Object{Path: filepath.ToSlash(rel), Size: info.Size(), Modified: info.ModTime()})
+	})
+}
+
+// Read читает файл хранилища
+func (s *FilesystemStorage) Read(_ context.Context, path string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.Root, filepath.FromSlash(path)))
+}
+
+// Delete удаляет каталог или файл prefix
+func (s *FilesystemStorage) Delete(_ context.Context, prefix string) error {
+	return os.RemoveAll(filepath.Join(s.Root, filepath.FromSlash(prefix)))
+}
+
+// String возвращает корневой каталог хранилища
+func (s *FilesystemStorage) String() string {
+	return s.Root
+}
@@ -0,0 +1,52 @@
+// Package gc запускает garbage collection Docker Registry после удаления манифестов,
+// чтобы освободить место, занятое blob, на которые больше нет ссылок
+package gc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// DefaultCommand команда garbage collection для официального образа registry
+const DefaultCommand = "registry garbage-collect /etc/docker/registry/config.yml"
+
+// Runner выполняет команду garbage collection и возвращает ее вывод
+type Runner interface {
+	Run(ctx context.Context, command string) (string, error)
+}
+
+// Info сведения о завершенной очистке, доступные в шаблоне команды
+type Info struct {
+	// RegistryURL адрес очищенного registry
+	RegistryURL string
+	// Deleted количество удаленных образов
+	Deleted int
+	// Repositories репозитории, в которых были удалены образы
+	Repositories []string
+}
+
+// Command шаблон команды garbage collection в синтаксисе text/template,
+// например "registry garbage-collect {{if gt .Deleted 100}}--delete-untagged {{end}}/etc/docker/registry/config.yml"
+type Command struct {
+	tmpl *template.Template
+}
+
+// ParseCommand разбирает шаблон команды
+func ParseCommand(text string) (*Command, error) {
+	tmpl, err := template.New("gc").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора шаблона команды garbage collection: %v", err)
+	}
+	return &Command{tmpl: tmpl}, nil
+}
+
+// Render подставляет сведения об очистке в шаблон
+func (c *Command) Render(info Info) (string, error) {
+	var out strings.Builder
+	if err := c.tmpl.Execute(&out, info); err != nil {
+		return "", fmt.Errorf("ошибка подстановки в шаблон команды garbage collection: %v", err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
@@ -0,0 +1,165 @@
+package gc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// DefaultGCSBaseURL адрес JSON API Google Cloud Storage
+const DefaultGCSBaseURL = "https://storage.googleapis.com"
+
+// gcsScope область доступа токенов ADC
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// GCSStorage хранилище registry с драйвером gcs
+type GCSStorage struct {
+	BaseURL string
+	Bucket  string
+	// Root префикс имен объектов (rootdirectory в конфигурации registry)
+	Root string
+	// Client HTTP клиент, добавляющий токен доступа к запросам
+	Client *http.Client
+}
+
+var _ Storage = (*GCSStorage)(nil)
+
+// NewGCSStorage создает хранилище с учетными данными Application Default Credentials.
+// Непустой endpoint задает адрес эмулятора, например fake-gcs-server, к которому запросы
+// выполняются без аутентификации
+func NewGCSStorage(ctx context.Context, bucket, root, endpoint string) (*GCSStorage, error) {
+	s := &GCSStorage{BaseURL: DefaultGCSBaseURL, Bucket: bucket, Root: strings.Trim(root, "/")}
+	if endpoint != "" {
+		s.BaseURL = strings.TrimSuffix(endpoint, "/")
+		s.Client = &http.Client{Timeout: 30 * time.Second}
+		return s, nil
+	}
+
+	tokens, err := google.DefaultTokenSource(ctx, gcsScope)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения учетных данных Google: %v", err)
+	}
+	s.Client = oauth2.NewClient(ctx, tokens)
+	s.Client.Timeout = 30 * time.Second
+	return s, nil
+}
+
+// name возвращает имя объекта по пути относительно корня хранилища
+func (s *GCSStorage) name(path string) string {
+	if s.Root == "" {
+		return path
+	}
+	return s.Root + "/" + path
+}
+
+// do выполняет запрос к API
+func (s *GCSStorage) do(ctx context.Context, method, target string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.BaseURL+target, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.Client.Do(req)
+}
+
+// objectPath путь объекта в JSON API
+func (s *GCSStorage) objectPath(name string) string {
+	return "/storage/v1/b/" + url.PathEscape(s.Bucket) + "/o/" + url.PathEscape(name)
+}
+
+// List перечисляет объекты с префиксом prefix
+func (s *GCSStorage) List(ctx context.Context, prefix string, fn func(Object) error) error {
+	root := s.name("")
+	query := url.Values{"prefix": {s.name(prefix)}, "fields": {"items(name,size,updated),nextPageToken"}}
+	for {
+		resp, err := s.do(ctx, "GET", "/storage/v1/b/"+url.PathEscape(s.Bucket)+"/o?"+query.Encode())
+		if err != nil {
+			return err
+		}
+
+		var page struct {
+			Items []struct {
+				Name    string    `json:"name"`
+				Size    string    `json:"size"`
+				Updated time.Time `json:"updated"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("получен статус %d при получении объектов бакета %s", resp.StatusCode, s.Bucket)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("ошибка декодирования списка объектов: %v", err)
+		}
+
+		for _, item := range page.Items {
+			size, _ := strconv.ParseInt(item.Size, 10, 64)
+			if err := fn(Object{Path: strings.TrimPrefix(item.Name, root), Size: size, Modified: item.Updated}); err != nil {
+				return err
+			}
+		}
+		if page.NextPageToken == "" {
+			return nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// Read читает объект
+func (s *GCSStorage) Read(ctx context.Context, path string) ([]byte, error) {
+	resp, err := s.do(ctx, "GET", s.objectPath(s.name(path))+"?alt=media")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%s: %w", path, fs.ErrNotExist)
+	default:
+		return nil, fmt.Errorf("получен статус %d при чтении %s", resp.StatusCode, path)
+	}
+}
+
+// Delete удаляет все объекты с префиксом prefix
+func (s *GCSStorage) Delete(ctx context.Context, prefix string) error {
+	var names []string
+	err := s.List(ctx, prefix, func(obj Object) error {
+		names = append(names, s.name(obj.Path))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		resp, err := s.do(ctx, "DELETE", s.objectPath(name))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+			return fmt.Errorf("получен статус %d при удалении %s", resp.StatusCode, name)
+		}
+	}
+	return nil
+}
+
+// String возвращает адрес хранилища в виде gs://bucket/root
+func (s *GCSStorage) String() string {
+	return "gs://" + s.Bucket + "/" + s.Root
+}
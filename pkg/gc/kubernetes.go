@@ -0,0 +1,139 @@
+package gc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/util/exec"
+)
+
+// KubernetesRunner выполняет команду garbage collection в поде registry через Kubernetes API.
+// Под выбирается по селектору меток, команда разбивается на аргументы по пробелам
+// и выполняется без оболочки
+type KubernetesRunner struct {
+	Namespace string
+	Selector  string
+	// Container контейнер пода, по умолчанию первый
+	Container string
+
+	config *rest.Config
+	client kubernetes.Interface
+}
+
+// NewKubernetesRunner создает runner с учетными данными из kubeconfig (по умолчанию KUBECONFIG
+// или ~/.kube/config) или, если он не найден, с учетными данными сервисного аккаунта пода.
+// Пустое пространство имен берется из текущего контекста
+func NewKubernetesRunner(kubeconfig, namespace, selector, container string) (*KubernetesRunner, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{})
+
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки конфигурации Kubernetes: %v", err)
+	}
+	if namespace == "" {
+		if namespace, _, err = clientConfig.Namespace(); err != nil {
+			return nil, fmt.Errorf("ошибка определения пространства имен Kubernetes: %v", err)
+		}
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания клиента Kubernetes: %v", err)
+	}
+	return &KubernetesRunner{
+		Namespace: namespace,
+		Selector:  selector,
+		Container: container,
+		config:    config,
+		client:    client,
+	}, nil
+}
+
+// findPod возвращает первый запущенный под, подходящий под селектор
+func (r *KubernetesRunner) findPod(ctx context.Context) (string, error) {
+	pods, err := r.client.CoreV1().Pods(r.Namespace).List(ctx, metav1.ListOptions{LabelSelector: r.Selector})
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения подов registry: %v", err)
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil {
+			return pod.Name, nil
+		}
+	}
+	return "", fmt.Errorf("в пространстве имен %s нет запущенных подов с метками %s", r.Namespace, r.Selector)
+}
+
+// Run выполняет команду в поде registry и проверяет код выхода. Если API-сервер
+// не поддерживает WebSocket, используется SPDY
+func (r *KubernetesRunner) Run(ctx context.Context, command string) (string, error) {
+	pod, err := r.findPod(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	req := r.client.CoreV1().RESTClient().Post().
+		Resource("pods").Namespace(r.Namespace).Name(pod).SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: r.Container,
+			Command:   strings.Fields(command),
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	websocket, err := remotecommand.NewWebSocketExecutor(r.config, "GET", req.URL().String())
+	if err != nil {
+		return "", fmt.Errorf("ошибка подключения к поду %s: %v", pod, err)
+	}
+	spdy, err := remotecommand.NewSPDYExecutor(r.config, "POST", req.URL())
+	if err != nil {
+		return "", fmt.Errorf("ошибка подключения к поду %s: %v", pod, err)
+	}
+	executor, err := remotecommand.NewFallbackExecutor(websocket, spdy, func(err error) bool {
+		return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+	})
+	if err != nil {
+		return "", fmt.Errorf("ошибка подключения к поду %s: %v", pod, err)
+	}
+
+	var out lockedBuilder
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: &out, Stderr: &out})
+	var exit exec.CodeExitError
+	if errors.As(err, &exit) {
+		return out.String(), fmt.Errorf("garbage collection в поде %s завершился с кодом %d", pod, exit.ExitStatus())
+	}
+	if err != nil {
+		return out.String(), fmt.Errorf("ошибка выполнения команды в поде %s: %v", pod, err)
+	}
+	return out.String(), nil
+}
+
+// lockedBuilder объединяет stdout и stderr, которые пишутся из разных горутин
+type lockedBuilder struct {
+	mu  sync.Mutex
+	out strings.Builder
+}
+
+func (b *lockedBuilder) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.out.Write(p)
+}
+
+func (b *lockedBuilder) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.out.String()
+}
@@ -0,0 +1,45 @@
+package gc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// ReadOnlyHook переводит registry в режим только для чтения на время garbage collection,
+// чтобы он не удалил blob образов, загружаемых в это время. API для этого у registry нет,
+// поэтому переключение выполняют внешние команды, например меняющие
+// REGISTRY_STORAGE_MAINTENANCE_READONLY и перезапускающие registry
+type ReadOnlyHook struct {
+	// Enable и Disable программы и их аргументы, включающие и выключающие режим
+	Enable  []string
+	Disable []string
+}
+
+// EnableReadOnly включает режим только для чтения и возвращает вывод команды
+func (h *ReadOnlyHook) EnableReadOnly(ctx context.Context) (string, error) {
+	return runHook(ctx, h.Enable)
+}
+
+// DisableReadOnly возвращает registry в обычный режим и возвращает вывод команды
+func (h *ReadOnlyHook) DisableReadOnly(ctx context.Context) (string, error) {
+	return runHook(ctx, h.Disable)
+}
+
+// runHook выполняет команду переключения режима, объединяя stdout и stderr
+func runHook(ctx context.Context, command []string) (string, error) {
+	if len(command) == 0 {
+		return "", fmt.Errorf("не задана команда переключения режима registry")
+	}
+
+	output, err := exec.CommandContext(ctx, command[0], command[1:]...).CombinedOutput()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return string(output), fmt.Errorf("%s завершился с кодом %d", command[0], exit.ExitCode())
+	}
+	if err != nil {
+		return string(output), fmt.Errorf("ошибка запуска %s: %v", command[0], err)
+	}
+	return string(output), nil
+}
@@ -0,0 +1,140 @@
+package gc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// s3DeleteBatch максимальное количество объектов в одном запросе DeleteObjects
+const s3DeleteBatch = 1000
+
+// S3API методы S3, которые использует S3Storage
+type S3API interface {
+	s3.ListObjectsV2APIClient
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+}
+
+// S3Storage хранилище registry с драйвером s3
+type S3Storage struct {
+	API    S3API
+	Bucket string
+	// Root префикс ключей (rootdirectory в конфигурации registry)
+	Root string
+}
+
+var _ Storage = (*S3Storage)(nil)
+
+// NewS3Storage создает хранилище с учетными данными из стандартной цепочки AWS SDK.
+// Непустой endpoint задает S3-совместимое хранилище, например MinIO, с адресацией path-style
+func NewS3Storage(ctx context.Context, bucket, root, endpoint string) (*S3Storage, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки конфигурации AWS: %v", err)
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return &S3Storage{API: client, Bucket: bucket, Root: strings.Trim(root, "/")}, nil
+}
+
+// key возвращает ключ объекта по пути относительно корня хранилища
+func (s *S3Storage) key(path string) string {
+	if s.Root == "" {
+		return path
+	}
+	return s.Root + "/" + path
+}
+
+// List перечисляет объекты с префиксом prefix
+func (s *S3Storage) List(ctx context.Context, prefix string, fn func(Object) error) error {
+	root := s.key("")
+	paginator := s3.NewListObjectsV2Paginator(s.API, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(s.key(prefix)),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, obj := range page.Contents {
+			err := fn(Object{
+				Path:     strings.TrimPrefix(aws.ToString(obj.Key), root),
+				Size:     aws.ToInt64(obj.Size),
+				Modified: aws.ToTime(obj.LastModified),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Read читает объект
+func (s *S3Storage) Read(ctx context.Context, path string) ([]byte, error) {
+	out, err := s.API.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.key(path)),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, fmt.Errorf("%s: %w", path, fs.ErrNotExist)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
+
+// Delete удаляет все объекты с префиксом prefix
+func (s *S3Storage) Delete(ctx context.Context, prefix string) error {
+	var keys []types.ObjectIdentifier
+	paginator := s3.NewListObjectsV2Paginator(s.API, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(s.key(prefix)),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, types.ObjectIdentifier{Key: obj.Key})
+		}
+	}
+
+	for start := 0; start < len(keys); start += s3DeleteBatch {
+		end := min(start+s3DeleteBatch, len(keys))
+		out, err := s.API.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.Bucket),
+			Delete: &types.Delete{Objects: keys[start:end], Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		if len(out.Errors) > 0 {
+			return fmt.Errorf("не удалось удалить %s: %s", aws.ToString(out.Errors[0].Key), aws.ToString(out.Errors[0].Message))
+		}
+	}
+	return nil
+}
+
+// String возвращает адрес хранилища в виде s3://bucket/root
+func (s *S3Storage) String() string {
+	return "s3://" + s.Bucket + "/" + s.Root
+}
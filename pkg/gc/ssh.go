@@ -0,0 +1,147 @@
+package gc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHRunner выполняет команду garbage collection на сервере registry по SSH.
+// Команда передается оболочке сервера как есть
+type SSHRunner struct {
+	Address string
+	Config  *ssh.ClientConfig
+}
+
+// defaultKeys закрытые ключи, которые используются, если ключ не указан явно
+var defaultKeys = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// NewSSHRunner создает runner для сервера target вида [user@]host[:port]. Аутентификация
+// выполняется через ssh-agent и ключ keyFile (по умолчанию ключи из ~/.ssh), ключ
+// сервера проверяется по knownHostsFile (по умолчанию ~/.ssh/known_hosts)
+func NewSSHRunner(target, keyFile, knownHostsFile string) (*SSHRunner, error) {
+	username, address, ok := strings.Cut(target, "@")
+	if !ok {
+		address = target
+		current, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("не удалось определить имя пользователя SSH: %v", err)
+		}
+		username = current.Username
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "22")
+	}
+
+	home, _ := os.UserHomeDir()
+	if knownHostsFile == "" {
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeys, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения %s: %v", knownHostsFile, err)
+	}
+
+	var auth []ssh.AuthMethod
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	if keyFile != "" {
+		signer, err := loadKey(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	} else {
+		var signers []ssh.Signer
+		for _, name := range defaultKeys {
+			// Отсутствующие и защищенные паролем ключи по умолчанию пропускаются
+			if signer, err := loadKey(filepath.Join(home, ".ssh", name)); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+		if len(signers) > 0 {
+			auth = append(auth, ssh.PublicKeys(signers...))
+		}
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("нет ключей для SSH: запустите ssh-agent или укажите --gc-ssh-key")
+	}
+
+	return &SSHRunner{
+		Address: address,
+		Config: &ssh.ClientConfig{
+			User:            username,
+			Auth:            auth,
+			HostKeyCallback: hostKeys,
+			Timeout:         30 * time.Second,
+		},
+	}, nil
+}
+
+// loadKey читает закрытый ключ без пароля
+func loadKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ключа SSH: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	var passphrase *ssh.PassphraseMissingError
+	if errors.As(err, &passphrase) {
+		return nil, fmt.Errorf("ключ %s защищен паролем, добавьте его в ssh-agent", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора ключа SSH %s: %v", path, err)
+	}
+	return signer, nil
+}
+
+// Run подключается к серверу и выполняет команду, объединяя stdout и stderr
+func (r *SSHRunner) Run(ctx context.Context, command string) (string, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", r.Address)
+	if err != nil {
+		return "", fmt.Errorf("ошибка подключения к %s: %v", r.Address, err)
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, r.Address, r.Config)
+	if err != nil {
+		conn.Close()
+		return "", fmt.Errorf("ошибка SSH-подключения к %s: %v", r.Address, err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	// Отмена контекста прерывает выполнение команды
+	stop := context.AfterFunc(ctx, func() { client.Close() })
+	defer stop()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания SSH-сессии: %v", err)
+	}
+	defer session.Close()
+
+	output, err := session.CombinedOutput(command)
+	var exit *ssh.ExitError
+	if errors.As(err, &exit) {
+		return string(output), fmt.Errorf("garbage collection завершился с кодом %d", exit.ExitStatus())
+	}
+	if err != nil {
+		return string(output), fmt.Errorf("ошибка выполнения команды по SSH: %v", err)
+	}
+	return string(output), nil
+}
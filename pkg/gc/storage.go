@@ -0,0 +1,305 @@
+package gc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultGracePeriod возраст, младше которого blob без ссылок не удаляются: они могут
+// принадлежать образу, который загружается прямо сейчас
+const DefaultGracePeriod = time.Hour
+
+// DefaultUploadMaxAge возраст незавершенной загрузки, после которого она считается
+// брошенной, как в uploadpurging registry
+const DefaultUploadMaxAge = 7 * 24 * time.Hour
+
+// v2Root каталог данных registry внутри корня хранилища
+const v2Root = "docker/registry/v2/"
+
+// Object файл или объект хранилища
+type Object struct {
+	// Path путь относительно корня хранилища с разделителем "/"
+	Path     string
+	Size     int64
+	Modified time.Time
+}
+
+// Storage хранилище registry: локальный каталог или бакет объектного хранилища
+type Storage interface {
+	// List вызывает fn для каждого объекта, путь которого начинается с prefix
+	List(ctx context.Context, prefix string, fn func(Object) error) error
+	// Read читает объект, для отсутствующего объекта возвращает ошибку fs.ErrNotExist
+	Read(ctx context.Context, path string) ([]byte, error)
+	// Delete удаляет все объекты, путь которых начинается с prefix
+	Delete(ctx context.Context, prefix string) error
+	// String описывает расположение хранилища
+	String() string
+}
+
+// StorageCollector удаляет прямо в хранилище registry blob, на которые не ссылается ни один
+// манифест, и брошенные загрузки. Как и registry garbage-collect, он небезопасен при
+// одновременной загрузке образов, поэтому свежие blob пропускаются
+type StorageCollector struct {
+	Storage      Storage
+	GracePeriod  time.Duration
+	UploadMaxAge time.Duration
+	// DryRun только выводит то, что было бы удалено
+	DryRun bool
+	// Log получает список удаляемых blob и загрузок, по умолчанию os.Stdout
+	Log io.Writer
+}
+
+// StorageResult итоги сборки blob
+type StorageResult struct {
+	// Manifests количество манифестов, на которые есть ссылки в репозиториях
+	Manifests int
+	// Removed количество удаленных blob и их суммарный размер
+	Removed int
+	Bytes   int64
+	// Recent количество blob без ссылок, пропущенных как слишком свежие
+	Recent int
+	// Uploads количество удаленных брошенных загрузок и их суммарный размер
+	Uploads     int
+	UploadBytes int64
+}
+
+// manifestReferences ссылки манифеста любого формата: образа, списка манифестов или schema1
+type manifestReferences struct {
+	Config *struct {
+		Digest string `json:"digest"`
+	} `json:"config"`
+	Layers []struct {
+		Digest string `json:"digest"`
+	} `json:"layers"`
+	Manifests []struct {
+		Digest string `json:"digest"`
+	} `json:"manifests"`
+	FSLayers []struct {
+		BlobSum string `json:"blobSum"`
+	} `json:"fsLayers"`
+}
+
+// upload незавершенная загрузка blob в репозиторий
+type upload struct {
+	dir       string
+	size      int64
+	modified  time.Time
+	startedAt string
+}
+
+// printf выводит сообщение
+func (c *StorageCollector) printf(format string, args ...interface{}) {
+	out := c.Log
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, format, args...)
+}
+
+// blobPath путь данных blob с указанным digest
+func blobPath(digest string) (string, error) {
+	algorithm, hex, ok := strings.Cut(digest, ":")
+	if !ok || len(hex) < 2 || strings.ContainsAny(algorithm+hex, `/\.`) {
+		return "", fmt.Errorf("некорректный digest %q", digest)
+	}
+	return v2Root + "blobs/" + algorithm + "/" + hex[:2] + "/" + hex + "/data", nil
+}
+
+// Collect помечает blob, на которые ссылаются манифесты всех репозиториев, удаляет брошенные
+// загрузки и непомеченные blob. Если хотя бы один манифест не удалось прочитать или манифестов
+// нет вовсе, ничего не удаляется
+func (c *StorageCollector) Collect(ctx context.Context) (StorageResult, error) {
+	var result StorageResult
+
+	marked, uploads, err := c.mark(ctx, &result)
+	if err != nil {
+		return result, err
+	}
+	// Без манифестов все blob оказались бы лишними: скорее всего, неверно указан корень хранилища
+	if result.Manifests == 0 {
+		return result, fmt.Errorf("в %s не найдено ни одного манифеста, проверьте корень хранилища", c.Storage)
+	}
+	if err := c.purgeUploads(ctx, uploads, &result); err != nil {
+		return result, err
+	}
+	return result, c.sweep(ctx, marked, &result)
+}
+
+// mark собирает digest всех манифестов из ревизий репозиториев и blob, на которые они ссылаются,
+// а также незавершенные загрузки
+func (c *StorageCollector) mark(ctx context.Context, result *StorageResult) (map[string]bool, []*upload, error) {
+	var revisions []string
+	uploads := make(map[string]*upload)
+	var order []*upload
+
+	// Служебные каталоги начинаются с "_", который запрещен в именах репозиториев
+	err := c.Storage.List(ctx, v2Root+"repositories/", func(obj Object) error {
+		if strings.Contains(obj.Path, "/_manifests/revisions/") && strings.HasSuffix(obj.Path, "/link") {
+			revisions = append(revisions, obj.Path)
+			return nil
+		}
+
+		i := strings.Index(obj.Path, "/_uploads/")
+		if i < 0 {
+			return nil
+		}
+		id, file, ok := strings.Cut(obj.Path[i+len("/_uploads/"):], "/")
+		if !ok {
+			return nil
+		}
+		dir := obj.Path[:i] + "/_uploads/" + id + "/"
+		u, ok := uploads[dir]
+		if !ok {
+			u = &upload{dir: dir}
+			uploads[dir] = u
+			order = append(order, u)
+		}
+		u.size += obj.Size
+		if obj.Modified.After(u.modified) {
+			u.modified = obj.Modified
+		}
+		if file == "startedat" {
+			u.startedAt = obj.Path
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка чтения репозиториев в %s: %v", c.Storage, err)
+	}
+
+	marked := make(map[string]bool)
+	for _, path := range revisions {
+		link, err := c.Storage.Read(ctx, path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ошибка чтения %s: %v", path, err)
+		}
+		digest := strings.TrimSpace(string(link))
+		if marked[digest] {
+			continue
+		}
+		result.Manifests++
+		if err := c.markManifest(ctx, digest, marked); err != nil {
+			return nil, nil, err
+		}
+	}
+	return marked, order, nil
+}
+
+// markManifest помечает манифест и blob, на которые он ссылается
+func (c *StorageCollector) markManifest(ctx context.Context, digest string, marked map[string]bool) error {
+	marked[digest] = true
+
+	path, err := blobPath(digest)
+	if err != nil {
+		return err
+	}
+	data, err := c.Storage.Read(ctx, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Ревизия без данных манифеста: удерживать нечего
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка чтения манифеста %s: %v", digest, err)
+	}
+
+	var refs manifestReferences
+	if err := json.Unmarshal(data, &refs); err != nil {
+		return fmt.Errorf("ошибка разбора манифеста %s: %v", digest, err)
+	}
+	if refs.Config != nil {
+		marked[refs.Config.Digest] = true
+	}
+	for _, layer := range refs.Layers {
+		marked[layer.Digest] = true
+	}
+	for _, layer := range refs.FSLayers {
+		marked[layer.BlobSum] = true
+	}
+	// Манифесты платформ списка обычно имеют собственные ревизии, но помечаем их и здесь
+	for _, child := range refs.Manifests {
+		if !marked[child.Digest] {
+			if err := c.markManifest(ctx, child.Digest, marked); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// purgeUploads удаляет загрузки, начатые раньше UploadMaxAge. Если время начала загрузки
+// не записано, используется время последнего изменения ее файлов
+func (c *StorageCollector) purgeUploads(ctx context.Context, uploads []*upload, result *StorageResult) error {
+	for _, u := range uploads {
+		started := u.modified
+		if u.startedAt != "" {
+			if data, err := c.Storage.Read(ctx, u.startedAt); err == nil {
+				if t, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data))); err == nil {
+					started = t
+				}
+			}
+		}
+		if time.Since(started) < c.UploadMaxAge {
+			continue
+		}
+
+		if c.DryRun {
+			c.printf("  Будет удалена загрузка %s (%d байт, начата %s)\n", u.dir, u.size, started.Local().Format("2006-01-02 15:04:05"))
+		} else {
+			if err := c.Storage.Delete(ctx, u.dir); err != nil {
+				return fmt.Errorf("ошибка удаления загрузки %s: %v", u.dir, err)
+			}
+			c.printf("  Удалена загрузка %s (%d байт)\n", u.dir, u.size)
+		}
+		result.Uploads++
+		result.UploadBytes += u.size
+	}
+	return nil
+}
+
+// sweep удаляет непомеченные blob старше GracePeriod
+func (c *StorageCollector) sweep(ctx context.Context, marked map[string]bool, result *StorageResult) error {
+	var orphans []Object
+	err := c.Storage.List(ctx, v2Root+"blobs/", func(obj Object) error {
+		// blobs/<алгоритм>/<первые два символа>/<hex>/data
+		parts := strings.Split(strings.TrimPrefix(obj.Path, v2Root+"blobs/"), "/")
+		if len(parts) != 4 || parts[3] != "data" || marked[parts[0]+":"+parts[2]] {
+			return nil
+		}
+		if time.Since(obj.Modified) < c.GracePeriod {
+			result.Recent++
+			return nil
+		}
+		orphans = append(orphans, obj)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка чтения blob в %s: %v", c.Storage, err)
+	}
+
+	for _, obj := range orphans {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		parts := strings.Split(strings.TrimPrefix(obj.Path, v2Root+"blobs/"), "/")
+		digest := parts[0] + ":" + parts[2]
+
+		if c.DryRun {
+			c.printf("  Будет удален blob %s (%d байт)\n", digest, obj.Size)
+		} else {
+			if err := c.Storage.Delete(ctx, strings.TrimSuffix(obj.Path, "data")); err != nil {
+				return fmt.Errorf("ошибка удаления blob %s: %v", digest, err)
+			}
+			c.printf("  Удален blob %s (%d байт)\n", digest, obj.Size)
+		}
+		result.Removed++
+		result.Bytes += obj.Size
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package gc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDigest возвращает digest с hex из повторенного символа
+func testDigest(c string) string {
+	return "sha256:" + strings.Repeat(c, 64)
+}
+
+// writeStorageFile создает файл хранилища с указанным временем изменения
+func writeStorageFile(t *testing.T, root, path, data string, modified time.Time) {
+	t.Helper()
+	full := filepath.Join(root, "docker", "registry", "v2", filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(full, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeBlob записывает данные blob
+func writeBlob(t *testing.T, root, digest, data string, modified time.Time) {
+	t.Helper()
+	hex := strings.TrimPrefix(digest, "sha256:")
+	writeStorageFile(t, root, "blobs/sha256/"+hex[:2]+"/"+hex+"/data", data, modified)
+}
+
+// writeRevision записывает ссылку на ревизию манифеста в репозитории
+func writeRevision(t *testing.T, root, repository, digest string) {
+	t.Helper()
+	hex := strings.TrimPrefix(digest, "sha256:")
+	writeStorageFile(t, root, "repositories/"+repository+"/_manifests/revisions/sha256/"+hex+"/link", digest, time.Now())
+}
+
+// manifestJSON манифест образа с указанными config и слоями
+func manifestJSON(config string, layers ...string) string {
+	var refs []string
+	for _, layer := range layers {
+		refs = append(refs, fmt.Sprintf(`{"digest":%q}`, layer))
+	}
+	return fmt.Sprintf(`{"schemaVersion":2,"config":{"digest":%q},"layers":[%s]}`, config, strings.Join(refs, ","))
+}
+
+// blobExists проверяет наличие данных blob
+func blobExists(root, digest string) bool {
+	hex := strings.TrimPrefix(digest, "sha256:")
+	_, err := os.Stat(filepath.Join(root, "docker", "registry", "v2", "blobs", "sha256", hex[:2], hex, "data"))
+	return err == nil
+}
+
+// newTestCollector сборщик для хранилища filesystem в каталоге root
+func newTestCollector(root string) *StorageCollector {
+	return &StorageCollector{
+		Storage:      &FilesystemStorage{Root: root},
+		GracePeriod:  DefaultGracePeriod,
+		UploadMaxAge: DefaultUploadMaxAge,
+		Log:          io.Discard,
+	}
+}
+
+func TestStorageCollectorCollect(t *testing.T) {
+	old := time.Now().Add(-2 * DefaultGracePeriod)
+	manifest, config, layer := testDigest("a"), testDigest("b"), testDigest("c")
+	orphan, fresh := testDigest("d"), testDigest("e")
+
+	root := t.TempDir()
+	writeBlob(t, root, manifest, manifestJSON(config, layer), old)
+	writeBlob(t, root, config, "{}", old)
+	writeBlob(t, root, layer, "layer", old)
+	writeBlob(t, root, orphan, "orphan", old)
+	writeBlob(t, root, fresh, "fresh", time.Now())
+	// Слой загружен в app, но манифест, который на него ссылается, есть только в ревизиях other
+	hex := strings.TrimPrefix(layer, "sha256:")
+	writeStorageFile(t, root, "repositories/app/_layers/sha256/"+hex+"/link", layer, old)
+	writeRevision(t, root, "other", manifest)
+
+	result, err := newTestCollector(root).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	for _, digest := range []string{manifest, config, layer, fresh} {
+		if !blobExists(root, digest) {
+			t.Errorf("blob %s удален", digest)
+		}
+	}
+	if blobExists(root, orphan) {
+		t.Errorf("blob без ссылок %s не удален", orphan)
+	}
+	if result.Manifests != 1 || result.Removed != 1 || result.Bytes != int64(len("orphan")) || result.Recent != 1 {
+		t.Errorf("итоги %+v, ожидался 1 манифест, 1 удаленный blob на %d байт и 1 свежий", result, len("orphan"))
+	}
+}
+
+func TestStorageCollectorNoManifests(t *testing.T) {
+	root := t.TempDir()
+	orphan := testDigest("d")
+	writeBlob(t, root, orphan, "orphan", time.Now().Add(-2*DefaultGracePeriod))
+
+	if _, err := newTestCollector(root).Collect(context.Background()); err == nil {
+		t.Error("ожидалась ошибка для хранилища без манифестов")
+	}
+	if !blobExists(root, orphan) {
+		t.Error("blob удален из хранилища без манифестов")
+	}
+}
+
+func TestStorageCollectorPurgeUploads(t *testing.T) {
+	stale := time.Now().Add(-2 * DefaultUploadMaxAge)
+	root := t.TempDir()
+	manifest := testDigest("a")
+	writeBlob(t, root, manifest, manifestJSON(testDigest("b")), time.Now())
+	writeRevision(t, root, "app", manifest)
+
+	uploads := "repositories/app/_uploads/"
+	// Время начала из startedat важнее времени изменения файлов
+	writeStorageFile(t, root, uploads+"stale/data", "stale", time.Now())
+	writeStorageFile(t, root, uploads+"stale/startedat", stale.Format(time.RFC3339), time.Now())
+	writeStorageFile(t, root, uploads+"active/data", "active", stale)
+	writeStorageFile(t, root, uploads+"active/startedat", time.Now().Format(time.RFC3339), stale)
+	// Без startedat возраст загрузки определяется по ее файлам
+	writeStorageFile(t, root, uploads+"unstarted/data", "unstarted", stale)
+
+	result, err := newTestCollector(root).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	for id, want := range map[string]bool{"stale": false, "active": true, "unstarted": false} {
+		_, err := os.Stat(filepath.Join(root, "docker", "registry", "v2", filepath.FromSlash(uploads+id)))
+		if exists := err == nil; exists != want {
+			t.Errorf("загрузка %s: существует %v, ожидалось %v", id, exists, want)
+		}
+	}
+	if result.Uploads != 2 {
+		t.Errorf("удалено загрузок %d, ожидалось 2", result.Uploads)
+	}
+}
@@ -0,0 +1,330 @@
+// Package gitlab реализует registry.Backend поверх API Container Registry GitLab:
+// время создания и размер берутся из сведений о тегах без скачивания конфигураций
+// образов, а теги удаляются одним запросом массового удаления
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// perPage количество элементов на странице ответов GitLab API
+const perPage = 100
+
+// Client клиент API Container Registry GitLab. Репозитории берутся из проекта Project
+// или из всех проектов группы Group (ID или полный путь)
+type Client struct {
+	BaseURL string
+	Project string
+	Group   string
+	// Token персональный токен или токен проекта, передается в заголовке PRIVATE-TOKEN
+	Token string
+	// JobToken токен задания CI (CI_JOB_TOKEN), используется, если Token не задан
+	JobToken string
+	Client   *http.Client
+
+	mu           sync.Mutex
+	repositories map[string]repository
+	tags         map[string]map[string]tagDetails
+}
+
+// repository репозиторий Container Registry
+type repository struct {
+	ID        int    `json:"id"`
+	Path      string `json:"path"`
+	ProjectID int    `json:"project_id"`
+}
+
+// tagDetails сведения о теге
+type tagDetails struct {
+	Name      string    `json:"name"`
+	Digest    string    `json:"digest"`
+	TotalSize int64     `json:"total_size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var (
+	_ registry.Backend     = (*Client)(nil)
+	_ registry.BulkDeleter = (*Client)(nil)
+)
+
+// NewClient создает клиент GitLab
+func NewClient(baseURL, project, group, token, jobToken string) *Client {
+	return &Client{
+		BaseURL:      strings.TrimSuffix(baseURL, "/"),
+		Project:      project,
+		Group:        group,
+		Token:        token,
+		JobToken:     jobToken,
+		Client:       &http.Client{Timeout: 30 * time.Second},
+		repositories: make(map[string]repository),
+		tags:         make(map[string]map[string]tagDetails),
+	}
+}
+
+// do выполняет запрос к GitLab API с аутентификацией
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	target := c.BaseURL + "/api/v4" + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("PRIVATE-TOKEN", c.Token)
+	} else if c.JobToken != "" {
+		req.Header.Set("JOB-TOKEN", c.JobToken)
+	}
+
+	return c.Client.Do(req)
+}
+
+// getPages получает все страницы списка по адресу path
+func getPages[T any](ctx context.Context, c *Client, path string, query url.Values) ([]T, error) {
+	var items []T
+	for page := "1"; page != ""; {
+		query.Set("page", page)
+		query.Set("per_page", fmt.Sprint(perPage))
+
+		resp, err := c.do(ctx, "GET", path, query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var batch []T
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("получен статус %d при запросе %s", resp.StatusCode, path)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&batch)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка декодирования ответа %s: %v", path, err)
+		}
+
+		items = append(items, batch...)
+		page = resp.Header.Get("X-Next-Page")
+	}
+	return items, nil
+}
+
+// ListRepositories получает репозитории Container Registry проекта или группы
+func (c *Client) ListRepositories(ctx context.Context) ([]string, error) {
+	path := "/projects/" + url.PathEscape(c.Project) + "/registry/repositories"
+	if c.Project == "" {
+		path = "/groups/" + url.PathEscape(c.Group) + "/registry/repositories"
+	}
+
+	repositories, err := getPages[repository](ctx, c, path, url.Values{})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении списка репозиториев: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var names []string
+	for _, r := range repositories {
+		c.repositories[r.Path] = r
+		names = append(names, r.Path)
+	}
+	return names, nil
+}
+
+// repository возвращает репозиторий по пути, полученному из ListRepositories
+func (c *Client) repository(name string) (repository, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.repositories[name]
+	if !ok {
+		return repository{}, fmt.Errorf("репозиторий %s не найден в GitLab: %w", name, registry.ErrNotFound)
+	}
+	return r, nil
+}
+
+// tagsPath возвращает путь API тегов репозитория
+func tagsPath(r repository) string {
+	return fmt.Sprintf("/projects/%d/registry/repositories/%d/tags", r.ProjectID, r.ID)
+}
+
+// ListTags получает список тегов репозитория
+func (c *Client) ListTags(ctx context.Context, name string) ([]string, error) {
+	r, err := c.repository(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := getPages[tagDetails](ctx, c, tagsPath(r), url.Values{})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении тегов для %s: %v", name, err)
+	}
+
+	c.mu.Lock()
+	c.tags[name] = make(map[string]tagDetails)
+	c.mu.Unlock()
+
+	var names []string
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names, nil
+}
+
+// details получает сведения о теге, запоминая их для Delete
+func (c *Client) details(ctx context.Context, name, tag string) (tagDetails, error) {
+	c.mu.Lock()
+	details, ok := c.tags[name][tag]
+	c.mu.Unlock()
+	if ok {
+		return details, nil
+	}
+
+	r, err := c.repository(name)
+	if err != nil {
+		return tagDetails{}, err
+	}
+
+	resp, err := c.do(ctx, "GET", tagsPath(r)+"/"+url.PathEscape(tag), nil, nil)
+	if err != nil {
+		return tagDetails{}, fmt.Errorf("ошибка при получении тега %s:%s: %v", name, tag, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return tagDetails{}, fmt.Errorf("получен статус %d при запросе тега %s:%s", resp.StatusCode, name, tag)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&details); err != nil {
+		return tagDetails{}, fmt.Errorf("ошибка декодирования тега %s:%s: %v", name, tag, err)
+	}
+
+	c.mu.Lock()
+	if c.tags[name] == nil {
+		c.tags[name] = make(map[string]tagDetails)
+	}
+	c.tags[name][tag] = details
+	c.mu.Unlock()
+
+	return details, nil
+}
+
+// ResolveDigest возвращает digest манифеста тега
+func (c *Client) ResolveDigest(ctx context.Context, name, tag string) (string, error) {
+	details, err := c.details(ctx, name, tag)
+	if err != nil {
+		return "", err
+	}
+	return details.Digest, nil
+}
+
+// GetImageMeta возвращает время создания и размер образа из сведений о теге
+func (c *Client) GetImageMeta(ctx context.Context, name, tag string) (registry.ImageMeta, error) {
+	details, err := c.details(ctx, name, tag)
+	if err != nil {
+		return registry.ImageMeta{}, err
+	}
+	return registry.ImageMeta{Created: details.CreatedAt, Size: details.TotalSize}, nil
+}
+
+// tagsByDigest возвращает известные теги репозитория, указывающие на digest
+func (c *Client) tagsByDigest(name, digest string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var tags []string
+	for tag, details := range c.tags[name] {
+		if details.Digest == digest {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Delete удаляет все теги репозитория, указывающие на digest
+func (c *Client) Delete(ctx context.Context, name, digest string) error {
+	r, err := c.repository(name)
+	if err != nil {
+		return err
+	}
+
+	tags := c.tagsByDigest(name, digest)
+	if len(tags) == 0 {
+		return fmt.Errorf("не найдено тегов %s с digest %s", name, digest)
+	}
+
+	for _, tag := range tags {
+		resp, err := c.do(ctx, "DELETE", tagsPath(r)+"/"+url.PathEscape(tag), nil, nil)
+		if err != nil {
+			return fmt.Errorf("ошибка выполнения DELETE запроса: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+			return fmt.Errorf("получен статус %d при удалении %s:%s", resp.StatusCode, name, tag)
+		}
+	}
+	return nil
+}
+
+// DeleteImages удаляет теги всех образов одним запросом массового удаления.
+// GitLab выполняет удаление асинхронно и ограничивает частоту таких запросов для репозитория
+func (c *Client) DeleteImages(ctx context.Context, name string, images []registry.ImageInfo) error {
+	r, err := c.repository(name)
+	if err != nil {
+		return err
+	}
+
+	var patterns []string
+	seen := make(map[string]bool)
+	for _, img := range images {
+		for _, tag := range c.tagsByDigest(name, img.Digest) {
+			if !seen[tag] {
+				seen[tag] = true
+				patterns = append(patterns, regexp.QuoteMeta(tag))
+			}
+		}
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	body := map[string]string{"name_regex_delete": "^(?:" + strings.Join(patterns, "|") + ")$"}
+	resp, err := c.do(ctx, "DELETE", tagsPath(r), nil, body)
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения массового удаления: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted:
+		return nil
+	case http.StatusTooManyRequests:
+		return fmt.Errorf("GitLab ограничивает частоту массового удаления для %s, повторите позже", name)
+	default:
+		return fmt.Errorf("получен статус %d при массовом удалении тегов %s", resp.StatusCode, name)
+	}
+}
@@ -0,0 +1,332 @@
+// Package harbor реализует registry.Backend поверх Harbor v2 API: время создания, метки
+// и неизменяемость тегов берутся из API артефактов, а удаление выполняется через Harbor,
+// чтобы учет квот и репликация оставались согласованными
+package harbor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// pageSize количество элементов на странице ответов Harbor API
+const pageSize = 100
+
+// LabelPrefix префикс, с которым метки Harbor добавляются к меткам образа
+const LabelPrefix = "harbor/"
+
+// Client клиент Harbor v2 API
+type Client struct {
+	BaseURL  string
+	Username string
+	Password string
+	Client   *http.Client
+
+	mu        sync.Mutex
+	artifacts map[string][]artifact
+}
+
+// project проект Harbor
+type project struct {
+	Name string `json:"name"`
+}
+
+// repository репозиторий Harbor, Name включает имя проекта
+type repository struct {
+	Name string `json:"name"`
+}
+
+// artifact артефакт Harbor с тегами и метками
+type artifact struct {
+	Digest   string    `json:"digest"`
+	Size     int64     `json:"size"`
+	PushTime time.Time `json:"push_time"`
+	PullTime time.Time `json:"pull_time"`
+	Tags     []struct {
+		Name      string `json:"name"`
+		Immutable bool   `json:"immutable"`
+	} `json:"tags"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	ExtraAttrs struct {
+		Created time.Time `json:"created"`
+		Config  struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"config"`
+	} `json:"extra_attrs"`
+	// ScanOverview итоги сканирования по типам отчетов
+	ScanOverview map[string]scanOverview `json:"scan_overview"`
+}
+
+// scanOverview итоги сканирования артефакта на уязвимости
+type scanOverview struct {
+	ScanStatus string `json:"scan_status"`
+	Summary    struct {
+		Summary map[string]int `json:"summary"`
+	} `json:"summary"`
+}
+
+// acceptVulnerabilities типы отчетов об уязвимостях, итоги которых запрашиваются вместе
+// с артефактами
+const acceptVulnerabilities = "application/vnd.security.vulnerability.report; version=1.1, application/vnd.scanner.adapter.vuln.report.harbor+json; version=1.0"
+
+var (
+	_ registry.Backend               = (*Client)(nil)
+	_ registry.ImmutableChecker      = (*Client)(nil)
+	_ registry.PullTimeProvider      = (*Client)(nil)
+	_ registry.VulnerabilityProvider = (*Client)(nil)
+)
+
+// NewClient создает новый клиент Harbor
+func NewClient(baseURL, username, password string) *Client {
+	return &Client{
+		BaseURL:   strings.TrimSuffix(baseURL, "/"),
+		Username:  username,
+		Password:  password,
+		Client:    &http.Client{Timeout: 30 * time.Second},
+		artifacts: make(map[string][]artifact),
+	}
+}
+
+// newRequest создает HTTP запрос с аутентификацией
+func (c *Client) newRequest(ctx context.Context, method, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Accept-Vulnerabilities", acceptVulnerabilities)
+	if c.Username != "" && c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	return req, nil
+}
+
+// getPages получает все страницы списка по адресу path и декодирует элементы в items
+func getPages[T any](ctx context.Context, c *Client, path string, query url.Values) ([]T, error) {
+	var items []T
+	for page := 1; ; page++ {
+		query.Set("page", fmt.Sprint(page))
+		query.Set("page_size", fmt.Sprint(pageSize))
+
+		req, err := c.newRequest(ctx, "GET", c.BaseURL+"/api/v2.0"+path+"?"+query.Encode())
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var batch []T
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("получен статус %d при запросе %s", resp.StatusCode, path)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&batch)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка декодирования ответа %s: %v", path, err)
+		}
+
+		items = append(items, batch...)
+		if len(batch) < pageSize {
+			return items, nil
+		}
+	}
+}
+
+// repositoryPath возвращает путь репозитория в Harbor API. Имя репозитория внутри
+// проекта может содержать "/", поэтому Harbor требует кодировать его дважды
+func repositoryPath(name string) (string, error) {
+	i := strings.Index(name, "/")
+	if i < 0 {
+		return "", fmt.Errorf("репозиторий %s не содержит имени проекта", name)
+	}
+	return fmt.Sprintf("/projects/%s/repositories/%s",
+		url.PathEscape(name[:i]), url.PathEscape(url.PathEscape(name[i+1:]))), nil
+}
+
+// ListRepositories получает репозитории всех доступных проектов в виде project/repository
+func (c *Client) ListRepositories(ctx context.Context) ([]string, error) {
+	projects, err := getPages[project](ctx, c, "/projects", url.Values{})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении списка проектов: %v", err)
+	}
+
+	var names []string
+	for _, p := range projects {
+		repositories, err := getPages[repository](ctx, c, "/projects/"+url.PathEscape(p.Name)+"/repositories", url.Values{})
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при получении репозиториев проекта %s: %v", p.Name, err)
+		}
+		for _, r := range repositories {
+			names = append(names, r.Name)
+		}
+	}
+
+	return names, nil
+}
+
+// ListTags получает артефакты репозитория и возвращает их теги. Артефакты запоминаются
+// до следующего вызова ListTags для этого репозитория, чтобы ResolveDigest и GetImageMeta
+// не выполняли отдельных запросов
+func (c *Client) ListTags(ctx context.Context, repositoryName string) ([]string, error) {
+	path, err := repositoryPath(repositoryName)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{"with_tag": {"true"}, "with_label": {"true"}, "with_scan_overview": {"true"}}
+	artifacts, err := getPages[artifact](ctx, c, path+"/artifacts", query)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении артефактов %s: %v", repositoryName, err)
+	}
+
+	c.mu.Lock()
+	c.artifacts[repositoryName] = artifacts
+	c.mu.Unlock()
+
+	var tags []string
+	for _, a := range artifacts {
+		for _, tag := range a.Tags {
+			tags = append(tags, tag.Name)
+		}
+	}
+	return tags, nil
+}
+
+// findArtifact возвращает артефакт, помеченный тегом, и признак неизменяемости тега
+func (c *Client) findArtifact(ctx context.Context, repositoryName, tag string) (artifact, bool, error) {
+	c.mu.Lock()
+	artifacts, ok := c.artifacts[repositoryName]
+	c.mu.Unlock()
+
+	if !ok {
+		if _, err := c.ListTags(ctx, repositoryName); err != nil {
+			return artifact{}, false, err
+		}
+		c.mu.Lock()
+		artifacts = c.artifacts[repositoryName]
+		c.mu.Unlock()
+	}
+
+	for _, a := range artifacts {
+		for _, t := range a.Tags {
+			if t.Name == tag {
+				return a, t.Immutable, nil
+			}
+		}
+	}
+	return artifact{}, false, fmt.Errorf("тег %s:%s: %w", repositoryName, tag, registry.ErrNotFound)
+}
+
+// ResolveDigest возвращает digest артефакта, помеченного тегом
+func (c *Client) ResolveDigest(ctx context.Context, repositoryName, tag string) (string, error) {
+	a, _, err := c.findArtifact(ctx, repositoryName, tag)
+	if err != nil {
+		return "", err
+	}
+	return a.Digest, nil
+}
+
+// GetImageMeta возвращает время создания, размер и метки артефакта. Метки Harbor
+// добавляются к меткам образа с префиксом LabelPrefix и значением "true"
+func (c *Client) GetImageMeta(ctx context.Context, repositoryName, tag string) (registry.ImageMeta, error) {
+	a, _, err := c.findArtifact(ctx, repositoryName, tag)
+	if err != nil {
+		return registry.ImageMeta{}, err
+	}
+
+	meta := registry.ImageMeta{Created: a.ExtraAttrs.Created, Size: a.Size}
+	if meta.Created.IsZero() {
+		meta.Created = a.PushTime
+	}
+
+	if len(a.ExtraAttrs.Config.Labels) > 0 || len(a.Labels) > 0 {
+		meta.Labels = make(map[string]string)
+		for name, value := range a.ExtraAttrs.Config.Labels {
+			meta.Labels[name] = value
+		}
+		for _, label := range a.Labels {
+			meta.Labels[LabelPrefix+label.Name] = "true"
+		}
+	}
+
+	return meta, nil
+}
+
+// Immutable сообщает, защищен ли тег правилом неизменяемости Harbor
+func (c *Client) Immutable(ctx context.Context, repositoryName, tag string) (bool, error) {
+	_, immutable, err := c.findArtifact(ctx, repositoryName, tag)
+	return immutable, err
+}
+
+// LastPulled возвращает время последнего скачивания артефакта, помеченного тегом
+func (c *Client) LastPulled(ctx context.Context, repositoryName, tag string) (time.Time, error) {
+	a, _, err := c.findArtifact(ctx, repositoryName, tag)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return a.PullTime, nil
+}
+
+// Vulnerabilities возвращает количество уязвимостей артефакта, помеченного тегом, по
+// итогам последнего успешного сканирования Harbor
+func (c *Client) Vulnerabilities(ctx context.Context, repositoryName, tag string) (registry.Vulnerabilities, bool, error) {
+	a, _, err := c.findArtifact(ctx, repositoryName, tag)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, overview := range a.ScanOverview {
+		if overview.ScanStatus != "Success" {
+			continue
+		}
+		v := make(registry.Vulnerabilities, len(overview.Summary.Summary))
+		for severity, n := range overview.Summary.Summary {
+			v[strings.ToUpper(severity)] += n
+		}
+		return v, true, nil
+	}
+	return nil, false, nil
+}
+
+// Delete удаляет артефакт через Harbor API
+func (c *Client) Delete(ctx context.Context, repositoryName, digest string) error {
+	path, err := repositoryPath(repositoryName)
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, "DELETE", c.BaseURL+"/api/v2.0"+path+"/artifacts/"+url.PathEscape(digest))
+	if err != nil {
+		return fmt.Errorf("ошибка создания DELETE запроса: %v", err)
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения DELETE запроса: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted:
+		return nil
+	case http.StatusPreconditionFailed:
+		return fmt.Errorf("артефакт %s@%s защищен правилом неизменяемости тегов", repositoryName, digest)
+	case http.StatusForbidden:
+		return fmt.Errorf("нет прав на удаление артефакта %s@%s", repositoryName, digest)
+	default:
+		return fmt.Errorf("получен статус %d при удалении %s@%s", resp.StatusCode, repositoryName, digest)
+	}
+}
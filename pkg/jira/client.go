@@ -0,0 +1,153 @@
+// Package jira создает задачи Jira через REST API v2: очистке нужно только открыть задачу
+// на согласование плана и дополнить ее, если план изменился до согласования
+package jira
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client обращается к Jira Cloud или Jira Data Center
+type Client struct {
+	// URL адрес Jira, например https://example.atlassian.net
+	URL string
+	// User и Token: с User - базовая аутентификация по email и API-токену (Jira Cloud),
+	// без User - персональный токен доступа в заголовке Authorization: Bearer (Data Center)
+	User   string
+	Token  string
+	Client *http.Client
+}
+
+// Issue новая задача
+type Issue struct {
+	Project     string
+	Type        string
+	Summary     string
+	Description string
+	Labels      []string
+}
+
+// NewClient создает клиент Jira
+func NewClient(baseURL, user, token string) *Client {
+	return &Client{
+		URL:    strings.TrimRight(baseURL, "/"),
+		User:   user,
+		Token:  token,
+		Client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// FindIssue возвращает ключ первой задачи, подходящей под запрос JQL, или пустую строку
+func (c *Client) FindIssue(ctx context.Context, jql string) (string, error) {
+	query := url.Values{"jql": {jql}, "fields": {"key"}, "maxResults": {"1"}}
+	var result struct {
+		Issues []struct {
+			Key string `json:"key"`
+		} `json:"issues"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/rest/api/2/search?"+query.Encode(), nil, &result); err != nil {
+		return "", fmt.Errorf("ошибка поиска задачи Jira: %v", err)
+	}
+	if len(result.Issues) == 0 {
+		return "", nil
+	}
+	return result.Issues[0].Key, nil
+}
+
+// CreateIssue создает задачу и возвращает ее ключ
+func (c *Client) CreateIssue(ctx context.Context, issue Issue) (string, error) {
+	fields := map[string]interface{}{
+		"project":     map[string]string{"key": issue.Project},
+		"issuetype":   map[string]string{"name": issue.Type},
+		"summary":     issue.Summary,
+		"description": issue.Description,
+	}
+	if len(issue.Labels) > 0 {
+		fields["labels"] = issue.Labels
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/rest/api/2/issue", map[string]interface{}{"fields": fields}, &created); err != nil {
+		return "", fmt.Errorf("ошибка создания задачи Jira: %v", err)
+	}
+	return created.Key, nil
+}
+
+// AddComment добавляет комментарий к задаче
+func (c *Client) AddComment(ctx context.Context, key, body string) error {
+	if err := c.do(ctx, http.MethodPost, "/rest/api/2/issue/"+url.PathEscape(key)+"/comment", map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("ошибка добавления комментария к задаче Jira %s: %v", key, err)
+	}
+	return nil
+}
+
+// IssueURL возвращает адрес задачи в веб-интерфейсе Jira
+func (c *Client) IssueURL(key string) string {
+	return c.URL + "/browse/" + key
+}
+
+// do выполняет запрос к API и разбирает ответ в out, если он задан
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.User != "" {
+		req.SetBasicAuth(c.User, c.Token)
+	} else if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("получен статус %d: %s", resp.StatusCode, errorMessages(resp.Body))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("ошибка разбора ответа: %v", err)
+	}
+	return nil
+}
+
+// errorMessages извлекает сообщения об ошибках из ответа Jira
+func errorMessages(r io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(r, 64<<10))
+	var result struct {
+		ErrorMessages []string          `json:"errorMessages"`
+		Errors        map[string]string `json:"errors"`
+	}
+	if json.Unmarshal(data, &result) != nil {
+		return strings.TrimSpace(string(data))
+	}
+	messages := result.ErrorMessages
+	for field, message := range result.Errors {
+		messages = append(messages, field+": "+message)
+	}
+	return strings.Join(messages, "; ")
+}
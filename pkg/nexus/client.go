@@ -0,0 +1,312 @@
+// Package nexus реализует registry.Backend поверх REST API Sonatype Nexus Repository 3.
+// Коннектор Docker в Nexus часто не разрешает удаление манифестов, поэтому образы
+// удаляются как компоненты через /service/rest/v1/components
+package nexus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// CompactTaskType тип задачи Nexus, освобождающей место в blob store
+const CompactTaskType = "blobstore.compact"
+
+// Client клиент REST API Nexus. Репозитории очистки именуются <репозиторий Nexus>/<образ>
+type Client struct {
+	BaseURL  string
+	Username string
+	Password string
+	Client   *http.Client
+
+	mu         sync.Mutex
+	components map[string][]component
+}
+
+// component компонент Docker: образ name с тегом version
+type component struct {
+	ID         string `json:"id"`
+	Repository string `json:"repository"`
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Assets     []struct {
+		Path     string `json:"path"`
+		Checksum struct {
+			SHA256 string `json:"sha256"`
+		} `json:"checksum"`
+		BlobCreated    time.Time `json:"blobCreated"`
+		LastModified   time.Time `json:"lastModified"`
+		LastDownloaded time.Time `json:"lastDownloaded"`
+		FileSize       int64     `json:"fileSize"`
+	} `json:"assets"`
+}
+
+var (
+	_ registry.Backend          = (*Client)(nil)
+	_ registry.PullTimeProvider = (*Client)(nil)
+)
+
+// NewClient создает клиент Nexus
+func NewClient(baseURL, username, password string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Username:   username,
+		Password:   password,
+		Client:     &http.Client{Timeout: 30 * time.Second},
+		components: make(map[string][]component),
+	}
+}
+
+// do выполняет запрос к REST API Nexus с аутентификацией
+func (c *Client) do(ctx context.Context, method, path string, query url.Values) (*http.Response, error) {
+	target := c.BaseURL + "/service/rest/v1" + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if c.Username != "" && c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	return c.Client.Do(req)
+}
+
+// getPages получает все страницы списка по адресу path, следуя continuationToken
+func getPages[T any](ctx context.Context, c *Client, path string, query url.Values) ([]T, error) {
+	var items []T
+	for {
+		resp, err := c.do(ctx, "GET", path, query)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Items             []T    `json:"items"`
+			ContinuationToken string `json:"continuationToken"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("получен статус %d при запросе %s", resp.StatusCode, path)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка декодирования ответа %s: %v", path, err)
+		}
+
+		items = append(items, page.Items...)
+		if page.ContinuationToken == "" {
+			return items, nil
+		}
+		query.Set("continuationToken", page.ContinuationToken)
+	}
+}
+
+// ListRepositories получает образы всех hosted-репозиториев Docker
+func (c *Client) ListRepositories(ctx context.Context) ([]string, error) {
+	resp, err := c.do(ctx, "GET", "/repositories", nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении списка репозиториев: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("получен статус %d при запросе репозиториев", resp.StatusCode)
+	}
+
+	var repositories []struct {
+		Name   string `json:"name"`
+		Format string `json:"format"`
+		Type   string `json:"type"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&repositories); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования ответа: %v", err)
+	}
+
+	var names []string
+	for _, r := range repositories {
+		// Удалять компоненты можно только в hosted-репозиториях
+		if r.Format != "docker" || r.Type != "hosted" {
+			continue
+		}
+
+		components, err := getPages[component](ctx, c, "/components", url.Values{"repository": {r.Name}})
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при получении компонентов %s: %v", r.Name, err)
+		}
+
+		seen := make(map[string]bool)
+		for _, comp := range components {
+			name := r.Name + "/" + comp.Name
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// ListTags получает компоненты образа и возвращает их версии (теги). Компоненты
+// запоминаются до следующего вызова ListTags для этого репозитория
+func (c *Client) ListTags(ctx context.Context, name string) ([]string, error) {
+	repo, image, ok := strings.Cut(name, "/")
+	if !ok {
+		return nil, fmt.Errorf("репозиторий %s должен иметь вид <репозиторий Nexus>/<образ>", name)
+	}
+
+	query := url.Values{"repository": {repo}, "format": {"docker"}, "name": {image}}
+	found, err := getPages[component](ctx, c, "/search", query)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении тегов для %s: %v", name, err)
+	}
+
+	// Поиск может вернуть образы с похожими именами
+	var components []component
+	var tags []string
+	for _, comp := range found {
+		if comp.Name == image {
+			components = append(components, comp)
+			tags = append(tags, comp.Version)
+		}
+	}
+
+	c.mu.Lock()
+	c.components[name] = components
+	c.mu.Unlock()
+
+	return tags, nil
+}
+
+// findComponent возвращает компонент образа с тегом
+func (c *Client) findComponent(ctx context.Context, name, tag string) (component, error) {
+	c.mu.Lock()
+	components, ok := c.components[name]
+	c.mu.Unlock()
+
+	if !ok {
+		if _, err := c.ListTags(ctx, name); err != nil {
+			return component{}, err
+		}
+		c.mu.Lock()
+		components = c.components[name]
+		c.mu.Unlock()
+	}
+
+	for _, comp := range components {
+		if comp.Version == tag && len(comp.Assets) > 0 {
+			return comp, nil
+		}
+	}
+	return component{}, fmt.Errorf("тег %s:%s: %w", name, tag, registry.ErrNotFound)
+}
+
+// ResolveDigest возвращает digest манифеста по контрольной сумме его asset
+func (c *Client) ResolveDigest(ctx context.Context, name, tag string) (string, error) {
+	comp, err := c.findComponent(ctx, name, tag)
+	if err != nil {
+		return "", err
+	}
+	return "sha256:" + comp.Assets[0].Checksum.SHA256, nil
+}
+
+// GetImageMeta возвращает время загрузки манифеста в Nexus в качестве времени создания.
+// Размер слоев Nexus не сообщает, поэтому размер образа не заполняется
+func (c *Client) GetImageMeta(ctx context.Context, name, tag string) (registry.ImageMeta, error) {
+	comp, err := c.findComponent(ctx, name, tag)
+	if err != nil {
+		return registry.ImageMeta{}, err
+	}
+
+	created := comp.Assets[0].BlobCreated
+	if created.IsZero() {
+		created = comp.Assets[0].LastModified
+	}
+	return registry.ImageMeta{Created: created}, nil
+}
+
+// LastPulled возвращает время последнего скачивания манифеста
+func (c *Client) LastPulled(ctx context.Context, name, tag string) (time.Time, error) {
+	comp, err := c.findComponent(ctx, name, tag)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return comp.Assets[0].LastDownloaded, nil
+}
+
+// Delete удаляет все компоненты образа, манифест которых имеет digest
+func (c *Client) Delete(ctx context.Context, name, digest string) error {
+	c.mu.Lock()
+	var ids []string
+	for _, comp := range c.components[name] {
+		if len(comp.Assets) > 0 && "sha256:"+comp.Assets[0].Checksum.SHA256 == digest {
+			ids = append(ids, comp.ID)
+		}
+	}
+	c.mu.Unlock()
+
+	if len(ids) == 0 {
+		return fmt.Errorf("не найдено компонентов %s с digest %s", name, digest)
+	}
+
+	for _, id := range ids {
+		resp, err := c.do(ctx, "DELETE", "/components/"+url.PathEscape(id), nil)
+		if err != nil {
+			return fmt.Errorf("ошибка выполнения DELETE запроса: %v", err)
+		}
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		case http.StatusForbidden:
+			return fmt.Errorf("нет прав на удаление компонента %s@%s (требуется nx-repository-view-docker-*-delete)", name, digest)
+		default:
+			return fmt.Errorf("получен статус %d при удалении компонента %s@%s", resp.StatusCode, name, digest)
+		}
+	}
+	return nil
+}
+
+// Compact запускает все задачи сжатия blob store и возвращает их имена. Задачи
+// выполняются асинхронно, Nexus освобождает место после их завершения
+func (c *Client) Compact(ctx context.Context) ([]string, error) {
+	tasks, err := getPages[struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}](ctx, c, "/tasks", url.Values{"type": {CompactTaskType}})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении задач %s: %v", CompactTaskType, err)
+	}
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("в Nexus не создано ни одной задачи %s (Admin - Compact blob store)", CompactTaskType)
+	}
+
+	var started []string
+	for _, task := range tasks {
+		resp, err := c.do(ctx, "POST", "/tasks/"+url.PathEscape(task.ID)+"/run", nil)
+		if err != nil {
+			return started, fmt.Errorf("ошибка запуска задачи %s: %v", task.Name, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+			return started, fmt.Errorf("получен статус %d при запуске задачи %s", resp.StatusCode, task.Name)
+		}
+		started = append(started, task.Name)
+	}
+	return started, nil
+}
@@ -0,0 +1,302 @@
+// Package quay реализует registry.Backend поверх API Quay. Теги удаляются через API
+// или получают срок действия, по истечении которого Quay удаляет их сам. Время создания
+// образа определяется по истории тега, поэтому восстановленный (reverted) тег считается новым
+package quay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"registryCleaner/pkg/registry"
+)
+
+// pageSize количество элементов на странице ответов Quay API
+const pageSize = 100
+
+// Client клиент API Quay. Репозитории берутся из пространств имен Namespaces
+// и именуются <пространство имен>/<репозиторий>
+type Client struct {
+	BaseURL    string
+	Namespaces []string
+	// Token OAuth-токен приложения Quay с правами на чтение и запись репозиториев
+	Token string
+	// ExpireAfter, если больше нуля, вместо удаления тегов устанавливает им срок действия
+	ExpireAfter time.Duration
+	Client      *http.Client
+
+	mu   sync.Mutex
+	tags map[string][]tag
+}
+
+// tag запись истории тега. У активного тега EndTS не задан
+type tag struct {
+	Name           string `json:"name"`
+	ManifestDigest string `json:"manifest_digest"`
+	Size           int64  `json:"size"`
+	StartTS        int64  `json:"start_ts"`
+	EndTS          *int64 `json:"end_ts"`
+}
+
+// tagPage страница ответа /tag/
+type tagPage struct {
+	Tags          []tag `json:"tags"`
+	HasAdditional bool  `json:"has_additional"`
+}
+
+var _ registry.Backend = (*Client)(nil)
+
+// NewClient создает клиент Quay
+func NewClient(baseURL string, namespaces []string, token string, expireAfter time.Duration) *Client {
+	return &Client{
+		BaseURL:     strings.TrimSuffix(baseURL, "/"),
+		Namespaces:  namespaces,
+		Token:       token,
+		ExpireAfter: expireAfter,
+		Client:      &http.Client{Timeout: 30 * time.Second},
+		tags:        make(map[string][]tag),
+	}
+}
+
+// do выполняет запрос к Quay API с аутентификацией
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	target := c.BaseURL + "/api/v1" + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	return c.Client.Do(req)
+}
+
+// get выполняет GET запрос и декодирует ответ в out
+func (c *Client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	resp, err := c.do(ctx, "GET", path, query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("получен статус %d при запросе %s", resp.StatusCode, path)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("ошибка декодирования ответа %s: %v", path, err)
+	}
+	return nil
+}
+
+// repositoryPath возвращает путь репозитория в Quay API
+func repositoryPath(name string) string {
+	return "/repository/" + name
+}
+
+// ListRepositories получает репозитории всех пространств имен
+func (c *Client) ListRepositories(ctx context.Context) ([]string, error) {
+	var names []string
+	for _, namespace := range c.Namespaces {
+		query := url.Values{"namespace": {namespace}}
+		for {
+			var page struct {
+				Repositories []struct {
+					Namespace string `json:"namespace"`
+					Name      string `json:"name"`
+				} `json:"repositories"`
+				NextPage string `json:"next_page"`
+			}
+			if err := c.get(ctx, "/repository", query, &page); err != nil {
+				return nil, fmt.Errorf("ошибка при получении репозиториев %s: %v", namespace, err)
+			}
+			for _, r := range page.Repositories {
+				names = append(names, r.Namespace+"/"+r.Name)
+			}
+			if page.NextPage == "" {
+				break
+			}
+			query.Set("next_page", page.NextPage)
+		}
+	}
+	return names, nil
+}
+
+// getTags получает все страницы списка тегов репозитория
+func (c *Client) getTags(ctx context.Context, name string, query url.Values) ([]tag, error) {
+	var tags []tag
+	query.Set("limit", fmt.Sprint(pageSize))
+	for page := 1; ; page++ {
+		query.Set("page", fmt.Sprint(page))
+
+		var batch tagPage
+		if err := c.get(ctx, repositoryPath(name)+"/tag/", query, &batch); err != nil {
+			return nil, err
+		}
+		tags = append(tags, batch.Tags...)
+		if !batch.HasAdditional {
+			return tags, nil
+		}
+	}
+}
+
+// ListTags получает активные теги репозитория. Они запоминаются до следующего вызова
+// ListTags для этого репозитория
+func (c *Client) ListTags(ctx context.Context, name string) ([]string, error) {
+	tags, err := c.getTags(ctx, name, url.Values{"onlyActiveTags": {"true"}})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении тегов для %s: %v", name, err)
+	}
+
+	c.mu.Lock()
+	c.tags[name] = tags
+	c.mu.Unlock()
+
+	var names []string
+	for _, t := range tags {
+		names = append(names, t.Name)
+	}
+	return names, nil
+}
+
+// findTag возвращает активный тег
+func (c *Client) findTag(ctx context.Context, name, tagName string) (tag, error) {
+	c.mu.Lock()
+	tags, ok := c.tags[name]
+	c.mu.Unlock()
+
+	if !ok {
+		if _, err := c.ListTags(ctx, name); err != nil {
+			return tag{}, err
+		}
+		c.mu.Lock()
+		tags = c.tags[name]
+		c.mu.Unlock()
+	}
+
+	for _, t := range tags {
+		if t.Name == tagName {
+			return t, nil
+		}
+	}
+	return tag{}, fmt.Errorf("тег %s:%s: %w", name, tagName, registry.ErrNotFound)
+}
+
+// ResolveDigest возвращает digest манифеста активного тега
+func (c *Client) ResolveDigest(ctx context.Context, name, tagName string) (string, error) {
+	t, err := c.findTag(ctx, name, tagName)
+	if err != nil {
+		return "", err
+	}
+	return t.ManifestDigest, nil
+}
+
+// GetImageMeta возвращает размер образа и время, с которого тег непрерывно указывает
+// на текущий манифест. Повторная загрузка того же манифеста не сбрасывает это время,
+// а возврат тега к старому манифесту или восстановление удаленного тега сбрасывает
+func (c *Client) GetImageMeta(ctx context.Context, name, tagName string) (registry.ImageMeta, error) {
+	active, err := c.findTag(ctx, name, tagName)
+	if err != nil {
+		return registry.ImageMeta{}, err
+	}
+
+	history, err := c.getTags(ctx, name, url.Values{"specificTag": {tagName}})
+	if err != nil {
+		return registry.ImageMeta{}, fmt.Errorf("ошибка при получении истории тега %s:%s: %v", name, tagName, err)
+	}
+
+	return registry.ImageMeta{Created: time.Unix(assignedSince(active, history), 0), Size: active.Size}, nil
+}
+
+// assignedSince возвращает время, с которого тег непрерывно указывает на манифест активной записи
+func assignedSince(active tag, history []tag) int64 {
+	sort.Slice(history, func(i, j int) bool { return history[i].StartTS > history[j].StartTS })
+
+	since := active.StartTS
+	for _, entry := range history {
+		if entry.StartTS >= since || entry.EndTS == nil {
+			continue
+		}
+		// Запись, завершившаяся раньше, чем началась следующая, означает, что тег
+		// был удален и затем восстановлен
+		if entry.ManifestDigest != active.ManifestDigest || *entry.EndTS < since {
+			break
+		}
+		since = entry.StartTS
+	}
+	return since
+}
+
+// tagsByDigest возвращает активные теги репозитория, указывающие на digest
+func (c *Client) tagsByDigest(name, digest string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var tags []string
+	for _, t := range c.tags[name] {
+		if t.ManifestDigest == digest {
+			tags = append(tags, t.Name)
+		}
+	}
+	return tags
+}
+
+// Delete удаляет все активные теги репозитория, указывающие на digest, или, если задан
+// ExpireAfter, устанавливает им срок действия
+func (c *Client) Delete(ctx context.Context, name, digest string) error {
+	tags := c.tagsByDigest(name, digest)
+	if len(tags) == 0 {
+		return fmt.Errorf("не найдено тегов %s с digest %s", name, digest)
+	}
+
+	for _, t := range tags {
+		path := repositoryPath(name) + "/tag/" + url.PathEscape(t)
+
+		var resp *http.Response
+		var err error
+		if c.ExpireAfter > 0 {
+			body := map[string]int64{"expiration": time.Now().Add(c.ExpireAfter).Unix()}
+			resp, err = c.do(ctx, "PUT", path, nil, body)
+		} else {
+			resp, err = c.do(ctx, "DELETE", path, nil, nil)
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка изменения тега %s:%s: %v", name, t, err)
+		}
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		case http.StatusForbidden:
+			return fmt.Errorf("нет прав на изменение тега %s:%s", name, t)
+		default:
+			return fmt.Errorf("получен статус %d при изменении тега %s:%s", resp.StatusCode, name, t)
+		}
+	}
+	return nil
+}
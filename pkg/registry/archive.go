@@ -0,0 +1,122 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Archiver копирует образы в архивный registry перед удалением
+type Archiver struct {
+	Source *Client
+	Target *Client
+	Prefix string
+
+	// mounted запоминает, в какой архивный репозиторий уже загружен blob,
+	// чтобы следующие копии монтировались без повторной передачи данных
+	mounted map[string]string
+}
+
+// NewArchiver создает архиватор для копирования образов из source в target
+func NewArchiver(source, target *Client, prefix string) *Archiver {
+	return &Archiver{
+		Source:  source,
+		Target:  target,
+		Prefix:  prefix,
+		mounted: make(map[string]string),
+	}
+}
+
+// targetRepository возвращает имя репозитория в архивном registry
+func (a *Archiver) targetRepository(repository string) string {
+	if a.Prefix == "" {
+		return repository
+	}
+	return a.Prefix + "/" + repository
+}
+
+// ArchiveImage копирует образ вместе со всеми blob и проверяет, что архивная копия
+// имеет тот же digest. Удалять оригинал можно только при отсутствии ошибки
+func (a *Archiver) ArchiveImage(ctx context.Context, img ImageInfo) error {
+	target := a.targetRepository(img.Repository)
+
+	if err := a.copyManifest(ctx, img.Repository, target, img.Digest, img.Tag); err != nil {
+		return err
+	}
+
+	digest, err := a.Target.ResolveManifest(ctx, target, img.Tag)
+	if err != nil {
+		return fmt.Errorf("не удалось проверить архивную копию: %v", err)
+	}
+	if digest != img.Digest {
+		return fmt.Errorf("digest архивной копии %s не совпадает с исходным %s", digest, img.Digest)
+	}
+
+	return nil
+}
+
+// copyManifest копирует манифест и все, на что он ссылается, в архивный репозиторий
+func (a *Archiver) copyManifest(ctx context.Context, source, target, digest, reference string) error {
+	raw, mediaType, _, err := a.Source.GetManifest(ctx, source, digest)
+	if err != nil {
+		return err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return fmt.Errorf("ошибка разбора манифеста %s@%s: %v", source, digest, err)
+	}
+
+	// Для manifest list и OCI index сначала копируем дочерние манифесты
+	for _, child := range manifest.Manifests {
+		if err := a.copyManifest(ctx, source, target, child.Digest, child.Digest); err != nil {
+			return err
+		}
+	}
+
+	for _, blob := range manifest.Blobs() {
+		if err := a.copyBlob(ctx, source, target, blob.Digest); err != nil {
+			return err
+		}
+	}
+
+	if _, err := a.Target.PutManifest(ctx, target, reference, mediaType, raw); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// copyBlob копирует blob, используя cross-repo mount, если blob уже есть в архиве
+func (a *Archiver) copyBlob(ctx context.Context, source, target, digest string) error {
+	exists, err := a.Target.BlobExists(ctx, target, digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		a.mounted[digest] = target
+		return nil
+	}
+
+	location, mounted, err := a.Target.StartBlobUpload(ctx, target, digest, a.mounted[digest])
+	if err != nil {
+		return err
+	}
+	if mounted {
+		a.mounted[digest] = target
+		return nil
+	}
+
+	content, size, err := a.Source.GetBlob(ctx, source, digest)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	if err := a.Target.UploadBlob(ctx, location, digest, size, content); err != nil {
+		return err
+	}
+
+	a.mounted[digest] = target
+	return nil
+}
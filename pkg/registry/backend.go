@@ -0,0 +1,95 @@
+package registry
+
+import (
+	"context"
+	"time"
+)
+
+// Backend операции с registry, необходимые для очистки. Реализуется HTTP-клиентом
+// Docker Registry API V2; альтернативные реализации (API Harbor, ECR, заглушки)
+// подключаются к Planner и Executor без изменения логики очистки
+type Backend interface {
+	// ListRepositories возвращает список всех репозиториев
+	ListRepositories(ctx context.Context) ([]string, error)
+	// ListTags возвращает список тегов репозитория
+	ListTags(ctx context.Context, repository string) ([]string, error)
+	// ResolveDigest возвращает digest манифеста, на который указывает тег
+	ResolveDigest(ctx context.Context, repository, tag string) (string, error)
+	// GetImageMeta возвращает время создания, размер и метки образа
+	GetImageMeta(ctx context.Context, repository, tag string) (ImageMeta, error)
+	// Delete удаляет манифест по digest. Если registry не поддерживает удаление,
+	// возвращается ошибка, оборачивающая ErrDeleteUnsupported
+	Delete(ctx context.Context, repository, digest string) error
+}
+
+// ImmutableChecker необязательный интерфейс Backend для registry с неизменяемыми тегами.
+// Образы с такими тегами не включаются в план удаления
+type ImmutableChecker interface {
+	// Immutable сообщает, защищен ли тег от удаления
+	Immutable(ctx context.Context, repository, tag string) (bool, error)
+}
+
+// PullTimeProvider необязательный интерфейс Backend для registry, которые ведут
+// статистику скачиваний образов
+type PullTimeProvider interface {
+	// LastPulled возвращает время последнего скачивания тега, нулевое время - тег не скачивался
+	LastPulled(ctx context.Context, repository, tag string) (time.Time, error)
+}
+
+// ModifiedTimeProvider необязательный интерфейс Backend для registry, которые сообщают
+// время изменения манифеста
+type ModifiedTimeProvider interface {
+	// ManifestModified возвращает время изменения манифеста по digest или тегу
+	ManifestModified(ctx context.Context, repository, reference string) (time.Time, error)
+}
+
+// VulnerabilityProvider необязательный интерфейс Backend для registry, которые сканируют
+// образы на уязвимости
+type VulnerabilityProvider interface {
+	// Vulnerabilities возвращает количество уязвимостей тега по уровням важности;
+	// ok = false, если образ не сканировался или сканирование не завершено
+	Vulnerabilities(ctx context.Context, repository, tag string) (v Vulnerabilities, ok bool, err error)
+}
+
+// BulkDeleter необязательный интерфейс Backend для registry, которые удаляют
+// несколько образов репозитория одним запросом
+type BulkDeleter interface {
+	// DeleteImages удаляет образы репозитория
+	DeleteImages(ctx context.Context, repository string, images []ImageInfo) error
+}
+
+// TagDeleter необязательный интерфейс Backend для registry, которые позволяют удалить
+// ссылку тега вместо манифеста по digest
+type TagDeleter interface {
+	// DeleteTag удаляет тег репозитория
+	DeleteTag(ctx context.Context, repository, tag string) error
+}
+
+// ManifestChecker необязательный интерфейс Backend для проверки после удаления, что
+// registry больше не отдает манифест
+type ManifestChecker interface {
+	// ManifestExists сообщает, отдает ли registry манифест по digest или тегу
+	ManifestExists(ctx context.Context, repository, digest string) (bool, error)
+}
+
+// TagResolver необязательный интерфейс Backend, получающий digest и метаданные тега за
+// один проход, без повторного запроса манифеста
+type TagResolver interface {
+	// ResolveTag возвращает digest тега и, если wantMeta для этого digest возвращает
+	// true, метаданные образа. Ошибка получения метаданных возвращается в TagDetails.MetaErr
+	ResolveTag(ctx context.Context, repository, tag string, wantMeta func(digest string) bool) (TagDetails, error)
+}
+
+// TagDetails digest и метаданные тега, полученные TagResolver
+type TagDetails struct {
+	Digest string
+	// Meta метаданные образа, если они запрашивались
+	Meta ImageMeta
+	// MetaErr ошибка получения метаданных; digest при этом известен
+	MetaErr error
+}
+
+var _ Backend = (*Client)(nil)
+var _ ManifestChecker = (*Client)(nil)
+var _ TagDeleter = (*Client)(nil)
+var _ TagResolver = (*Client)(nil)
@@ -0,0 +1,144 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// nextLink ссылка на следующую страницу в заголовке Link
+var nextLink = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// CatalogRange часть каталога, которую обрабатывает запуск: так очистку огромного
+// registry можно разделить между несколькими запусками по расписанию. Registry
+// возвращает каталог в лексикографическом порядке, поэтому запрос начинается с
+// параметра last, а получение страниц прекращается после последнего подходящего имени
+type CatalogRange struct {
+	// After репозитории с именем после After, не включая его; пустое - с начала каталога
+	After string
+	// UpTo репозитории с именем до UpTo включительно; пустое - до конца каталога
+	UpTo string
+	// Prefix только репозитории, имя которых начинается с Prefix
+	Prefix string
+}
+
+// ParseCatalogRange разбирает диапазон вида <после>..<до>, где любая граница может
+// быть пустой: ..m, m..t, t... Границы совпадают с параметром last каталога, поэтому
+// диапазоны ..m, m..t и t.. покрывают каталог без пересечений
+func ParseCatalogRange(value string) (CatalogRange, error) {
+	if value == "" {
+		return CatalogRange{}, nil
+	}
+	after, upTo, ok := strings.Cut(value, "..")
+	if !ok {
+		return CatalogRange{}, fmt.Errorf("ожидается диапазон <после>..<до>, например ..m или m..t")
+	}
+	if after != "" && upTo != "" && after >= upTo {
+		return CatalogRange{}, fmt.Errorf("начало диапазона %q должно быть меньше конца %q", after, upTo)
+	}
+	return CatalogRange{After: after, UpTo: upTo}, nil
+}
+
+// Contains сообщает, входит ли репозиторий в часть каталога
+func (r CatalogRange) Contains(name string) bool {
+	if r.After != "" && name <= r.After {
+		return false
+	}
+	if r.UpTo != "" && name > r.UpTo {
+		return false
+	}
+	return strings.HasPrefix(name, r.Prefix)
+}
+
+// start значение параметра last для первого запроса каталога
+func (r CatalogRange) start() string {
+	last := r.After
+	if r.Prefix != "" {
+		// Имя перед всеми именами с префиксом: последний символ префикса уменьшается
+		// на единицу. Лишние имена между ними отбрасываются Contains
+		i := len(r.Prefix) - 1
+		if before := r.Prefix[:i] + string(r.Prefix[i]-1); r.Prefix[i] > 0 && before > last {
+			last = before
+		}
+	}
+	return last
+}
+
+// past сообщает, что имя и все следующие за ним в каталоге не входят в часть каталога
+func (r CatalogRange) past(name string) bool {
+	if r.UpTo != "" && name > r.UpTo {
+		return true
+	}
+	return r.Prefix != "" && name > r.Prefix && !strings.HasPrefix(name, r.Prefix)
+}
+
+// ListRepositories получает список репозиториев, запрашивая каталог по страницам
+// CatalogPageSize имен. Если задан Catalog, возвращается только эта часть каталога
+func (rc *Client) ListRepositories(ctx context.Context) ([]string, error) {
+	query := url.Values{}
+	if rc.CatalogPageSize > 0 {
+		query.Set("n", fmt.Sprint(rc.CatalogPageSize))
+	}
+	if last := rc.Catalog.start(); last != "" {
+		query.Set("last", last)
+	}
+	next := fmt.Sprintf("%s/v2/_catalog", rc.BaseURL)
+	if len(query) > 0 {
+		next += "?" + query.Encode()
+	}
+
+	repositories := []string{}
+	for next != "" {
+		page, link, err := rc.catalogPage(ctx, next)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range page {
+			if rc.Catalog.past(name) {
+				return repositories, nil
+			}
+			if rc.Catalog.Contains(name) {
+				repositories = append(repositories, name)
+			}
+		}
+		// Registry без поддержки last может возвращать ту же страницу
+		if link == next || len(page) == 0 {
+			break
+		}
+		next = link
+	}
+	return repositories, nil
+}
+
+// catalogPage получает одну страницу каталога и адрес следующей страницы из заголовка Link
+func (rc *Client) catalogPage(ctx context.Context, pageURL string) ([]string, string, error) {
+	resp, err := rc.makeRequest(ctx, "GET", pageURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка при получении списка репозиториев: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("получен статус %d при запросе репозиториев", resp.StatusCode)
+	}
+
+	var repoResp repositoriesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&repoResp); err != nil {
+		return nil, "", fmt.Errorf("ошибка декодирования ответа: %v", err)
+	}
+
+	m := nextLink.FindStringSubmatch(resp.Header.Get("Link"))
+	if m == nil {
+		return repoResp.Repositories, "", nil
+	}
+	// Registry возвращает ссылку относительно своего адреса
+	link, err := resp.Request.URL.Parse(m[1])
+	if err != nil {
+		return nil, "", fmt.Errorf("некорректная ссылка на следующую страницу каталога %q: %v", m[1], err)
+	}
+	return repoResp.Repositories, link.String(), nil
+}
@@ -0,0 +1,133 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// catalogServer отдает каталог страницами по n имен начиная после last, как registry
+func catalogServer(t *testing.T, repositories []string, pages *int) *httptest.Server {
+	t.Helper()
+	sorted := append([]string(nil), repositories...)
+	sort.Strings(sorted)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*pages++
+		query := r.URL.Query()
+		n, err := strconv.Atoi(query.Get("n"))
+		if err != nil || n <= 0 {
+			n = len(sorted)
+		}
+		start := sort.SearchStrings(sorted, query.Get("last"))
+		if start < len(sorted) && sorted[start] == query.Get("last") {
+			start++
+		}
+		end := min(start+n, len(sorted))
+		page := sorted[start:end]
+		if end < len(sorted) {
+			next := url.Values{"n": {strconv.Itoa(n)}, "last": {page[len(page)-1]}}
+			w.Header().Set("Link", fmt.Sprintf(`</v2/_catalog?%s>; rel="next"`, next.Encode()))
+		}
+		json.NewEncoder(w).Encode(repositoriesResponse{Repositories: append([]string{}, page...)})
+	}))
+}
+
+func TestClientListRepositories(t *testing.T) {
+	repositories := []string{"api", "app", "base/alpine", "base/debian", "cache", "team/app", "team/web", "web"}
+	tests := []struct {
+		name     string
+		pageSize int
+		catalog  CatalogRange
+		want     []string
+		// pages ожидаемое количество запросов каталога
+		pages int
+	}{
+		{name: "каталог одной страницей", want: repositories, pages: 1},
+		{name: "каталог по страницам", pageSize: 3, want: repositories, pages: 3},
+		{
+			name:     "диапазон запрашивается с last и заканчивается на UpTo",
+			pageSize: 2,
+			catalog:  CatalogRange{After: "app", UpTo: "cache"},
+			want:     []string{"base/alpine", "base/debian", "cache"},
+			pages:    2,
+		},
+		{
+			name:     "префикс",
+			pageSize: 2,
+			catalog:  CatalogRange{Prefix: "team/"},
+			want:     []string{"team/app", "team/web"},
+			pages:    2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages int
+			server := catalogServer(t, repositories, &pages)
+			defer server.Close()
+
+			client := NewClient(server.URL, "", "")
+			client.CatalogPageSize = tt.pageSize
+			client.Catalog = tt.catalog
+			got, err := client.ListRepositories(context.Background())
+			if err != nil {
+				t.Fatalf("ListRepositories: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("репозитории %v, ожидалось %v", got, tt.want)
+			}
+			if pages != tt.pages {
+				t.Errorf("запросов каталога %d, ожидалось %d", pages, tt.pages)
+			}
+		})
+	}
+}
+
+func TestClientListRepositoriesIgnoresLast(t *testing.T) {
+	// Registry без поддержки last возвращает первую страницу со ссылкой на саму себя
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", fmt.Sprintf(`<%s/v2/_catalog?n=2>; rel="next"`, server.URL))
+		json.NewEncoder(w).Encode(repositoriesResponse{Repositories: []string{"api", "app"}})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "", "")
+	client.CatalogPageSize = 2
+	got, err := client.ListRepositories(context.Background())
+	if err != nil {
+		t.Fatalf("ListRepositories: %v", err)
+	}
+	if want := []string{"api", "app"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("репозитории %v, ожидалось %v", got, want)
+	}
+}
+
+func TestParseCatalogRange(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    CatalogRange
+		wantErr bool
+	}{
+		{value: "", want: CatalogRange{}},
+		{value: "..m", want: CatalogRange{UpTo: "m"}},
+		{value: "m..t", want: CatalogRange{After: "m", UpTo: "t"}},
+		{value: "t..", want: CatalogRange{After: "t"}},
+		{value: "t..m", wantErr: true},
+		{value: "m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCatalogRange(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseCatalogRange(%q) = %+v, %v; ожидалось %+v, ошибка: %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,596 @@
+// Package registry реализует клиент Docker Registry HTTP API V2
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client структура для работы с Docker Registry
+type Client struct {
+	BaseURL  string
+	Username string
+	Password string
+	Client   *http.Client
+	// TrustDigestHeader отключает сверку заголовка Docker-Content-Digest с sha256
+	// полученного манифеста; без сверки digest тегов запрашиваются запросом HEAD
+	TrustDigestHeader bool
+	// TagDeleteFallback удаление будет выполняться по тегу, если registry отклоняет
+	// удаление по digest: Preflight и CheckAccess считают такой отказ предупреждением
+	TagDeleteFallback bool
+	// IndexCreated способ определения времени создания образа с несколькими платформами:
+	// IndexCreatedNewest (по умолчанию), IndexCreatedOldest, IndexCreatedAnnotation или
+	// платформа вида linux/amd64
+	IndexCreated string
+	// Configs кэш конфигураций образов по digest; nil - конфигурации не кэшируются
+	Configs *ConfigCache
+	// ETags кэш ответов для условных запросов, включается UseETagCache
+	ETags *ETagCache
+	// CatalogPageSize количество имен на странице каталога (параметр n); 0 - размер
+	// страницы по умолчанию registry
+	CatalogPageSize int
+	// Catalog часть каталога, которую возвращает ListRepositories; пустая - весь каталог
+	Catalog CatalogRange
+}
+
+// repositoriesResponse структура ответа со списком репозиториев
+type repositoriesResponse struct {
+	Repositories []string `json:"repositories"`
+}
+
+// tagsResponse структура ответа со списком тегов
+type tagsResponse struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// Descriptor ссылка на blob или манифест внутри манифеста
+type Descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+	// Platform и Annotations заполнены для манифестов в manifest list и OCI index
+	Platform    *Platform         `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Manifest структура ответа с манифестом v2
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+	// Manifests заполнено для manifest list и OCI index
+	Manifests []Descriptor `json:"manifests"`
+	// FSLayers заполнено для манифестов schema1
+	FSLayers []struct {
+		BlobSum string `json:"blobSum"`
+	} `json:"fsLayers"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// Blobs возвращает дескрипторы всех blob, на которые ссылается манифест образа
+func (m *Manifest) Blobs() []Descriptor {
+	var blobs []Descriptor
+	if m.Config.Digest != "" {
+		blobs = append(blobs, m.Config)
+	}
+	blobs = append(blobs, m.Layers...)
+	for _, layer := range m.FSLayers {
+		blobs = append(blobs, Descriptor{Digest: layer.BlobSum})
+	}
+	return blobs
+}
+
+// ImageConfig структура ответа с конфигурацией образа
+type ImageConfig struct {
+	Created      time.Time `json:"created"`
+	OS           string    `json:"os"`
+	Architecture string    `json:"architecture"`
+	Variant      string    `json:"variant"`
+	Config       struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"config"`
+}
+
+// ImageInfo информация об образе
+type ImageInfo struct {
+	Repository string
+	Tag        string
+	Digest     string
+	Created    time.Time
+	Size       int64
+	Labels     map[string]string
+	// Annotations аннотации манифеста OCI, например org.opencontainers.image.source
+	Annotations map[string]string
+	// LastPulled время последнего скачивания, если backend реализует PullTimeProvider
+	LastPulled time.Time
+	// Blobs конфигурация и слои образа, если backend их сообщает
+	Blobs []Descriptor
+	// Platforms платформы образа, если backend их сообщает
+	Platforms []Platform
+	// Scan итоги сканирования образа, если политика сканирует образы; nil - образ не
+	// сканировался или сканирование не удалось
+	Scan *ScanResult
+}
+
+// ImageMeta метаданные образа, неизменные для одного digest
+type ImageMeta struct {
+	Created time.Time         `json:"created"`
+	Size    int64             `json:"size"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	// Annotations аннотации манифеста
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Blobs конфигурация и слои образа: по ним оценивается место, освобождаемое
+	// с учетом слоев, общих с другими образами
+	Blobs []Descriptor `json:"blobs,omitempty"`
+	// Platforms платформы образа; пусто у артефактов без платформы (Helm-чарты, подписи)
+	Platforms []Platform `json:"platforms,omitempty"`
+}
+
+// ErrDeleteUnsupported возвращается, если Registry не настроен для удаления образов
+var ErrDeleteUnsupported = errors.New("удаление не поддерживается Registry (статус 405)")
+
+// ErrDeleteRejected оборачивается в ошибки удаления, отклоненного статусом 400: так
+// отвечают registry, не поддерживающие удаление по digest
+var ErrDeleteRejected = errors.New("запрос удаления отклонен (статус 400)")
+
+// ErrNotFound оборачивается в ошибки запросов, завершившихся статусом 404
+var ErrNotFound = errors.New("не найдено")
+
+// ErrDigestMismatch оборачивается в ошибки, если Docker-Content-Digest не совпадает
+// с содержимым манифеста: манифест поврежден или переписан прокси
+var ErrDigestMismatch = errors.New("Docker-Content-Digest не совпадает с содержимым манифеста")
+
+// Типы манифестов, которые клиент принимает при копировании образов
+const manifestAcceptAll = "application/vnd.docker.distribution.manifest.v2+json, " +
+	"application/vnd.docker.distribution.manifest.list.v2+json, " +
+	"application/vnd.oci.image.manifest.v1+json, " +
+	"application/vnd.oci.image.index.v1+json, " +
+	"application/vnd.docker.distribution.manifest.v1+prettyjws"
+
+// NewClient создает новый клиент для работы с Registry
+func NewClient(baseURL, username, password string) *Client {
+	return &Client{
+		BaseURL:  strings.TrimSuffix(baseURL, "/"),
+		Username: username,
+		Password: password,
+		Client:   &http.Client{Timeout: 30 * time.Second},
+		Configs:  NewConfigCache(),
+	}
+}
+
+// makeRequest выполняет HTTP запрос с аутентификацией
+func (rc *Client) makeRequest(ctx context.Context, method, url string) (*http.Response, error) {
+	req, err := rc.newRequest(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json")
+
+	return rc.Client.Do(req)
+}
+
+// newRequest создает HTTP запрос с аутентификацией
+func (rc *Client) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if rc.Username != "" && rc.Password != "" {
+		req.SetBasicAuth(rc.Username, rc.Password)
+	}
+
+	return req, nil
+}
+
+// ListTags получает список тегов для репозитория
+func (rc *Client) ListTags(ctx context.Context, repository string) ([]string, error) {
+	url := fmt.Sprintf("%s/v2/%s/tags/list", rc.BaseURL, repository)
+	resp, err := rc.makeRequest(ctx, "GET", url)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении тегов для %s: %v", repository, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("получен статус %d при запросе тегов для %s", resp.StatusCode, repository)
+	}
+
+	var tagsResp tagsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tagsResp); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования тегов: %v", err)
+	}
+
+	return tagsResp.Tags, nil
+}
+
+// ResolveDigest получает digest манифеста по тегу. Если заголовку Docker-Content-Digest
+// не доверяют, манифест скачивается и digest сверяется с его содержимым
+func (rc *Client) ResolveDigest(ctx context.Context, repository, tag string) (string, error) {
+	if !rc.TrustDigestHeader {
+		_, _, digest, err := rc.GetManifest(ctx, repository, tag)
+		return digest, err
+	}
+
+	url := fmt.Sprintf("%s/v2/%s/manifests/%s", rc.BaseURL, repository, tag)
+	req, err := rc.newRequest(ctx, "HEAD", url, nil)
+	if err != nil {
+		return "", err
+	}
+	// Все типы манифестов, чтобы для образа с несколькими платформами registry вернул
+	// digest index, а не манифеста платформы по умолчанию
+	req.Header.Set("Accept", manifestAcceptAll)
+	resp, err := rc.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ошибка при получении манифеста для %s:%s: %v", repository, tag, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("получен статус %d при запросе манифеста для %s:%s", resp.StatusCode, repository, tag)
+	}
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("digest не найден для %s:%s", repository, tag)
+	}
+	return digest, nil
+}
+
+// ResolveTag получает digest тега и метаданные образа, скачивая манифест один раз. С
+// TrustDigestHeader digest запрашивается запросом HEAD, а манифест скачивается по digest,
+// только если нужны метаданные, например их нет в кэше
+func (rc *Client) ResolveTag(ctx context.Context, repository, tag string, wantMeta func(digest string) bool) (TagDetails, error) {
+	if rc.TrustDigestHeader {
+		digest, err := rc.ResolveDigest(ctx, repository, tag)
+		if err != nil {
+			return TagDetails{}, err
+		}
+		details := TagDetails{Digest: digest}
+		if wantMeta != nil && wantMeta(digest) {
+			details.Meta, details.MetaErr = rc.imageMeta(ctx, repository, tag, digest)
+		}
+		return details, nil
+	}
+
+	body, _, digest, err := rc.GetManifest(ctx, repository, tag)
+	if err != nil {
+		return TagDetails{}, err
+	}
+	details := TagDetails{Digest: digest}
+	if wantMeta != nil && wantMeta(digest) {
+		details.Meta, details.MetaErr = rc.manifestMeta(ctx, repository, tag, body)
+	}
+	return details, nil
+}
+
+// checkManifestDigest сверяет digest из заголовка ответа с sha256 манифеста. Digest
+// подписанного манифеста schema1 вычисляется по содержимому без подписи
+func (rc *Client) checkManifestDigest(body []byte, mediaType, digest string) error {
+	if rc.TrustDigestHeader || digest == "" {
+		return nil
+	}
+	content, err := manifestContent(body, mediaType)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDigestMismatch, err)
+	}
+	if err := verifyDigest(content, digest); err != nil {
+		return fmt.Errorf("%w: %v", ErrDigestMismatch, err)
+	}
+	return nil
+}
+
+// GetImageCreated получает время создания образа из манифеста
+func (rc *Client) GetImageCreated(ctx context.Context, repository, tag string) (time.Time, error) {
+	meta, err := rc.GetImageMeta(ctx, repository, tag)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return meta.Created, nil
+}
+
+// GetImageMeta получает время создания, размер, метки и аннотации образа из манифеста
+func (rc *Client) GetImageMeta(ctx context.Context, repository, tag string) (ImageMeta, error) {
+	return rc.imageMeta(ctx, repository, tag, tag)
+}
+
+// imageMeta скачивает манифест по тегу или digest и получает по нему метаданные образа
+func (rc *Client) imageMeta(ctx context.Context, repository, tag, reference string) (ImageMeta, error) {
+	body, _, _, err := rc.GetManifest(ctx, repository, reference)
+	if err != nil {
+		return ImageMeta{}, err
+	}
+	return rc.manifestMeta(ctx, repository, tag, body)
+}
+
+// manifestMeta получает метаданные образа по манифесту любого типа: schema1 содержит их
+// в истории, для образа с несколькими платформами запрашиваются манифесты платформ, для
+// остальных - конфигурация образа
+func (rc *Client) manifestMeta(ctx context.Context, repository, tag string, body []byte) (ImageMeta, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return ImageMeta{}, fmt.Errorf("ошибка разбора манифеста %s:%s: %v", repository, tag, err)
+	}
+
+	switch {
+	case manifest.SchemaVersion == 1:
+		var manifestV1 manifestV1
+		if err := json.Unmarshal(body, &manifestV1); err != nil {
+			return ImageMeta{}, fmt.Errorf("ошибка разбора манифеста schema1 %s:%s: %v", repository, tag, err)
+		}
+		meta, err := manifestV1.meta()
+		if err != nil {
+			return ImageMeta{}, fmt.Errorf("манифест %s:%s: %v", repository, tag, err)
+		}
+		return meta, nil
+	case len(manifest.Manifests) > 0:
+		return rc.indexMeta(ctx, repository, tag, manifest)
+	default:
+		return rc.configMeta(ctx, repository, tag, manifest)
+	}
+}
+
+// configMeta получает метаданные образа из конфигурации, на которую ссылается манифест
+func (rc *Client) configMeta(ctx context.Context, repository, tag string, manifestV2 Manifest) (ImageMeta, error) {
+	if manifestV2.Config.Digest == "" {
+		return ImageMeta{}, fmt.Errorf("манифест %s:%s не содержит ссылки на конфигурацию образа", repository, tag)
+	}
+
+	fetch := func() (ImageConfig, error) {
+		return rc.fetchConfig(ctx, repository, tag, manifestV2.Config.Digest)
+	}
+	var config ImageConfig
+	var err error
+	if rc.Configs != nil {
+		config, err = rc.Configs.get(manifestV2.Config.Digest, fetch)
+	} else {
+		config, err = fetch()
+	}
+	if err != nil {
+		return ImageMeta{}, err
+	}
+
+	meta := ImageMeta{Created: config.Created, Labels: config.Config.Labels, Annotations: manifestV2.Annotations, Blobs: manifestV2.Blobs()}
+	if config.OS != "" && config.Architecture != "" {
+		meta.Platforms = []Platform{{OS: config.OS, Architecture: config.Architecture, Variant: config.Variant}}
+	}
+	for _, blob := range manifestV2.Blobs() {
+		meta.Size += blob.Size
+	}
+
+	return meta, nil
+}
+
+// fetchConfig скачивает и разбирает конфигурацию образа
+func (rc *Client) fetchConfig(ctx context.Context, repository, tag, digest string) (ImageConfig, error) {
+	configURL := fmt.Sprintf("%s/v2/%s/blobs/%s", rc.BaseURL, repository, digest)
+	configResp, err := rc.makeRequest(ctx, "GET", configURL)
+	if err != nil {
+		return ImageConfig{}, fmt.Errorf("ошибка при получении конфигурации %s:%s: %v", repository, tag, err)
+	}
+	defer configResp.Body.Close()
+
+	if configResp.StatusCode != http.StatusOK {
+		return ImageConfig{}, fmt.Errorf("получен статус %d при запросе конфигурации %s:%s", configResp.StatusCode, repository, tag)
+	}
+
+	var config ImageConfig
+	if err := json.NewDecoder(configResp.Body).Decode(&config); err != nil {
+		return ImageConfig{}, fmt.Errorf("ошибка декодирования конфигурации %s:%s: %v", repository, tag, err)
+	}
+	return config, nil
+}
+
+// Delete удаляет манифест по digest
+func (rc *Client) Delete(ctx context.Context, repository, digest string) error {
+	return rc.deleteManifest(ctx, repository, digest)
+}
+
+// DeleteTag удаляет ссылку тега. Стандартный registry такой запрос не поддерживает,
+// но некоторые реализации (Nexus, прокси) разрешают удаление только по тегу
+func (rc *Client) DeleteTag(ctx context.Context, repository, tag string) error {
+	return rc.deleteManifest(ctx, repository, tag)
+}
+
+// deleteManifest отправляет DELETE манифеста по digest или тегу
+func (rc *Client) deleteManifest(ctx context.Context, repository, reference string) error {
+	url := fmt.Sprintf("%s/v2/%s/manifests/%s", rc.BaseURL, repository, reference)
+
+	req, err := rc.newRequest(ctx, "DELETE", url, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка создания DELETE запроса: %v", err)
+	}
+
+	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json")
+
+	resp, err := rc.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка при удалении манифеста %s: %v", reference, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusAccepted || resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	// Читаем тело ответа для получения детальной информации об ошибке
+	body, _ := io.ReadAll(resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusMethodNotAllowed: // 405
+		return ErrDeleteUnsupported
+	case http.StatusBadRequest: // 400
+		return fmt.Errorf("%w: %s", ErrDeleteRejected, string(body))
+	case http.StatusNotFound: // 404
+		return fmt.Errorf("манифест не найден (статус 404): %s", string(body))
+	case http.StatusUnauthorized: // 401
+		return fmt.Errorf("ошибка авторизации (статус 401): %s", string(body))
+	case http.StatusForbidden: // 403
+		return fmt.Errorf("доступ запрещен (статус 403): %s", string(body))
+	default:
+		return fmt.Errorf("получен статус %d при удалении манифеста: %s", resp.StatusCode, string(body))
+	}
+}
+
+// GetManifest получает манифест в исходном виде вместе с его типом и digest
+func (rc *Client) GetManifest(ctx context.Context, repository, reference string) ([]byte, string, string, error) {
+	url := fmt.Sprintf("%s/v2/%s/manifests/%s", rc.BaseURL, repository, reference)
+	req, err := rc.newRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, "", "", err
+	}
+	req.Header.Set("Accept", manifestAcceptAll)
+
+	resp, err := rc.Client.Do(req)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("ошибка при получении манифеста %s@%s: %v", repository, reference, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", "", fmt.Errorf("манифест %s@%s: %w", repository, reference, ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", "", fmt.Errorf("получен статус %d при запросе манифеста %s@%s", resp.StatusCode, repository, reference)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("ошибка чтения манифеста %s@%s: %v", repository, reference, err)
+	}
+
+	mediaType, digest := resp.Header.Get("Content-Type"), resp.Header.Get("Docker-Content-Digest")
+	// Старые registry не возвращают digest для манифестов schema1: он вычисляется
+	// так же, как это делает registry, и по нему манифест можно удалить
+	if digest == "" {
+		if digest, err = manifestDigest(body, mediaType); err != nil {
+			return nil, "", "", fmt.Errorf("digest не найден для %s@%s: %v", repository, reference, err)
+		}
+		return body, mediaType, digest, nil
+	}
+	if err := rc.checkManifestDigest(body, mediaType, digest); err != nil {
+		return nil, "", "", fmt.Errorf("манифест %s@%s: %w", repository, reference, err)
+	}
+	return body, mediaType, digest, nil
+}
+
+// ResolveManifest получает digest манифеста с поддержкой всех типов манифестов
+func (rc *Client) ResolveManifest(ctx context.Context, repository, reference string) (string, error) {
+	url := fmt.Sprintf("%s/v2/%s/manifests/%s", rc.BaseURL, repository, reference)
+	req, err := rc.newRequest(ctx, "HEAD", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", manifestAcceptAll)
+
+	resp, err := rc.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ошибка при проверке манифеста %s@%s: %v", repository, reference, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("получен статус %d при проверке манифеста %s@%s", resp.StatusCode, repository, reference)
+	}
+
+	return resp.Header.Get("Docker-Content-Digest"), nil
+}
+
+// ManifestModified получает время изменения манифеста из заголовка Last-Modified ответа
+// на запрос HEAD. Docker Registry не всегда возвращает этот заголовок для манифестов
+func (rc *Client) ManifestModified(ctx context.Context, repository, reference string) (time.Time, error) {
+	url := fmt.Sprintf("%s/v2/%s/manifests/%s", rc.BaseURL, repository, reference)
+	req, err := rc.newRequest(ctx, "HEAD", url, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Accept", manifestAcceptAll)
+
+	resp, err := rc.Client.Do(req)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ошибка при проверке манифеста %s@%s: %v", repository, reference, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("получен статус %d при проверке манифеста %s@%s", resp.StatusCode, repository, reference)
+	}
+	value := resp.Header.Get("Last-Modified")
+	if value == "" {
+		return time.Time{}, fmt.Errorf("registry не вернул Last-Modified для манифеста %s@%s", repository, reference)
+	}
+	modified, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("некорректный Last-Modified %q манифеста %s@%s", value, repository, reference)
+	}
+	return modified, nil
+}
+
+// ManifestExists проверяет запросом HEAD, отдает ли registry манифест по digest или тегу
+func (rc *Client) ManifestExists(ctx context.Context, repository, reference string) (bool, error) {
+	url := fmt.Sprintf("%s/v2/%s/manifests/%s", rc.BaseURL, repository, reference)
+	req, err := rc.newRequest(ctx, "HEAD", url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", manifestAcceptAll)
+
+	resp, err := rc.Client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("ошибка при проверке манифеста %s@%s: %v", repository, reference, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("получен статус %d при проверке манифеста %s@%s", resp.StatusCode, repository, reference)
+	}
+}
+
+// BlobExists проверяет наличие blob в репозитории
+func (rc *Client) BlobExists(ctx context.Context, repository, digest string) (bool, error) {
+	url := fmt.Sprintf("%s/v2/%s/blobs/%s", rc.BaseURL, repository, digest)
+	resp, err := rc.makeRequest(ctx, "HEAD", url)
+	if err != nil {
+		return false, fmt.Errorf("ошибка при проверке blob %s: %v", digest, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("получен статус %d при проверке blob %s", resp.StatusCode, digest)
+	}
+}
+
+// GetBlob открывает поток чтения blob, вызывающий обязан закрыть его
+func (rc *Client) GetBlob(ctx context.Context, repository, digest string) (io.ReadCloser, int64, error) {
+	url := fmt.Sprintf("%s/v2/%s/blobs/%s", rc.BaseURL, repository, digest)
+	resp, err := rc.makeRequest(ctx, "GET", url)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка при получении blob %s: %v", digest, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("получен статус %d при получении blob %s", resp.StatusCode, digest)
+	}
+
+	return resp.Body, resp.ContentLength, nil
+}
@@ -0,0 +1,143 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// configCacheVersion версия формата файла кэша конфигураций. Кэш другой версии не
+// загружается: в его записях может не быть полей, добавленных в ImageConfig позже
+const configCacheVersion = 1
+
+// configCacheFile содержимое файла кэша конфигураций
+type configCacheFile struct {
+	Version int                    `json:"version"`
+	Entries map[string]ImageConfig `json:"entries"`
+}
+
+// ConfigCache кэш разобранных конфигураций образов по digest. Одна конфигурация
+// встречается во многих репозиториях (общие базовые образы, один образ под разными
+// именами), а ее содержимое для digest не меняется, поэтому за запуск каждая
+// конфигурация скачивается не больше одного раза. Если задан Path, кэш сохраняется
+// в файл и используется следующими запусками
+type ConfigCache struct {
+	Path string
+
+	mu      sync.Mutex
+	entries map[string]ImageConfig
+	// pending конфигурации, которые скачиваются сейчас: параллельные запросы того же
+	// digest ждут результата вместо повторного скачивания
+	pending map[string]*configFetch
+	dirty   bool
+}
+
+// configFetch скачивание одной конфигурации
+type configFetch struct {
+	done   chan struct{}
+	config ImageConfig
+	err    error
+}
+
+// NewConfigCache создает кэш конфигураций в памяти
+func NewConfigCache() *ConfigCache {
+	return &ConfigCache{entries: make(map[string]ImageConfig), pending: make(map[string]*configFetch)}
+}
+
+// LoadConfigCache загружает кэш конфигураций из файла, отсутствующий файл означает пустой кэш
+func LoadConfigCache(path string) (*ConfigCache, error) {
+	cache := NewConfigCache()
+	cache.Path = path
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения кэша конфигураций %s: %v", path, err)
+	}
+
+	var file configCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("ошибка разбора кэша конфигураций %s: %v", path, err)
+	}
+	// Кэш прежней версии заполняется заново
+	if file.Version == configCacheVersion && file.Entries != nil {
+		cache.entries = file.Entries
+	}
+	return cache, nil
+}
+
+// Len возвращает количество конфигураций в кэше
+func (c *ConfigCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// get возвращает конфигурацию из кэша или получает ее через fetch. Ошибки не
+// кэшируются: следующий запрос того же digest скачает конфигурацию заново
+func (c *ConfigCache) get(digest string, fetch func() (ImageConfig, error)) (ImageConfig, error) {
+	c.mu.Lock()
+	if config, ok := c.entries[digest]; ok {
+		c.mu.Unlock()
+		return config, nil
+	}
+	if pending, ok := c.pending[digest]; ok {
+		c.mu.Unlock()
+		<-pending.done
+		return pending.config, pending.err
+	}
+	pending := &configFetch{done: make(chan struct{})}
+	c.pending[digest] = pending
+	c.mu.Unlock()
+
+	pending.config, pending.err = fetch()
+
+	c.mu.Lock()
+	delete(c.pending, digest)
+	if pending.err == nil {
+		c.entries[digest] = pending.config
+		c.dirty = true
+	}
+	c.mu.Unlock()
+	close(pending.done)
+
+	return pending.config, pending.err
+}
+
+// Save атомарно записывает кэш в файл, если он изменился. Кэш без Path не сохраняется
+func (c *ConfigCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Path == "" || !c.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(configCacheFile{Version: configCacheVersion, Entries: c.entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.Path), filepath.Base(c.Path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("ошибка записи %s: %v", c.Path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка записи %s: %v", c.Path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка записи %s: %v", c.Path, err)
+	}
+	if err := os.Rename(tmp.Name(), c.Path); err != nil {
+		return fmt.Errorf("ошибка записи %s: %v", c.Path, err)
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,124 @@
+package registry
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redacted значение, которым заменяются учетные данные в трассировке
+const redacted = "***"
+
+// secretWords части имен заголовков и параметров запроса, значения которых скрываются
+var secretWords = []string{"auth", "token", "secret", "password", "key", "cookie", "signature", "credential"}
+
+// DebugTransport выводит каждый запрос к registry: метод, URL, статус и время ответа,
+// а с DumpHeaders и заголовки запроса и ответа, примерно как curl -v. Учетные данные в
+// заголовках и параметрах URL заменяются на ***
+type DebugTransport struct {
+	Next        http.RoundTripper
+	Log         io.Writer
+	DumpHeaders bool
+
+	mu sync.Mutex
+}
+
+// RoundTrip выполняет запрос и выводит его трассировку
+func (t *DebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	started := time.Now()
+	resp, err := t.Next.RoundTrip(req)
+	latency := time.Since(started).Round(time.Microsecond)
+
+	// Трассировка запроса собирается целиком, чтобы параллельные запросы не перемешивались
+	var out bytes.Buffer
+	if err != nil {
+		fmt.Fprintf(&out, "http: %s %s -> ошибка за %s: %v\n", req.Method, redactURL(req.URL), latency, err)
+	} else {
+		fmt.Fprintf(&out, "http: %s %s -> %d за %s\n", req.Method, redactURL(req.URL), resp.StatusCode, latency)
+	}
+	if t.DumpHeaders {
+		writeHeaders(&out, "> ", req.Header)
+		if resp != nil {
+			writeHeaders(&out, "< ", resp.Header)
+		}
+	}
+
+	t.mu.Lock()
+	t.Log.Write(out.Bytes())
+	t.mu.Unlock()
+	return resp, err
+}
+
+// writeHeaders выводит заголовки в алфавитном порядке со скрытыми учетными данными
+func writeHeaders(out io.Writer, prefix string, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			fmt.Fprintf(out, "  %s%s: %s\n", prefix, name, redactHeader(name, value))
+		}
+	}
+}
+
+// redactHeader скрывает значение заголовка с учетными данными. Для Authorization
+// сохраняется схема, например Basic или Bearer, а ссылки Location выводятся без секретов
+func redactHeader(name, value string) string {
+	switch lower := strings.ToLower(name); {
+	case lower == "www-authenticate":
+		// Вызов авторизации не содержит секретов и нужен для разбора ошибок 401
+		return value
+	case lower == "location":
+		if u, err := url.Parse(value); err == nil {
+			return redactURL(u)
+		}
+		return redacted
+	case lower == "authorization" || lower == "proxy-authorization":
+		if scheme, _, ok := strings.Cut(value, " "); ok {
+			return scheme + " " + redacted
+		}
+		return redacted
+	case secretName(lower):
+		return redacted
+	}
+	return value
+}
+
+// redactURL возвращает URL без пароля и значений секретных параметров, например
+// подписей временных ссылок на blob
+func redactURL(u *url.URL) string {
+	clean := *u
+	if clean.User != nil {
+		clean.User = url.User(clean.User.Username())
+	}
+	query := clean.Query()
+	changed := false
+	for name := range query {
+		if secretName(strings.ToLower(name)) {
+			query.Set(name, redacted)
+			changed = true
+		}
+	}
+	if changed {
+		clean.RawQuery = query.Encode()
+	}
+	return clean.String()
+}
+
+// secretName сообщает, может ли заголовок или параметр с таким именем содержать учетные данные
+func secretName(lower string) bool {
+	for _, word := range secretWords {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// etagCacheVersion версия формата файла кэша ETag
+const etagCacheVersion = 1
+
+// etagCacheTTL срок, после которого неиспользуемая запись удаляется из кэша ETag:
+// теги удаляются, и записи о них не должны копиться бесконечно
+const etagCacheTTL = 30 * 24 * time.Hour
+
+// etagHeaders заголовки ответа, которые сохраняются вместе с телом и подставляются
+// в ответ, восстановленный из кэша
+var etagHeaders = []string{"Content-Type", "Docker-Content-Digest"}
+
+// etagCacheFile содержимое файла кэша ETag
+type etagCacheFile struct {
+	Version int                   `json:"version"`
+	Entries map[string]*etagEntry `json:"entries"`
+}
+
+// etagEntry сохраненный ответ
+type etagEntry struct {
+	ETag   string            `json:"etag"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   []byte            `json:"body"`
+	Used   time.Time         `json:"used"`
+}
+
+// ETagCache хранит ответы на запросы списков тегов и манифестов по тегу вместе с их
+// ETag. Повторный запрос отправляется с If-None-Match, и если ресурс не изменился,
+// registry отвечает 304 без тела, а ответ восстанавливается из кэша. Манифесты по
+// digest не меняются и не сохраняются. Если задан Path, кэш сохраняется в файл
+type ETagCache struct {
+	Path string
+
+	mu      sync.Mutex
+	entries map[string]*etagEntry
+	dirty   bool
+	// requests и hits количество условных запросов и ответов 304 за запуск
+	requests int
+	hits     int
+}
+
+// NewETagCache создает кэш ETag в памяти
+func NewETagCache() *ETagCache {
+	return &ETagCache{entries: make(map[string]*etagEntry)}
+}
+
+// LoadETagCache загружает кэш ETag из файла, отсутствующий файл означает пустой кэш
+func LoadETagCache(path string) (*ETagCache, error) {
+	cache := NewETagCache()
+	cache.Path = path
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения кэша ETag %s: %v", path, err)
+	}
+
+	var file etagCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("ошибка разбора кэша ETag %s: %v", path, err)
+	}
+	if file.Version == etagCacheVersion && file.Entries != nil {
+		cache.entries = file.Entries
+	}
+	return cache, nil
+}
+
+// Len возвращает количество записей в кэше
+func (c *ETagCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Stats возвращает количество условных запросов и ответов 304 за запуск
+func (c *ETagCache) Stats() (requests, notModified int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests, c.hits
+}
+
+// get возвращает сохраненный ответ и отмечает его использование
+func (c *ETagCache) get(key string) (*etagEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if ok {
+		c.requests++
+		entry.Used = time.Now()
+		c.dirty = true
+	}
+	return entry, ok
+}
+
+// put сохраняет ответ
+func (c *ETagCache) put(key string, entry *etagEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.Used = time.Now()
+	c.entries[key] = entry
+	c.dirty = true
+}
+
+// notModified учитывает ответ 304
+func (c *ETagCache) notModified() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hits++
+}
+
+// Save атомарно записывает кэш в файл, если он изменился, удаляя записи, которые
+// не использовались дольше etagCacheTTL. Кэш без Path не сохраняется
+func (c *ETagCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Path == "" || !c.dirty {
+		return nil
+	}
+
+	for key, entry := range c.entries {
+		if time.Since(entry.Used) > etagCacheTTL {
+			delete(c.entries, key)
+		}
+	}
+
+	data, err := json.Marshal(etagCacheFile{Version: etagCacheVersion, Entries: c.entries})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.Path), filepath.Base(c.Path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("ошибка записи %s: %v", c.Path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка записи %s: %v", c.Path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка записи %s: %v", c.Path, err)
+	}
+	if err := os.Rename(tmp.Name(), c.Path); err != nil {
+		return fmt.Errorf("ошибка записи %s: %v", c.Path, err)
+	}
+	c.dirty = false
+	return nil
+}
+
+// etagTransport добавляет к запросам списков тегов и манифестов по тегу If-None-Match
+// и подменяет ответы 304 сохраненными ответами
+type etagTransport struct {
+	next  http.RoundTripper
+	cache *ETagCache
+}
+
+// RoundTrip выполняет запрос, используя кэш ETag
+func (t *etagTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || !etagCacheable(req.URL.Path) {
+		return t.next.RoundTrip(req)
+	}
+
+	// Манифест одного тега отличается для разных Accept
+	key := req.URL.String() + " " + req.Header.Get("Accept")
+	entry, cached := t.cache.get(key)
+	if cached {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		t.cache.notModified()
+		resp.StatusCode, resp.Status = http.StatusOK, "200 OK"
+		for name, value := range entry.Header {
+			resp.Header.Set(name, value)
+		}
+		resp.Header.Set("Content-Length", fmt.Sprint(len(entry.Body)))
+		resp.ContentLength = int64(len(entry.Body))
+		resp.Body = io.NopCloser(bytes.NewReader(entry.Body))
+		return resp, nil
+	}
+
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	stored := &etagEntry{ETag: etag, Header: make(map[string]string), Body: body}
+	for _, name := range etagHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			stored.Header[name] = value
+		}
+	}
+	t.cache.put(key, stored)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// etagCacheable сообщает, сохраняются ли ответы на запрос: списки тегов и манифесты по
+// тегу. Манифесты по digest неизменны, их метаданные хранит кэш метаданных
+func etagCacheable(path string) bool {
+	if strings.HasSuffix(path, "/tags/list") {
+		return true
+	}
+	i := strings.LastIndex(path, "/manifests/")
+	return i >= 0 && !strings.Contains(path[i:], ":")
+}
+
+// UseETagCache включает условные запросы с If-None-Match для списков тегов и
+// манифестов по тегу
+func (rc *Client) UseETagCache(cache *ETagCache) {
+	rc.ETags = cache
+	next := rc.Client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	rc.Client.Transport = &etagTransport{next: next, cache: cache}
+}
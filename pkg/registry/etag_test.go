@@ -0,0 +1,107 @@
+package registry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestETagCacheable(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/v2/app/tags/list", want: true},
+		{path: "/v2/team/app/manifests/v1", want: true},
+		{path: "/v2/app/manifests/sha256:0123", want: false},
+		{path: "/v2/_catalog", want: false},
+		{path: "/v2/app/blobs/sha256:0123", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := etagCacheable(tt.path); got != tt.want {
+			t.Errorf("etagCacheable(%q) = %v, ожидалось %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestClientETagCache(t *testing.T) {
+	var requests, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"app","tags":["v1","v2"]}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "etags.json")
+	cache, err := LoadETagCache(path)
+	if err != nil {
+		t.Fatalf("LoadETagCache: %v", err)
+	}
+	client := NewClient(server.URL, "", "")
+	client.UseETagCache(cache)
+
+	for i := 0; i < 2; i++ {
+		tags, err := client.ListTags(context.Background(), "app")
+		if err != nil {
+			t.Fatalf("ListTags: %v", err)
+		}
+		if want := []string{"v1", "v2"}; !reflect.DeepEqual(tags, want) {
+			t.Errorf("запрос %d: теги %v, ожидалось %v", i+1, tags, want)
+		}
+	}
+	if requests != 2 || notModified != 1 {
+		t.Errorf("запросов %d, из них с ответом 304 %d; ожидалось 2 и 1", requests, notModified)
+	}
+	if conditional, hits := cache.Stats(); conditional != 1 || hits != 1 {
+		t.Errorf("статистика кэша %d/%d, ожидалось 1/1", conditional, hits)
+	}
+
+	// Сохраненный кэш используется следующим запуском
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := LoadETagCache(path)
+	if err != nil {
+		t.Fatalf("LoadETagCache: %v", err)
+	}
+	client = NewClient(server.URL, "", "")
+	client.UseETagCache(loaded)
+	if _, err := client.ListTags(context.Background(), "app"); err != nil {
+		t.Fatalf("ListTags: %v", err)
+	}
+	if notModified != 2 {
+		t.Errorf("после загрузки кэша ответов 304 %d, ожидалось 2", notModified)
+	}
+}
+
+func TestETagCacheSaveExpires(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "etags.json")
+	cache := NewETagCache()
+	cache.Path = path
+	cache.put("fresh", &etagEntry{ETag: `"a"`})
+	cache.put("stale", &etagEntry{ETag: `"b"`})
+	cache.entries["stale"].Used = time.Now().Add(-2 * etagCacheTTL)
+
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := LoadETagCache(path)
+	if err != nil {
+		t.Fatalf("LoadETagCache: %v", err)
+	}
+	if _, ok := loaded.entries["stale"]; ok || loaded.Len() != 1 {
+		t.Errorf("после сохранения записей %d, устаревшая запись должна быть удалена", loaded.Len())
+	}
+}
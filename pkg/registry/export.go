@@ -0,0 +1,209 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ociIndex структура index.json в OCI image layout
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+// ociDescriptor дескриптор манифеста в index.json с аннотациями
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Exporter сохраняет образы в tar-архивы формата OCI image layout
+type Exporter struct {
+	Client *Client
+	Dir    string
+}
+
+// ExportImage выгружает образ в <dir>/<repository>_<tag>.tar и возвращает путь к файлу.
+// Содержимое каждого blob проверяется по digest, частично записанный файл удаляется
+func (e *Exporter) ExportImage(ctx context.Context, img ImageInfo) (string, error) {
+	if err := os.MkdirAll(e.Dir, 0o755); err != nil {
+		return "", fmt.Errorf("ошибка создания каталога экспорта: %v", err)
+	}
+
+	name := strings.ReplaceAll(img.Repository, "/", "_") + "_" + img.Tag + ".tar"
+	path := filepath.Join(e.Dir, name)
+
+	file, err := os.CreateTemp(e.Dir, name+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания файла экспорта: %v", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	tw := tar.NewWriter(file)
+	written := make(map[string]bool)
+
+	raw, mediaType, err := e.writeManifest(ctx, tw, written, img.Repository, img.Digest)
+	if err != nil {
+		return "", err
+	}
+
+	index := ociIndex{
+		SchemaVersion: 2,
+		MediaType:     "application/vnd.oci.image.index.v1+json",
+		Manifests: []ociDescriptor{{
+			MediaType: mediaType,
+			Digest:    img.Digest,
+			Size:      int64(len(raw)),
+			Annotations: map[string]string{
+				"org.opencontainers.image.ref.name": img.Tag,
+				"io.containerd.image.name":          img.Repository + ":" + img.Tag,
+			},
+		}},
+	}
+	indexJSON, err := json.Marshal(index)
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeTarFile(tw, "oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`)); err != nil {
+		return "", err
+	}
+	if err := writeTarFile(tw, "index.json", indexJSON); err != nil {
+		return "", err
+	}
+	if err := tw.Close(); err != nil {
+		return "", fmt.Errorf("ошибка записи архива %s: %v", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("ошибка записи архива %s: %v", path, err)
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return "", fmt.Errorf("ошибка сохранения архива %s: %v", path, err)
+	}
+
+	return path, nil
+}
+
+// writeManifest записывает манифест и все его blob (рекурсивно для manifest list) в архив
+func (e *Exporter) writeManifest(ctx context.Context, tw *tar.Writer, written map[string]bool, repository, digest string) ([]byte, string, error) {
+	raw, mediaType, _, err := e.Client.GetManifest(ctx, repository, digest)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := verifyDigest(raw, digest); err != nil {
+		return nil, "", fmt.Errorf("манифест %s@%s: %v", repository, digest, err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, "", fmt.Errorf("ошибка разбора манифеста %s@%s: %v", repository, digest, err)
+	}
+
+	for _, child := range manifest.Manifests {
+		if _, _, err := e.writeManifest(ctx, tw, written, repository, child.Digest); err != nil {
+			return nil, "", err
+		}
+	}
+
+	for _, blob := range manifest.Blobs() {
+		if written[blob.Digest] {
+			continue
+		}
+		if err := e.writeBlob(ctx, tw, repository, blob); err != nil {
+			return nil, "", err
+		}
+		written[blob.Digest] = true
+	}
+
+	if !written[digest] {
+		if err := writeTarFile(tw, blobPath(digest), raw); err != nil {
+			return nil, "", err
+		}
+		written[digest] = true
+	}
+
+	return raw, mediaType, nil
+}
+
+// writeBlob скачивает blob из registry прямо в архив, проверяя его digest
+func (e *Exporter) writeBlob(ctx context.Context, tw *tar.Writer, repository string, blob Descriptor) error {
+	content, size, err := e.Client.GetBlob(ctx, repository, blob.Digest)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	if blob.Size > 0 {
+		size = blob.Size
+	}
+
+	// Размер нужен для заголовка tar; если он неизвестен, читаем blob в память
+	var reader io.Reader = content
+	if size < 0 {
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения blob %s: %v", blob.Digest, err)
+		}
+		reader = bytes.NewReader(data)
+		size = int64(len(data))
+	}
+
+	header := &tar.Header{Name: blobPath(blob.Digest), Mode: 0o644, Size: size, ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(tw, io.TeeReader(reader, hash)); err != nil {
+		return fmt.Errorf("ошибка записи blob %s: %v", blob.Digest, err)
+	}
+
+	if computed := "sha256:" + hex.EncodeToString(hash.Sum(nil)); computed != blob.Digest {
+		return fmt.Errorf("digest blob %s не совпадает с полученным содержимым (%s)", blob.Digest, computed)
+	}
+
+	return nil
+}
+
+// verifyDigest проверяет, что sha256 содержимого совпадает с ожидаемым digest
+func verifyDigest(content []byte, digest string) error {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return nil
+	}
+	sum := sha256.Sum256(content)
+	if computed := "sha256:" + hex.EncodeToString(sum[:]); computed != digest {
+		return fmt.Errorf("digest %s не совпадает с содержимым (%s)", digest, computed)
+	}
+	return nil
+}
+
+// blobPath возвращает путь blob внутри OCI image layout
+func blobPath(digest string) string {
+	return "blobs/" + strings.Replace(digest, ":", "/", 1)
+}
+
+// writeTarFile записывает небольшой файл в tar-архив
+func writeTarFile(tw *tar.Writer, name string, content []byte) error {
+	header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(content)
+	return err
+}
@@ -0,0 +1,145 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Способы определения времени создания образа с несколькими платформами
+const (
+	// IndexCreatedNewest время самой новой платформы
+	IndexCreatedNewest = "newest"
+	// IndexCreatedOldest время самой старой платформы
+	IndexCreatedOldest = "oldest"
+	// IndexCreatedAnnotation аннотация org.opencontainers.image.created манифеста index
+	IndexCreatedAnnotation = "annotation"
+)
+
+// AnnotationCreated аннотация OCI с временем создания образа
+const AnnotationCreated = "org.opencontainers.image.created"
+
+// Platform платформа манифеста в manifest list или OCI index
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// String возвращает платформу в виде os/architecture[/variant]
+func (p Platform) String() string {
+	if p.Variant != "" {
+		return p.OS + "/" + p.Architecture + "/" + p.Variant
+	}
+	return p.OS + "/" + p.Architecture
+}
+
+// ValidIndexCreated сообщает, допустим ли способ определения времени создания: newest,
+// oldest, annotation или платформа вида os/architecture[/variant]
+func ValidIndexCreated(strategy string) bool {
+	switch strategy {
+	case IndexCreatedNewest, IndexCreatedOldest, IndexCreatedAnnotation:
+		return true
+	}
+	return ValidPlatform(strategy)
+}
+
+// ValidPlatform сообщает, имеет ли платформа вид os/architecture[/variant]
+func ValidPlatform(platform string) bool {
+	parts := strings.Split(platform, "/")
+	return (len(parts) == 2 || len(parts) == 3) && parts[0] != "" && parts[1] != ""
+}
+
+// indexMeta получает метаданные образа с несколькими платформами. Размер и слои - все
+// платформы вместе, метки - первой платформы, время создания выбирается по IndexCreated
+func (rc *Client) indexMeta(ctx context.Context, repository, tag string, index Manifest) (ImageMeta, error) {
+	strategy := rc.IndexCreated
+	if strategy == "" {
+		strategy = IndexCreatedNewest
+	}
+
+	meta := ImageMeta{Annotations: index.Annotations}
+	seen := make(map[string]bool)
+	var platformFound bool
+	for _, child := range index.Manifests {
+		// Аттестации buildx хранятся как манифесты платформы unknown/unknown
+		if child.Platform != nil && child.Platform.OS == "unknown" {
+			continue
+		}
+		raw, _, _, err := rc.GetManifest(ctx, repository, child.Digest)
+		if err != nil {
+			return ImageMeta{}, err
+		}
+		var manifest Manifest
+		if err := json.Unmarshal(raw, &manifest); err != nil {
+			return ImageMeta{}, fmt.Errorf("ошибка разбора манифеста %s@%s: %v", repository, child.Digest, err)
+		}
+		childMeta, err := rc.configMeta(ctx, repository, tag, manifest)
+		if err != nil {
+			return ImageMeta{}, err
+		}
+
+		for _, blob := range childMeta.Blobs {
+			if !seen[blob.Digest] {
+				seen[blob.Digest] = true
+				meta.Size += blob.Size
+				meta.Blobs = append(meta.Blobs, blob)
+			}
+		}
+		if meta.Labels == nil {
+			meta.Labels = childMeta.Labels
+		}
+		if child.Platform != nil {
+			meta.Platforms = append(meta.Platforms, *child.Platform)
+		} else {
+			meta.Platforms = append(meta.Platforms, childMeta.Platforms...)
+		}
+
+		switch strategy {
+		case IndexCreatedNewest:
+			if childMeta.Created.After(meta.Created) {
+				meta.Created = childMeta.Created
+			}
+		case IndexCreatedOldest:
+			if meta.Created.IsZero() || childMeta.Created.Before(meta.Created) {
+				meta.Created = childMeta.Created
+			}
+		case IndexCreatedAnnotation:
+		default:
+			if child.Platform != nil && child.Platform.Matches(strategy) {
+				meta.Created, platformFound = childMeta.Created, true
+			}
+		}
+	}
+
+	switch strategy {
+	case IndexCreatedNewest, IndexCreatedOldest:
+	case IndexCreatedAnnotation:
+		value, ok := index.Annotations[AnnotationCreated]
+		if !ok {
+			return ImageMeta{}, fmt.Errorf("index %s:%s не содержит аннотации %s", repository, tag, AnnotationCreated)
+		}
+		created, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return ImageMeta{}, fmt.Errorf("некорректная аннотация %s index %s:%s: %v", AnnotationCreated, repository, tag, err)
+		}
+		meta.Created = created
+	default:
+		if !platformFound {
+			return ImageMeta{}, fmt.Errorf("index %s:%s не содержит платформы %s", repository, tag, strategy)
+		}
+	}
+	return meta, nil
+}
+
+// Matches сообщает, подходит ли платформа под os/architecture[/variant]; без variant
+// подходит платформа с любым variant
+func (p Platform) Matches(platform string) bool {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || p.OS != parts[0] || p.Architecture != parts[1] {
+		return false
+	}
+	return len(parts) == 2 || p.Variant == parts[2]
+}
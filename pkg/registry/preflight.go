@@ -0,0 +1,178 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Состояние удаления манифестов, определенное предварительной проверкой
+const (
+	// DeleteEnabled registry принимает запросы удаления (storage.delete.enabled: true)
+	DeleteEnabled = "enabled"
+	// DeleteDisabled registry отвечает 405: удаление выключено в конфигурации
+	DeleteDisabled = "disabled"
+	// DeleteDenied учетная запись не имеет права удаления (401 или 403)
+	DeleteDenied = "denied"
+	// DeleteUnknown ответ не позволяет определить, поддерживается ли удаление
+	DeleteUnknown = "unknown"
+)
+
+// probeDigest digest, которого нет ни в одном registry: его удаление ничего не удаляет,
+// а по статусу ответа видно, обрабатывает ли registry запросы удаления
+const probeDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+
+// Capabilities результат предварительной проверки registry
+type Capabilities struct {
+	URL string `json:"url"`
+	// Reachable /v2/ ответил, Authorized - с учетными данными клиента без 401
+	Reachable  bool `json:"reachable"`
+	Authorized bool `json:"authorized"`
+	// APIVersion значение заголовка Docker-Distribution-API-Version
+	APIVersion string `json:"apiVersion"`
+	// ProbeRepository репозиторий, на котором проверялось удаление
+	ProbeRepository string `json:"probeRepository,omitempty"`
+	Delete          string `json:"delete"`
+	// DeleteStatus статус ответа на пробный запрос удаления
+	DeleteStatus int `json:"deleteStatus,omitempty"`
+	// Problems препятствия для очистки, Warnings - подозрительные, но не блокирующие ответы
+	Problems []string `json:"problems,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	// Repositories права учетной записи в каждом очищаемом репозитории, если проверялись
+	Repositories []RepositoryAccess `json:"repositories,omitempty"`
+}
+
+// OK сообщает, что очистка возможна
+func (c *Capabilities) OK() bool {
+	return len(c.Problems) == 0
+}
+
+// Preflight проверяет доступность /v2/, версию API и поддержку удаления. Удаление
+// проверяется запросом DELETE несуществующего манифеста в repository: registry с
+// выключенным удалением отвечает 405, с включенным - 404. Пустой repository - удаление
+// не проверяется. Отсутствие права удаления в repository - предупреждение: права
+// в каждом репозитории проверяет CheckAccess
+func (rc *Client) Preflight(ctx context.Context, repository string) *Capabilities {
+	caps := &Capabilities{URL: rc.BaseURL, Delete: DeleteUnknown}
+
+	resp, err := rc.makeRequest(ctx, "GET", rc.BaseURL+"/v2/")
+	if err != nil {
+		caps.Problems = append(caps.Problems, fmt.Sprintf("registry недоступен: %v", err))
+		return caps
+	}
+	resp.Body.Close()
+	caps.Reachable = true
+	caps.APIVersion = resp.Header.Get("Docker-Distribution-API-Version")
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		caps.Authorized = true
+	case http.StatusUnauthorized:
+		caps.Problems = append(caps.Problems, "учетные данные не приняты: /v2/ отвечает 401")
+		return caps
+	default:
+		caps.Problems = append(caps.Problems, fmt.Sprintf("/v2/ отвечает статусом %d", resp.StatusCode))
+		return caps
+	}
+	if caps.APIVersion == "" {
+		caps.Warnings = append(caps.Warnings, "нет заголовка Docker-Distribution-API-Version: возможно, это не Docker Registry или прокси удаляет заголовки")
+	} else if !strings.HasPrefix(caps.APIVersion, "registry/2.") {
+		caps.Warnings = append(caps.Warnings, fmt.Sprintf("неожиданная версия API %s", caps.APIVersion))
+	}
+
+	if repository == "" {
+		caps.Warnings = append(caps.Warnings, "нет репозитория для проверки удаления")
+		return caps
+	}
+	caps.ProbeRepository = repository
+
+	caps.Delete, caps.DeleteStatus, err = rc.probeDelete(ctx, repository)
+	switch {
+	case err != nil:
+		caps.Warnings = append(caps.Warnings, fmt.Sprintf("не удалось проверить удаление: %v", err))
+	case caps.Delete == DeleteDisabled && rc.TagDeleteFallback:
+		caps.Warnings = append(caps.Warnings, "удаление по digest отклонено (405), будет выполняться удаление по тегу")
+	case caps.Delete == DeleteDisabled:
+		caps.Problems = append(caps.Problems, "удаление выключено в registry (405): нужен storage.delete.enabled: true")
+	case caps.Delete == DeleteDenied:
+		caps.Warnings = append(caps.Warnings, fmt.Sprintf("нет права удаления в %s (%d)", repository, caps.DeleteStatus))
+	case caps.Delete == DeleteUnknown:
+		caps.Warnings = append(caps.Warnings, fmt.Sprintf("пробный запрос удаления вернул статус %d", caps.DeleteStatus))
+	}
+	return caps
+}
+
+// probeDelete отправляет DELETE несуществующего манифеста и определяет по статусу ответа,
+// поддерживается ли удаление в репозитории
+func (rc *Client) probeDelete(ctx context.Context, repository string) (string, int, error) {
+	url := fmt.Sprintf("%s/v2/%s/manifests/%s", rc.BaseURL, repository, probeDigest)
+	resp, err := rc.makeRequest(ctx, "DELETE", url)
+	if err != nil {
+		return DeleteUnknown, 0, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound, http.StatusAccepted, http.StatusOK:
+		return DeleteEnabled, resp.StatusCode, nil
+	case http.StatusMethodNotAllowed:
+		return DeleteDisabled, resp.StatusCode, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return DeleteDenied, resp.StatusCode, nil
+	default:
+		return DeleteUnknown, resp.StatusCode, nil
+	}
+}
+
+// RepositoryAccess права учетной записи в репозитории
+type RepositoryAccess struct {
+	Repository string `json:"repository"`
+	// ListStatus статус ответа на запрос списка тегов
+	ListStatus int    `json:"listStatus"`
+	Delete     string `json:"delete"`
+	// DeleteStatus статус ответа на пробный запрос удаления
+	DeleteStatus int      `json:"deleteStatus"`
+	Problems     []string `json:"problems,omitempty"`
+}
+
+// OK сообщает, что очистка репозитория возможна
+func (a RepositoryAccess) OK() bool {
+	return len(a.Problems) == 0
+}
+
+// CheckAccess проверяет, что учетная запись может получить список тегов репозитория
+// и удалять в нем манифесты. Registry с токенами выдает права на каждый репозиторий
+// отдельно, поэтому ответ /v2/ не гарантирует доступа к конкретному репозиторию
+func (rc *Client) CheckAccess(ctx context.Context, repository string) RepositoryAccess {
+	access := RepositoryAccess{Repository: repository, Delete: DeleteUnknown}
+
+	resp, err := rc.makeRequest(ctx, "GET", fmt.Sprintf("%s/v2/%s/tags/list?n=1", rc.BaseURL, repository))
+	if err != nil {
+		access.Problems = append(access.Problems, fmt.Sprintf("не удалось получить список тегов: %v", err))
+		return access
+	}
+	resp.Body.Close()
+	access.ListStatus = resp.StatusCode
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		access.Problems = append(access.Problems, fmt.Sprintf("нет права на список тегов (%d)", resp.StatusCode))
+	case http.StatusNotFound:
+		access.Problems = append(access.Problems, "репозиторий не найден (404)")
+	default:
+		access.Problems = append(access.Problems, fmt.Sprintf("список тегов отвечает статусом %d", resp.StatusCode))
+	}
+
+	access.Delete, access.DeleteStatus, err = rc.probeDelete(ctx, repository)
+	switch {
+	case err != nil:
+		access.Problems = append(access.Problems, fmt.Sprintf("не удалось проверить удаление: %v", err))
+	case access.Delete == DeleteDisabled && rc.TagDeleteFallback:
+	case access.Delete == DeleteDisabled:
+		access.Problems = append(access.Problems, "удаление выключено в registry (405)")
+	case access.Delete == DeleteDenied:
+		access.Problems = append(access.Problems, fmt.Sprintf("нет права удаления (%d)", access.DeleteStatus))
+	}
+	return access
+}
@@ -0,0 +1,111 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PutManifest загружает манифест под указанной ссылкой и возвращает его digest
+func (rc *Client) PutManifest(ctx context.Context, repository, reference, mediaType string, manifest []byte) (string, error) {
+	url := fmt.Sprintf("%s/v2/%s/manifests/%s", rc.BaseURL, repository, reference)
+	req, err := rc.newRequest(ctx, "PUT", url, bytes.NewReader(manifest))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mediaType)
+
+	resp, err := rc.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ошибка при загрузке манифеста %s:%s: %v", repository, reference, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("получен статус %d при загрузке манифеста %s:%s: %s", resp.StatusCode, repository, reference, string(body))
+	}
+
+	return resp.Header.Get("Docker-Content-Digest"), nil
+}
+
+// StartBlobUpload начинает загрузку blob. Если указан from, registry сначала пробует
+// смонтировать blob из другого репозитория, в этом случае mounted будет true
+func (rc *Client) StartBlobUpload(ctx context.Context, repository, digest, from string) (location string, mounted bool, err error) {
+	uploadURL := fmt.Sprintf("%s/v2/%s/blobs/uploads/", rc.BaseURL, repository)
+	if from != "" {
+		uploadURL += "?mount=" + url.QueryEscape(digest) + "&from=" + url.QueryEscape(from)
+	}
+
+	req, err := rc.newRequest(ctx, "POST", uploadURL, nil)
+	if err != nil {
+		return "", false, err
+	}
+
+	resp, err := rc.Client.Do(req)
+	if err != nil {
+		return "", false, fmt.Errorf("ошибка при начале загрузки blob %s: %v", digest, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return "", true, nil
+	case http.StatusAccepted:
+		location, err := rc.resolveLocation(resp.Header.Get("Location"))
+		return location, false, err
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return "", false, fmt.Errorf("получен статус %d при начале загрузки blob %s: %s", resp.StatusCode, digest, string(body))
+	}
+}
+
+// UploadBlob завершает загрузку blob одним запросом по адресу из StartBlobUpload
+func (rc *Client) UploadBlob(ctx context.Context, location, digest string, size int64, content io.Reader) error {
+	separator := "?"
+	if strings.Contains(location, "?") {
+		separator = "&"
+	}
+
+	req, err := rc.newRequest(ctx, "PUT", location+separator+"digest="+url.QueryEscape(digest), content)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := rc.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка при загрузке blob %s: %v", digest, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("получен статус %d при загрузке blob %s: %s", resp.StatusCode, digest, string(body))
+	}
+
+	return nil
+}
+
+// resolveLocation преобразует относительный заголовок Location в абсолютный URL
+func (rc *Client) resolveLocation(location string) (string, error) {
+	if location == "" {
+		return "", fmt.Errorf("registry не вернул заголовок Location")
+	}
+
+	base, err := url.Parse(rc.BaseURL)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+
+	return base.ResolveReference(ref).String(), nil
+}
@@ -0,0 +1,131 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// mediaTypeSchema1Signed тип подписанного манифеста schema1
+const mediaTypeSchema1Signed = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+
+// manifestV1 манифест schema1. Записи History соответствуют FSLayers с тем же индексом,
+// первая запись описывает верхний слой и конфигурацию образа
+type manifestV1 struct {
+	SchemaVersion int `json:"schemaVersion"`
+	FSLayers      []struct {
+		BlobSum string `json:"blobSum"`
+	} `json:"fsLayers"`
+	History []struct {
+		V1Compatibility string `json:"v1Compatibility"`
+	} `json:"history"`
+}
+
+// v1Compatibility запись истории манифеста schema1
+type v1Compatibility struct {
+	Created time.Time `json:"created"`
+	// OS и Architecture платформа образа, заполняются в первой записи
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	// Size размер слоя, указывается не всеми сборщиками
+	Size   int64 `json:"Size"`
+	Config struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"config"`
+}
+
+// meta возвращает метаданные образа по всей истории манифеста. Время создания - самое
+// позднее в истории: некоторые сборщики не заполняют его в первой записи. Метки берутся
+// из первой записи, где они есть, платформа - из первой записи, размер - сумма размеров
+// различных слоев
+func (m *manifestV1) meta() (ImageMeta, error) {
+	var meta ImageMeta
+	seen := make(map[string]bool)
+	for i, entry := range m.History {
+		var v1 v1Compatibility
+		if err := json.Unmarshal([]byte(entry.V1Compatibility), &v1); err != nil {
+			return ImageMeta{}, fmt.Errorf("ошибка разбора истории манифеста schema1: %v", err)
+		}
+		if v1.Created.After(meta.Created) {
+			meta.Created = v1.Created
+		}
+		if meta.Labels == nil && v1.Config.Labels != nil {
+			meta.Labels = v1.Config.Labels
+		}
+		if i == 0 && v1.OS != "" && v1.Architecture != "" {
+			meta.Platforms = []Platform{{OS: v1.OS, Architecture: v1.Architecture}}
+		}
+		// Пустые слои команд без изменений файлов повторяются, их размер учитывается один раз
+		if i < len(m.FSLayers) && !seen[m.FSLayers[i].BlobSum] {
+			seen[m.FSLayers[i].BlobSum] = true
+			meta.Size += v1.Size
+			meta.Blobs = append(meta.Blobs, Descriptor{Digest: m.FSLayers[i].BlobSum, Size: v1.Size})
+		}
+	}
+	if meta.Created.IsZero() {
+		return ImageMeta{}, fmt.Errorf("история манифеста schema1 не содержит времени создания")
+	}
+	return meta, nil
+}
+
+// jwsProtected защищенный заголовок подписи манифеста schema1: по нему из подписанного
+// манифеста восстанавливается исходное содержимое
+type jwsProtected struct {
+	FormatLength int    `json:"formatLength"`
+	FormatTail   string `json:"formatTail"`
+}
+
+// schema1Payload возвращает содержимое подписанного манифеста schema1 без подписей:
+// registry вычисляет digest таких манифестов по нему
+func schema1Payload(body []byte) ([]byte, error) {
+	var signed struct {
+		Signatures []struct {
+			Protected string `json:"protected"`
+		} `json:"signatures"`
+	}
+	if err := json.Unmarshal(body, &signed); err != nil {
+		return nil, fmt.Errorf("ошибка разбора манифеста schema1: %v", err)
+	}
+	if len(signed.Signatures) == 0 {
+		return body, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(signed.Signatures[0].Protected, "="))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка декодирования подписи schema1: %v", err)
+	}
+	var protected jwsProtected
+	if err := json.Unmarshal(raw, &protected); err != nil {
+		return nil, fmt.Errorf("ошибка разбора подписи schema1: %v", err)
+	}
+	tail, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(protected.FormatTail, "="))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка декодирования подписи schema1: %v", err)
+	}
+	if protected.FormatLength <= 0 || protected.FormatLength > len(body) {
+		return nil, fmt.Errorf("некорректная длина содержимого в подписи schema1: %d", protected.FormatLength)
+	}
+	return append(append([]byte(nil), body[:protected.FormatLength]...), tail...), nil
+}
+
+// manifestContent возвращает содержимое манифеста, по которому вычисляется его digest
+func manifestContent(body []byte, mediaType string) ([]byte, error) {
+	if strings.HasPrefix(mediaType, mediaTypeSchema1Signed) {
+		return schema1Payload(body)
+	}
+	return body, nil
+}
+
+// manifestDigest вычисляет digest манифеста так же, как registry
+func manifestDigest(body []byte, mediaType string) (string, error) {
+	content, err := manifestContent(body, mediaType)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
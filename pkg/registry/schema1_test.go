@@ -0,0 +1,166 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// schema1Unsigned содержимое манифеста schema1 до подписи в форматировании libtrust
+const schema1Unsigned = `{
+   "schemaVersion": 1,
+   "name": "app",
+   "tag": "v1",
+   "architecture": "amd64",
+   "fsLayers": [
+      {
+         "blobSum": "sha256:a3ed95caeb02ffe68cdd9fd84406680ae93d633cb16422d00e8a7c22955b46d4"
+      }
+   ],
+   "history": [
+      {
+         "v1Compatibility": "{\"created\":\"2024-01-01T00:00:00Z\"}"
+      }
+   ]
+}`
+
+// signSchema1 добавляет к манифесту блок подписей так же, как libtrust: содержимое
+// обрезается перед закрывающей скобкой, а отрезанный хвост записывается в formatTail
+func signSchema1(payload string) string {
+	length := len(payload) - 2
+	protected := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"formatLength":%d,"formatTail":%q}`,
+		length, base64.RawURLEncoding.EncodeToString([]byte(payload[length:])))))
+	return payload[:length] + ",\n   \"signatures\": [\n      {\n         \"protected\": \"" + protected + "\",\n         \"signature\": \"c2lnbmF0dXJl\"\n      }\n   ]\n}"
+}
+
+// sha256Digest возвращает digest содержимого
+func sha256Digest(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestManifestDigest(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		mediaType string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "подписанный манифест без подписей",
+			body:      signSchema1(schema1Unsigned),
+			mediaType: mediaTypeSchema1Signed,
+			want:      sha256Digest(schema1Unsigned),
+		},
+		{
+			name:      "неподписанный манифест целиком",
+			body:      schema1Unsigned,
+			mediaType: "application/vnd.docker.distribution.manifest.v1+json",
+			want:      sha256Digest(schema1Unsigned),
+		},
+		{
+			name:      "манифест schema2 целиком",
+			body:      `{"schemaVersion":2}`,
+			mediaType: "application/vnd.docker.distribution.manifest.v2+json",
+			want:      sha256Digest(`{"schemaVersion":2}`),
+		},
+		{
+			name:      "длина содержимого больше манифеста",
+			body:      `{"signatures":[{"protected":"` + base64.RawURLEncoding.EncodeToString([]byte(`{"formatLength":1000,"formatTail":"fQ"}`)) + `"}]}`,
+			mediaType: mediaTypeSchema1Signed,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := manifestDigest([]byte(tt.body), tt.mediaType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("manifestDigest вернул ошибку %v, ошибка ожидалась: %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("digest %s, ожидался %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManifestV1Meta(t *testing.T) {
+	manifest := manifestV1{SchemaVersion: 1}
+	for _, layer := range []struct{ blobSum, v1 string }{
+		{"sha256:top", `{"Size":10,"config":{"Labels":{"team":"core"}}}`},
+		{"sha256:empty", `{"created":"2024-01-02T00:00:00Z"}`},
+		{"sha256:empty", `{"created":"2024-01-01T00:00:00Z"}`},
+		{"sha256:base", `{"created":"2023-12-31T00:00:00Z","Size":100}`},
+	} {
+		manifest.FSLayers = append(manifest.FSLayers, struct {
+			BlobSum string `json:"blobSum"`
+		}{layer.blobSum})
+		manifest.History = append(manifest.History, struct {
+			V1Compatibility string `json:"v1Compatibility"`
+		}{layer.v1})
+	}
+
+	meta, err := manifest.meta()
+	if err != nil {
+		t.Fatalf("meta: %v", err)
+	}
+	if want := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC); !meta.Created.Equal(want) {
+		t.Errorf("время создания %s, ожидалось самое позднее в истории %s", meta.Created, want)
+	}
+	if meta.Size != 110 || len(meta.Blobs) != 3 {
+		t.Errorf("размер %d из %d слоев, ожидалось 110 из 3", meta.Size, len(meta.Blobs))
+	}
+	if meta.Labels["team"] != "core" {
+		t.Errorf("метки %v, ожидалась team=core", meta.Labels)
+	}
+}
+
+func TestClientResolveDigestSchema1(t *testing.T) {
+	signed := signSchema1(schema1Unsigned)
+	tests := []struct {
+		name    string
+		header  string
+		want    string
+		wantErr error
+	}{
+		{name: "registry не вернул digest", want: sha256Digest(schema1Unsigned)},
+		{name: "digest из заголовка совпадает", header: sha256Digest(schema1Unsigned), want: sha256Digest(schema1Unsigned)},
+		{name: "digest подписанного манифеста", header: sha256Digest(signed), wantErr: ErrDigestMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", mediaTypeSchema1Signed)
+				if tt.header != "" {
+					w.Header().Set("Docker-Content-Digest", tt.header)
+				}
+				w.Write([]byte(signed))
+			}))
+			defer server.Close()
+
+			got, err := NewClient(server.URL, "", "").ResolveDigest(context.Background(), "app", "v1")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ResolveDigest вернул %v, ожидалась ошибка %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveDigest: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("digest %s, ожидался %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package registry
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// TransportOptions параметры повторного использования соединений с registry
+type TransportOptions struct {
+	// MaxIdleConnsPerHost сколько простаивающих соединений с одним хостом сохраняется
+	// для повторного использования. У http.DefaultTransport их 2: при параллельных
+	// запросах остальные соединения закрываются и открываются заново, а закрытые
+	// соединения занимают локальные порты в TIME_WAIT
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout через сколько простаивающее соединение закрывается
+	IdleConnTimeout time.Duration
+	// KeepAlive период TCP keep-alive, отрицательное значение отключает keep-alive
+	KeepAlive time.Duration
+	// HTTP1Only отключает HTTP/2. По умолчанию HTTP/2 согласуется через ALPN для
+	// registry с TLS, а registry и балансировщики без его поддержки отвечают по HTTP/1.1
+	HTTP1Only bool
+}
+
+// NewTransport создает HTTP-транспорт на основе http.DefaultTransport с заданными
+// параметрами соединений. Параллельные запросы к registry с HTTP/2 передаются по
+// одному соединению
+func NewTransport(options TransportOptions) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: options.KeepAlive}
+	transport.DialContext = dialer.DialContext
+	// Со своим DialContext HTTP/2 включается только явно
+	transport.ForceAttemptHTTP2 = !options.HTTP1Only
+	if options.HTTP1Only {
+		// Пустая карта вместо nil отключает HTTP/2
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	if options.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = options.MaxIdleConnsPerHost
+		if transport.MaxIdleConns < options.MaxIdleConnsPerHost {
+			transport.MaxIdleConns = options.MaxIdleConnsPerHost
+		}
+	}
+	if options.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = options.IdleConnTimeout
+	}
+	return transport
+}
+
+// DefaultUserAgent возвращает User-Agent программы с версией модуля, если она известна
+func DefaultUserAgent() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return "registry-cleaner/" + info.Main.Version
+	}
+	return "registry-cleaner"
+}
+
+// ParseHeader разбирает заголовок в виде "Имя: значение"
+func ParseHeader(value string) (string, string, error) {
+	name, content, ok := strings.Cut(value, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", fmt.Errorf("ожидается заголовок в виде \"Имя: значение\"")
+	}
+	return textproto.CanonicalMIMEHeaderKey(name), strings.TrimSpace(content), nil
+}
+
+// HeaderTransport добавляет к каждому запросу User-Agent и дополнительные заголовки,
+// например требуемые корпоративным прокси перед registry. Заголовки, которые клиент
+// задал сам (Accept, Authorization и другие), не заменяются
+type HeaderTransport struct {
+	Next      http.RoundTripper
+	UserAgent string
+	Header    http.Header
+}
+
+// RoundTrip выполняет запрос с дополнительными заголовками
+func (t *HeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTripper не должен изменять исходный запрос
+	req = req.Clone(req.Context())
+	if t.UserAgent != "" {
+		req.Header.Set("User-Agent", t.UserAgent)
+	}
+	for name, values := range t.Header {
+		if _, ok := req.Header[name]; !ok {
+			req.Header[name] = values
+		}
+	}
+	return t.Next.RoundTrip(req)
+}
@@ -0,0 +1,150 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Виды нарушений целостности
+const (
+	// ProblemManifest манифест тега не читается, не разбирается или не совпадает со своим digest
+	ProblemManifest = "manifest"
+	// ProblemBlob манифест ссылается на отсутствующий blob
+	ProblemBlob = "blob"
+	// ProblemChild manifest list или OCI index ссылается на отсутствующий манифест
+	ProblemChild = "child"
+)
+
+// Problem нарушение целостности образа
+type Problem struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	// Digest манифест, blob или дочерний манифест, к которому относится нарушение
+	Digest string `json:"digest,omitempty"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// Error возвращает описание нарушения
+func (p Problem) Error() string {
+	if p.Digest == "" {
+		return fmt.Sprintf("%s:%s: %s", p.Repository, p.Tag, p.Detail)
+	}
+	return fmt.Sprintf("%s:%s (%s): %s", p.Repository, p.Tag, p.Digest, p.Detail)
+}
+
+// Verifier проверяет, что манифесты тегов читаются, а все blob и дочерние манифесты,
+// на которые они ссылаются, существуют. Результаты проверки blob запоминаются, поэтому
+// общие слои репозитория проверяются один раз
+type Verifier struct {
+	Client *Client
+
+	mu    sync.Mutex
+	blobs map[string]*blobCheck
+}
+
+// blobCheck результат проверки blob в репозитории, done закрывается по готовности
+type blobCheck struct {
+	done   chan struct{}
+	exists bool
+	err    error
+}
+
+// NewVerifier создает Verifier
+func NewVerifier(client *Client) *Verifier {
+	return &Verifier{Client: client, blobs: make(map[string]*blobCheck)}
+}
+
+// VerifyTag проверяет тег и возвращает найденные нарушения. Ошибка возвращается, только
+// если проверку прервал контекст
+func (v *Verifier) VerifyTag(ctx context.Context, repository, tag string) ([]Problem, error) {
+	var problems []Problem
+	report := func(kind, digest, format string, args ...interface{}) {
+		problems = append(problems, Problem{Repository: repository, Tag: tag, Digest: digest, Kind: kind, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	raw, _, digest, err := v.Client.GetManifest(ctx, repository, tag)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		report(ProblemManifest, "", "манифест не читается: %v", err)
+		return problems, nil
+	}
+	if err := v.verifyManifest(ctx, repository, digest, raw, report); err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
+
+// verifyManifest проверяет содержимое манифеста, его blob и дочерние манифесты
+func (v *Verifier) verifyManifest(ctx context.Context, repository, digest string, raw []byte,
+	report func(kind, digest, format string, args ...interface{})) error {
+	if digest != "" {
+		if err := verifyDigest(raw, digest); err != nil {
+			report(ProblemManifest, digest, "%v", err)
+		}
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		report(ProblemManifest, digest, "ошибка разбора манифеста: %v", err)
+		return nil
+	}
+
+	for _, child := range manifest.Manifests {
+		childRaw, _, _, err := v.Client.GetManifest(ctx, repository, child.Digest)
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case errors.Is(err, ErrNotFound):
+			report(ProblemChild, child.Digest, "дочерний манифест отсутствует")
+			continue
+		case err != nil:
+			report(ProblemManifest, child.Digest, "дочерний манифест не читается: %v", err)
+			continue
+		}
+		if err := v.verifyManifest(ctx, repository, child.Digest, childRaw, report); err != nil {
+			return err
+		}
+	}
+
+	for _, blob := range manifest.Blobs() {
+		exists, err := v.blobExists(ctx, repository, blob.Digest)
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			report(ProblemBlob, blob.Digest, "не удалось проверить blob: %v", err)
+		case !exists:
+			report(ProblemBlob, blob.Digest, "blob отсутствует (404)")
+		}
+	}
+	return nil
+}
+
+// blobExists проверяет blob в репозитории не больше одного раза
+func (v *Verifier) blobExists(ctx context.Context, repository, digest string) (bool, error) {
+	key := repository + "@" + digest
+	v.mu.Lock()
+	check, ok := v.blobs[key]
+	if !ok {
+		check = &blobCheck{done: make(chan struct{})}
+		v.blobs[key] = check
+	}
+	v.mu.Unlock()
+
+	if !ok {
+		check.exists, check.err = v.Client.BlobExists(ctx, repository, digest)
+		close(check.done)
+	}
+	select {
+	case <-check.done:
+		return check.exists, check.err
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
@@ -0,0 +1,44 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severities уровни важности уязвимостей в порядке возрастания, как в отчетах Trivy и Harbor
+var Severities = []string{"UNKNOWN", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+// Vulnerabilities количество уязвимостей образа по уровням важности Severities
+type Vulnerabilities map[string]int
+
+// ParseSeverity приводит уровень важности к виду из Severities без учета регистра
+func ParseSeverity(s string) (string, error) {
+	severity := strings.ToUpper(s)
+	for _, known := range Severities {
+		if severity == known {
+			return severity, nil
+		}
+	}
+	return "", fmt.Errorf("неизвестный уровень важности %q, допустимо: %s", s, strings.ToLower(strings.Join(Severities, ", ")))
+}
+
+// AtLeast возвращает количество уязвимостей уровня severity и выше
+func (v Vulnerabilities) AtLeast(severity string) int {
+	n := 0
+	counting := false
+	for _, s := range Severities {
+		counting = counting || s == severity
+		if counting {
+			n += v[s]
+		}
+	}
+	return n
+}
+
+// ScanResult итоги сканирования образа сканером безопасности или качества
+type ScanResult struct {
+	// Vulnerabilities количество уязвимостей по уровням важности
+	Vulnerabilities Vulnerabilities `json:"vulnerabilities,omitempty"`
+	// Attributes вердикты сканера, например license=denied или policy=fail
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
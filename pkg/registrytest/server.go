@@ -0,0 +1,360 @@
+// Package registrytest реализует Docker Registry HTTP API V2 в памяти для проверки
+// очистки без настоящего registry
+package registrytest
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	manifestMediaType     = "application/vnd.docker.distribution.manifest.v2+json"
+	manifestListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// repository содержимое одного репозитория
+type repository struct {
+	tags      map[string]string
+	manifests map[string][]byte
+	types     map[string]string
+	blobs     map[string]bool
+}
+
+// Registry хранит репозитории в памяти и обслуживает запросы Registry API V2.
+// Поддерживаются каталог, список тегов, манифесты (HEAD, GET, PUT, DELETE),
+// чтение блобов и загрузка блобов, в том числе с монтированием из другого репозитория
+type Registry struct {
+	// DeleteDisabled имитирует registry без storage.delete.enabled: DELETE возвращает 405
+	DeleteDisabled bool
+
+	mu           sync.Mutex
+	repositories map[string]*repository
+	blobs        map[string][]byte
+	uploads      map[string]string
+	nextUpload   int
+}
+
+// New создает пустой registry
+func New() *Registry {
+	return &Registry{
+		repositories: make(map[string]*repository),
+		blobs:        make(map[string][]byte),
+		uploads:      make(map[string]string),
+	}
+}
+
+// digestOf вычисляет sha256 digest содержимого
+func digestOf(content []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+}
+
+// repository возвращает репозиторий, создавая его при необходимости
+func (r *Registry) repository(name string) *repository {
+	repo := r.repositories[name]
+	if repo == nil {
+		repo = &repository{
+			tags:      make(map[string]string),
+			manifests: make(map[string][]byte),
+			types:     make(map[string]string),
+			blobs:     make(map[string]bool),
+		}
+		r.repositories[name] = repo
+	}
+	return repo
+}
+
+// putBlob сохраняет блоб и делает его доступным в репозитории
+func (r *Registry) putBlob(repo *repository, content []byte) string {
+	digest := digestOf(content)
+	r.blobs[digest] = content
+	repo.blobs[digest] = true
+	return digest
+}
+
+// Seed добавляет в репозиторий образ с указанным временем создания и возвращает digest манифеста.
+// Образ состоит из конфигурации, общего для всех образов базового слоя и собственного слоя
+func (r *Registry) Seed(name, tag string, created time.Time) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	repo := r.repository(name)
+	digest := r.putImage(repo, name, tag, created, "linux", "amd64")
+	repo.tags[tag] = digest
+	return digest
+}
+
+// SeedIndex добавляет в репозиторий manifest list с образами платформ вида os/architecture,
+// созданными в указанное время, и возвращает digest manifest list. Манифесты платформ
+// хранятся без тегов, как после docker buildx
+func (r *Registry) SeedIndex(name, tag string, created time.Time, platforms ...string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	repo := r.repository(name)
+	var manifests []interface{}
+	for _, platform := range platforms {
+		os, architecture, _ := strings.Cut(platform, "/")
+		digest := r.putImage(repo, name, tag+"-"+os+"-"+architecture, created, os, architecture)
+		manifests = append(manifests, map[string]interface{}{
+			"mediaType": manifestMediaType,
+			"digest":    digest,
+			"size":      len(repo.manifests[digest]),
+			"platform":  map[string]string{"os": os, "architecture": architecture},
+		})
+	}
+	index, _ := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     manifestListMediaType,
+		"manifests":     manifests,
+	})
+
+	digest := digestOf(index)
+	repo.manifests[digest] = index
+	repo.types[digest] = manifestListMediaType
+	repo.tags[tag] = digest
+	return digest
+}
+
+// putImage сохраняет конфигурацию, слои и манифест образа платформы os/architecture и
+// возвращает digest манифеста. Слой образа определяется ключом key
+func (r *Registry) putImage(repo *repository, name, key string, created time.Time, os, architecture string) string {
+	config, _ := json.Marshal(map[string]interface{}{
+		"created":      created,
+		"architecture": architecture,
+		"os":           os,
+	})
+	layer := []byte("layer " + name + ":" + key)
+	base := []byte("base layer")
+
+	descriptor := func(mediaType string, content []byte) map[string]interface{} {
+		return map[string]interface{}{"mediaType": mediaType, "digest": r.putBlob(repo, content), "size": len(content)}
+	}
+	manifest, _ := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     manifestMediaType,
+		"config":        descriptor("application/vnd.docker.container.image.v1+json", config),
+		"layers": []interface{}{
+			descriptor("application/vnd.docker.image.rootfs.diff.tar.gzip", base),
+			descriptor("application/vnd.docker.image.rootfs.diff.tar.gzip", layer),
+		},
+	})
+
+	digest := digestOf(manifest)
+	repo.manifests[digest] = manifest
+	repo.types[digest] = manifestMediaType
+	return digest
+}
+
+// Tag указывает тегом на манифест репозитория с digest
+func (r *Registry) Tag(name, tag, digest string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.repository(name).tags[tag] = digest
+}
+
+// HasManifest сообщает, хранится ли в репозитории манифест с digest
+func (r *Registry) HasManifest(name, digest string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	repo, ok := r.repositories[name]
+	if !ok {
+		return false
+	}
+	_, ok = repo.manifests[digest]
+	return ok
+}
+
+// Tags возвращает отсортированный список тегов репозитория
+func (r *Registry) Tags(name string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	repo, ok := r.repositories[name]
+	if !ok {
+		return nil
+	}
+	tags := make([]string, 0, len(repo.tags))
+	for tag := range repo.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// ServeHTTP обрабатывает запросы Registry API V2
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := req.URL.Path
+	switch {
+	case path == "/v2/" || path == "/v2":
+		w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	case path == "/v2/_catalog":
+		r.serveCatalog(w, req)
+	case strings.HasSuffix(path, "/tags/list"):
+		r.serveTags(w, strings.TrimSuffix(strings.TrimPrefix(path, "/v2/"), "/tags/list"))
+	case strings.Contains(path, "/manifests/"):
+		name, reference := splitPath(path, "/manifests/")
+		r.serveManifest(w, req, name, reference)
+	case strings.Contains(path, "/blobs/uploads/"):
+		name, id := splitPath(path, "/blobs/uploads/")
+		r.serveUpload(w, req, name, id)
+	case strings.Contains(path, "/blobs/"):
+		name, digest := splitPath(path, "/blobs/")
+		r.serveBlob(w, req, name, digest)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// splitPath разделяет путь запроса на имя репозитория и ссылку после разделителя
+func splitPath(path, separator string) (string, string) {
+	path = strings.TrimPrefix(path, "/v2/")
+	i := strings.LastIndex(path, separator)
+	return path[:i], path[i+len(separator):]
+}
+
+// serveCatalog возвращает каталог по страницам, как distribution: после имени last
+// не больше n имен и ссылку на следующую страницу в заголовке Link
+func (r *Registry) serveCatalog(w http.ResponseWriter, req *http.Request) {
+	last := req.URL.Query().Get("last")
+	names := []string{}
+	for name, repo := range r.repositories {
+		if (len(repo.tags) > 0 || len(repo.manifests) > 0) && name > last {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if n, err := strconv.Atoi(req.URL.Query().Get("n")); err == nil && n > 0 && len(names) > n {
+		names = names[:n]
+		w.Header().Set("Link", fmt.Sprintf(`</v2/_catalog?last=%s&n=%d>; rel="next"`, url.QueryEscape(names[n-1]), n))
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"repositories": names})
+}
+
+func (r *Registry) serveTags(w http.ResponseWriter, name string) {
+	repo, ok := r.repositories[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	tags := []string{}
+	for tag := range repo.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	json.NewEncoder(w).Encode(map[string]interface{}{"name": name, "tags": tags})
+}
+
+func (r *Registry) serveManifest(w http.ResponseWriter, req *http.Request, name, reference string) {
+	repo := r.repository(name)
+	digest := reference
+	if !strings.HasPrefix(reference, "sha256:") {
+		digest = repo.tags[reference]
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		manifest, ok := repo.manifests[digest]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", repo.types[digest])
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Content-Length", fmt.Sprint(len(manifest)))
+		if req.Method == http.MethodGet {
+			w.Write(manifest)
+		}
+	case http.MethodPut:
+		manifest, err := io.ReadAll(req.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		digest = digestOf(manifest)
+		repo.manifests[digest] = manifest
+		repo.types[digest] = req.Header.Get("Content-Type")
+		if !strings.HasPrefix(reference, "sha256:") {
+			repo.tags[reference] = digest
+		}
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if r.DeleteDisabled {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if _, ok := repo.manifests[digest]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(repo.manifests, digest)
+		for tag, tagDigest := range repo.tags {
+			if tagDigest == digest {
+				delete(repo.tags, tag)
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (r *Registry) serveUpload(w http.ResponseWriter, req *http.Request, name, id string) {
+	repo := r.repository(name)
+	switch req.Method {
+	case http.MethodPost:
+		query := req.URL.Query()
+		if mount := query.Get("mount"); mount != "" {
+			if from, ok := r.repositories[query.Get("from")]; ok && from.blobs[mount] {
+				repo.blobs[mount] = true
+				w.Header().Set("Docker-Content-Digest", mount)
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+		}
+		r.nextUpload++
+		id := fmt.Sprint(r.nextUpload)
+		r.uploads[id] = name
+		w.Header().Set("Location", "/v2/"+name+"/blobs/uploads/"+id)
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodPut:
+		content, err := io.ReadAll(req.Body)
+		digest := req.URL.Query().Get("digest")
+		if err != nil || r.uploads[id] != name || digestOf(content) != digest {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		delete(r.uploads, id)
+		r.putBlob(repo, content)
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (r *Registry) serveBlob(w http.ResponseWriter, req *http.Request, name, digest string) {
+	repo := r.repository(name)
+	if !repo.blobs[digest] {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	content := r.blobs[digest]
+	w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+	w.Header().Set("Docker-Content-Digest", digest)
+	if req.Method == http.MethodGet {
+		w.Write(content)
+	}
+}
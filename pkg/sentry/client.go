@@ -0,0 +1,205 @@
+// Package sentry отправляет события в Sentry через HTTP API envelope без SDK: очистке
+// нужны только паники и сводка ошибок запуска
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Уровни событий Sentry
+const (
+	LevelError = "error"
+	LevelFatal = "fatal"
+)
+
+// Client отправляет события в проект Sentry, заданный DSN
+type Client struct {
+	// Environment и Release добавляются к каждому событию, если заданы
+	Environment string
+	Release     string
+	ServerName  string
+	Client      *http.Client
+
+	endpoint string
+	key      string
+	dsn      string
+}
+
+// Event событие Sentry
+type Event struct {
+	Level       string
+	Message     string
+	Tags        map[string]string
+	Extra       map[string]interface{}
+	Fingerprint []string
+	// Exception, если задано, описывает панику со стеком вызовов
+	Exception *Exception
+}
+
+// Exception исключение события со стеком вызовов
+type Exception struct {
+	Type       string      `json:"type"`
+	Value      string      `json:"value"`
+	Stacktrace *Stacktrace `json:"stacktrace,omitempty"`
+}
+
+// Stacktrace стек вызовов, кадры от внешнего вызова к месту ошибки, как ожидает Sentry
+type Stacktrace struct {
+	Frames []Frame `json:"frames"`
+}
+
+// Frame кадр стека вызовов
+type Frame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// NewClient создает клиент по DSN вида https://<ключ>@<хост>/<проект>
+func NewClient(dsn string) (*Client, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный DSN Sentry: %v", err)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("в DSN Sentry нет ключа проекта")
+	}
+	path := strings.Trim(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	project := path[i+1:]
+	if project == "" {
+		return nil, fmt.Errorf("в DSN Sentry нет номера проекта")
+	}
+
+	// Устаревший DSN с секретным ключом <ключ>:<секрет>@ передается без секрета
+	u.User = url.User(u.User.Username())
+	// Sentry на пути /prefix/<проект> принимает события на /prefix/api/<проект>/envelope/
+	endpoint := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/" + path[:i+1] + "api/" + project + "/envelope/"}
+	return &Client{
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		endpoint: endpoint.String(),
+		key:      u.User.Username(),
+		dsn:      u.String(),
+	}, nil
+}
+
+// Capture отправляет событие и возвращает его идентификатор
+func (c *Client) Capture(ctx context.Context, event *Event) (string, error) {
+	id := newEventID()
+	payload := map[string]interface{}{
+		"event_id":  id,
+		"timestamp": time.Now().UTC().Format(time.RFC3339Nano),
+		"level":     event.Level,
+		"platform":  "go",
+		"logger":    "registry-cleaner",
+	}
+	if event.Message != "" {
+		payload["message"] = map[string]string{"formatted": event.Message}
+	}
+	if event.Exception != nil {
+		payload["exception"] = map[string]interface{}{"values": []*Exception{event.Exception}}
+	}
+	if len(event.Tags) > 0 {
+		payload["tags"] = event.Tags
+	}
+	if len(event.Extra) > 0 {
+		payload["extra"] = event.Extra
+	}
+	if len(event.Fingerprint) > 0 {
+		payload["fingerprint"] = event.Fingerprint
+	}
+	for name, value := range map[string]string{"environment": c.Environment, "release": c.Release, "server_name": c.ServerName} {
+		if value != "" {
+			payload[name] = value
+		}
+	}
+
+	item, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	var body bytes.Buffer
+	header, _ := json.Marshal(map[string]string{"event_id": id, "dsn": c.dsn, "sent_at": time.Now().UTC().Format(time.RFC3339)})
+	itemHeader, _ := json.Marshal(map[string]interface{}{"type": "event", "length": len(item)})
+	body.Write(header)
+	body.WriteByte('\n')
+	body.Write(itemHeader)
+	body.WriteByte('\n')
+	body.Write(item)
+	body.WriteByte('\n')
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=registry-cleaner, sentry_key=%s", c.key))
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ошибка отправки события в Sentry: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("получен статус %d при отправке события в Sentry", resp.StatusCode)
+	}
+	return id, nil
+}
+
+// PanicException описывает панику со стеком вызова recover. skip количество кадров
+// над вызовом PanicException, которые не входят в стек: функция с recover и сам runtime
+func PanicException(value interface{}, skip int) *Exception {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []Frame
+	for {
+		frame, more := frames.Next()
+		module, function := splitFunction(frame.Function)
+		stack = append(stack, Frame{
+			Function: function,
+			Module:   module,
+			AbsPath:  frame.File,
+			Lineno:   frame.Line,
+			InApp:    !strings.HasPrefix(frame.Function, "runtime."),
+		})
+		if !more {
+			break
+		}
+	}
+	// Sentry ожидает кадры от самого внешнего вызова
+	for i, j := 0, len(stack)-1; i < j; i, j = i+1, j-1 {
+		stack[i], stack[j] = stack[j], stack[i]
+	}
+	return &Exception{Type: "panic", Value: fmt.Sprint(value), Stacktrace: &Stacktrace{Frames: stack}}
+}
+
+// splitFunction разделяет полное имя функции на пакет и имя
+func splitFunction(name string) (string, string) {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return "", name
+	}
+	return name[:slash+1+dot], name[slash+2+dot:]
+}
+
+// newEventID создает идентификатор события: 32 шестнадцатеричных символа
+func newEventID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"registryCleaner/pkg/cleanup"
+	"registryCleaner/pkg/registry"
+)
+
+// runPlanCommand выполняет подкоманду plan: составляет планы очистки всех репозиториев,
+// выводит их и с -out сохраняет в файл для последующего apply, ничего не удаляя
+func runPlanCommand(args []string) int {
+	cfg := parseConfig(args)
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: %v\n", err)
+		return 2
+	}
+	defer startLogSink(cfg)()
+	defer startProfiling(cfg)()
+	defer reportPanic(cfg)
+
+	file, failures, err := makePlanFile(cfg)
+	if err != nil {
+		log.Printf("Ошибка составления плана: %v", err)
+		return 1
+	}
+	printPlanFile(os.Stdout, file, cfg.location())
+
+	// План с ошибками неполон: по нему нельзя выполнять очистку
+	if len(failures) > 0 {
+		printFailures(failures)
+		fmt.Println("План не сохранен из-за ошибок")
+		return 1
+	}
+	if cfg.PlanOut != "" {
+		if err := file.Save(cfg.PlanOut); err != nil {
+			log.Printf("Ошибка сохранения плана: %v", err)
+			return 1
+		}
+		fmt.Printf("\nПлан сохранен в %s. Выполнить его: registry-cleaner apply %s\n", cfg.PlanOut, cfg.PlanOut)
+	}
+	return 0
+}
+
+// makePlanFile составляет планы очистки всех репозиториев по конфигурации. Ошибки
+// отдельных репозиториев и тегов возвращаются списком, ошибка - если план составить нельзя
+func makePlanFile(cfg *Config) (*cleanup.PlanFile, []error, error) {
+	ctx := context.Background()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	policy, closePolicy, err := buildPolicy(ctx, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка загрузки политики: %v", err)
+	}
+	defer closePolicy()
+
+	client := newRegistryClient(cfg)
+	backend, err := buildBackend(ctx, cfg, client)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка настройки backend: %v", err)
+	}
+	planner, closePlanner, err := newPlanner(ctx, cfg, backend, policy, os.Stdout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка настройки очистки: %v", err)
+	}
+	defer closePlanner()
+
+	repositories, err := planRepositories(ctx, cfg, backend, planner.Tenants)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var plans []*cleanup.RepositoryPlan
+	var failures []error
+	for _, repo := range repositories {
+		plan, err := planner.Plan(ctx, repo)
+		if err != nil {
+			fmt.Printf("Ошибка при составлении плана %s: %v\n", repo, err)
+			failures = append(failures, fmt.Errorf("%s: %w", repo, err))
+			continue
+		}
+		failures = append(failures, plan.Errors...)
+		plans = append(plans, plan)
+	}
+
+	if planner.Cache != nil {
+		if err := planner.Cache.Save(); err != nil {
+			fmt.Printf("Предупреждение: не удалось сохранить кэш: %v\n", err)
+		}
+	}
+	if planner.SoftDelete != nil {
+		if err := planner.SoftDelete.Save(); err != nil {
+			fmt.Printf("Предупреждение: не удалось сохранить отметки удаления: %v\n", err)
+		}
+	}
+	saveClientCaches(client)
+
+	if cfg.Order == cleanup.OrderLargest {
+		cleanup.SortPlansBySize(plans)
+	}
+	return cleanup.NewPlanFile(cfg.RegistryURL, cfg.Backend, plans), failures, nil
+}
+
+// planRepositories возвращает репозитории, для которых составляется план без очистки.
+// С --managed план показывает, какие репозитории возьмет под управление --adopt, но
+// состояние не меняет
+func planRepositories(ctx context.Context, cfg *Config, backend registry.Backend, tenants *cleanup.Tenants) ([]string, error) {
+	repositories, err := listRepositories(ctx, cfg, backend)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении списка репозиториев: %v", err)
+	}
+	repositories = cleanupRepositories(cfg, repositories)
+	if cfg.Managed {
+		state, err := cleanup.LoadState(cfg.StateFile)
+		if err != nil {
+			return nil, err
+		}
+		repositories = managedRepositories(cfg, state, tenants, repositories)
+	}
+	return repositories, nil
+}
+
+// runShowCommand выполняет подкоманду show: выводит сохраненный план
+func runShowCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Использование: registry-cleaner show <план.json>\n")
+		return 2
+	}
+	file, err := cleanup.LoadPlanFile(args[0])
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	printPlanFile(os.Stdout, file, nil)
+	return 0
+}
+
+// runApplyCommand выполняет подкоманду apply: удаляет образы ровно по сохраненному плану.
+// Перед удалением каждый образ сверяется с registry; изменившиеся после составления плана
+// образы не удаляются, а выводятся как расхождения
+func runApplyCommand(args []string) int {
+	cfg := parseConfig(args)
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: %v\n", err)
+		return 2
+	}
+	defer startLogSink(cfg)()
+	defer startProfiling(cfg)()
+	defer reportPanic(cfg)
+	if len(cfg.Args) != 1 {
+		fmt.Fprintf(os.Stderr, "Использование: registry-cleaner apply [флаги] <план.json>\n")
+		return 2
+	}
+
+	file, err := cleanup.LoadPlanFile(cfg.Args[0])
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	if file.RegistryURL != cfg.RegistryURL || file.Backend != cfg.Backend {
+		log.Printf("План составлен для %s (%s), а очистка запущена для %s (%s)", file.RegistryURL, file.Backend, cfg.RegistryURL, cfg.Backend)
+		return 1
+	}
+
+	ctx := context.Background()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	gcSetup, err := buildGC(ctx, cfg)
+	if err != nil {
+		log.Printf("Ошибка настройки garbage collection: %v", err)
+		return 1
+	}
+	client := newRegistryClient(cfg)
+	backend, err := buildBackend(ctx, cfg, client)
+	if err != nil {
+		log.Printf("Ошибка настройки backend: %v", err)
+		return 1
+	}
+	shutdown := NewShutdown()
+	executor := newExecutor(cfg, client, backend, shutdown)
+	// Образ могли поставить на удержание после составления плана
+	holds, err := fetchLegalHolds(cfg)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+
+	ctx, release, ok := acquireLocks(ctx, cfg, client)
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	var targeted []string
+	for _, planned := range file.Plans {
+		if len(planned.Delete) > 0 {
+			targeted = append(targeted, planned.Repository)
+		}
+	}
+	allowed, accessFailures, ok := checkPreflight(ctx, cfg, client, targeted)
+	if !ok || (cfg.FailFast && len(accessFailures) > 0) {
+		printFailures(accessFailures)
+		return 1
+	}
+	accessible := make(map[string]bool, len(allowed))
+	for _, repo := range allowed {
+		accessible[repo] = true
+	}
+
+	fmt.Printf("Применение плана %s от %s: к удалению образов %d\n", cfg.Args[0],
+		cleanup.FormatTime(file.Created, cfg.location()), file.DeleteCount())
+
+	var verified, executed []*cleanup.RepositoryPlan
+	var drifts []cleanup.Drift
+	failures := accessFailures
+	for _, planned := range file.Plans {
+		if shutdown.Requested() || ctx.Err() != nil {
+			break
+		}
+		if len(planned.Delete) == 0 || !accessible[planned.Repository] {
+			continue
+		}
+
+		fmt.Printf("Проверка плана репозитория %s\n", planned.Repository)
+		plan, repoDrifts, err := planned.Verify(ctx, backend, cfg.Concurrency)
+		if err != nil {
+			fmt.Printf("Ошибка при проверке репозитория %s: %v\n", planned.Repository, err)
+			failures = append(failures, fmt.Errorf("%s: %w", planned.Repository, err))
+			if cfg.FailFast {
+				break
+			}
+			continue
+		}
+		verified = append(verified, plan)
+		for _, drift := range repoDrifts {
+			fmt.Printf("  ⚠️  Расхождение с планом, образ не удаляется: %v\n", drift)
+		}
+		drifts = append(drifts, repoDrifts...)
+		if holds != nil {
+			for _, img := range holds.Keep(plan) {
+				fmt.Printf("  Образ %v на удержании, не удаляется\n", img)
+			}
+		}
+	}
+	if cfg.FailFast && len(failures) > 0 {
+		fmt.Println("\n⛔ Применение плана остановлено после ошибки (--fail-fast), образы не удалялись")
+		printFailures(failures)
+		reportErrors(cfg, failures)
+		return 1
+	}
+
+	// Проверяем пороги до удаления чего-либо, как при обычном запуске
+	guard := &cleanup.DeleteGuard{MaxPercent: cfg.MaxDeletePercent, MaxCount: cfg.MaxDeleteCount}
+	if violations := guard.Check(file.GuardPlans(verified)); len(violations) > 0 {
+		fmt.Printf("\n🚨 План очистки превышает допустимые пороги:\n")
+		for _, v := range violations {
+			fmt.Printf("  - %s\n", v)
+		}
+		if !cfg.Force {
+			fmt.Printf("Удаление отменено. Проверьте политику очистки или запустите с --force\n")
+			return 1
+		}
+		fmt.Printf("Указан --force, продолжаем удаление\n\n")
+	}
+
+	for _, plan := range verified {
+		if shutdown.Requested() || ctx.Err() != nil {
+			break
+		}
+		if len(plan.Delete) == 0 {
+			continue
+		}
+
+		executed = append(executed, plan)
+		err := executor.Execute(ctx, plan)
+		if err != nil {
+			failures = append(failures, deleteFailures(err)...)
+		}
+		if errors.Is(err, cleanup.ErrInterrupted) || (err != nil && cfg.FailFast) {
+			break
+		}
+	}
+
+	if shutdown.Requested() || ctx.Err() != nil {
+		printInterruptedSummary(len(file.Plans), verified, executed)
+		printFailures(failures)
+		reportErrors(cfg, failures)
+		if shutdown.Requested() {
+			return shutdown.ExitCode()
+		}
+		return 1
+	}
+
+	if len(drifts) > 0 {
+		fmt.Printf("\n⚠️  Расхождений с планом: %d, эти образы не удалены. Составьте план заново\n", len(drifts))
+		for _, drift := range drifts {
+			fmt.Printf("  - %v\n", drift)
+		}
+	}
+	if len(failures) > 0 || len(drifts) > 0 {
+		fmt.Println("\n⚠️  План применен не полностью")
+	} else {
+		fmt.Println("\n✅ План применен!")
+	}
+
+	code := finishCleanup(ctx, cfg, backend, gcSetup, newGCInfo(cfg, executed))
+	if len(failures) > 0 || len(drifts) > 0 {
+		printFailures(failures)
+		reportErrors(cfg, failures)
+		return 1
+	}
+	return code
+}
+
+// runDiffCommand выполняет подкоманду diff: сравнивает два сохраненных плана, а если
+// указан один - сохраненный план с планом, составленным сейчас по текущим флагам
+func runDiffCommand(args []string) int {
+	cfg := parseConfig(args)
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: %v\n", err)
+		return 2
+	}
+	if len(cfg.Args) < 1 || len(cfg.Args) > 2 {
+		fmt.Fprintf(os.Stderr, "Использование: registry-cleaner diff [флаги] <старый план.json> [<новый план.json>]\n")
+		return 2
+	}
+
+	old, err := cleanup.LoadPlanFile(cfg.Args[0])
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	var current *cleanup.PlanFile
+	newName := "текущий план"
+	if len(cfg.Args) == 2 {
+		newName = cfg.Args[1]
+		if current, err = cleanup.LoadPlanFile(cfg.Args[1]); err != nil {
+			log.Printf("%v", err)
+			return 1
+		}
+	} else {
+		var failures []error
+		if current, failures, err = makePlanFile(cfg); err != nil {
+			log.Printf("Ошибка составления плана: %v", err)
+			return 1
+		}
+		if len(failures) > 0 {
+			printFailures(failures)
+			fmt.Println("Текущий план неполон, сравнение может быть неточным")
+		}
+	}
+
+	changes := cleanup.DiffPlans(old, current)
+	fmt.Printf("\n🔀 Изменения: %s -> %s\n", cfg.Args[0], newName)
+	if len(changes) == 0 {
+		fmt.Println("Решения по образам не изменились")
+		return 0
+	}
+
+	counts := make(map[string]int)
+	repository := ""
+	for _, change := range changes {
+		if change.Repository != repository {
+			repository = change.Repository
+			fmt.Printf("\n%s:\n", repository)
+		}
+		counts[change.Change]++
+		img := change.Image
+		switch change.Change {
+		case cleanup.ChangeDeletable:
+			fmt.Printf("  + %s  %s  стал удаляемым (%s)\n", img.Tag, img.Digest, formatBytes(img.Size))
+		case cleanup.ChangeKept:
+			fmt.Printf("  - %s  %s  больше не удаляется\n", img.Tag, img.Digest)
+		case cleanup.ChangeGone:
+			fmt.Printf("  ? %s  %s  удалялся, но отсутствует в новом плане\n", img.Tag, img.Digest)
+		}
+	}
+	fmt.Printf("\nИтого: стали удаляемыми %d, больше не удаляются %d, отсутствуют %d\n",
+		counts[cleanup.ChangeDeletable], counts[cleanup.ChangeKept], counts[cleanup.ChangeGone])
+	return 0
+}
+
+// printPlanFile выводит план в w в читаемом виде: удаляемые образы каждого репозитория и
+// итог. Время выводится в часовом поясе loc, nil - в локальном
+func printPlanFile(w io.Writer, file *cleanup.PlanFile, loc *time.Location) {
+	fmt.Fprintf(w, "\n📋 План очистки %s от %s\n", file.RegistryURL, cleanup.FormatTime(file.Created, loc))
+
+	var size int64
+	for _, plan := range file.Plans {
+		if len(plan.Delete) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s: удаляется %d из %d образов (%s)\n", plan.Repository,
+			len(plan.Delete), len(plan.Keep)+len(plan.Delete), formatBytes(plan.DeleteSize()))
+		for _, img := range plan.Delete {
+			fmt.Fprintf(w, "  - %s  %s  создан %s\n", img.Tag, img.Digest, cleanup.FormatTime(img.Created, loc))
+		}
+		size += plan.DeleteSize()
+	}
+
+	fmt.Fprintf(w, "\nИтого: репозиториев %d, к удалению образов %d (%s)\n", len(file.Plans), file.DeleteCount(), formatBytes(size))
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"registryCleaner/pkg/cleanup"
+	"registryCleaner/pkg/harbor"
+	"registryCleaner/pkg/registry"
+)
+
+// Допустимые значения --vuln-scanner
+const (
+	ScannerTrivy  = "trivy"
+	ScannerGrype  = "grype"
+	ScannerHarbor = "harbor"
+	ScannerHTTP   = "http"
+)
+
+// describePolicy описывает политику хранения флагами, которыми она задана, например
+// "keep-last 5, keep-prefix release-=10". Описание записывается в --state-file, чтобы было
+// видно, по какой политике очищался репозиторий
+func describePolicy(cfg *Config) string {
+	parts := []string{fmt.Sprintf("keep-last %d", cfg.KeepLast)}
+	add := func(flag, value string) {
+		if value != "" {
+			parts = append(parts, flag+" "+value)
+		}
+	}
+	for _, prefix := range cfg.KeepPrefixes {
+		add("keep-prefix", prefix)
+	}
+	if cfg.KeepPattern != "" {
+		add("keep-pattern", fmt.Sprintf("%s (по %d)", cfg.KeepPattern, cfg.KeepPerGroup))
+	}
+	for _, annotation := range cfg.KeepAnnotations {
+		add("keep-annotation", annotation)
+	}
+	add("expires-label", cfg.ExpiresLabel)
+	add("delete-vulnerable", cfg.DeleteVulnerable)
+	add("keep-clean", cfg.KeepClean)
+	add("git-repo", cfg.GitRepo)
+	if cfg.UnpulledDays > 0 {
+		add("unpulled-days", fmt.Sprint(cfg.UnpulledDays))
+	}
+	for _, filter := range cfg.PurgeFilters {
+		add("purge-filter", filter)
+	}
+	add("purge-ago", cfg.PurgeAgo)
+	add("policy-cel", cfg.PolicyCEL)
+	add("policy-rego", cfg.PolicyRego)
+	add("policy-exec", strings.Join(cfg.PolicyExec, " "))
+	add("policy-wasm", cfg.PolicyWasm)
+	return strings.Join(parts, ", ")
+}
+
+// buildPolicy создает политику хранения, заданную в конфигурации.
+// Возвращаемая функция освобождает ресурсы политики
+func buildPolicy(ctx context.Context, cfg *Config) (cleanup.Policy, func(), error) {
+	var policy cleanup.Policy = cleanup.KeepLastPolicy{N: cfg.KeepLast}
+	closer := func() {}
+
+	if len(cfg.KeepPrefixes) > 0 {
+		prefixes := cleanup.PrefixKeepPolicy{Base: policy}
+		for _, c := range cfg.KeepPrefixes {
+			class, err := cleanup.ParsePrefixClass(c)
+			if err != nil {
+				return nil, nil, err
+			}
+			prefixes.Classes = append(prefixes.Classes, class)
+		}
+		policy = prefixes
+	}
+
+	if cfg.KeepPattern != "" {
+		pattern, err := regexp.Compile(cfg.KeepPattern)
+		if err != nil {
+			return nil, nil, fmt.Errorf("некорректное выражение --keep-pattern: %v", err)
+		}
+		policy = cleanup.GroupKeepPolicy{Base: policy, Pattern: pattern, PerGroup: cfg.KeepPerGroup}
+	}
+
+	if cfg.GitRepo != "" {
+		pattern, err := regexp.Compile(cfg.GitTagPattern)
+		if err != nil {
+			return nil, nil, fmt.Errorf("некорректное выражение --git-tag-pattern: %v", err)
+		}
+		commits, err := cleanup.LoadGitCommits(ctx, cfg.GitRepo, cfg.GitBranches)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ошибка загрузки коммитов %s: %v", cfg.GitRepo, err)
+		}
+		policy = cleanup.GitPolicy{Base: policy, Commits: commits, Pattern: pattern}
+	}
+
+	if len(cfg.KeepAnnotations) > 0 {
+		annotated := cleanup.KeepAnnotatedPolicy{Base: policy}
+		for _, a := range cfg.KeepAnnotations {
+			rule, err := cleanup.ParseAnnotationRule(a)
+			if err != nil {
+				return nil, nil, err
+			}
+			annotated.Rules = append(annotated.Rules, rule)
+		}
+		policy = annotated
+	}
+
+	if cfg.UnpulledDays > 0 {
+		policy = cleanup.UnpulledPolicy{Base: policy, MaxAge: time.Duration(cfg.UnpulledDays) * 24 * time.Hour, Location: cfg.location()}
+	}
+
+	if len(cfg.PurgeFilters) > 0 || cfg.PurgeAgo != "" {
+		purge := cleanup.PurgePolicy{Base: policy, Location: cfg.location()}
+		for _, f := range cfg.PurgeFilters {
+			filter, err := cleanup.ParsePurgeFilter(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			purge.Filters = append(purge.Filters, filter)
+		}
+		if cfg.PurgeAgo != "" {
+			ago, err := cleanup.ParseAgo(cfg.PurgeAgo)
+			if err != nil {
+				return nil, nil, err
+			}
+			purge.Ago = ago
+		}
+		policy = purge
+	}
+
+	if cfg.PolicyCEL != "" {
+		cel, err := cleanup.NewCELPolicy(policy, cfg.PolicyCEL)
+		if err != nil {
+			return nil, nil, err
+		}
+		policy = cel
+	}
+
+	if cfg.PolicyRego != "" {
+		rego, err := cleanup.LoadRegoPolicy(ctx, policy, cfg.PolicyRego, cfg.PolicyRegoQuery)
+		if err != nil {
+			return nil, nil, err
+		}
+		policy = rego
+	}
+
+	if len(cfg.PolicyExec) > 0 {
+		policy = &cleanup.ExecPolicy{
+			Base:    policy,
+			Command: append([]string(nil), cfg.PolicyExec...),
+			Timeout: cfg.PolicyExecTimeout,
+		}
+	}
+
+	if cfg.PolicyWasm != "" {
+		wasm, err := cleanup.LoadWasmPolicy(ctx, policy, cfg.PolicyWasm)
+		if err != nil {
+			return nil, nil, err
+		}
+		wasm.Timeout = cfg.PolicyExecTimeout
+		policy = wasm
+		closer = func() { wasm.Close(context.Background()) }
+	}
+
+	if cfg.VulnScanner != "" {
+		scanned, err := buildScanPolicies(cfg, policy)
+		if err != nil {
+			return nil, nil, err
+		}
+		policy = scanned
+	}
+
+	// Срок хранения из метки образа сильнее остальных политик
+	if cfg.ExpiresLabel != "" {
+		policy = cleanup.ExpirationPolicy{Base: policy, Label: cfg.ExpiresLabel}
+	}
+
+	return policy, closer, nil
+}
+
+// buildScanner создает сканер образов, заданный --vuln-scanner. Итоги сканирования Harbor
+// запрашиваются отдельным клиентом Harbor API
+func buildScanner(cfg *Config) (cleanup.Scanner, error) {
+	if cfg.VulnScanner == ScannerHarbor {
+		return cleanup.BackendScanner{Provider: harbor.NewClient(cfg.RegistryURL, cfg.Username, cfg.Password)}, nil
+	}
+	if cfg.VulnScanner == ScannerHTTP {
+		return &cleanup.HTTPScanner{URL: cfg.ScannerURL, Token: cfg.ScannerToken}, nil
+	}
+
+	u, err := url.Parse(cfg.RegistryURL)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный --registry-url: %v", err)
+	}
+	if cfg.VulnScanner == ScannerGrype {
+		return &cleanup.GrypeScanner{
+			Command:  cfg.GrypeCommand,
+			Registry: u.Host,
+			Insecure: u.Scheme == "http",
+			Username: cfg.Username,
+			Password: cfg.Password,
+		}, nil
+	}
+	return &cleanup.TrivyScanner{
+		Command:  cfg.TrivyCommand,
+		Server:   cfg.TrivyServer,
+		Registry: u.Host,
+		Insecure: u.Scheme == "http",
+		Username: cfg.Username,
+		Password: cfg.Password,
+	}, nil
+}
+
+// buildScanPolicies оборачивает политику сканированием всех образов (--scan-all) и
+// правилами уязвимостей. Обе обертки используют общий кэш итогов сканирования
+func buildScanPolicies(cfg *Config, policy cleanup.Policy) (cleanup.Policy, error) {
+	scanner, err := buildScanner(cfg)
+	if err != nil {
+		return nil, err
+	}
+	scans := cleanup.NewScanCache(scanner, cfg.ScanTimeout)
+	if cfg.ScanAll {
+		policy = &cleanup.ScanPolicy{Base: policy, Scans: scans, Concurrency: cfg.Concurrency}
+	}
+	if cfg.DeleteVulnerable == "" && cfg.KeepClean == "" {
+		return policy, nil
+	}
+
+	vulnerability := &cleanup.VulnerabilityPolicy{Base: policy, Scans: scans, Location: cfg.location()}
+	if cfg.DeleteVulnerable != "" {
+		severity, age, err := cleanup.ParseVulnerableRule(cfg.DeleteVulnerable)
+		if err != nil {
+			return nil, fmt.Errorf("--delete-vulnerable: %v", err)
+		}
+		vulnerability.DeleteSeverity, vulnerability.DeleteAge = severity, age
+	}
+	if cfg.KeepClean != "" {
+		severity, err := registry.ParseSeverity(cfg.KeepClean)
+		if err != nil {
+			return nil, fmt.Errorf("--keep-clean: %v", err)
+		}
+		vulnerability.KeepClean = severity
+	}
+	return vulnerability, nil
+}
+
+// fetchLegalHolds запрашивает список удержания --hold-url; nil, если адрес не задан
+func fetchLegalHolds(cfg *Config) (*cleanup.LegalHolds, error) {
+	if cfg.HoldURL == "" {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	// Транспорт тот же, что у запросов к registry: прокси, заголовки и отладка HTTP
+	client := &http.Client{Transport: newTransport(cfg)}
+	holds, err := cleanup.FetchLegalHolds(ctx, client, cfg.HoldURL, cfg.HoldToken)
+	if err != nil {
+		return nil, fmt.Errorf("--hold-url: %v", err)
+	}
+	return holds, nil
+}
+
+// buildSBOMArchiver создает архив SBOM удаляемых образов. Недостающие SBOM создаются
+// программой syft, только если задан --sbom-generate
+func buildSBOMArchiver(cfg *Config, client *registry.Client) *cleanup.SBOMArchiver {
+	archiver := &cleanup.SBOMArchiver{Client: client, Dir: cfg.SBOMDir, Timeout: cfg.ScanTimeout}
+	if !cfg.SBOMGenerate {
+		return archiver
+	}
+	archiver.Command = cfg.SyftCommand
+	archiver.Username, archiver.Password = cfg.Username, cfg.Password
+	if u, err := url.Parse(cfg.RegistryURL); err == nil {
+		archiver.Registry, archiver.Insecure = u.Host, u.Scheme == "http"
+	}
+	return archiver
+}
+
+// buildSignatureVerifier создает проверку подписей доверенными ключами и удостоверениями
+// и список тегов, для которых она отменяется; nil, если доверенные ключи не заданы
+func buildSignatureVerifier(cfg *Config) (*cleanup.SignatureVerifier, *cleanup.ProtectedTags, error) {
+	if len(cfg.TrustedKeys) == 0 && len(cfg.TrustedIdentities) == 0 {
+		return nil, nil, nil
+	}
+	u, err := url.Parse(cfg.RegistryURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("некорректный --registry-url: %v", err)
+	}
+	verifier := &cleanup.SignatureVerifier{
+		Command:  cfg.CosignCommand,
+		Keys:     cfg.TrustedKeys,
+		Registry: u.Host,
+		Insecure: u.Scheme == "http",
+		Timeout:  cfg.ScanTimeout,
+	}
+	for _, s := range cfg.TrustedIdentities {
+		identity, err := cleanup.ParseTrustedIdentity(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		verifier.Identities = append(verifier.Identities, identity)
+	}
+
+	var deleteSigned *cleanup.ProtectedTags
+	if len(cfg.DeleteSigned) > 0 {
+		if deleteSigned, err = cleanup.ParseProtectedTags(cfg.DeleteSigned); err != nil {
+			return nil, nil, err
+		}
+	}
+	return verifier, deleteSigned, nil
+}
+
+// buildTagTime создает разбор времени создания из имен тегов, если он задан в конфигурации
+func buildTagTime(cfg *Config) (*cleanup.TagTimeParser, error) {
+	if cfg.TagTimeLayout == "" {
+		return nil, nil
+	}
+	parser, err := cleanup.NewTagTimeParser(cfg.TagTimeLayout, cfg.TagTimePattern)
+	if err != nil {
+		return nil, err
+	}
+	parser.Location = cfg.location()
+	return parser, nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"registryCleaner/pkg/registry"
+)
+
+// runPreflightCommand выполняет подкоманду preflight: проверяет доступность /v2/, версию
+// API, поддержку удаления и права учетной записи в каждом очищаемом репозитории, ничего
+// не удаляя. С --json результат выводится в формате JSON.
+// Завершается с кодом 1, если очистка невозможна
+func runPreflightCommand(args []string) int {
+	cfg := parseConfig(args)
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: %v\n", err)
+		return 2
+	}
+	if cfg.Backend != BackendRegistry {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: preflight доступна только с --backend registry\n")
+		return 2
+	}
+
+	ctx := context.Background()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	client := newRegistryClient(cfg)
+	caps := client.Preflight(ctx, "")
+	// Удаление проверяется на первом репозитории, который будет очищаться
+	denied := 0
+	if caps.OK() {
+		repositories, err := listRepositories(ctx, cfg, client)
+		if err != nil {
+			caps.Problems = append(caps.Problems, fmt.Sprintf("не удалось получить список репозиториев: %v", err))
+		} else if repositories = cleanupRepositories(cfg, repositories); len(repositories) > 0 {
+			caps = client.Preflight(ctx, repositories[0])
+			if caps.OK() {
+				caps.Repositories = checkAccess(ctx, client, repositories, cfg.Concurrency)
+			}
+		}
+		for _, access := range caps.Repositories {
+			if !access.OK() {
+				denied++
+			}
+		}
+	}
+
+	if cfg.JSONOutput {
+		data, err := json.MarshalIndent(caps, "", "  ")
+		if err != nil {
+			log.Printf("%v", err)
+			return 1
+		}
+		fmt.Println(string(data))
+	} else {
+		printCapabilities(caps)
+	}
+	if !caps.OK() || denied > 0 {
+		return 1
+	}
+	return 0
+}
+
+// checkPreflight проверяет перед удалением, что registry доступен и поддерживает удаление,
+// а учетная запись может получить теги и удалять манифесты в каждом из repositories.
+// Возвращает репозитории, доступные для очистки, ошибки доступа к остальным и false,
+// если очистку выполнять нельзя
+func checkPreflight(ctx context.Context, cfg *Config, client *registry.Client, repositories []string) ([]string, []error, bool) {
+	if cfg.SkipPreflight || cfg.Backend != BackendRegistry || len(repositories) == 0 {
+		return repositories, nil, true
+	}
+	caps := client.Preflight(ctx, repositories[0])
+	if !caps.OK() {
+		printCapabilities(caps)
+		fmt.Println("Очистка не запускалась. Пропустить проверку: --skip-preflight")
+		return nil, nil, false
+	}
+	for _, warning := range caps.Warnings {
+		fmt.Printf("Предупреждение: %s\n", warning)
+	}
+
+	var allowed []string
+	var failures []error
+	for _, access := range checkAccess(ctx, client, repositories, cfg.Concurrency) {
+		if access.OK() {
+			allowed = append(allowed, access.Repository)
+			continue
+		}
+		err := fmt.Errorf("%s: %s", access.Repository, strings.Join(access.Problems, ", "))
+		fmt.Printf("⛔ Репозиторий пропущен: %v\n", err)
+		failures = append(failures, err)
+	}
+	if len(failures) > 0 {
+		fmt.Printf("Недоступно для очистки репозиториев: %d из %d\n", len(failures), len(repositories))
+	}
+	if len(allowed) == 0 {
+		fmt.Println("Очистка не запускалась: нет доступных репозиториев. Пропустить проверку: --skip-preflight")
+		return nil, failures, false
+	}
+	return allowed, failures, true
+}
+
+// checkAccess проверяет права учетной записи в репозиториях, одновременно не больше
+// concurrency репозиториев
+func checkAccess(ctx context.Context, client *registry.Client, repositories []string, concurrency int) []registry.RepositoryAccess {
+	results := make([]registry.RepositoryAccess, len(repositories))
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i, repo := range repositories {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = client.CheckAccess(ctx, repo)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// printCapabilities выводит результат предварительной проверки
+func printCapabilities(caps *registry.Capabilities) {
+	fmt.Printf("\n🩺 Проверка registry %s\n", caps.URL)
+	fmt.Printf("  Доступен: %s\n", yesNo(caps.Reachable))
+	if caps.Reachable {
+		fmt.Printf("  Авторизация: %s\n", yesNo(caps.Authorized))
+		fmt.Printf("  Версия API: %s\n", valueOr(caps.APIVersion, "не указана"))
+	}
+	switch caps.Delete {
+	case registry.DeleteEnabled:
+		fmt.Printf("  Удаление: включено (проверено на %s)\n", caps.ProbeRepository)
+	case registry.DeleteDisabled:
+		fmt.Printf("  Удаление: выключено (storage.delete.enabled: false)\n")
+	case registry.DeleteDenied:
+		fmt.Printf("  Удаление: запрещено для учетной записи (статус %d)\n", caps.DeleteStatus)
+	default:
+		fmt.Printf("  Удаление: не определено\n")
+	}
+	for _, warning := range caps.Warnings {
+		fmt.Printf("  ⚠️  %s\n", warning)
+	}
+	for _, problem := range caps.Problems {
+		fmt.Printf("  ❌ %s\n", problem)
+	}
+	if caps.Delete == registry.DeleteDisabled {
+		fmt.Println("  Включите удаление в config.yml registry (storage: delete: enabled: true), см. REGISTRY_SETUP.md")
+	}
+
+	if len(caps.Repositories) == 0 {
+		return
+	}
+	denied := 0
+	for _, access := range caps.Repositories {
+		if !access.OK() {
+			denied++
+		}
+	}
+	fmt.Printf("\n  Права в репозиториях: доступно %d из %d\n", len(caps.Repositories)-denied, len(caps.Repositories))
+	for _, access := range caps.Repositories {
+		if !access.OK() {
+			fmt.Printf("  ❌ %s: %s\n", access.Repository, strings.Join(access.Problems, ", "))
+		}
+	}
+}
+
+// yesNo форматирует логическое значение для вывода
+func yesNo(v bool) string {
+	if v {
+		return "да"
+	}
+	return "нет"
+}
+
+// valueOr возвращает value или def, если value пусто
+func valueOr(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
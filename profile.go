@@ -0,0 +1,89 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	httppprof "net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// Допустимые значения --profile
+const (
+	ProfileCPU = "cpu"
+	ProfileMem = "mem"
+)
+
+// startProfiling запускает сервер pprof и запись профиля, заданные в конфигурации.
+// Возвращаемая функция останавливает профилирование и записывает профиль в файл,
+// ее нужно вызвать перед выходом. Ошибки профилирования не мешают очистке и только
+// выводятся
+func startProfiling(cfg *Config) func() {
+	if cfg.PprofAddr != "" {
+		servePprof(cfg.PprofAddr)
+	}
+	if cfg.Profile == "" {
+		return func() {}
+	}
+
+	path := cfg.ProfileFile
+	if path == "" {
+		path = cfg.Profile + ".pprof"
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		log.Printf("Предупреждение: не удалось создать файл профиля: %v", err)
+		return func() {}
+	}
+
+	if cfg.Profile == ProfileCPU {
+		if err := pprof.StartCPUProfile(file); err != nil {
+			log.Printf("Предупреждение: не удалось запустить профилирование CPU: %v", err)
+			file.Close()
+			return func() {}
+		}
+	}
+
+	return func() {
+		switch cfg.Profile {
+		case ProfileCPU:
+			pprof.StopCPUProfile()
+		case ProfileMem:
+			// Профиль памяти отражает состояние после последней сборки мусора
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(file); err != nil {
+				log.Printf("Предупреждение: не удалось записать профиль памяти: %v", err)
+			}
+		}
+		if err := file.Close(); err != nil {
+			log.Printf("Предупреждение: не удалось записать профиль %s: %v", path, err)
+			return
+		}
+		log.Printf("Профиль %s записан в %s", cfg.Profile, path)
+	}
+}
+
+// servePprof запускает HTTP-сервер net/http/pprof на отдельном адресе, чтобы
+// профилировать долгий запуск на лету
+func servePprof(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", httppprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", httppprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", httppprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", httppprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", httppprof.Trace)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Printf("Предупреждение: не удалось запустить pprof на %s: %v", addr, err)
+		return
+	}
+	log.Printf("pprof: http://%s/debug/pprof/", listener.Addr())
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Printf("Предупреждение: сервер pprof остановлен: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// progressFDEnv переменная окружения с номером файлового дескриптора, в который
+// дочерний процесс очистки, запущенный serve, передает ход обработки репозиториев
+const progressFDEnv = "REGISTRY_CLEANER_PROGRESS_FD"
+
+// Этапы обработки репозитория
+const (
+	stagePlanned  = "planned"
+	stageExecuted = "executed"
+)
+
+// progressEvent обработка одного репозитория. Index - номер репозитория, начиная с 1,
+// из Total на этом этапе
+type progressEvent struct {
+	Repository string `json:"repository"`
+	Stage      string `json:"stage"`
+	Index      int    `json:"index"`
+	Total      int    `json:"total"`
+	Keep       int    `json:"keep"`
+	Delete     int    `json:"delete"`
+	Deleted    int    `json:"deleted"`
+	Error      string `json:"error,omitempty"`
+}
+
+// progressReporter передает события хода очистки построчно в формате JSON
+type progressReporter struct {
+	enc *json.Encoder
+}
+
+// openProgress возвращает получателя событий хода очистки, если serve передал
+// дескриптор в progressFDEnv, иначе nil
+func openProgress() *progressReporter {
+	fd, err := strconv.Atoi(os.Getenv(progressFDEnv))
+	if err != nil || fd < 3 {
+		return nil
+	}
+	// Дескриптор не должен наследоваться внешними командами, например хуками garbage collection
+	syscall.CloseOnExec(fd)
+	return &progressReporter{enc: json.NewEncoder(os.NewFile(uintptr(fd), "progress"))}
+}
+
+// report передает событие; ошибки записи не прерывают очистку
+func (p *progressReporter) report(event progressEvent) {
+	if p == nil {
+		return
+	}
+	p.enc.Encode(event)
+}
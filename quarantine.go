@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"registryCleaner/pkg/cleanup"
+)
+
+// runPurgeQuarantine выполняет подкоманду purge-quarantine: удаляет образы, помещенные в
+// карантин раньше --older-than назад. Теги карантина ищутся во всех репозиториях каталога,
+// поэтому подкоманде не нужен способ --quarantine, которым образы помещались в карантин
+func runPurgeQuarantine(args []string) int {
+	cfg := parseConfig(args)
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: %v\n", err)
+		return 2
+	}
+	defer startLogSink(cfg)()
+	defer startProfiling(cfg)()
+	defer reportPanic(cfg)
+	if cfg.Backend != BackendRegistry {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: purge-quarantine доступна только с --backend registry\n")
+		return 2
+	}
+	if cfg.QuarantineOlderThan == "" {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: purge-quarantine требует --older-than\n")
+		return 2
+	}
+	age, _ := cleanup.ParseAgo(cfg.QuarantineOlderThan)
+	before := cleanup.Cutoff(time.Now(), age, cfg.location())
+
+	ctx := context.Background()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	client := newRegistryClient(cfg)
+	quarantine := cleanup.NewQuarantine(client, cleanup.QuarantineRepository, strings.Trim(cfg.QuarantinePrefix, "/"))
+	// Образы неизменяемых тегов и на удержании не удаляются и из карантина
+	if cfg.ProtectedFile != "" {
+		protected, err := cleanup.LoadProtectedTags(cfg.ProtectedFile)
+		if err != nil {
+			log.Printf("Ошибка загрузки неизменяемых тегов: %v", err)
+			return 1
+		}
+		quarantine.Protected = protected
+	}
+	holds, err := fetchLegalHolds(cfg)
+	if err != nil {
+		log.Printf("Ошибка загрузки списка удержания: %v", err)
+		return 1
+	}
+	quarantine.Holds = holds
+
+	// Удаление из карантина не выполняется одновременно с очисткой
+	ctx, release, ok := acquireLocks(ctx, cfg, client)
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	repositories, err := client.ListRepositories(ctx)
+	if err != nil {
+		log.Printf("Ошибка при получении списка репозиториев: %v", err)
+		return 1
+	}
+
+	fmt.Printf("Удаление образов, помещенных в карантин до %s\n", cleanup.FormatTime(before, cfg.location()))
+	purged := 0
+	var failures []error
+	for _, repo := range quarantineRepositories(cfg, repositories) {
+		// Контекст отменяется и при потере блокировки
+		if ctx.Err() != nil {
+			failures = append(failures, ctx.Err())
+			break
+		}
+		n, errs, err := quarantine.Purge(ctx, repo, before)
+		if err != nil {
+			fmt.Printf("Ошибка при получении тегов %s: %v\n", repo, err)
+			failures = append(failures, fmt.Errorf("%s: %w", repo, err))
+			continue
+		}
+		purged += n
+		failures = append(failures, errs...)
+	}
+
+	fmt.Printf("\n🗑️  Удалено из карантина образов: %d\n", purged)
+	printFailures(failures)
+	reportErrors(cfg, failures)
+	if len(failures) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// quarantineRepositories отбирает репозитории по --repository и --exclude-namespace. Для
+// репозиториев карантина <--quarantine-prefix>/<репозиторий> проверяется исходное имя
+func quarantineRepositories(cfg *Config, repositories []string) []string {
+	prefix := strings.Trim(cfg.QuarantinePrefix, "/") + "/"
+	var result []string
+	for _, repo := range repositories {
+		original := repo
+		if prefix != "/" {
+			original = strings.TrimPrefix(repo, prefix)
+		}
+		if len(cleanupRepositories(cfg, []string{original})) > 0 {
+			result = append(result, repo)
+		}
+	}
+	return result
+}
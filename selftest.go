@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"registryCleaner/pkg/cleanup"
+	"registryCleaner/pkg/registry"
+	"registryCleaner/pkg/registrytest"
+)
+
+// selftestRepository репозиторий, которым заполняется встроенный registry
+type selftestRepository struct {
+	name string
+	tags int
+}
+
+// selftestRepositories набор репозиториев для самопроверки: с лишними образами,
+// с образами ровно на границе политики и с единственным образом
+func selftestRepositories(keepLast int) []selftestRepository {
+	return []selftestRepository{
+		{name: "selftest/service", tags: keepLast + 3},
+		{name: "selftest/boundary", tags: keepLast},
+		{name: "selftest/single", tags: 1},
+	}
+}
+
+// runSelftest запускает встроенный registry, заполняет его тестовыми образами,
+// выполняет очистку по keep-last из параметров args и проверяет, что остались ожидаемые теги.
+// Остальные правила политики не применяются: ожидаемые теги известны только для keep-last
+func runSelftest(args []string) int {
+	cfg := parseConfig(args)
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: %v\n", err)
+		return 2
+	}
+	ctx := context.Background()
+
+	fmt.Printf("🧪 Самопроверка очистки на встроенном registry\n")
+	if policy := describePolicy(cfg); policy != fmt.Sprintf("keep-last %d", cfg.KeepLast) {
+		fmt.Printf("Предупреждение: самопроверка применяет только keep-last %d, остальные правила политики (%s) не проверяются\n", cfg.KeepLast, policy)
+	}
+
+	mock := registrytest.New()
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	// Теги создаются с интервалом в сутки, каждый следующий новее предыдущего
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	expected := make(map[string][]string)
+	for _, repo := range selftestRepositories(cfg.KeepLast) {
+		var tags []string
+		for i := 0; i < repo.tags; i++ {
+			tag := fmt.Sprintf("v%d", i+1)
+			mock.Seed(repo.name, tag, base.Add(time.Duration(i)*24*time.Hour))
+			tags = append(tags, tag)
+		}
+		if len(tags) > cfg.KeepLast {
+			tags = tags[len(tags)-cfg.KeepLast:]
+		}
+		sort.Strings(tags)
+		expected[repo.name] = tags
+	}
+
+	client := registry.NewClient(server.URL, "", "")
+	planner := &cleanup.Planner{
+		Backend:     client,
+		Policy:      cleanup.KeepLastPolicy{N: cfg.KeepLast},
+		Concurrency: cfg.Concurrency,
+	}
+	executor := &cleanup.Executor{Backend: client}
+
+	repositories, err := client.ListRepositories(ctx)
+	if err != nil {
+		fmt.Printf("❌ Не удалось получить список репозиториев: %v\n", err)
+		return 1
+	}
+	for _, repo := range repositories {
+		plan, err := planner.Plan(ctx, repo)
+		if err != nil {
+			fmt.Printf("❌ Ошибка составления плана для %s: %v\n", repo, err)
+			return 1
+		}
+		if err := executor.Execute(ctx, plan); err != nil {
+			fmt.Printf("❌ Ошибка очистки %s: %v\n", repo, err)
+			return 1
+		}
+	}
+
+	fmt.Printf("\nРезультаты самопроверки:\n")
+	failed := false
+	for _, repo := range selftestRepositories(cfg.KeepLast) {
+		got := mock.Tags(repo.name)
+		want := expected[repo.name]
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			fmt.Printf("  ❌ %s: остались теги [%s], ожидались [%s]\n", repo.name, strings.Join(got, ", "), strings.Join(want, ", "))
+			failed = true
+			continue
+		}
+		fmt.Printf("  ✅ %s: остались теги [%s]\n", repo.name, strings.Join(got, ", "))
+	}
+
+	if failed {
+		fmt.Printf("\n❌ Самопроверка не пройдена\n")
+		return 1
+	}
+	fmt.Printf("\n✅ Самопроверка пройдена\n")
+	return 0
+}
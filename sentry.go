@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"registryCleaner/pkg/registry"
+	"registryCleaner/pkg/sentry"
+)
+
+// sentryMaxErrors сколько ошибок каждого репозитория передается в событии Sentry
+const sentryMaxErrors = 20
+
+// newSentry создает клиент Sentry по --sentry-dsn или nil, если он не задан. DSN
+// проверен в Validate
+func newSentry(cfg *Config) *sentry.Client {
+	if cfg.SentryDSN == "" {
+		return nil
+	}
+	client, err := sentry.NewClient(cfg.SentryDSN)
+	if err != nil {
+		return nil
+	}
+	client.Environment = cfg.SentryEnvironment
+	client.Release = registry.DefaultUserAgent()
+	client.ServerName, _ = os.Hostname()
+	return client
+}
+
+// sentryTags теги событий запуска: registry, backend и подкоманда
+func sentryTags(cfg *Config) map[string]string {
+	command := "cleanup"
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		command = os.Args[1]
+	}
+	return map[string]string{"registry": urlHost(cfg.RegistryURL), "backend": cfg.Backend, "command": command}
+}
+
+// reportPanic отправляет панику в Sentry и продолжает ее, чтобы процесс завершился как
+// обычно. Вызывается через defer в начале подкоманды; паники в других горутинах не
+// перехватываются
+func reportPanic(cfg *Config) {
+	client := newSentry(cfg)
+	if client == nil {
+		return
+	}
+	value := recover()
+	if value == nil {
+		return
+	}
+	// Пропускаются reportPanic и runtime.gopanic
+	event := &sentry.Event{Level: sentry.LevelFatal, Tags: sentryTags(cfg), Exception: sentry.PanicException(value, 2)}
+	if _, err := client.Capture(context.Background(), event); err != nil {
+		log.Printf("Предупреждение: %v", err)
+	}
+	panic(value)
+}
+
+// reportErrors отправляет ошибки запуска в Sentry одним событием, сгруппированными по
+// репозиториям. События одного registry объединяются в Sentry в одну проблему
+func reportErrors(cfg *Config, failures []error) {
+	client := newSentry(cfg)
+	if client == nil || len(failures) == 0 {
+		return
+	}
+
+	byRepository := make(map[string][]string)
+	for _, err := range failures {
+		repo := failureRepository(err)
+		if len(byRepository[repo]) < sentryMaxErrors {
+			byRepository[repo] = append(byRepository[repo], err.Error())
+		}
+	}
+
+	tags := sentryTags(cfg)
+	if len(byRepository) == 1 {
+		for repo := range byRepository {
+			tags["repository"] = repo
+		}
+	}
+	event := &sentry.Event{
+		Level:       sentry.LevelError,
+		Message:     fmt.Sprintf("Очистка %s завершилась с ошибками: %d", urlHost(cfg.RegistryURL), len(failures)),
+		Tags:        tags,
+		Extra:       map[string]interface{}{"errors": len(failures), "repositories": byRepository},
+		Fingerprint: []string{"registry-cleaner-errors", urlHost(cfg.RegistryURL)},
+	}
+	if _, err := client.Capture(context.Background(), event); err != nil {
+		fmt.Printf("Предупреждение: %v\n", err)
+	}
+}
@@ -0,0 +1,816 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"k8s.io/client-go/tools/leaderelection"
+
+	"registryCleaner/pkg/registry"
+)
+
+// serveHistoryLimit количество последних запусков, которые хранит сервер
+const serveHistoryLimit = 50
+
+// planCacheTTL время, в течение которого план очистки отдается без повторного обхода registry
+const planCacheTTL = 5 * time.Minute
+
+// Состояния запуска очистки
+const (
+	runRunning   = "running"
+	runSucceeded = "succeeded"
+	runFailed    = "failed"
+)
+
+// serverRun запуск очистки, выполняемый сервером в дочернем процессе
+type serverRun struct {
+	id      int
+	started time.Time
+
+	mu       sync.Mutex
+	finished time.Time
+	status   string
+	exitCode int
+	output   []string
+	progress []progressEvent
+	// changed закрывается и заменяется при каждом изменении запуска
+	changed chan struct{}
+	cmd     *exec.Cmd
+}
+
+// runSummary сведения о запуске в ответах API
+type runSummary struct {
+	ID       int        `json:"id"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Status   string     `json:"status"`
+	ExitCode int        `json:"exitCode"`
+	Output   []string   `json:"output,omitempty"`
+}
+
+// apiImage образ в плане очистки
+type apiImage struct {
+	Tag        string     `json:"tag"`
+	Digest     string     `json:"digest"`
+	Created    time.Time  `json:"created"`
+	Size       int64      `json:"size"`
+	LastPulled *time.Time `json:"lastPulled,omitempty"`
+}
+
+// apiPlan план очистки репозитория
+type apiPlan struct {
+	Repository string     `json:"repository"`
+	Error      string     `json:"error,omitempty"`
+	Keep       []apiImage `json:"keep"`
+	Delete     []apiImage `json:"delete"`
+}
+
+// summary возвращает сведения о запуске, с выводом, если withOutput
+func (r *serverRun) summary(withOutput bool) runSummary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := runSummary{ID: r.id, Started: r.started, Status: r.status, ExitCode: r.exitCode}
+	if !r.finished.IsZero() {
+		finished := r.finished
+		s.Finished = &finished
+	}
+	if withOutput {
+		s.Output = append([]string{}, r.output...)
+	}
+	return s
+}
+
+// appendLine добавляет строку вывода и оповещает подписчиков
+func (r *serverRun) appendLine(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.output = append(r.output, line)
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// appendProgress добавляет событие обработки репозитория и оповещает подписчиков
+func (r *serverRun) appendProgress(event progressEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.progress = append(r.progress, event)
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// since возвращает строки вывода и события обработки репозиториев после первых lines и events,
+// канал, который закроется при следующем изменении, и признак завершения запуска
+func (r *serverRun) since(lines, events int) ([]string, []progressEvent, <-chan struct{}, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.output[lines:], r.progress[events:], r.changed, !r.finished.IsZero()
+}
+
+// finish отмечает завершение дочернего процесса
+func (r *serverRun) finish(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.finished = time.Now()
+	r.status = runSucceeded
+	if err != nil {
+		r.status = runFailed
+		r.exitCode = 1
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			r.exitCode = exit.ExitCode()
+		} else {
+			r.output = append(r.output, fmt.Sprintf("Ошибка запуска очистки: %v", err))
+		}
+	}
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// Server HTTP API для запуска очистки, просмотра плана и истории запусков.
+// Очистка выполняется дочерним процессом с теми же параметрами, что и у сервера
+type Server struct {
+	cfg  *Config
+	args []string
+	// env секреты из флагов сервера, которые дочерний процесс получает через окружение
+	env []string
+
+	// elector выбор лидера среди реплик; nil, если сервер работает в одном экземпляре
+	elector *leaderelection.LeaderElector
+
+	mu     sync.Mutex
+	runs   []*serverRun
+	nextID int
+	wg     sync.WaitGroup
+
+	// planning не дает нескольким запросам одновременно составлять план
+	planning sync.Mutex
+	// plans последний составленный план; после запуска очистки он устаревает (planStale)
+	plans     []apiPlan
+	plannedAt time.Time
+	planStale bool
+}
+
+// NewServer создает сервер для конфигурации cfg, разобранной из args
+func NewServer(cfg *Config, args []string) *Server {
+	childArgs, env := childCommand(args)
+	return &Server{cfg: cfg, args: childArgs, env: env, nextID: 1}
+}
+
+// serveOnlyFlags флаги сервера, которые не передаются дочернему процессу очистки
+var serveOnlyFlags = map[string]bool{
+	"listen":                 true,
+	"grpc-listen":            true,
+	"api-token":              true,
+	"api-viewer-token":       true,
+	"leader-elect":           true,
+	"leader-elect-lease":     true,
+	"leader-elect-namespace": true,
+}
+
+// serveOnlyEnv переменные окружения сервера, которые не передаются дочернему процессу очистки
+var serveOnlyEnv = []string{"API_TOKEN", "API_VIEWER_TOKEN"}
+
+// secretFlags флаги с секретами и переменные окружения, через которые их значения
+// получает дочерний процесс: командная строка процесса видна всем пользователям в ps
+var secretFlags = map[string]string{
+	"password":              "REGISTRY_PASSWORD",
+	"gitlab-token":          "GITLAB_TOKEN",
+	"quay-token":            "QUAY_TOKEN",
+	"artifactory-token":     "ARTIFACTORY_TOKEN",
+	"hold-token":            "HOLD_TOKEN",
+	"archive-password":      "ARCHIVE_REGISTRY_PASSWORD",
+	"jira-token":            "JIRA_TOKEN",
+	"pagerduty-routing-key": "PAGERDUTY_ROUTING_KEY",
+	"opsgenie-api-key":      "OPSGENIE_API_KEY",
+	"sentry-dsn":            "SENTRY_DSN",
+	"scanner-token":         "SCANNER_TOKEN",
+	"gc-azure-key":          "AZURE_STORAGE_KEY",
+}
+
+// childCommand разбирает аргументы сервера на аргументы дочернего процесса очистки и
+// переменные окружения с секретами. Флаги режима serve отбрасываются, значения флагов
+// с секретами переносятся в окружение
+func childCommand(args []string) (childArgs, env []string) {
+	fs := newFlagSet(&Config{})
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		// Как и пакет flag, разбор заканчивается на первом аргументе, не являющемся флагом
+		if arg == "-" || arg == "--" || !strings.HasPrefix(arg, "-") {
+			return append(childArgs, args[i:]...), env
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-"), "=")
+		f := fs.Lookup(name)
+		if f == nil {
+			childArgs = append(childArgs, arg)
+			continue
+		}
+		// Значение флага, кроме логического, может идти отдельным аргументом
+		boolFlag, _ := f.Value.(interface{ IsBoolFlag() bool })
+		separate := !hasValue && (boolFlag == nil || !boolFlag.IsBoolFlag()) && i+1 < len(args)
+		if separate {
+			value = args[i+1]
+		}
+		switch {
+		case serveOnlyFlags[name]:
+		case secretFlags[name] != "":
+			env = append(env, secretFlags[name]+"="+value)
+		case separate:
+			childArgs = append(childArgs, arg, value)
+		default:
+			childArgs = append(childArgs, arg)
+		}
+		if separate {
+			i++
+		}
+	}
+	return childArgs, env
+}
+
+// childEnv возвращает окружение дочернего процесса очистки: окружение сервера без
+// токенов API и с секретами из флагов сервера
+func (s *Server) childEnv() []string {
+	env := make([]string, 0, len(os.Environ())+len(s.env)+1)
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if !slices.Contains(serveOnlyEnv, name) {
+			env = append(env, kv)
+		}
+	}
+	return append(env, s.env...)
+}
+
+// Handler возвращает обработчик API с проверкой токена и веб-интерфейса.
+// Страницы интерфейса отдаются без токена, его запрашивает сама страница
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/v1/runs", s.authorize(roleOperator, s.startRun))
+	mux.Handle("GET /api/v1/runs", s.authorize(roleViewer, s.listRuns))
+	mux.Handle("GET /api/v1/runs/{id}", s.authorize(roleViewer, s.getRun))
+	mux.Handle("GET /api/v1/runs/{id}/events", s.authorize(roleViewer, s.streamRun))
+	mux.Handle("GET /api/v1/plan", s.authorize(roleViewer, s.getPlan))
+	mux.Handle("GET /api/v1/session", s.authorize(roleViewer, s.getSession))
+	mux.HandleFunc("GET /readyz", s.ready)
+	mux.Handle("/", webHandler())
+	return mux
+}
+
+// role уровень доступа к API
+type role int
+
+// Уровни доступа: viewer видит планы и запуски, operator также запускает очистку
+const (
+	roleNone role = iota
+	roleViewer
+	roleOperator
+)
+
+// String возвращает название роли
+func (r role) String() string {
+	switch r {
+	case roleViewer:
+		return "viewer"
+	case roleOperator:
+		return "operator"
+	default:
+		return "none"
+	}
+}
+
+// roleOf возвращает роль по значению Authorization: Bearer <токен>.
+// --api-token дает роль operator, --api-viewer-token - viewer
+func (s *Server) roleOf(authorization string) role {
+	if subtle.ConstantTimeCompare([]byte(authorization), []byte("Bearer "+s.cfg.APIToken)) == 1 {
+		return roleOperator
+	}
+	if s.cfg.APIViewerToken != "" &&
+		subtle.ConstantTimeCompare([]byte(authorization), []byte("Bearer "+s.cfg.APIViewerToken)) == 1 {
+		return roleViewer
+	}
+	return roleNone
+}
+
+// authorize пропускает только запросы с токеном роли не ниже required
+func (s *Server) authorize(required role, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch got := s.roleOf(r.Header.Get("Authorization")); {
+		case got == roleNone:
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "требуется токен API")
+		case got < required:
+			writeError(w, http.StatusForbidden, fmt.Sprintf("недостаточно прав: требуется роль %s", required))
+		default:
+			next(w, r)
+		}
+	})
+}
+
+// ready отвечает на проверку готовности: экземпляр в резерве не готов, чтобы
+// Service Kubernetes направлял запросы только лидеру
+func (s *Server) ready(w http.ResponseWriter, _ *http.Request) {
+	if !s.leading() {
+		writeError(w, http.StatusServiceUnavailable, errNotLeader.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// getSession возвращает роль токена запроса, чтобы веб-интерфейс скрывал недоступные действия
+func (s *Server) getSession(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"role": s.roleOf(r.Header.Get("Authorization")).String()})
+}
+
+// writeJSON отправляет ответ в формате JSON
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError отправляет ошибку в формате JSON
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// Ошибки запуска очистки
+var (
+	errRunInProgress     = errors.New("очистка уже выполняется")
+	errUnknownRepository = errors.New("репозиторий не очищается с параметрами сервера")
+	errNotLeader         = errors.New("экземпляр в резерве, очистку запускает лидер")
+	errPlanNotReady      = errors.New("план еще не составлен, составить его может только роль operator")
+)
+
+// leading сообщает, может ли экземпляр запускать очистку
+func (s *Server) leading() bool {
+	return s.elector == nil || s.elector.IsLeader()
+}
+
+// findRun возвращает запуск по идентификатору или nil
+func (s *Server) findRun(id int) *serverRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, run := range s.runs {
+		if run.id == id {
+			return run
+		}
+	}
+	return nil
+}
+
+// pathRun возвращает запуск по идентификатору из пути запроса или nil
+func (s *Server) pathRun(r *http.Request) *serverRun {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return nil
+	}
+	return s.findRun(id)
+}
+
+// start запускает очистку дочерним процессом, если она еще не выполняется.
+// Непустой repositories ограничивает очистку этими репозиториями
+func (s *Server) start(repositories []string) (*serverRun, error) {
+	// Флаги репозиториев идут перед аргументами сервера: разбор флагов дочернего процесса
+	// заканчивается на "--" или первом позиционном аргументе
+	var args []string
+	for _, repo := range repositories {
+		if len(s.cfg.Repositories) > 0 && !slices.Contains(s.cfg.Repositories, repo) {
+			return nil, fmt.Errorf("%w: %s", errUnknownRepository, repo)
+		}
+		args = append(args, "--repository", repo)
+	}
+	args = append(args, s.args...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.leading() {
+		return nil, fmt.Errorf("%w (лидер: %s)", errNotLeader, s.elector.GetLeader())
+	}
+	for _, run := range s.runs {
+		if run.summary(false).Status == runRunning {
+			return nil, fmt.Errorf("%w (запуск %d)", errRunInProgress, run.id)
+		}
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось определить путь программы: %v", err)
+	}
+
+	// Ход обработки репозиториев дочерний процесс передает в отдельный канал (дескриптор 3)
+	progressReader, progressWriter, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запуска очистки: %v", err)
+	}
+	defer progressWriter.Close()
+
+	run := &serverRun{id: s.nextID, started: time.Now(), status: runRunning, changed: make(chan struct{})}
+	reader, writer := io.Pipe()
+	run.cmd = exec.Command(exe, args...)
+	run.cmd.Stdout = writer
+	run.cmd.Stderr = writer
+	run.cmd.ExtraFiles = []*os.File{progressWriter}
+	run.cmd.Env = append(s.childEnv(), progressFDEnv+"=3")
+	if err := run.cmd.Start(); err != nil {
+		progressReader.Close()
+		return nil, fmt.Errorf("ошибка запуска очистки: %v", err)
+	}
+	s.nextID++
+
+	s.runs = append(s.runs, run)
+	if len(s.runs) > serveHistoryLimit {
+		s.runs = s.runs[len(s.runs)-serveHistoryLimit:]
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		scanned := make(chan struct{}, 2)
+		go func() {
+			defer func() { scanned <- struct{}{} }()
+			scanner := bufio.NewScanner(reader)
+			for scanner.Scan() {
+				run.appendLine(scanner.Text())
+			}
+			io.Copy(io.Discard, reader)
+		}()
+		go func() {
+			defer func() { scanned <- struct{}{} }()
+			defer progressReader.Close()
+			decoder := json.NewDecoder(progressReader)
+			for {
+				var event progressEvent
+				if err := decoder.Decode(&event); err != nil {
+					return
+				}
+				run.appendProgress(event)
+			}
+		}()
+		err := run.cmd.Wait()
+		writer.Close()
+		<-scanned
+		<-scanned
+		run.finish(err)
+		s.mu.Lock()
+		s.planStale = true
+		s.mu.Unlock()
+		log.Printf("Запуск %d завершен: %s", run.id, run.summary(false).Status)
+	}()
+
+	log.Printf("Запуск %d начат", run.id)
+	return run, nil
+}
+
+// startRequest необязательное тело запроса запуска очистки
+type startRequest struct {
+	// Repositories репозитории, очистка которых утверждена; пустой список - все
+	Repositories []string `json:"repositories"`
+}
+
+// startRun запускает очистку, если она еще не выполняется
+func (s *Server) startRun(w http.ResponseWriter, r *http.Request) {
+	var req startRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("некорректный запрос: %v", err))
+		return
+	}
+
+	run, err := s.start(req.Repositories)
+	if errors.Is(err, errRunInProgress) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, errUnknownRepository) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, errNotLeader) {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/runs/%d", run.id))
+	writeJSON(w, http.StatusAccepted, run.summary(false))
+}
+
+// listRuns возвращает историю запусков, начиная с последнего
+func (s *Server) listRuns(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	runs := append([]*serverRun{}, s.runs...)
+	s.mu.Unlock()
+
+	summaries := make([]runSummary, 0, len(runs))
+	for i := len(runs) - 1; i >= 0; i-- {
+		summaries = append(summaries, runs[i].summary(false))
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+// getRun возвращает запуск вместе с его выводом
+func (s *Server) getRun(w http.ResponseWriter, r *http.Request) {
+	run := s.pathRun(r)
+	if run == nil {
+		writeError(w, http.StatusNotFound, "запуск не найден")
+		return
+	}
+	writeJSON(w, http.StatusOK, run.summary(true))
+}
+
+// streamRun передает ход запуска как Server-Sent Events: каждое событие output
+// содержит строку вывода, progress - обработку репозитория, done - итог запуска
+func (s *Server) streamRun(w http.ResponseWriter, r *http.Request) {
+	run := s.pathRun(r)
+	if run == nil {
+		writeError(w, http.StatusNotFound, "запуск не найден")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	controller := http.NewResponseController(w)
+
+	sentLines, sentEvents := 0, 0
+	for {
+		lines, events, changed, done := run.since(sentLines, sentEvents)
+		for _, line := range lines {
+			data, _ := json.Marshal(line)
+			fmt.Fprintf(w, "event: output\ndata: %s\n\n", data)
+		}
+		for _, event := range events {
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
+		}
+		sentLines += len(lines)
+		sentEvents += len(events)
+		if done {
+			data, _ := json.Marshal(run.summary(false))
+			fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+			controller.Flush()
+			return
+		}
+		controller.Flush()
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// getPlan возвращает план очистки без удаления образов; время его составления
+// передается в Last-Modified
+func (s *Server) getPlan(w http.ResponseWriter, r *http.Request) {
+	plans, plannedAt, err := s.plan(r.Context(), s.roleOf(r.Header.Get("Authorization")) == roleOperator)
+	if errors.Is(err, errPlanNotReady) {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	w.Header().Set("Last-Modified", plannedAt.UTC().Format(http.TimeFormat))
+	writeJSON(w, http.StatusOK, plans)
+}
+
+// plan возвращает последний план очистки, если он составлен не раньше planCacheTTL
+// и после него не было запусков. Иначе план заново составляется только при mayPlan
+// (роль operator): обход всего registry нагружает его, поэтому viewer получает
+// последний составленный план
+func (s *Server) plan(ctx context.Context, mayPlan bool) ([]apiPlan, time.Time, error) {
+	if mayPlan {
+		// Запросы, пришедшие во время составления плана, получают его же
+		s.planning.Lock()
+		defer s.planning.Unlock()
+	}
+	s.mu.Lock()
+	plans, plannedAt := s.plans, s.plannedAt
+	fresh := plans != nil && !s.planStale && time.Since(plannedAt) < planCacheTTL
+	s.mu.Unlock()
+	if fresh || (!mayPlan && plans != nil) {
+		return plans, plannedAt, nil
+	}
+	if !mayPlan {
+		return nil, time.Time{}, errPlanNotReady
+	}
+
+	plans, err := planCleanup(ctx, s.cfg)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	plannedAt = time.Now()
+	s.mu.Lock()
+	s.plans, s.plannedAt, s.planStale = plans, plannedAt, false
+	s.mu.Unlock()
+	return plans, plannedAt, nil
+}
+
+// planCleanup составляет планы очистки всех репозиториев так же, как запуск очистки
+func planCleanup(ctx context.Context, cfg *Config) ([]apiPlan, error) {
+	policy, closePolicy, err := buildPolicy(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки политики: %v", err)
+	}
+	defer closePolicy()
+
+	client := newRegistryClient(cfg)
+	backend, err := buildBackend(ctx, cfg, client)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка настройки backend: %v", err)
+	}
+	// Кэш метаданных и отметки удаления не сохраняются: просмотр плана не должен
+	// менять файлы, с которыми работает запущенная очистка
+	planner, closePlanner, err := newPlanner(ctx, cfg, backend, policy, io.Discard)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка настройки очистки: %v", err)
+	}
+	defer closePlanner()
+
+	repositories, err := planRepositories(ctx, cfg, backend, planner.Tenants)
+	if err != nil {
+		return nil, err
+	}
+
+	plans := []apiPlan{}
+	for _, repo := range repositories {
+		result := apiPlan{Repository: repo, Keep: []apiImage{}, Delete: []apiImage{}}
+		plan, err := planner.Plan(ctx, repo)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Keep = apiImages(plan.Keep)
+			result.Delete = apiImages(plan.Delete)
+		}
+		plans = append(plans, result)
+	}
+	return plans, nil
+}
+
+// apiImages преобразует образы плана для ответа API
+func apiImages(images []registry.ImageInfo) []apiImage {
+	result := make([]apiImage, 0, len(images))
+	for _, img := range images {
+		item := apiImage{Tag: img.Tag, Digest: img.Digest, Created: img.Created, Size: img.Size}
+		if !img.LastPulled.IsZero() {
+			lastPulled := img.LastPulled
+			item.LastPulled = &lastPulled
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// stop просит выполняющуюся очистку завершиться, как по SIGTERM, и дожидается ее
+func (s *Server) stop() {
+	s.signal(syscall.SIGTERM)
+	s.wg.Wait()
+}
+
+// terminate просит выполняющуюся очистку завершиться по SIGTERM, а если она не
+// завершилась за grace, завершает ее по SIGKILL, и дожидается ее. Используется при
+// потере лидерства: после SIGTERM очистка освобождает блокировки сама, но не должна
+// продолжать удаление, когда очистку уже может начать новый лидер
+func (s *Server) terminate(grace time.Duration) {
+	s.signal(syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return
+	case <-time.After(grace):
+	}
+	s.signal(os.Kill)
+	<-done
+}
+
+// signal отправляет sig выполняющимся дочерним процессам очистки
+func (s *Server) signal(sig os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, run := range s.runs {
+		if run.summary(false).Status == runRunning {
+			run.cmd.Process.Signal(sig)
+		}
+	}
+}
+
+// runServe запускает HTTP API с параметрами очистки из args и возвращает код выхода процесса
+func runServe(args []string) int {
+	cfg := parseConfig(args)
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: %v\n", err)
+		return 2
+	}
+	defer startLogSink(cfg)()
+	defer startProfiling(cfg)()
+	if cfg.APIToken == "" {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: для serve требуется --api-token\n")
+		return 2
+	}
+	if cfg.APIViewerToken == cfg.APIToken {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: --api-viewer-token должен отличаться от --api-token\n")
+		return 2
+	}
+	if cfg.Interactive {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: --interactive недоступен в serve\n")
+		return 2
+	}
+	if cfg.ReposFile == "-" {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: --repos-file - недоступен в serve, укажите файл\n")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	server := NewServer(cfg, args)
+	var election sync.WaitGroup
+	electionCtx, stopElection := context.WithCancel(context.Background())
+	defer func() {
+		// Lease освобождается только после остановки очистки, чтобы новый лидер
+		// не начал ее одновременно с этим экземпляром
+		stopElection()
+		election.Wait()
+	}()
+	if cfg.LeaderElect {
+		elector, err := newLeaderElector(cfg, server)
+		if err != nil {
+			log.Printf("Ошибка настройки выбора лидера: %v", err)
+			return 1
+		}
+		server.elector = elector
+		election.Add(1)
+		go func() {
+			defer election.Done()
+			runLeaderElection(electionCtx, elector)
+		}()
+		fmt.Printf("Выбор лидера через Lease %s включен\n", cfg.LeaderElectLease)
+	}
+
+	httpServer := &http.Server{Addr: cfg.Listen, Handler: server.Handler()}
+	errs := make(chan error, 2)
+	go func() {
+		errs <- httpServer.ListenAndServe()
+	}()
+	fmt.Printf("🐳 Docker Registry Cleaner: API доступен на %s\n", cfg.Listen)
+
+	var grpcServer *grpc.Server
+	if cfg.GRPCListen != "" {
+		listener, err := net.Listen("tcp", cfg.GRPCListen)
+		if err != nil {
+			log.Printf("Ошибка gRPC сервера: %v", err)
+			return 1
+		}
+		grpcServer = server.NewGRPCServer()
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				errs <- err
+			}
+		}()
+		fmt.Printf("gRPC API доступен на %s\n", cfg.GRPCListen)
+	}
+
+	code := 0
+	select {
+	case err := <-errs:
+		// Очистку, начатую через упавший сервер, нельзя оставлять без присмотра
+		log.Printf("Ошибка сервера: %v", err)
+		code = 1
+	case <-ctx.Done():
+		fmt.Println("\n⛔ Остановка сервера, дожидаемся выполняющейся очистки")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	httpServer.Shutdown(shutdownCtx)
+	server.stop()
+	if grpcServer != nil {
+		// Потоки WatchRun завершаются вместе с запусками, поэтому остановка не зависает
+		grpcServer.GracefulStop()
+	}
+	return code
+}
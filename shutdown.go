@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Shutdown отслеживает сигналы SIGINT/SIGTERM. Первый сигнал просит завершить работу
+// после текущих запросов, второй немедленно завершает процесс
+type Shutdown struct {
+	mu     sync.Mutex
+	signal os.Signal
+}
+
+// NewShutdown начинает перехват сигналов остановки
+func NewShutdown() *Shutdown {
+	s := &Shutdown{}
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signals
+		s.mu.Lock()
+		s.signal = sig
+		s.mu.Unlock()
+		fmt.Printf("\n⛔ Получен сигнал %s: новые удаления не начинаются, дожидаемся текущих запросов. Повторный сигнал прервет работу немедленно\n", sig)
+
+		sig = <-signals
+		fmt.Printf("\n⛔ Получен повторный сигнал %s, немедленное завершение\n", sig)
+		os.Exit(signalExitCode(sig))
+	}()
+
+	return s
+}
+
+// Requested сообщает, была ли запрошена остановка
+func (s *Shutdown) Requested() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.signal != nil
+}
+
+// ExitCode возвращает код выхода по соглашению shell: 128 + номер сигнала
+func (s *Shutdown) ExitCode() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return signalExitCode(s.signal)
+}
+
+// signalExitCode возвращает код выхода для завершения по сигналу
+func signalExitCode(sig os.Signal) int {
+	if number, ok := sig.(syscall.Signal); ok {
+		return 128 + int(number)
+	}
+	return 1
+}
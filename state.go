@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"registryCleaner/pkg/cleanup"
+)
+
+// unmanagedListLimit максимальное количество репозиториев не под управлением в сообщении
+const unmanagedListLimit = 20
+
+// managedRepositories оставляет из repositories только взятые под управление, то есть
+// записанные в состояние. С --adopt новые репозитории берутся под управление, без него
+// пропускаются. Для управляемых репозиториев, политика которых изменилась с прошлой
+// очистки, выводится прежняя политика
+func managedRepositories(cfg *Config, state *cleanup.State, tenants *cleanup.Tenants, repositories []string) []string {
+	var managed, unmanaged []string
+	for _, repo := range repositories {
+		previous := state.Repository(repo)
+		switch {
+		case previous != nil:
+			if previous.Policy != "" && previous.Policy != repositoryPolicy(cfg, tenants, repo) {
+				fmt.Printf("Политика очистки %s изменилась с прошлой очистки, прежняя: %s\n", repo, previous.Policy)
+			}
+			managed = append(managed, repo)
+		case cfg.Adopt:
+			state.Adopt(repo)
+			fmt.Printf("Репозиторий %s взят под управление\n", repo)
+			managed = append(managed, repo)
+		default:
+			unmanaged = append(unmanaged, repo)
+		}
+	}
+
+	if len(unmanaged) > 0 {
+		listed := unmanaged
+		if len(listed) > unmanagedListLimit {
+			listed = listed[:unmanagedListLimit]
+		}
+		fmt.Printf("Репозиториев не под управлением: %d, они не очищаются (взять под управление: --adopt): %s",
+			len(unmanaged), strings.Join(listed, ", "))
+		if len(unmanaged) > len(listed) {
+			fmt.Printf(" и еще %d", len(unmanaged)-len(listed))
+		}
+		fmt.Println()
+	}
+	return managed
+}
+
+// runStateCommand выполняет подкоманду state: выводит репозитории под управлением из
+// файла состояния, когда они взяты под управление и последний раз очищены, политику и
+// решения последней очистки
+func runStateCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Использование: registry-cleaner state <состояние.json>\n")
+		return 2
+	}
+	if _, err := os.Stat(args[0]); err != nil {
+		log.Printf("Ошибка чтения состояния: %v", err)
+		return 1
+	}
+	state, err := cleanup.LoadState(args[0])
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	if len(state.Repositories) == 0 {
+		fmt.Println("Репозиториев под управлением нет")
+		return 0
+	}
+
+	repositories := make([]string, 0, len(state.Repositories))
+	for repo := range state.Repositories {
+		repositories = append(repositories, repo)
+	}
+	sort.Strings(repositories)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Репозиторий\tПод управлением с\tПоследняя очистка\tСохранено\tУдалено\tПолитика")
+	for _, repo := range repositories {
+		repoState := state.Repositories[repo]
+		firstSeen, cleaned := "-", "-"
+		if !repoState.FirstSeen.IsZero() {
+			firstSeen = cleanup.FormatTime(repoState.FirstSeen, nil)
+		}
+		if !repoState.Cleaned.IsZero() {
+			cleaned = cleanup.FormatTime(repoState.Cleaned, nil)
+		}
+		policy := repoState.Policy
+		if policy == "" {
+			policy = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n", repo, firstSeen, cleaned, repoState.Kept, len(repoState.Deleted), policy)
+	}
+	w.Flush()
+	if state.Checkpoint != nil {
+		fmt.Printf("\nПрерванный запуск для %s от %s: обработано %d репозиториев\n",
+			state.Checkpoint.Registry, cleanup.FormatTime(state.Checkpoint.Started, nil), len(state.Checkpoint.Completed))
+	}
+	return 0
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"registryCleaner/pkg/cleanup"
+)
+
+// cleanupStats итоги запуска очистки
+type cleanupStats struct {
+	Started time.Time `json:"started"`
+	// Repositories репозитории, выбранные для очистки
+	Repositories int `json:"repositories"`
+	// Processed репозитории, для которых составлен план с образами
+	Processed int `json:"processed"`
+	// Skipped репозитории, пропущенные без запроса метаданных: неизменившиеся
+	// или с количеством тегов не больше сохраняемого
+	Skipped int `json:"skipped"`
+	// Failed репозитории, план которых составить не удалось
+	Failed       int `json:"failed"`
+	TagsExamined int `json:"tagsExamined"`
+	Deleted      int `json:"deleted"`
+	// DeleteFailed образы, удалить которые не удалось
+	DeleteFailed int `json:"deleteFailed"`
+	// DeleteFailedRepositories репозитории, в которых не удалось удалить ни одного из
+	// образов плана из-за ошибок
+	DeleteFailedRepositories int `json:"deleteFailedRepositories"`
+	// BytesReclaimed оценка места, которое освободит garbage collection: размер слоев
+	// удаленных образов, на которые не ссылаются оставшиеся образы
+	BytesReclaimed  int64   `json:"bytesReclaimed"`
+	Failures        int     `json:"failures"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	Interrupted     bool    `json:"interrupted"`
+	// Alerts нарушенные пороги оповещения --alert-*
+	Alerts []string `json:"alerts,omitempty"`
+	// Held образы, которые были бы удалены, но находятся на удержании --hold-url
+	Held []heldImage `json:"held,omitempty"`
+	// RepositoryStats репозитории с удаленными образами по убыванию освобожденного места
+	RepositoryStats []repositoryStats `json:"repositoryStats"`
+
+	// results итоги каждого репозитория с составленным планом в порядке обработки
+	results []*repositoryResult
+}
+
+// repositoryResult итоги плана репозитория для отчета JUnit
+type repositoryResult struct {
+	Repository string
+	Tags       int
+	Keep       int
+	Delete     int
+	Deleted    int
+	Unchanged  bool
+	Executed   bool
+}
+
+// repositoryStats итоги очистки одного репозитория
+type repositoryStats struct {
+	Repository     string `json:"repository"`
+	Deleted        int    `json:"deleted"`
+	BytesReclaimed int64  `json:"bytesReclaimed"`
+}
+
+// heldImage образ на удержании и причина удержания
+type heldImage struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// newCleanupStats подсчитывает итоги по составленным и выполненным планам. deleteFailed
+// количество ошибок удаления по репозиториям
+func newCleanupStats(started time.Time, repositories, failed int, plans, executed []*cleanup.RepositoryPlan, deleteFailed map[string]int, failures []error) *cleanupStats {
+	summary := startCleanupStats(started, repositories)
+	summary.Failed = failed
+	for _, plan := range plans {
+		summary.addPlanned(plan)
+	}
+	reclaimed := cleanup.EstimateReclaimed(plans)
+	for _, plan := range executed {
+		summary.addExecuted(plan, reclaimed[plan.Repository], deleteFailed[plan.Repository])
+	}
+	summary.finish(len(failures))
+	return summary
+}
+
+// startCleanupStats создает пустые итоги, которые заполняются по мере очистки
+func startCleanupStats(started time.Time, repositories int) *cleanupStats {
+	return &cleanupStats{Started: started, Repositories: repositories, RepositoryStats: []repositoryStats{}}
+}
+
+// addPlanned учитывает составленный план
+func (s *cleanupStats) addPlanned(plan *cleanup.RepositoryPlan) {
+	s.TagsExamined += len(plan.Tags)
+	if plan.Unchanged || plan.Total() == 0 {
+		s.Skipped++
+	} else {
+		s.Processed++
+	}
+	for _, img := range plan.Held {
+		s.Held = append(s.Held, heldImage{Repository: img.Repository, Tag: img.Tag, Digest: img.Digest, Reason: img.Reason})
+	}
+	s.results = append(s.results, &repositoryResult{
+		Repository: plan.Repository, Tags: len(plan.Tags), Keep: len(plan.Keep), Delete: len(plan.Delete), Unchanged: plan.Unchanged,
+	})
+}
+
+// addExecuted учитывает выполненный план, оценку освобожденного им места и количество
+// образов, удалить которые не удалось
+func (s *cleanupStats) addExecuted(plan *cleanup.RepositoryPlan, reclaimed int64, failed int) {
+	s.DeleteFailed += failed
+	for _, result := range s.results {
+		if result.Repository == plan.Repository {
+			result.Executed, result.Deleted = true, len(plan.Deleted)
+		}
+	}
+	if len(plan.Deleted) == 0 {
+		if failed > 0 {
+			s.DeleteFailedRepositories++
+		}
+		return
+	}
+	repo := repositoryStats{Repository: plan.Repository, Deleted: len(plan.Deleted), BytesReclaimed: reclaimed}
+	s.Deleted += repo.Deleted
+	s.BytesReclaimed += repo.BytesReclaimed
+	s.RepositoryStats = append(s.RepositoryStats, repo)
+}
+
+// finish завершает подсчет: фиксирует количество ошибок и длительность и сортирует
+// репозитории по освобожденному месту
+func (s *cleanupStats) finish(failures int) {
+	s.Failures = failures
+	s.DurationSeconds = time.Since(s.Started).Seconds()
+	sort.SliceStable(s.RepositoryStats, func(i, j int) bool {
+		a, b := s.RepositoryStats[i], s.RepositoryStats[j]
+		if a.BytesReclaimed != b.BytesReclaimed {
+			return a.BytesReclaimed > b.BytesReclaimed
+		}
+		return a.Deleted > b.Deleted
+	})
+}
+
+// print выводит итоги запуска
+func (s *cleanupStats) print() {
+	fmt.Printf("\n📊 Итоги запуска\n")
+	fmt.Printf("  Репозиториев: %d (обработано %d, пропущено %d, с ошибками %d)\n", s.Repositories, s.Processed, s.Skipped, s.Failed)
+	fmt.Printf("  Тегов проверено: %d\n", s.TagsExamined)
+	fmt.Printf("  Манифестов удалено: %d\n", s.Deleted)
+	if s.DeleteFailed > 0 {
+		fmt.Printf("  Не удалось удалить: %d\n", s.DeleteFailed)
+	}
+	if len(s.Held) > 0 {
+		fmt.Printf("  На удержании: %d\n", len(s.Held))
+	}
+	fmt.Printf("  Освобождено (оценка): %s\n", formatBytes(s.BytesReclaimed))
+	fmt.Printf("  Ошибок: %d\n", s.Failures)
+	fmt.Printf("  Длительность: %s\n", (time.Duration(s.DurationSeconds * float64(time.Second))).Round(time.Millisecond))
+	if len(s.Alerts) > 0 {
+		fmt.Printf("\n🚨 Превышены пороги оповещения:\n")
+		for _, alert := range s.Alerts {
+			fmt.Printf("  - %s\n", alert)
+		}
+	}
+	if len(s.Held) > 0 {
+		fmt.Printf("\n🔒 Не удалены из-за удержания:\n")
+		for _, img := range s.Held {
+			fmt.Printf("  - %s:%s", img.Repository, img.Tag)
+			if img.Reason != "" {
+				fmt.Printf(" (%s)", img.Reason)
+			}
+			fmt.Println()
+		}
+	}
+	s.printRepositories()
+}
+
+// printRepositories выводит таблицу репозиториев с удаленными образами, начиная с тех,
+// где освобождено больше всего места
+func (s *cleanupStats) printRepositories() {
+	if len(s.RepositoryStats) == 0 {
+		return
+	}
+	fmt.Println("\nОсвобождено по репозиториям:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  Репозиторий\tУдалено\tОсвобождено")
+	for _, repo := range s.RepositoryStats {
+		fmt.Fprintf(w, "  %s\t%d\t%s\n", repo.Repository, repo.Deleted, formatBytes(repo.BytesReclaimed))
+	}
+	w.Flush()
+}
+
+// save записывает итоги в файл в формате JSON
+func (s *cleanupStats) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("ошибка записи итогов %s: %v", path, err)
+	}
+	return nil
+}
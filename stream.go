@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"registryCleaner/pkg/cleanup"
+	"registryCleaner/pkg/gc"
+	"registryCleaner/pkg/registry"
+)
+
+// streamBuffer сколько составленных планов ожидают удаления в потоковом режиме: планы
+// следующих репозиториев составляются, пока удаляются образы текущего
+const streamBuffer = 2
+
+// streamRun зависимости потоковой очистки, подготовленные run
+type streamRun struct {
+	cfg      *Config
+	client   *registry.Client
+	backend  registry.Backend
+	planner  *cleanup.Planner
+	executor *cleanup.Executor
+	state    *cleanup.State
+	shutdown *Shutdown
+	gcSetup  *gcSetup
+	started  time.Time
+	// targeted количество репозиториев, выбранных для очистки, включая недоступные
+	targeted int
+}
+
+// plannedRepository план репозитория, составленный в потоковом режиме, вместе с выводом
+// Planner: он печатается перед удалением, чтобы не перемешиваться с выводом Executor
+type plannedRepository struct {
+	index      int
+	repository string
+	plan       *cleanup.RepositoryPlan
+	err        error
+	log        []byte
+}
+
+// runStream выполняет очистку потоком: план каждого репозитория выполняется сразу после
+// составления и затем освобождается, а итоги подсчитываются по мере очистки. В памяти
+// находятся список репозиториев и образы не более streamBuffer+1 репозиториев, поэтому
+// пороги --max-delete-* проверяются для каждого репозитория и по уже удаленным образам,
+// а не по плану всего registry
+func runStream(ctx context.Context, run *streamRun, repositories []string, failures []error) int {
+	cfg := run.cfg
+	stats := startCleanupStats(run.started, run.targeted)
+	stats.Failed = len(failures)
+	info := gc.Info{RegistryURL: cfg.RegistryURL}
+	guard := &cleanup.DeleteGuard{MaxPercent: cfg.MaxDeletePercent, MaxCount: cfg.MaxDeleteCount}
+	progress := openProgress()
+
+	var confirmer *Confirmer
+	if cfg.Interactive {
+		confirmer = NewConfirmer(os.Stdin, os.Stdout, cfg.location())
+	}
+
+	// Запоминаем прогресс, чтобы прерванный запуск можно было продолжить
+	if run.state != nil {
+		checkpoint := run.state.InterruptedCheckpoint(cfg.RegistryURL)
+		if checkpoint == nil || cfg.Restart {
+			if err := run.state.StartCheckpoint(cfg.RegistryURL); err != nil {
+				fmt.Printf("Предупреждение: не удалось сохранить прогресс: %v\n", err)
+			}
+		}
+	}
+
+	// Планы составляются в отдельной горутине и передаются через ограниченный буфер
+	planCtx, stopPlanning := context.WithCancel(ctx)
+	defer stopPlanning()
+	planned := make(chan plannedRepository, streamBuffer)
+	go func() {
+		defer close(planned)
+		for i, repo := range repositories {
+			if run.shutdown.Requested() || planCtx.Err() != nil {
+				return
+			}
+			var log bytes.Buffer
+			planner := *run.planner
+			planner.Log = &log
+			plan, err := planner.Plan(planCtx, repo)
+			select {
+			case planned <- plannedRepository{index: i + 1, repository: repo, plan: plan, err: err, log: log.Bytes()}:
+			case <-planCtx.Done():
+				return
+			}
+		}
+	}()
+
+	processed, deleted := 0, 0
+	stopped := false
+stream:
+	for item := range planned {
+		if run.shutdown.Requested() || ctx.Err() != nil {
+			break
+		}
+		os.Stdout.Write(item.log)
+		processed++
+
+		event := progressEvent{Repository: item.repository, Stage: stagePlanned, Index: item.index, Total: len(repositories)}
+		if item.err != nil {
+			fmt.Printf("Ошибка при очистке репозитория %s: %v\n", item.repository, item.err)
+			failures = append(failures, fmt.Errorf("%s: %w", item.repository, item.err))
+			stats.Failed++
+			event.Error = item.err.Error()
+			progress.report(event)
+			if cfg.FailFast {
+				stopped = true
+				break
+			}
+			continue
+		}
+
+		plan := item.plan
+		failures = append(failures, plan.Errors...)
+		event.Keep, event.Delete = len(plan.Keep), len(plan.Delete)
+		progress.report(event)
+		stats.addPlanned(plan)
+		if cfg.FailFast && len(plan.Errors) > 0 {
+			stopped = true
+			break
+		}
+
+		if violations := guard.CheckStream(plan, deleted); len(violations) > 0 {
+			fmt.Printf("\n🚨 План очистки %s превышает допустимые пороги:\n", plan.Repository)
+			for _, v := range violations {
+				fmt.Printf("  - %s\n", v)
+			}
+			if !cfg.Force {
+				fmt.Printf("Удаление остановлено. Проверьте политику очистки или запустите с --force\n")
+				failures = append(failures, fmt.Errorf("%s: план превышает допустимые пороги удаления", plan.Repository))
+				stopped = true
+				break
+			}
+			fmt.Printf("Указан --force, продолжаем удаление\n\n")
+		}
+
+		if confirmer != nil && len(plan.Delete) > 0 {
+			switch confirmer.Confirm(plan) {
+			case ConfirmNo:
+				fmt.Printf("Репозиторий %s пропущен\n", plan.Repository)
+				continue
+			case ConfirmQuit:
+				fmt.Println("Удаление прервано пользователем")
+				break stream
+			}
+		}
+
+		err := run.executor.Execute(ctx, plan)
+		event = progressEvent{
+			Repository: plan.Repository, Stage: stageExecuted, Index: item.index, Total: len(repositories),
+			Keep: len(plan.Keep), Delete: len(plan.Delete), Deleted: len(plan.Deleted),
+		}
+		var failed []error
+		if err != nil {
+			event.Error = err.Error()
+			failed = deleteFailures(err)
+			failures = append(failures, failed...)
+		}
+		progress.report(event)
+
+		// Слои, общие с другими репозиториями, неизвестны: оценка - верхняя граница
+		stats.addExecuted(plan, cleanup.EstimateReclaimed([]*cleanup.RepositoryPlan{plan})[plan.Repository], len(failed))
+		addGCInfo(&info, plan)
+		deleted += len(plan.Deleted)
+
+		if errors.Is(err, cleanup.ErrInterrupted) {
+			break
+		}
+		if err == nil && run.state != nil {
+			run.state.RecordRepository(plan, repositoryPolicy(run.cfg, run.planner.Tenants, plan.Repository))
+		}
+		if run.state != nil {
+			if err := run.state.CheckpointRepository(plan.Repository); err != nil {
+				fmt.Printf("Предупреждение: не удалось сохранить прогресс: %v\n", err)
+			}
+		}
+		if err != nil && cfg.FailFast {
+			stopped = true
+			break
+		}
+	}
+
+	// Дожидаемся остановки составления планов, чтобы кэши сохранялись без него
+	stopPlanning()
+	for range planned {
+	}
+	if run.planner.Cache != nil {
+		if err := run.planner.Cache.Save(); err != nil {
+			fmt.Printf("Предупреждение: не удалось сохранить кэш: %v\n", err)
+		}
+	}
+	if run.planner.SoftDelete != nil {
+		if err := run.planner.SoftDelete.Save(); err != nil {
+			fmt.Printf("Предупреждение: не удалось сохранить отметки удаления: %v\n", err)
+		}
+	}
+	saveClientCaches(run.client)
+	stats.finish(len(failures))
+
+	stoppedOnError := stopped || (cfg.FailFast && len(failures) > 0)
+	if run.shutdown.Requested() || ctx.Err() != nil || stoppedOnError {
+		switch {
+		case stoppedOnError && cfg.FailFast:
+			fmt.Println("\n⛔ Очистка остановлена после ошибки (--fail-fast)")
+		case ctx.Err() != nil:
+			fmt.Printf("\n⛔ Превышено время работы (--timeout %s)\n", cfg.Timeout)
+		}
+		fmt.Printf("\n⛔ Очистка прервана\n")
+		fmt.Printf("  Репозиториев обработано: %d из %d\n", processed, len(repositories))
+		fmt.Printf("  Образов удалено: %d\n", deleted)
+		reportSummary(cfg, stats, failures, true)
+		if run.state != nil {
+			if err := run.state.Save(); err != nil {
+				fmt.Printf("Предупреждение: не удалось сохранить состояние: %v\n", err)
+			} else {
+				fmt.Printf("Прогресс сохранен в %s, следующий запуск продолжит очистку\n", cfg.StateFile)
+			}
+		}
+		if run.shutdown.Requested() && !stoppedOnError {
+			return run.shutdown.ExitCode()
+		}
+		return 1
+	}
+
+	if run.state != nil {
+		run.state.FinishCheckpoint()
+		if err := run.state.Save(); err != nil {
+			fmt.Printf("Предупреждение: не удалось сохранить состояние: %v\n", err)
+		}
+	}
+
+	if len(failures) > 0 {
+		fmt.Println("\n⚠️  Очистка завершена с ошибками")
+	} else {
+		fmt.Println("\n✅ Очистка завершена!")
+	}
+	code := finishCleanup(ctx, cfg, run.backend, run.gcSetup, info)
+	reportSummary(cfg, stats, failures, false)
+	if len(failures) > 0 {
+		return 1
+	}
+	return code
+}
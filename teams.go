@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"registryCleaner/pkg/cleanup"
+)
+
+// teamConfig блок команды в --teams-file: пространства имен, которыми она владеет, ее
+// политика хранения в терминах флагов очистки, неизменяемые теги и адреса уведомлений
+type teamConfig struct {
+	Name       string   `json:"name"`
+	Namespaces []string `json:"namespaces"`
+	// KeepLast количество сохраняемых новейших образов, по умолчанию 2, как у очистки
+	KeepLast        *int     `json:"keepLast,omitempty"`
+	KeepPrefixes    []string `json:"keepPrefixes,omitempty"`
+	KeepPattern     string   `json:"keepPattern,omitempty"`
+	KeepPerGroup    int      `json:"keepPerGroup,omitempty"`
+	KeepAnnotations []string `json:"keepAnnotations,omitempty"`
+	UnpulledDays    int      `json:"unpulledDays,omitempty"`
+	PurgeAgo        string   `json:"purgeAgo,omitempty"`
+	PolicyCEL       string   `json:"policyCel,omitempty"`
+	// Protected неизменяемые теги команды <репозиторий>:<тег>; шаблон репозитория должен
+	// начинаться с одного из пространств имен команды
+	Protected      []string `json:"protected,omitempty"`
+	NotifyWebhooks []string `json:"notifyWebhooks,omitempty"`
+}
+
+// loadTeamsFile читает --teams-file: JSON-объект {"teams": [...]} с блоками teamConfig
+func loadTeamsFile(path string) ([]teamConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла команд: %v", err)
+	}
+	var file struct {
+		Teams []teamConfig `json:"teams"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("ошибка разбора файла команд %s: %v", path, err)
+	}
+	if len(file.Teams) == 0 {
+		return nil, fmt.Errorf("в файле команд %s нет ни одной команды", path)
+	}
+	return file.Teams, nil
+}
+
+// teamPolicyConfig возвращает конфигурацию очистки с политикой команды: флаги политики
+// хранения заменяются значениями из блока команды, общие флаги политики на команду не
+// действуют
+func teamPolicyConfig(cfg *Config, team teamConfig) *Config {
+	teamCfg := *cfg
+	teamCfg.KeepLast = 2
+	if team.KeepLast != nil {
+		teamCfg.KeepLast = *team.KeepLast
+	}
+	teamCfg.KeepPrefixes = team.KeepPrefixes
+	teamCfg.KeepPattern = team.KeepPattern
+	teamCfg.KeepPerGroup = 3
+	if team.KeepPerGroup > 0 {
+		teamCfg.KeepPerGroup = team.KeepPerGroup
+	}
+	teamCfg.KeepAnnotations = team.KeepAnnotations
+	teamCfg.UnpulledDays = team.UnpulledDays
+	teamCfg.PurgeAgo = team.PurgeAgo
+	teamCfg.PolicyCEL = team.PolicyCEL
+	teamCfg.PurgeFilters = nil
+	teamCfg.ExpiresLabel = ""
+	teamCfg.DeleteVulnerable = ""
+	teamCfg.KeepClean = ""
+	teamCfg.GitRepo = ""
+	teamCfg.PolicyRego = ""
+	teamCfg.PolicyExec = nil
+	teamCfg.PolicyWasm = ""
+	return &teamCfg
+}
+
+// newTenants создает команды из блоков --teams-file без политик хранения: этого
+// достаточно, чтобы проверить файл и определить владельца репозитория
+func newTenants(teams []teamConfig) (*cleanup.Tenants, error) {
+	var tenants []*cleanup.Tenant
+	for _, team := range teams {
+		tenant := &cleanup.Tenant{Name: team.Name, Namespaces: append([]string(nil), team.Namespaces...)}
+		if len(team.Protected) > 0 {
+			protected, err := cleanup.ParseProtectedTags(team.Protected)
+			if err != nil {
+				return nil, fmt.Errorf("неизменяемые теги команды %s: %v", team.Name, err)
+			}
+			tenant.Protected = protected
+		}
+		tenants = append(tenants, tenant)
+	}
+	return cleanup.NewTenants(tenants)
+}
+
+// buildTenants создает команды --teams-file с их политиками хранения. Возвращаемая
+// функция освобождает ресурсы политик
+func buildTenants(ctx context.Context, cfg *Config) (*cleanup.Tenants, func(), error) {
+	if cfg.TeamsFile == "" {
+		return nil, func() {}, nil
+	}
+	teams, err := loadTeamsFile(cfg.TeamsFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	tenants, err := newTenants(teams)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var closers []func()
+	closeAll := func() {
+		for _, closer := range closers {
+			closer()
+		}
+	}
+	for _, team := range teams {
+		teamCfg := teamPolicyConfig(cfg, team)
+		policy, closePolicy, err := buildPolicy(ctx, teamCfg)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("политика команды %s: %v", team.Name, err)
+		}
+		closers = append(closers, closePolicy)
+		for _, tenant := range tenants.All() {
+			if tenant.Name == team.Name {
+				tenant.Policy = policy
+				tenant.Description = describePolicy(teamCfg)
+			}
+		}
+	}
+	return tenants, closeAll, nil
+}
+
+// repositoryPolicy описывает политику, по которой очищается репозиторий: политику
+// команды-владельца или общую
+func repositoryPolicy(cfg *Config, tenants *cleanup.Tenants, repository string) string {
+	if tenant := tenants.Owner(repository); tenant != nil {
+		return "команда " + tenant.Name + ": " + tenant.Description
+	}
+	return describePolicy(cfg)
+}
+
+// teamNotification уведомление команды об очистке ее репозиториев
+type teamNotification struct {
+	Severity     string           `json:"severity"`
+	Registry     string           `json:"registry"`
+	Team         string           `json:"team"`
+	Interrupted  bool             `json:"interrupted"`
+	Repositories []teamRepository `json:"repositories"`
+	Errors       []string         `json:"errors,omitempty"`
+}
+
+// teamRepository итоги очистки репозитория команды
+type teamRepository struct {
+	Repository     string `json:"repository"`
+	Deleted        int    `json:"deleted"`
+	BytesReclaimed int64  `json:"bytesReclaimed"`
+}
+
+// notifyTeams отправляет каждой команде с notifyWebhooks итоги очистки только ее
+// репозиториев и их ошибки. Уровень уведомления - warning при ошибках или прерывании,
+// иначе info; уведомления ниже --notify-severity не отправляются
+func notifyTeams(cfg *Config, s *cleanupStats, failures []error) {
+	if cfg.TeamsFile == "" {
+		return
+	}
+	teams, err := loadTeamsFile(cfg.TeamsFile)
+	if err != nil {
+		fmt.Printf("Предупреждение: уведомления команд не отправлены: %v\n", err)
+		return
+	}
+	tenants, err := newTenants(teams)
+	if err != nil {
+		fmt.Printf("Предупреждение: уведомления команд не отправлены: %v\n", err)
+		return
+	}
+
+	reclaimed := make(map[string]int64)
+	for _, repo := range s.RepositoryStats {
+		reclaimed[repo.Repository] = repo.BytesReclaimed
+	}
+	client := &http.Client{Timeout: notifyTimeout}
+	for _, team := range teams {
+		if len(team.NotifyWebhooks) == 0 {
+			continue
+		}
+		notification := teamNotification{Registry: urlHost(cfg.RegistryURL), Team: team.Name, Interrupted: s.Interrupted, Repositories: []teamRepository{}}
+		for _, result := range s.results {
+			if owner := tenants.Owner(result.Repository); owner != nil && owner.Name == team.Name {
+				notification.Repositories = append(notification.Repositories, teamRepository{
+					Repository: result.Repository, Deleted: result.Deleted, BytesReclaimed: reclaimed[result.Repository],
+				})
+			}
+		}
+		for _, failure := range failures {
+			if owner := tenants.Owner(failureRepository(failure)); owner != nil && owner.Name == team.Name {
+				notification.Errors = append(notification.Errors, failure.Error())
+			}
+		}
+		if len(notification.Repositories) == 0 && len(notification.Errors) == 0 {
+			continue
+		}
+		notification.Severity = SeverityInfo
+		if len(notification.Errors) > 0 || s.Interrupted {
+			notification.Severity = SeverityWarning
+		}
+		if severityRank[notification.Severity] < severityRank[cfg.NotifySeverity] {
+			continue
+		}
+
+		body, err := json.Marshal(notification)
+		if err != nil {
+			fmt.Printf("Предупреждение: не удалось подготовить уведомление команды %s: %v\n", team.Name, err)
+			continue
+		}
+		for _, webhook := range team.NotifyWebhooks {
+			if err := postNotification(client, webhook, body); err != nil {
+				fmt.Printf("Предупреждение: не удалось отправить уведомление команды %s: %v\n", team.Name, err)
+			}
+		}
+	}
+}
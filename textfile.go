@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// unsafeFileChars символы, которые заменяются в имени файла метрик
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// urlHost возвращает хост адреса без учетных данных и пути или адрес целиком, если он
+// не является URL. Используется как имя registry в метриках и уведомлениях
+func urlHost(registryURL string) string {
+	if u, err := url.Parse(registryURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return registryURL
+}
+
+// textfilePath возвращает путь файла метрик registry в каталоге dir. У каждого registry
+// свой файл, чтобы запуски для разных registry на одном хосте не перезаписывали друг друга
+func textfilePath(dir, registryURL string) string {
+	name := unsafeFileChars.ReplaceAllString(urlHost(registryURL), "_")
+	return filepath.Join(dir, "registry_cleaner_"+name+".prom")
+}
+
+// writeTextfile записывает итоги запуска в формате Prometheus для textfile collector
+// node_exporter. Файл записывается атомарно: node_exporter читает только файлы *.prom
+// и не увидит частично записанный
+func (s *cleanupStats) writeTextfile(dir, registryURL string) error {
+	labels := fmt.Sprintf(`registry="%s"`, escapeLabel(urlHost(registryURL)))
+	success := 0
+	if s.Failures == 0 && !s.Interrupted {
+		success = 1
+	}
+	interrupted := 0
+	if s.Interrupted {
+		interrupted = 1
+	}
+	alert := 0
+	if len(s.Alerts) > 0 {
+		alert = 1
+	}
+
+	var out bytes.Buffer
+	metric := func(name, help string, value interface{}) {
+		fmt.Fprintf(&out, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		fmt.Fprintf(&out, "%s{%s} %v\n", name, labels, value)
+	}
+	metric("registry_cleaner_last_run_timestamp_seconds", "Unix time of the end of the last cleanup run.", time.Now().Unix())
+	metric("registry_cleaner_last_run_duration_seconds", "Duration of the last cleanup run.", s.DurationSeconds)
+	metric("registry_cleaner_last_run_success", "1 if the last cleanup run finished without errors and was not interrupted.", success)
+	metric("registry_cleaner_last_run_interrupted", "1 if the last cleanup run was interrupted.", interrupted)
+
+	fmt.Fprintf(&out, "# HELP registry_cleaner_repositories Repositories in the last cleanup run by state.\n# TYPE registry_cleaner_repositories gauge\n")
+	for _, state := range []struct {
+		name  string
+		value int
+	}{{"selected", s.Repositories}, {"processed", s.Processed}, {"skipped", s.Skipped}, {"failed", s.Failed}} {
+		fmt.Fprintf(&out, "registry_cleaner_repositories{%s,state=\"%s\"} %d\n", labels, state.name, state.value)
+	}
+
+	metric("registry_cleaner_tags_examined", "Tags examined in the last cleanup run.", s.TagsExamined)
+	metric("registry_cleaner_manifests_deleted", "Manifests deleted in the last cleanup run.", s.Deleted)
+	metric("registry_cleaner_reclaimed_bytes_estimate", "Estimated bytes garbage collection frees after the last cleanup run.", s.BytesReclaimed)
+	metric("registry_cleaner_errors", "Errors in the last cleanup run.", s.Failures)
+	metric("registry_cleaner_delete_failures", "Images that could not be deleted in the last cleanup run.", s.DeleteFailed)
+	metric("registry_cleaner_last_run_alert", "1 if the last cleanup run exceeded the --alert-* thresholds.", alert)
+
+	path := textfilePath(dir, registryURL)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("ошибка записи метрик %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(out.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка записи метрик %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка записи метрик %s: %v", path, err)
+	}
+	// node_exporter работает от другого пользователя, а CreateTemp создает файл с правами 0600
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("ошибка записи метрик %s: %v", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("ошибка записи метрик %s: %v", path, err)
+	}
+	return nil
+}
+
+// escapeLabel экранирует значение метки в формате Prometheus
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"registryCleaner/pkg/registry"
+)
+
+// runVerifyCommand выполняет подкоманду verify: проверяет целостность всех тегов registry
+// и выводит отчет о тегах с нечитаемыми манифестами, отсутствующими blob и дочерними
+// манифестами. Ничего не удаляет; завершается с кодом 1, если нарушения найдены
+func runVerifyCommand(args []string) int {
+	cfg := parseConfig(args)
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: %v\n", err)
+		return 2
+	}
+	defer startLogSink(cfg)()
+	defer startProfiling(cfg)()
+	defer reportPanic(cfg)
+	if cfg.Backend != BackendRegistry {
+		fmt.Fprintf(os.Stderr, "Ошибка параметров: verify доступна только с --backend registry\n")
+		return 2
+	}
+
+	ctx := context.Background()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	client := newRegistryClient(cfg)
+	repositories, err := listRepositories(ctx, cfg, client)
+	if err != nil {
+		log.Printf("Ошибка при получении списка репозиториев: %v", err)
+		return 1
+	}
+	repositories = cleanupRepositories(cfg, repositories)
+
+	verifier := registry.NewVerifier(client)
+	concurrency := max(cfg.Concurrency, 1)
+	var problems []registry.Problem
+	var failures []error
+	tagsChecked := 0
+	for _, repo := range repositories {
+		fmt.Printf("Проверка репозитория: %s\n", repo)
+		tags, err := client.ListTags(ctx, repo)
+		if err != nil {
+			fmt.Printf("Ошибка при получении тегов %s: %v\n", repo, err)
+			failures = append(failures, fmt.Errorf("%s: %w", repo, err))
+			continue
+		}
+
+		results := make([][]registry.Problem, len(tags))
+		errs := make([]error, len(tags))
+		sem := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
+		for i, tag := range tags {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				results[i], errs[i] = verifier.VerifyTag(ctx, repo, tag)
+			}()
+		}
+		wg.Wait()
+
+		for i := range tags {
+			if errs[i] != nil {
+				log.Printf("Проверка прервана: %v", errs[i])
+				return 1
+			}
+			for _, problem := range results[i] {
+				fmt.Printf("  ❌ %v\n", problem)
+			}
+			problems = append(problems, results[i]...)
+		}
+		tagsChecked += len(tags)
+	}
+
+	printVerifyReport(len(repositories), tagsChecked, problems)
+	if len(failures) > 0 {
+		printFailures(failures)
+	}
+	if len(problems) > 0 || len(failures) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printVerifyReport выводит итоги проверки целостности по видам нарушений
+func printVerifyReport(repositories, tags int, problems []registry.Problem) {
+	fmt.Printf("\n🔍 Проверка целостности: репозиториев %d, тегов %d\n", repositories, tags)
+	if len(problems) == 0 {
+		fmt.Println("✅ Нарушений не найдено")
+		return
+	}
+
+	sections := []struct{ kind, title string }{
+		{registry.ProblemManifest, "Нечитаемые манифесты"},
+		{registry.ProblemBlob, "Отсутствующие blob"},
+		{registry.ProblemChild, "Отсутствующие дочерние манифесты"},
+	}
+	for _, section := range sections {
+		var found []registry.Problem
+		for _, problem := range problems {
+			if problem.Kind == section.kind {
+				found = append(found, problem)
+			}
+		}
+		if len(found) == 0 {
+			continue
+		}
+		fmt.Printf("\n%s: %d\n", section.title, len(found))
+		for _, problem := range found {
+			fmt.Printf("  - %v\n", problem)
+		}
+	}
+	fmt.Printf("\n⚠️  Найдено нарушений: %d. Исправьте их до очистки: удаление и garbage collection поврежденного registry могут привести к потере данных\n", len(problems))
+}
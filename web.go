@@ -0,0 +1,21 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// webFiles веб-интерфейс подкоманды serve, встроенный в программу
+//
+//go:embed web
+var webFiles embed.FS
+
+// webHandler отдает файлы веб-интерфейса
+func webHandler() http.Handler {
+	root, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServerFS(root)
+}